@@ -17,6 +17,7 @@ limitations under the License.
 package v1alpha1
 
 import (
+	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
@@ -48,6 +49,11 @@ const (
 	// annotation is present and status is empty, BMO will reconstruct BMH Status
 	// from the status annotation.
 	StatusAnnotation = "baremetalhost.metal3.io/status"
+
+	// UserDataTemplateAnnotation is the annotation of a user data Secret
+	// marking its content as a Go template, rendered for each host with
+	// its metadata and hardware details.
+	UserDataTemplateAnnotation = "userdata.metal3.io/template"
 )
 
 // RootDeviceHints holds the hints for specifying the storage location
@@ -158,6 +164,15 @@ const (
 	// DetachError is an error condition occurring when the
 	// controller is unable to detatch the host from the provisioner
 	DetachError ErrorType = "detach error"
+	// FirmwareUpdateError is an error condition occurring when the
+	// firmware of one of the host's components could not be updated.
+	FirmwareUpdateError ErrorType = "firmware update error"
+	// ServicingError is an error condition occurring when the
+	// controller fails to apply changes to a provisioned Host.
+	ServicingError ErrorType = "servicing error"
+	// UserDataError is an error condition occurring when the user data
+	// template of the Host cannot be rendered.
+	UserDataError ErrorType = "user data error"
 )
 
 // ProvisioningState defines the states the provisioner will report
@@ -196,6 +211,10 @@ const (
 	// disk(s)
 	StateProvisioned ProvisioningState = "provisioned"
 
+	// StateServicing means we are applying changes to the firmware of
+	// a provisioned host without removing the image from its disk(s)
+	StateServicing ProvisioningState = "servicing"
+
 	// StateExternallyProvisioned means something else is managing the
 	// image on the host
 	StateExternallyProvisioned ProvisioningState = "externally provisioned"
@@ -231,6 +250,46 @@ type BMCDetails struct {
 	// insecure because it allows a man-in-the-middle to intercept the
 	// connection.
 	DisableCertificateVerification bool `json:"disableCertificateVerification,omitempty"`
+
+	// CACertificateRef refers to the CA bundle used to verify the
+	// server certificate of the BMC, for BMCs with a certificate
+	// signed by a private CA. Only supported by the redfish,
+	// idrac-redfish, ilo5 and irmc drivers. Ignored when certificate
+	// verification is disabled.
+	// +optional
+	CACertificateRef *CACertificateReference `json:"caCertificateRef,omitempty"`
+}
+
+// CACertificateReferenceKind is the kind of resource holding a CA
+// bundle.
+type CACertificateReferenceKind string
+
+const (
+	// CACertificateInSecret means the CA bundle is in a Secret.
+	CACertificateInSecret CACertificateReferenceKind = "Secret"
+	// CACertificateInConfigMap means the CA bundle is in a ConfigMap.
+	CACertificateInConfigMap CACertificateReferenceKind = "ConfigMap"
+)
+
+// DefaultCACertificateKey is the key holding the CA bundle when none is
+// set in the reference.
+const DefaultCACertificateKey = "ca.crt"
+
+// CACertificateReference locates a PEM-encoded CA bundle in a Secret or
+// ConfigMap in the namespace of the host.
+type CACertificateReference struct {
+	// The kind of the resource holding the CA bundle.
+	// +kubebuilder:validation:Enum=Secret;ConfigMap
+	// +kubebuilder:default=Secret
+	// +optional
+	Kind CACertificateReferenceKind `json:"kind,omitempty"`
+
+	// The name of the resource holding the CA bundle.
+	Name string `json:"name"`
+
+	// The key of the CA bundle in the resource, "ca.crt" if not set.
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 // HardwareRAIDVolume defines the desired configuration of volume in hardware RAID
@@ -305,6 +364,39 @@ type FirmwareConfig struct {
 	// This supports following options: true, false.
 	// +kubebuilder:validation:Enum=true;false
 	SriovEnabled *bool `json:"sriovEnabled,omitempty"`
+
+	// Settings holds arbitrary BIOS settings to apply, keyed by the
+	// setting name as it appears in the settings registry reported
+	// by the BMC, e.g. "BootMode" or "WorkloadProfile".
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+// FirmwareComponent is the name of a component of the host whose
+// firmware can be updated.
+type FirmwareComponent string
+
+const (
+	// FirmwareComponentBIOS is the system BIOS or UEFI firmware.
+	FirmwareComponentBIOS FirmwareComponent = "bios"
+	// FirmwareComponentBMC is the baseboard management controller.
+	FirmwareComponentBMC FirmwareComponent = "bmc"
+	// FirmwareComponentNIC is a network interface card.
+	FirmwareComponentNIC FirmwareComponent = "nic"
+)
+
+// FirmwareUpdate describes a firmware image to flash onto one of the
+// components of the host.
+type FirmwareUpdate struct {
+	// The component the firmware image is for.
+	// +kubebuilder:validation:Enum=bios;bmc;nic
+	Component FirmwareComponent `json:"component"`
+
+	// URL of the firmware image.
+	URL string `json:"url"`
+
+	// Checksum of the firmware image, in the format expected by the
+	// BMC driver (SHA1 for redfish, MD5 for iLO).
+	Checksum string `json:"checksum"`
 }
 
 // BareMetalHostSpec defines the desired state of BareMetalHost
@@ -327,6 +419,10 @@ type BareMetalHostSpec struct {
 	// BIOS configuration for bare metal server
 	Firmware *FirmwareConfig `json:"firmware,omitempty"`
 
+	// Firmware images to flash onto the components of the host when
+	// it is next prepared.
+	FirmwareUpdates []FirmwareUpdate `json:"firmwareUpdates,omitempty"`
+
 	// What is the name of the hardware profile for this host? It
 	// should only be necessary to set this when inspection cannot
 	// automatically determine the profile.
@@ -349,6 +445,11 @@ type BareMetalHostSpec struct {
 	// Should the server be online?
 	Online bool `json:"online"`
 
+	// PowerPolicy powers off the host while it is available without
+	// a consumer, overriding Online.
+	// +optional
+	PowerPolicy *PowerPolicy `json:"powerPolicy,omitempty"`
+
 	// ConsumerRef can be used to store information about something
 	// that is using a host. When it is not empty, the host is
 	// considered "in use".
@@ -422,17 +523,40 @@ const (
 	SHA512 ChecksumType = "sha512"
 )
 
+// checksumTypesByLength holds the checksum algorithms by the length of
+// their hexadecimal checksums.
+var checksumTypesByLength = map[int]ChecksumType{
+	32:  MD5,
+	64:  SHA256,
+	128: SHA512,
+}
+
+// DetectChecksumType returns the algorithm of a hexadecimal checksum
+// from its length, defaulting to MD5.
+func DetectChecksumType(checksum string) ChecksumType {
+	if checksumType, ok := checksumTypesByLength[len(checksum)]; ok {
+		return checksumType
+	}
+	return MD5
+}
+
 // Image holds the details of an image either to provisioned or that
 // has been provisioned.
 type Image struct {
-	// URL is a location of an image to deploy.
+	// URL is a location of an image to deploy. It can also reference an
+	// image in an OCI registry, as oci://registry/repository:tag or
+	// oci://registry/repository@digest, in which case the checksum is
+	// taken from the registry.
 	URL string `json:"url"`
 
-	// Checksum is the checksum for the image.
+	// Checksum is the checksum for the image, or the http(s) URL of a
+	// file holding it, such as the output of sha256sum listing the
+	// checksums of several images by file name.
 	Checksum string `json:"checksum,omitempty"`
 
 	// ChecksumType is the checksum algorithm for the image.
-	// e.g md5, sha256, sha512
+	// e.g md5, sha256, sha512. When unset, it is detected from the length
+	// of the checksum, or of the checksum read from the checksum URL.
 	ChecksumType ChecksumType `json:"checksumType,omitempty"`
 
 	// DiskFormat contains the format of the image (raw, qcow2, ...).
@@ -442,6 +566,41 @@ type Image struct {
 	// are not required and if specified will be ignored.
 	// +kubebuilder:validation:Enum=raw;qcow2;vdi;vmdk;live-iso
 	DiskFormat *string `json:"format,omitempty"`
+
+	// PullSecretName is the name of a Secret of type
+	// kubernetes.io/dockerconfigjson, in the namespace of the host,
+	// holding the credentials of the OCI registry of the image.
+	// +optional
+	PullSecretName string `json:"pullSecretName,omitempty"`
+}
+
+// ImageValidation records the result of the validation of an image
+// before provisioning it.
+type ImageValidation struct {
+	// URL is the URL of the validated image.
+	URL string `json:"url"`
+
+	// Checksum is the checksum the image was validated with, read from
+	// the checksum URL if needed.
+	// +optional
+	Checksum string `json:"checksum,omitempty"`
+
+	// ChecksumURL is the URL the checksum was read from, if any.
+	// +optional
+	ChecksumURL string `json:"checksumURL,omitempty"`
+
+	// DiskFormat is the format the image was validated with.
+	// +optional
+	DiskFormat *string `json:"format,omitempty"`
+
+	// Error explains why the image is not valid. It is empty when the
+	// validation succeeded.
+	// +optional
+	Error string `json:"error,omitempty"`
+
+	// ValidatedAt is when the image was last validated.
+	// +optional
+	ValidatedAt *metav1.Time `json:"validatedAt,omitempty"`
 }
 
 // Custom deploy is a description of a customized deploy process.
@@ -498,6 +657,85 @@ type CPU struct {
 	ClockMegahertz ClockSpeed `json:"clockMegahertz,omitempty"`
 	Flags          []string   `json:"flags,omitempty"`
 	Count          int        `json:"count,omitempty"`
+
+	// The number of processor sockets in use
+	Sockets int `json:"sockets,omitempty"`
+
+	// The number of physical cores of each processor
+	CoresPerSocket int `json:"coresPerSocket,omitempty"`
+}
+
+// NUMANode describes the resources local to a NUMA node of the host.
+type NUMANode struct {
+	// The ID of the NUMA node
+	ID int `json:"id"`
+
+	// The number of logical CPUs of the node
+	CPUCount int `json:"cpuCount,omitempty"`
+
+	// The amount of memory of the node in Mebibytes
+	RAMMebibytes int `json:"ramMebibytes,omitempty"`
+
+	// The names of the network interfaces attached to the node
+	NICs []string `json:"nics,omitempty"`
+}
+
+// PCIDevice describes one PCI device on the host.
+type PCIDevice struct {
+	// The PCI address of the device, e.g. "0000:3b:00.0"
+	Address string `json:"address,omitempty"`
+
+	// The PCI vendor ID, e.g. "10de"
+	VendorID string `json:"vendorID"`
+
+	// The PCI device ID, e.g. "1eb8"
+	DeviceID string `json:"deviceID"`
+
+	// The PCI class code, e.g. "030200"
+	Class string `json:"class,omitempty"`
+
+	// The revision of the device
+	Revision string `json:"revision,omitempty"`
+}
+
+// GPU describes one GPU or compute accelerator on the host.
+type GPU struct {
+	// The PCI address of the device, e.g. "0000:3b:00.0"
+	Address string `json:"address,omitempty"`
+
+	// The name of the vendor of the device, if known
+	Vendor string `json:"vendor,omitempty"`
+
+	// The PCI vendor ID, e.g. "10de"
+	VendorID string `json:"vendorID"`
+
+	// The PCI device ID, e.g. "1eb8"
+	DeviceID string `json:"deviceID"`
+}
+
+// MemoryModule describes one memory module (DIMM) installed on the
+// host.
+type MemoryModule struct {
+	// The slot the module is installed in, e.g. "DIMM_A1"
+	Slot string `json:"slot,omitempty"`
+
+	// The size of the module in Bytes
+	SizeBytes Capacity `json:"sizeBytes,omitempty"`
+
+	// The speed of the module
+	SpeedMegahertz ClockSpeed `json:"speedMegahertz,omitempty"`
+
+	// The type of memory, e.g. "DDR4"
+	Type string `json:"type,omitempty"`
+
+	// The name of the vendor of the module
+	Vendor string `json:"vendor,omitempty"`
+
+	// The part number of the module
+	Model string `json:"model,omitempty"`
+
+	// The serial number of the module
+	SerialNumber string `json:"serialNumber,omitempty"`
 }
 
 // Storage describes one storage device (disk, SSD, etc.) on the host.
@@ -583,12 +821,55 @@ type NIC struct {
 
 	// Whether the NIC is PXE Bootable
 	PXE bool `json:"pxe,omitempty"`
+
+	// The switch port the NIC is connected to, as advertised by LLDP
+	LLDP *LLDP `json:"lldp,omitempty"`
+}
+
+// LLDP describes the switch port a NIC is connected to, as received
+// from the switch with the Link Layer Discovery Protocol.
+type LLDP struct {
+	// The chassis ID of the switch, usually its MAC address
+	SwitchID string `json:"switchID,omitempty"`
+
+	// The ID of the switch port, e.g. "Ethernet1/3"
+	PortID string `json:"portID,omitempty"`
+
+	// The system name of the switch
+	SwitchSystemName string `json:"switchSystemName,omitempty"`
+
+	// The description of the switch port
+	PortDescription string `json:"portDescription,omitempty"`
+
+	// The untagged VLAN ID of the switch port
+	PortVLANID VLANID `json:"portVlanId,omitempty"`
 }
 
 // Firmware describes the firmware on the host.
 type Firmware struct {
 	// The BIOS for this firmware
 	BIOS BIOS `json:"bios,omitempty"`
+
+	// The firmware versions of the individual components of the host,
+	// as reported after the host was last prepared.
+	Components []FirmwareComponentStatus `json:"components,omitempty"`
+}
+
+// FirmwareComponentStatus describes the firmware version installed on
+// a component of the host.
+type FirmwareComponentStatus struct {
+	// The name of the component, e.g. "bios" or "bmc"
+	Component string `json:"component"`
+
+	// The version of the firmware found when the host was first
+	// registered
+	InitialVersion string `json:"initialVersion,omitempty"`
+
+	// The version of the firmware currently installed
+	CurrentVersion string `json:"currentVersion,omitempty"`
+
+	// The version of the firmware last flashed onto the component
+	LastVersionFlashed string `json:"lastVersionFlashed,omitempty"`
 }
 
 // BIOS describes the BIOS version on the host.
@@ -613,6 +894,15 @@ type HardwareDetails struct {
 	Storage      []Storage            `json:"storage,omitempty"`
 	CPU          CPU                  `json:"cpu,omitempty"`
 	Hostname     string               `json:"hostname,omitempty"`
+
+	// The memory modules installed
+	Memory []MemoryModule `json:"memory,omitempty"`
+	// The NUMA topology of the CPUs, memory and NICs
+	NUMANodes []NUMANode `json:"numaNodes,omitempty"`
+	// All the PCI devices
+	PCIDevices []PCIDevice `json:"pciDevices,omitempty"`
+	// The PCI devices that are GPUs or compute accelerators
+	GPUs []GPU `json:"gpus,omitempty"`
 }
 
 // HardwareSystemVendor stores details about the whole hardware system.
@@ -687,6 +977,154 @@ type OperationHistory struct {
 	Deprovision OperationMetric `json:"deprovision,omitempty"`
 }
 
+// HostStatusConditionType is the type of a condition reported in the
+// status of a BareMetalHost.
+type HostStatusConditionType string
+
+const (
+	// ConditionRegistered indicates that the host is registered with
+	// the provisioner.
+	ConditionRegistered HostStatusConditionType = "Registered"
+
+	// ConditionCredentialsValid indicates that the BMC credentials
+	// have been validated as working.
+	ConditionCredentialsValid HostStatusConditionType = "CredentialsValid"
+
+	// ConditionInspected indicates that the hardware details of the
+	// host are known.
+	ConditionInspected HostStatusConditionType = "Inspected"
+
+	// ConditionPrepared indicates that the host has been configured
+	// with the RAID and firmware settings from the spec.
+	ConditionPrepared HostStatusConditionType = "Prepared"
+
+	// ConditionProvisioned indicates that an image has been written
+	// to the host, or that the host is externally provisioned.
+	ConditionProvisioned HostStatusConditionType = "Provisioned"
+
+	// ConditionPoweredOnMatchesSpec indicates that the power state of
+	// the host matches the Online field of the spec.
+	ConditionPoweredOnMatchesSpec HostStatusConditionType = "PoweredOnMatchesSpec"
+)
+
+// PowerWindow is a time of the day, in UTC. A window ending before it
+// starts spans midnight.
+type PowerWindow struct {
+	// The start of the window, as HH:MM.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	Start string `json:"start"`
+
+	// The end of the window, as HH:MM.
+	// +kubebuilder:validation:Pattern=`^([01][0-9]|2[0-3]):[0-5][0-9]$`
+	End string `json:"end"`
+}
+
+// PowerPolicy controls the power state of a host while it is available
+// and has no consumer. Once the host is claimed, its power state follows
+// Online again.
+type PowerPolicy struct {
+	// IdleTimeout is how long the host can stay available without a
+	// consumer before it is powered off. Idle hosts are not powered off
+	// when unset.
+	// +optional
+	IdleTimeout *metav1.Duration `json:"idleTimeout,omitempty"`
+
+	// PowerOnWindows are the times of the day during which idle hosts
+	// are kept powered on according to Online, e.g. to have them ready
+	// ahead of the claims expected during working hours. They take
+	// precedence over PowerOffWindows.
+	// +optional
+	PowerOnWindows []PowerWindow `json:"powerOnWindows,omitempty"`
+
+	// PowerOffWindows are the times of the day during which idle hosts
+	// are powered off, regardless of the IdleTimeout.
+	// +optional
+	PowerOffWindows []PowerWindow `json:"powerOffWindows,omitempty"`
+}
+
+// PowerPolicyStatus records the decisions of the power policy.
+type PowerPolicyStatus struct {
+	// IdleSince is when the host was last found available without a
+	// consumer.
+	// +optional
+	IdleSince *metav1.Time `json:"idleSince,omitempty"`
+
+	// PoweredOff is true when the policy keeps the host powered off.
+	PoweredOff bool `json:"poweredOff"`
+
+	// Reason explains the last decision.
+	Reason string `json:"reason,omitempty"`
+
+	// LastDecisionTime is when the decision last changed.
+	// +optional
+	LastDecisionTime *metav1.Time `json:"lastDecisionTime,omitempty"`
+}
+
+// HealthStatus is the health of a host or of one of its components,
+// as reported by the BMC.
+// +kubebuilder:validation:Enum=OK;Warning;Critical
+type HealthStatus string
+
+const (
+	// HealthOK means that the component is working normally.
+	HealthOK HealthStatus = "OK"
+
+	// HealthWarning means that the component needs attention.
+	HealthWarning HealthStatus = "Warning"
+
+	// HealthCritical means that the component has failed or is about
+	// to fail.
+	HealthCritical HealthStatus = "Critical"
+)
+
+// ComponentType is the kind of hardware component whose health is
+// reported.
+type ComponentType string
+
+const (
+	// ComponentFan is a fan.
+	ComponentFan ComponentType = "Fan"
+
+	// ComponentPowerSupply is a power supply unit.
+	ComponentPowerSupply ComponentType = "PowerSupply"
+
+	// ComponentTemperature is a temperature sensor.
+	ComponentTemperature ComponentType = "Temperature"
+
+	// ComponentDisk is a storage device.
+	ComponentDisk ComponentType = "Disk"
+
+	// ComponentMemory is the system memory.
+	ComponentMemory ComponentType = "Memory"
+
+	// ComponentProcessor is the set of processors.
+	ComponentProcessor ComponentType = "Processor"
+)
+
+// ComponentHealth is the health of a single hardware component.
+type ComponentHealth struct {
+	// The name of the component, as reported by the BMC.
+	Name string `json:"name"`
+
+	// The kind of component.
+	// +kubebuilder:validation:Enum=Fan;PowerSupply;Temperature;Disk;Memory;Processor
+	Type ComponentType `json:"type"`
+
+	// The health of the component.
+	Status HealthStatus `json:"status"`
+}
+
+// HostHealth holds the health of the hardware of the host, as last
+// collected from the BMC.
+type HostHealth struct {
+	// The overall health of the host.
+	Status HealthStatus `json:"status"`
+
+	// The health of each of the monitored components.
+	// +optional
+	Components []ComponentHealth `json:"components,omitempty"`
+}
+
 // BareMetalHostStatus defines the observed state of BareMetalHost
 type BareMetalHostStatus struct {
 	// Important: Run "make generate manifests" to regenerate code
@@ -698,7 +1136,7 @@ type BareMetalHostStatus struct {
 
 	// ErrorType indicates the type of failure encountered when the
 	// OperationalStatus is OperationalStatusError
-	// +kubebuilder:validation:Enum=provisioned registration error;registration error;inspection error;preparation error;provisioning error;power management error
+	// +kubebuilder:validation:Enum=provisioned registration error;registration error;inspection error;preparation error;provisioning error;power management error;firmware update error;servicing error;user data error
 	ErrorType ErrorType `json:"errorType,omitempty"`
 
 	// LastUpdated identifies when this status was last observed.
@@ -723,9 +1161,34 @@ type BareMetalHostStatus struct {
 	// the last error message reported by the provisioning subsystem
 	ErrorMessage string `json:"errorMessage"`
 
+	// the reason the current operation is delayed, while the
+	// operational status is delayed
+	// +optional
+	DelayReason string `json:"delayReason,omitempty"`
+
+	// the state the host is waiting to enter, while the operational
+	// status is delayed
+	// +optional
+	DelayedState ProvisioningState `json:"delayedState,omitempty"`
+
 	// indicator for whether or not the host is powered on
 	PoweredOn bool `json:"poweredOn"`
 
+	// PowerPolicy records the decisions of the power policy of the
+	// host.
+	// +optional
+	PowerPolicy *PowerPolicyStatus `json:"powerPolicy,omitempty"`
+
+	// Health holds the health of the hardware, when the provisioner
+	// is able to collect it.
+	// +optional
+	Health *HostHealth `json:"health,omitempty"`
+
+	// ImageValidation holds the result of the validation of the image
+	// before provisioning, when enabled.
+	// +optional
+	ImageValidation *ImageValidation `json:"imageValidation,omitempty"`
+
 	// OperationHistory holds information about operations performed
 	// on this host.
 	OperationHistory OperationHistory `json:"operationHistory,omitempty"`
@@ -733,6 +1196,20 @@ type BareMetalHostStatus struct {
 	// ErrorCount records how many times the host has encoutered an error since the last successful operation
 	// +kubebuilder:default:=0
 	ErrorCount int `json:"errorCount"`
+
+	// FirmwareSettings holds the actual values of the BIOS settings
+	// requested in the Settings field of the firmware configuration,
+	// as reported by the BMC when the host was last prepared, and
+	// refreshed periodically afterwards.
+	FirmwareSettings map[string]string `json:"firmwareSettings,omitempty"`
+
+	// Conditions describe the state of the host
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 // ProvisionStatus holds the state information for a single target.
@@ -743,6 +1220,10 @@ type ProvisionStatus struct {
 	// The machine's UUID from the underlying provisioning tool
 	ID string `json:"ID"`
 
+	// The name of the provisioning backend managing the host, when
+	// several are configured. Empty for the default backend.
+	Backend string `json:"backend,omitempty"`
+
 	// Image holds the details of the last image successfully
 	// provisioned to the host.
 	Image Image `json:"image,omitempty"`
@@ -759,6 +1240,9 @@ type ProvisionStatus struct {
 	// The Bios set by the user
 	Firmware *FirmwareConfig `json:"firmware,omitempty"`
 
+	// The firmware updates set by the user
+	FirmwareUpdates []FirmwareUpdate `json:"firmwareUpdates,omitempty"`
+
 	// Custom deploy procedure applied to the host.
 	CustomDeploy *CustomDeploy `json:"customDeploy,omitempty"`
 }
@@ -1008,7 +1492,14 @@ func (host *BareMetalHost) OperationMetricForState(operation ProvisioningState)
 	return
 }
 
-// GetChecksum method returns the checksum of an image
+// ChecksumIsURL returns whether the checksum of the image is the URL of
+// a file holding it.
+func (image *Image) ChecksumIsURL() bool {
+	return strings.HasPrefix(image.Checksum, "http://") || strings.HasPrefix(image.Checksum, "https://")
+}
+
+// GetChecksum method returns the checksum of an image. The checksum type
+// is empty for a checksum URL without an explicit type.
 func (image *Image) GetChecksum() (checksum, checksumType string, ok bool) {
 	if image == nil {
 		return
@@ -1027,7 +1518,11 @@ func (image *Image) GetChecksum() (checksum, checksumType string, ok bool) {
 
 	switch image.ChecksumType {
 	case "":
-		checksumType = string(MD5)
+		// The algorithm of a checksum URL is only known once the
+		// checksum is read, and is left empty
+		if !image.ChecksumIsURL() {
+			checksumType = string(DetectChecksumType(image.Checksum))
+		}
 	case MD5, SHA256, SHA512:
 		checksumType = string(image.ChecksumType)
 	default:
@@ -1,6 +1,7 @@
 package v1alpha1
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -451,6 +452,63 @@ func TestGetImageChecksum(t *testing.T) {
 	}
 }
 
+func TestGetImageChecksumType(t *testing.T) {
+	for _, tc := range []struct {
+		Scenario     string
+		Image        Image
+		ExpectedType string
+	}{
+		{
+			Scenario:     "md5 detected from the length",
+			Image:        Image{Checksum: strings.Repeat("a", 32)},
+			ExpectedType: "md5",
+		},
+		{
+			Scenario:     "sha256 detected from the length",
+			Image:        Image{Checksum: strings.Repeat("a", 64)},
+			ExpectedType: "sha256",
+		},
+		{
+			Scenario:     "sha512 detected from the length",
+			Image:        Image{Checksum: strings.Repeat("a", 128)},
+			ExpectedType: "sha512",
+		},
+		{
+			Scenario:     "unknown length",
+			Image:        Image{Checksum: "md5hash"},
+			ExpectedType: "md5",
+		},
+		{
+			Scenario:     "explicit type",
+			Image:        Image{Checksum: strings.Repeat("a", 64), ChecksumType: SHA512},
+			ExpectedType: "sha512",
+		},
+		{
+			Scenario:     "checksum URL",
+			Image:        Image{Checksum: "http://images.test/SHA256SUMS"},
+			ExpectedType: "",
+		},
+		{
+			Scenario:     "checksum URL with explicit type",
+			Image:        Image{Checksum: "https://images.test/SHA256SUMS", ChecksumType: SHA256},
+			ExpectedType: "sha256",
+		},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			checksum, checksumType, ok := tc.Image.GetChecksum()
+			if !ok {
+				t.Fatalf("expected a checksum")
+			}
+			if checksum != tc.Image.Checksum {
+				t.Errorf("expected %q but got %q", tc.Image.Checksum, checksum)
+			}
+			if checksumType != tc.ExpectedType {
+				t.Errorf("expected %q but got %q", tc.ExpectedType, checksumType)
+			}
+		})
+	}
+}
+
 func TestBootMode(t *testing.T) {
 	for _, tc := range []struct {
 		Scenario  string
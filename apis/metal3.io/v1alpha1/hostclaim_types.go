@@ -0,0 +1,193 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// HostClaimFinalizer is the name of the finalizer added to claims
+	// to release the bound host when the claim is deleted.
+	HostClaimFinalizer string = "hostclaim.metal3.io"
+)
+
+// DiskRequirement describes a storage device the host must have.
+type DiskRequirement struct {
+	// The type of the device, one of: HDD, SSD, NVME. Any type is
+	// accepted if not set.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=HDD;SSD;NVME;
+	Type DiskType `json:"type,omitempty"`
+
+	// The minimum size of the device in Gigabytes.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinSizeGigabytes int `json:"minSizeGigabytes,omitempty"`
+}
+
+// Matches returns true if the storage device fulfills the requirement.
+func (req *DiskRequirement) Matches(storage Storage) bool {
+	if req.Type != "" && req.Type != storage.Type {
+		return false
+	}
+	return storage.SizeBytes >= Capacity(req.MinSizeGigabytes)*GigaByte
+}
+
+// HardwareRequirements describes the hardware a host must have to be
+// bound to a claim. Unset fields are not checked.
+type HardwareRequirements struct {
+	// The minimum number of CPUs.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinCPUCount int `json:"minCPUCount,omitempty"`
+
+	// The minimum amount of RAM in Mebibytes.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinRAMMebibytes int `json:"minRAMMebibytes,omitempty"`
+
+	// A storage device the host must have.
+	// +optional
+	Disk *DiskRequirement `json:"disk,omitempty"`
+
+	// The minimum speed of at least one NIC in Gigabits per second.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	MinNICSpeedGbps int `json:"minNICSpeedGbps,omitempty"`
+}
+
+// Matches returns true if the hardware details fulfill all of the
+// requirements. Hosts without hardware details only match when there
+// are no requirements.
+func (req *HardwareRequirements) Matches(details *HardwareDetails) bool {
+	if details == nil {
+		return *req == HardwareRequirements{}
+	}
+
+	if details.CPU.Count < req.MinCPUCount {
+		return false
+	}
+	if details.RAMMebibytes < req.MinRAMMebibytes {
+		return false
+	}
+
+	if req.Disk != nil {
+		found := false
+		for _, storage := range details.Storage {
+			if req.Disk.Matches(storage) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if req.MinNICSpeedGbps > 0 {
+		found := false
+		for _, nic := range details.NIC {
+			if nic.SpeedGbps >= req.MinNICSpeedGbps {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HostClaimSpec defines the desired state of HostClaim
+type HostClaimSpec struct {
+	// HostSelector restricts the hosts that can be bound to the claim
+	// to those with matching labels.
+	// +optional
+	HostSelector *metav1.LabelSelector `json:"hostSelector,omitempty"`
+
+	// HardwareRequirements restricts the hosts that can be bound to
+	// the claim to those with matching hardware details.
+	// +optional
+	HardwareRequirements HardwareRequirements `json:"hardwareRequirements,omitempty"`
+
+	// Image holds the details of the image to be provisioned to the
+	// bound host.
+	// +optional
+	Image *Image `json:"image,omitempty"`
+
+	// UserData holds the reference to the Secret containing the user
+	// data to be passed to the bound host. The Secret must be in the
+	// same namespace as the claim.
+	// +optional
+	UserData *corev1.SecretReference `json:"userData,omitempty"`
+}
+
+type HostClaimConditionType string
+
+const (
+	// Bound indicates that a host has been bound to the claim.
+	ConditionHostClaimBound HostClaimConditionType = "Bound"
+)
+
+// HostClaimStatus defines the observed state of HostClaim
+type HostClaimStatus struct {
+	// HostName is the name of the BareMetalHost bound to the claim.
+	// +optional
+	HostName string `json:"hostName,omitempty"`
+
+	// conditions describe the state of the claim
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=hc
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Host",type="string",JSONPath=".status.hostName",description="Bound host"
+// +kubebuilder:printcolumn:name="Bound",type="string",JSONPath=".status.conditions[?(@.type==\"Bound\")].status",description="Whether a host is bound"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp",description="Time duration since creation of HostClaim"
+
+// HostClaim is the Schema for the hostclaims API. The operator binds
+// each claim to one matching available BareMetalHost in the same
+// namespace and sets the consumerRef, image and userData of the host
+// from the claim.
+type HostClaim struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostClaimSpec   `json:"spec,omitempty"`
+	Status HostClaimStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HostClaimList contains a list of HostClaim
+type HostClaimList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostClaim `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HostClaim{}, &HostClaimList{})
+}
@@ -0,0 +1,97 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHardwareRequirementsMatches(t *testing.T) {
+	details := &HardwareDetails{
+		CPU:          CPU{Count: 32},
+		RAMMebibytes: 131072,
+		Storage: []Storage{
+			{Name: "/dev/sda", Type: HDD, SizeBytes: 4 * TeraByte},
+			{Name: "/dev/nvme0n1", Type: NVME, SizeBytes: 500 * GigaByte},
+		},
+		NIC: []NIC{
+			{Name: "eth0", SpeedGbps: 1},
+			{Name: "eth1", SpeedGbps: 25},
+		},
+	}
+
+	testCases := []struct {
+		Scenario     string
+		Requirements HardwareRequirements
+		Details      *HardwareDetails
+		Expected     bool
+	}{
+		{
+			Scenario: "no requirements",
+			Details:  details,
+			Expected: true,
+		},
+		{
+			Scenario: "no requirements or details",
+			Expected: true,
+		},
+		{
+			Scenario:     "no details",
+			Requirements: HardwareRequirements{MinCPUCount: 1},
+			Expected:     false,
+		},
+		{
+			Scenario: "all requirements met",
+			Requirements: HardwareRequirements{
+				MinCPUCount:     32,
+				MinRAMMebibytes: 65536,
+				Disk:            &DiskRequirement{Type: NVME, MinSizeGigabytes: 500},
+				MinNICSpeedGbps: 25,
+			},
+			Details:  details,
+			Expected: true,
+		},
+		{
+			Scenario:     "not enough CPUs",
+			Requirements: HardwareRequirements{MinCPUCount: 64},
+			Details:      details,
+			Expected:     false,
+		},
+		{
+			Scenario:     "not enough RAM",
+			Requirements: HardwareRequirements{MinRAMMebibytes: 262144},
+			Details:      details,
+			Expected:     false,
+		},
+		{
+			Scenario:     "disk of any type",
+			Requirements: HardwareRequirements{Disk: &DiskRequirement{MinSizeGigabytes: 1000}},
+			Details:      details,
+			Expected:     true,
+		},
+		{
+			Scenario:     "disk of type too small",
+			Requirements: HardwareRequirements{Disk: &DiskRequirement{Type: NVME, MinSizeGigabytes: 1000}},
+			Details:      details,
+			Expected:     false,
+		},
+		{
+			Scenario:     "no disk of type",
+			Requirements: HardwareRequirements{Disk: &DiskRequirement{Type: SSD}},
+			Details:      details,
+			Expected:     false,
+		},
+		{
+			Scenario:     "NICs too slow",
+			Requirements: HardwareRequirements{MinNICSpeedGbps: 100},
+			Details:      details,
+			Expected:     false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			assert.Equal(t, tc.Expected, tc.Requirements.Matches(tc.Details))
+		})
+	}
+}
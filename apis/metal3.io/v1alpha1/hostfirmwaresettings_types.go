@@ -0,0 +1,208 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1alpha1
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// SettingSchema describes the values that a firmware setting can take,
+// as reported by the BMC.
+type SettingSchema struct {
+	// The type of the setting: Enumeration, Integer, String, Boolean
+	// or Password.
+	// +optional
+	AttributeType string `json:"attributeType,omitempty"`
+
+	// The values allowed for an Enumeration setting.
+	// +optional
+	AllowableValues []string `json:"allowableValues,omitempty"`
+
+	// The lowest value allowed for an Integer setting.
+	// +optional
+	LowerBound *int `json:"lowerBound,omitempty"`
+
+	// The highest value allowed for an Integer setting.
+	// +optional
+	UpperBound *int `json:"upperBound,omitempty"`
+
+	// The minimum length of a String setting.
+	// +optional
+	MinLength *int `json:"minLength,omitempty"`
+
+	// The maximum length of a String setting.
+	// +optional
+	MaxLength *int `json:"maxLength,omitempty"`
+
+	// Whether the setting cannot be changed.
+	// +optional
+	ReadOnly *bool `json:"readOnly,omitempty"`
+}
+
+// Validate checks that value is allowed for the setting with the given
+// name.
+func (schema *SettingSchema) Validate(name, value string) error {
+	if schema.ReadOnly != nil && *schema.ReadOnly {
+		return fmt.Errorf("setting %s is read-only", name)
+	}
+
+	switch schema.AttributeType {
+	case "Enumeration":
+		if len(schema.AllowableValues) == 0 {
+			return nil
+		}
+		for _, allowed := range schema.AllowableValues {
+			if value == allowed {
+				return nil
+			}
+		}
+		return fmt.Errorf("value %q of setting %s is not one of %s",
+			value, name, strings.Join(schema.AllowableValues, ", "))
+	case "Integer":
+		intValue, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("value %q of setting %s is not an integer", value, name)
+		}
+		if schema.LowerBound != nil && intValue < *schema.LowerBound {
+			return fmt.Errorf("value %d of setting %s is lower than %d", intValue, name, *schema.LowerBound)
+		}
+		if schema.UpperBound != nil && intValue > *schema.UpperBound {
+			return fmt.Errorf("value %d of setting %s is higher than %d", intValue, name, *schema.UpperBound)
+		}
+	case "String":
+		if schema.MinLength != nil && len(value) < *schema.MinLength {
+			return fmt.Errorf("value of setting %s is shorter than %d characters", name, *schema.MinLength)
+		}
+		if schema.MaxLength != nil && len(value) > *schema.MaxLength {
+			return fmt.Errorf("value of setting %s is longer than %d characters", name, *schema.MaxLength)
+		}
+	case "Boolean":
+		if _, err := strconv.ParseBool(value); err != nil {
+			return fmt.Errorf("value %q of setting %s is not a boolean", value, name)
+		}
+	}
+	return nil
+}
+
+// ValidateSettings checks the requested settings against the schema
+// reported by the BMC, keyed by setting name. If the BMC does not
+// report a schema there is nothing to check against, and the settings
+// are left for the BMC to validate when they are applied.
+func ValidateSettings(requested map[string]string, schema map[string]SettingSchema) error {
+	if len(schema) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(requested))
+	for name := range requested {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var errorMessages []string
+	for _, name := range names {
+		settingSchema, ok := schema[name]
+		if !ok {
+			errorMessages = append(errorMessages, fmt.Sprintf("unknown setting %s", name))
+			continue
+		}
+		if err := settingSchema.Validate(name, requested[name]); err != nil {
+			errorMessages = append(errorMessages, err.Error())
+		}
+	}
+
+	if len(errorMessages) != 0 {
+		return errors.New(strings.Join(errorMessages, "; "))
+	}
+	return nil
+}
+
+// HostFirmwareSettingsSpec defines the desired state of HostFirmwareSettings
+type HostFirmwareSettingsSpec struct {
+	// Settings are the firmware settings to apply the next time the
+	// host is prepared, keyed by setting name.
+	// +optional
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+type SettingsConditionType string
+
+const (
+	// Valid indicates that the settings in the spec are allowed by
+	// the schema reported by the BMC.
+	ConditionSettingsValid SettingsConditionType = "Valid"
+
+	// ChangeDetected indicates that the settings in the spec differ
+	// from the current settings of the host.
+	ConditionSettingsChangeDetected SettingsConditionType = "ChangeDetected"
+)
+
+// HostFirmwareSettingsStatus defines the observed state of HostFirmwareSettings
+type HostFirmwareSettingsStatus struct {
+	// Settings are the current firmware settings of the host.
+	// +optional
+	Settings map[string]string `json:"settings,omitempty"`
+
+	// Schema describes the values allowed for each setting, when the
+	// BMC reports it.
+	// +optional
+	Schema map[string]SettingSchema `json:"schema,omitempty"`
+
+	// Time that the settings were last read from the host.
+	// +optional
+	LastUpdated *metav1.Time `json:"lastUpdated,omitempty"`
+
+	// conditions describe the state of the firmware settings
+	// +patchMergeKey=type
+	// +patchStrategy=merge
+	// +listType=map
+	// +listMapKey=type
+	// +optional
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type" protobuf:"bytes,1,rep,name=conditions"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:resource:shortName=hfs
+// +kubebuilder:subresource:status
+
+// HostFirmwareSettings is the Schema for the hostfirmwaresettings API.
+// There is one for each BareMetalHost, with the same name, and it is
+// owned by the host.
+type HostFirmwareSettings struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   HostFirmwareSettingsSpec   `json:"spec,omitempty"`
+	Status HostFirmwareSettingsStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// HostFirmwareSettingsList contains a list of HostFirmwareSettings
+type HostFirmwareSettingsList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []HostFirmwareSettings `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&HostFirmwareSettings{}, &HostFirmwareSettingsList{})
+}
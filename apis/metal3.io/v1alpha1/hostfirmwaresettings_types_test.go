@@ -0,0 +1,123 @@
+package v1alpha1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSettingSchemaValidate(t *testing.T) {
+	lower, upper, maxLength := 1, 16, 8
+	readOnly := true
+
+	testCases := []struct {
+		Scenario      string
+		Schema        SettingSchema
+		Value         string
+		ExpectedError string
+	}{
+		{
+			Scenario: "no type",
+			Schema:   SettingSchema{},
+			Value:    "anything",
+		},
+		{
+			Scenario: "enumeration allowed",
+			Schema:   SettingSchema{AttributeType: "Enumeration", AllowableValues: []string{"Enabled", "Disabled"}},
+			Value:    "Disabled",
+		},
+		{
+			Scenario:      "enumeration not allowed",
+			Schema:        SettingSchema{AttributeType: "Enumeration", AllowableValues: []string{"Enabled", "Disabled"}},
+			Value:         "Maybe",
+			ExpectedError: "value \"Maybe\" of setting Test is not one of Enabled, Disabled",
+		},
+		{
+			Scenario: "integer in bounds",
+			Schema:   SettingSchema{AttributeType: "Integer", LowerBound: &lower, UpperBound: &upper},
+			Value:    "16",
+		},
+		{
+			Scenario:      "integer too low",
+			Schema:        SettingSchema{AttributeType: "Integer", LowerBound: &lower, UpperBound: &upper},
+			Value:         "0",
+			ExpectedError: "value 0 of setting Test is lower than 1",
+		},
+		{
+			Scenario:      "not an integer",
+			Schema:        SettingSchema{AttributeType: "Integer"},
+			Value:         "many",
+			ExpectedError: "value \"many\" of setting Test is not an integer",
+		},
+		{
+			Scenario:      "string too long",
+			Schema:        SettingSchema{AttributeType: "String", MaxLength: &maxLength},
+			Value:         "a-very-long-tag",
+			ExpectedError: "value of setting Test is longer than 8 characters",
+		},
+		{
+			Scenario:      "not a boolean",
+			Schema:        SettingSchema{AttributeType: "Boolean"},
+			Value:         "perhaps",
+			ExpectedError: "value \"perhaps\" of setting Test is not a boolean",
+		},
+		{
+			Scenario:      "read-only",
+			Schema:        SettingSchema{AttributeType: "String", ReadOnly: &readOnly},
+			Value:         "value",
+			ExpectedError: "setting Test is read-only",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			err := tc.Schema.Validate("Test", tc.Value)
+			if tc.ExpectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.ExpectedError)
+			}
+		})
+	}
+}
+
+func TestValidateSettings(t *testing.T) {
+	schema := map[string]SettingSchema{
+		"ProcVirtualization": {AttributeType: "Enumeration", AllowableValues: []string{"Enabled", "Disabled"}},
+		"SecureBoot":         {AttributeType: "Boolean"},
+	}
+
+	testCases := []struct {
+		Scenario      string
+		Schema        map[string]SettingSchema
+		Requested     map[string]string
+		ExpectedError string
+	}{
+		{
+			Scenario:  "no schema",
+			Requested: map[string]string{"Unknown": "value"},
+		},
+		{
+			Scenario:  "valid",
+			Schema:    schema,
+			Requested: map[string]string{"ProcVirtualization": "Disabled", "SecureBoot": "true"},
+		},
+		{
+			Scenario:      "all errors reported in order",
+			Schema:        schema,
+			Requested:     map[string]string{"Unknown": "value", "SecureBoot": "maybe"},
+			ExpectedError: "value \"maybe\" of setting SecureBoot is not a boolean; unknown setting Unknown",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			err := ValidateSettings(tc.Requested, tc.Schema)
+			if tc.ExpectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.ExpectedError)
+			}
+		})
+	}
+}
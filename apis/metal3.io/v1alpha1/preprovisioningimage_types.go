@@ -37,6 +37,11 @@ type PreprovisioningImageSpec struct {
 	// architecture is the processor architecture for which to build the image.
 	// +optional
 	Architecture string `json:"architecture,omitempty"`
+
+	// acceptFormats is a list of acceptable image formats, in order of
+	// preference. An ISO is built if it is not set.
+	// +optional
+	AcceptFormats []ImageFormat `json:"acceptFormats,omitempty"`
 }
 
 type SecretStatus struct {
@@ -44,6 +44,11 @@ func (in *BIOS) DeepCopy() *BIOS {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *BMCDetails) DeepCopyInto(out *BMCDetails) {
 	*out = *in
+	if in.CACertificateRef != nil {
+		in, out := &in.CACertificateRef, &out.CACertificateRef
+		*out = new(CACertificateReference)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BMCDetails.
@@ -125,7 +130,7 @@ func (in *BareMetalHostSpec) DeepCopyInto(out *BareMetalHostSpec) {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
-	out.BMC = in.BMC
+	in.BMC.DeepCopyInto(&out.BMC)
 	if in.RAID != nil {
 		in, out := &in.RAID, &out.RAID
 		*out = new(RAIDConfig)
@@ -136,11 +141,21 @@ func (in *BareMetalHostSpec) DeepCopyInto(out *BareMetalHostSpec) {
 		*out = new(FirmwareConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.FirmwareUpdates != nil {
+		in, out := &in.FirmwareUpdates, &out.FirmwareUpdates
+		*out = make([]FirmwareUpdate, len(*in))
+		copy(*out, *in)
+	}
 	if in.RootDeviceHints != nil {
 		in, out := &in.RootDeviceHints, &out.RootDeviceHints
 		*out = new(RootDeviceHints)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.PowerPolicy != nil {
+		in, out := &in.PowerPolicy, &out.PowerPolicy
+		*out = new(PowerPolicy)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.ConsumerRef != nil {
 		in, out := &in.ConsumerRef, &out.ConsumerRef
 		*out = new(v1.ObjectReference)
@@ -198,7 +213,36 @@ func (in *BareMetalHostStatus) DeepCopyInto(out *BareMetalHostStatus) {
 	in.Provisioning.DeepCopyInto(&out.Provisioning)
 	in.GoodCredentials.DeepCopyInto(&out.GoodCredentials)
 	in.TriedCredentials.DeepCopyInto(&out.TriedCredentials)
+	if in.PowerPolicy != nil {
+		in, out := &in.PowerPolicy, &out.PowerPolicy
+		*out = new(PowerPolicyStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Health != nil {
+		in, out := &in.Health, &out.Health
+		*out = new(HostHealth)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ImageValidation != nil {
+		in, out := &in.ImageValidation, &out.ImageValidation
+		*out = new(ImageValidation)
+		(*in).DeepCopyInto(*out)
+	}
 	in.OperationHistory.DeepCopyInto(&out.OperationHistory)
+	if in.FirmwareSettings != nil {
+		in, out := &in.FirmwareSettings, &out.FirmwareSettings
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BareMetalHostStatus.
@@ -211,6 +255,21 @@ func (in *BareMetalHostStatus) DeepCopy() *BareMetalHostStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CACertificateReference) DeepCopyInto(out *CACertificateReference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CACertificateReference.
+func (in *CACertificateReference) DeepCopy() *CACertificateReference {
+	if in == nil {
+		return nil
+	}
+	out := new(CACertificateReference)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CPU) DeepCopyInto(out *CPU) {
 	*out = *in
@@ -231,6 +290,21 @@ func (in *CPU) DeepCopy() *CPU {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ComponentHealth) DeepCopyInto(out *ComponentHealth) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ComponentHealth.
+func (in *ComponentHealth) DeepCopy() *ComponentHealth {
+	if in == nil {
+		return nil
+	}
+	out := new(ComponentHealth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CredentialsStatus) DeepCopyInto(out *CredentialsStatus) {
 	*out = *in
@@ -266,10 +340,30 @@ func (in *CustomDeploy) DeepCopy() *CustomDeploy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DiskRequirement) DeepCopyInto(out *DiskRequirement) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DiskRequirement.
+func (in *DiskRequirement) DeepCopy() *DiskRequirement {
+	if in == nil {
+		return nil
+	}
+	out := new(DiskRequirement)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Firmware) DeepCopyInto(out *Firmware) {
 	*out = *in
 	out.BIOS = in.BIOS
+	if in.Components != nil {
+		in, out := &in.Components, &out.Components
+		*out = make([]FirmwareComponentStatus, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Firmware.
@@ -282,6 +376,21 @@ func (in *Firmware) DeepCopy() *Firmware {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirmwareComponentStatus) DeepCopyInto(out *FirmwareComponentStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirmwareComponentStatus.
+func (in *FirmwareComponentStatus) DeepCopy() *FirmwareComponentStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(FirmwareComponentStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *FirmwareConfig) DeepCopyInto(out *FirmwareConfig) {
 	*out = *in
@@ -300,6 +409,13 @@ func (in *FirmwareConfig) DeepCopyInto(out *FirmwareConfig) {
 		*out = new(bool)
 		**out = **in
 	}
+	if in.Settings != nil {
+		in, out := &in.Settings, &out.Settings
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirmwareConfig.
@@ -312,11 +428,41 @@ func (in *FirmwareConfig) DeepCopy() *FirmwareConfig {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *FirmwareUpdate) DeepCopyInto(out *FirmwareUpdate) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new FirmwareUpdate.
+func (in *FirmwareUpdate) DeepCopy() *FirmwareUpdate {
+	if in == nil {
+		return nil
+	}
+	out := new(FirmwareUpdate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *GPU) DeepCopyInto(out *GPU) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new GPU.
+func (in *GPU) DeepCopy() *GPU {
+	if in == nil {
+		return nil
+	}
+	out := new(GPU)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HardwareDetails) DeepCopyInto(out *HardwareDetails) {
 	*out = *in
 	out.SystemVendor = in.SystemVendor
-	out.Firmware = in.Firmware
+	in.Firmware.DeepCopyInto(&out.Firmware)
 	if in.NIC != nil {
 		in, out := &in.NIC, &out.NIC
 		*out = make([]NIC, len(*in))
@@ -330,6 +476,28 @@ func (in *HardwareDetails) DeepCopyInto(out *HardwareDetails) {
 		copy(*out, *in)
 	}
 	in.CPU.DeepCopyInto(&out.CPU)
+	if in.Memory != nil {
+		in, out := &in.Memory, &out.Memory
+		*out = make([]MemoryModule, len(*in))
+		copy(*out, *in)
+	}
+	if in.NUMANodes != nil {
+		in, out := &in.NUMANodes, &out.NUMANodes
+		*out = make([]NUMANode, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.PCIDevices != nil {
+		in, out := &in.PCIDevices, &out.PCIDevices
+		*out = make([]PCIDevice, len(*in))
+		copy(*out, *in)
+	}
+	if in.GPUs != nil {
+		in, out := &in.GPUs, &out.GPUs
+		*out = make([]GPU, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareDetails.
@@ -372,6 +540,26 @@ func (in *HardwareRAIDVolume) DeepCopy() *HardwareRAIDVolume {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HardwareRequirements) DeepCopyInto(out *HardwareRequirements) {
+	*out = *in
+	if in.Disk != nil {
+		in, out := &in.Disk, &out.Disk
+		*out = new(DiskRequirement)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HardwareRequirements.
+func (in *HardwareRequirements) DeepCopy() *HardwareRequirements {
+	if in == nil {
+		return nil
+	}
+	out := new(HardwareRequirements)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *HardwareSystemVendor) DeepCopyInto(out *HardwareSystemVendor) {
 	*out = *in
@@ -387,6 +575,259 @@ func (in *HardwareSystemVendor) DeepCopy() *HardwareSystemVendor {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostClaim) DeepCopyInto(out *HostClaim) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostClaim.
+func (in *HostClaim) DeepCopy() *HostClaim {
+	if in == nil {
+		return nil
+	}
+	out := new(HostClaim)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostClaim) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostClaimList) DeepCopyInto(out *HostClaimList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HostClaim, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostClaimList.
+func (in *HostClaimList) DeepCopy() *HostClaimList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostClaimList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostClaimList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostClaimSpec) DeepCopyInto(out *HostClaimSpec) {
+	*out = *in
+	if in.HostSelector != nil {
+		in, out := &in.HostSelector, &out.HostSelector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	in.HardwareRequirements.DeepCopyInto(&out.HardwareRequirements)
+	if in.Image != nil {
+		in, out := &in.Image, &out.Image
+		*out = new(Image)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.UserData != nil {
+		in, out := &in.UserData, &out.UserData
+		*out = new(v1.SecretReference)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostClaimSpec.
+func (in *HostClaimSpec) DeepCopy() *HostClaimSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostClaimSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostClaimStatus) DeepCopyInto(out *HostClaimStatus) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostClaimStatus.
+func (in *HostClaimStatus) DeepCopy() *HostClaimStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostClaimStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostFirmwareSettings) DeepCopyInto(out *HostFirmwareSettings) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostFirmwareSettings.
+func (in *HostFirmwareSettings) DeepCopy() *HostFirmwareSettings {
+	if in == nil {
+		return nil
+	}
+	out := new(HostFirmwareSettings)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostFirmwareSettings) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostFirmwareSettingsList) DeepCopyInto(out *HostFirmwareSettingsList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]HostFirmwareSettings, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostFirmwareSettingsList.
+func (in *HostFirmwareSettingsList) DeepCopy() *HostFirmwareSettingsList {
+	if in == nil {
+		return nil
+	}
+	out := new(HostFirmwareSettingsList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *HostFirmwareSettingsList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostFirmwareSettingsSpec) DeepCopyInto(out *HostFirmwareSettingsSpec) {
+	*out = *in
+	if in.Settings != nil {
+		in, out := &in.Settings, &out.Settings
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostFirmwareSettingsSpec.
+func (in *HostFirmwareSettingsSpec) DeepCopy() *HostFirmwareSettingsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(HostFirmwareSettingsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostFirmwareSettingsStatus) DeepCopyInto(out *HostFirmwareSettingsStatus) {
+	*out = *in
+	if in.Settings != nil {
+		in, out := &in.Settings, &out.Settings
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.Schema != nil {
+		in, out := &in.Schema, &out.Schema
+		*out = make(map[string]SettingSchema, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.LastUpdated != nil {
+		in, out := &in.LastUpdated, &out.LastUpdated
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostFirmwareSettingsStatus.
+func (in *HostFirmwareSettingsStatus) DeepCopy() *HostFirmwareSettingsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(HostFirmwareSettingsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HostHealth) DeepCopyInto(out *HostHealth) {
+	*out = *in
+	if in.Components != nil {
+		in, out := &in.Components, &out.Components
+		*out = make([]ComponentHealth, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HostHealth.
+func (in *HostHealth) DeepCopy() *HostHealth {
+	if in == nil {
+		return nil
+	}
+	out := new(HostHealth)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Image) DeepCopyInto(out *Image) {
 	*out = *in
@@ -407,6 +848,60 @@ func (in *Image) DeepCopy() *Image {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ImageValidation) DeepCopyInto(out *ImageValidation) {
+	*out = *in
+	if in.DiskFormat != nil {
+		in, out := &in.DiskFormat, &out.DiskFormat
+		*out = new(string)
+		**out = **in
+	}
+	if in.ValidatedAt != nil {
+		in, out := &in.ValidatedAt, &out.ValidatedAt
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ImageValidation.
+func (in *ImageValidation) DeepCopy() *ImageValidation {
+	if in == nil {
+		return nil
+	}
+	out := new(ImageValidation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LLDP) DeepCopyInto(out *LLDP) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LLDP.
+func (in *LLDP) DeepCopy() *LLDP {
+	if in == nil {
+		return nil
+	}
+	out := new(LLDP)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MemoryModule) DeepCopyInto(out *MemoryModule) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MemoryModule.
+func (in *MemoryModule) DeepCopy() *MemoryModule {
+	if in == nil {
+		return nil
+	}
+	out := new(MemoryModule)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NIC) DeepCopyInto(out *NIC) {
 	*out = *in
@@ -415,6 +910,11 @@ func (in *NIC) DeepCopyInto(out *NIC) {
 		*out = make([]VLAN, len(*in))
 		copy(*out, *in)
 	}
+	if in.LLDP != nil {
+		in, out := &in.LLDP, &out.LLDP
+		*out = new(LLDP)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NIC.
@@ -427,6 +927,26 @@ func (in *NIC) DeepCopy() *NIC {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NUMANode) DeepCopyInto(out *NUMANode) {
+	*out = *in
+	if in.NICs != nil {
+		in, out := &in.NICs, &out.NICs
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NUMANode.
+func (in *NUMANode) DeepCopy() *NUMANode {
+	if in == nil {
+		return nil
+	}
+	out := new(NUMANode)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *OperationHistory) DeepCopyInto(out *OperationHistory) {
 	*out = *in
@@ -463,12 +983,95 @@ func (in *OperationMetric) DeepCopy() *OperationMetric {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PCIDevice) DeepCopyInto(out *PCIDevice) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PCIDevice.
+func (in *PCIDevice) DeepCopy() *PCIDevice {
+	if in == nil {
+		return nil
+	}
+	out := new(PCIDevice)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerPolicy) DeepCopyInto(out *PowerPolicy) {
+	*out = *in
+	if in.IdleTimeout != nil {
+		in, out := &in.IdleTimeout, &out.IdleTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.PowerOnWindows != nil {
+		in, out := &in.PowerOnWindows, &out.PowerOnWindows
+		*out = make([]PowerWindow, len(*in))
+		copy(*out, *in)
+	}
+	if in.PowerOffWindows != nil {
+		in, out := &in.PowerOffWindows, &out.PowerOffWindows
+		*out = make([]PowerWindow, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerPolicy.
+func (in *PowerPolicy) DeepCopy() *PowerPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerPolicyStatus) DeepCopyInto(out *PowerPolicyStatus) {
+	*out = *in
+	if in.IdleSince != nil {
+		in, out := &in.IdleSince, &out.IdleSince
+		*out = (*in).DeepCopy()
+	}
+	if in.LastDecisionTime != nil {
+		in, out := &in.LastDecisionTime, &out.LastDecisionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerPolicyStatus.
+func (in *PowerPolicyStatus) DeepCopy() *PowerPolicyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerPolicyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PowerWindow) DeepCopyInto(out *PowerWindow) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PowerWindow.
+func (in *PowerWindow) DeepCopy() *PowerWindow {
+	if in == nil {
+		return nil
+	}
+	out := new(PowerWindow)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PreprovisioningImage) DeepCopyInto(out *PreprovisioningImage) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	in.Status.DeepCopyInto(&out.Status)
 }
 
@@ -525,6 +1128,11 @@ func (in *PreprovisioningImageList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PreprovisioningImageSpec) DeepCopyInto(out *PreprovisioningImageSpec) {
 	*out = *in
+	if in.AcceptFormats != nil {
+		in, out := &in.AcceptFormats, &out.AcceptFormats
+		*out = make([]ImageFormat, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PreprovisioningImageSpec.
@@ -579,6 +1187,11 @@ func (in *ProvisionStatus) DeepCopyInto(out *ProvisionStatus) {
 		*out = new(FirmwareConfig)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.FirmwareUpdates != nil {
+		in, out := &in.FirmwareUpdates, &out.FirmwareUpdates
+		*out = make([]FirmwareUpdate, len(*in))
+		copy(*out, *in)
+	}
 	if in.CustomDeploy != nil {
 		in, out := &in.CustomDeploy, &out.CustomDeploy
 		*out = new(CustomDeploy)
@@ -675,6 +1288,51 @@ func (in *SecretStatus) DeepCopy() *SecretStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SettingSchema) DeepCopyInto(out *SettingSchema) {
+	*out = *in
+	if in.AllowableValues != nil {
+		in, out := &in.AllowableValues, &out.AllowableValues
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LowerBound != nil {
+		in, out := &in.LowerBound, &out.LowerBound
+		*out = new(int)
+		**out = **in
+	}
+	if in.UpperBound != nil {
+		in, out := &in.UpperBound, &out.UpperBound
+		*out = new(int)
+		**out = **in
+	}
+	if in.MinLength != nil {
+		in, out := &in.MinLength, &out.MinLength
+		*out = new(int)
+		**out = **in
+	}
+	if in.MaxLength != nil {
+		in, out := &in.MaxLength, &out.MaxLength
+		*out = new(int)
+		**out = **in
+	}
+	if in.ReadOnly != nil {
+		in, out := &in.ReadOnly, &out.ReadOnly
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SettingSchema.
+func (in *SettingSchema) DeepCopy() *SettingSchema {
+	if in == nil {
+		return nil
+	}
+	out := new(SettingSchema)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SoftwareRAIDVolume) DeepCopyInto(out *SoftwareRAIDVolume) {
 	*out = *in
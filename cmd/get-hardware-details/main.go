@@ -41,7 +41,7 @@ func main() {
 	}
 
 	introData := introspection.GetIntrospectionData(inspector, opts.NodeID)
-	data, err := introData.Extract()
+	data, err := hardwaredetails.ExtractData(introData)
 	if err != nil {
 		fmt.Printf("could not get introspection data: %s", err)
 		os.Exit(1)
@@ -0,0 +1,64 @@
+// redfish-emulator runs a Redfish service emulating the BMC of one or
+// more hosts, for local development without real hardware.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/metal3-io/baremetal-operator/pkg/bmc/emulator"
+)
+
+func main() {
+	var addr, username, password, certFile, keyFile string
+	var systems int
+	var poweredOn bool
+
+	flag.StringVar(&addr, "addr", ":8000", "address to listen on")
+	flag.StringVar(&username, "username", "admin", "the BMC username")
+	flag.StringVar(&password, "password", "password", "the BMC password")
+	flag.IntVar(&systems, "systems", 1, "the number of systems to emulate")
+	flag.BoolVar(&poweredOn, "powered-on", false, "start with the systems powered on")
+	flag.StringVar(&certFile, "tls-cert", "", "the TLS certificate file, to serve HTTPS")
+	flag.StringVar(&keyFile, "tls-key", "", "the TLS key file, to serve HTTPS")
+	flag.Parse()
+
+	if systems < 1 || systems > 200 {
+		fmt.Fprintf(os.Stderr, "the number of systems must be between 1 and 200\n")
+		os.Exit(1)
+	}
+	if (certFile == "") != (keyFile == "") {
+		fmt.Fprintf(os.Stderr, "-tls-cert and -tls-key must be used together\n")
+		os.Exit(1)
+	}
+
+	e := emulator.New(username, password)
+	for i := 1; i <= systems; i++ {
+		system := emulator.DefaultSystem(fmt.Sprint(i))
+		system.NICs[0].MACAddress = fmt.Sprintf("52:54:00:AA:%02X:01", i)
+		system.NICs[1].MACAddress = fmt.Sprintf("52:54:00:AA:%02X:02", i)
+		system.NICs[0].IPv4Addresses = []string{fmt.Sprintf("192.168.111.%d", 19+i)}
+		if poweredOn {
+			system.PowerState = "On"
+		}
+		e.AddSystem(system)
+	}
+
+	fmt.Printf("listening on %s\n", addr)
+	for i := 1; i <= systems; i++ {
+		fmt.Printf("emulating system %s\n", emulator.SystemPath(fmt.Sprint(i)))
+	}
+
+	var err error
+	if certFile != "" {
+		err = http.ListenAndServeTLS(addr, certFile, keyFile, e)
+	} else {
+		err = http.ListenAndServe(addr, e)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
@@ -116,6 +116,16 @@ func (r actionError) NeedsRegistration() bool {
 	return errors.Is(r.err, provisioner.ErrNeedsRegistration)
 }
 
+// actionErrorUpdate is the same as an actionError, but the Status data
+// changed and is written before retrying.
+type actionErrorUpdate struct {
+	actionError
+}
+
+func (r actionErrorUpdate) Dirty() bool {
+	return true
+}
+
 // actionFailed is a result indicating that the current action has failed,
 // and that the resource should be marked as in error.
 type actionFailed struct {
@@ -155,3 +165,18 @@ func (r actionFailed) Result() (result reconcile.Result, err error) {
 func (r actionFailed) Dirty() bool {
 	return r.dirty
 }
+
+// withStatusUpdate returns a result that writes the Status data, for
+// when it changed while the action itself had nothing new to save.
+func withStatusUpdate(r actionResult) actionResult {
+	switch res := r.(type) {
+	case actionContinue:
+		return actionUpdate{res}
+	case actionError:
+		return actionErrorUpdate{res}
+	case actionFailed:
+		res.dirty = true
+		return res
+	}
+	return r
+}
@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"math"
 	"testing"
 	"time"
@@ -28,3 +29,33 @@ func TestMaxBackoffDuration(t *testing.T) {
 	assert.LessOrEqual(t, calculateBackoff(maxBackOffCount+1).Milliseconds(), maxBackOffDuration)
 	assert.LessOrEqual(t, calculateBackoff(maxBackOffCount+100).Milliseconds(), maxBackOffDuration)
 }
+
+func TestWithStatusUpdate(t *testing.T) {
+	testCases := []struct {
+		Scenario string
+		Result   actionResult
+	}{
+		{Scenario: "continue", Result: actionContinue{time.Minute}},
+		{Scenario: "update", Result: actionUpdate{}},
+		{Scenario: "complete", Result: actionComplete{}},
+		{Scenario: "failed", Result: actionFailed{}},
+		{Scenario: "error", Result: actionError{errors.New("boom")}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			expected, expectedErr := tc.Result.Result()
+			res := withStatusUpdate(tc.Result)
+			assert.True(t, res.Dirty())
+
+			result, err := res.Result()
+			assert.Equal(t, expectedErr, err)
+			if _, failed := tc.Result.(actionFailed); !failed {
+				// The backoff of failures is random
+				assert.Equal(t, expected, result)
+			}
+		})
+	}
+
+	assert.False(t, withStatusUpdate(deleteComplete{}).Dirty(), "deleted host status saved")
+}
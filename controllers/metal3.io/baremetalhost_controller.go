@@ -24,6 +24,7 @@ import (
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-logr/logr"
@@ -33,17 +34,25 @@ import (
 	corev1 "k8s.io/api/core/v1"
 	apiequality "k8s.io/apimachinery/pkg/api/equality"
 	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/metal3-io/baremetal-operator/pkg/bmc"
 	"github.com/metal3-io/baremetal-operator/pkg/hardware"
+	"github.com/metal3-io/baremetal-operator/pkg/imagevalidation"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
 	"github.com/metal3-io/baremetal-operator/pkg/secretutils"
 	"github.com/metal3-io/baremetal-operator/pkg/utils"
@@ -54,8 +63,11 @@ const (
 	unmanagedRetryDelay           = time.Minute * 10
 	preprovImageRetryDelay        = time.Minute * 5
 	provisionerNotReadyRetryDelay = time.Second * 30
+	defaultPowerPollInterval      = time.Second * 60
+	defaultHealthPollInterval     = time.Minute * 5
 	rebootAnnotationPrefix        = "reboot.metal3.io"
 	inspectAnnotationPrefix       = "inspect.metal3.io"
+	servicingAnnotation           = "servicing.metal3.io"
 	hardwareDetailsAnnotation     = inspectAnnotationPrefix + "/hardwaredetails"
 )
 
@@ -65,6 +77,21 @@ type BareMetalHostReconciler struct {
 	Log                logr.Logger
 	ProvisionerFactory provisioner.Factory
 	APIReader          client.Reader
+
+	// How often the power state of a host in a steady state is
+	// checked, 60 seconds if not set
+	PowerPollInterval time.Duration
+	// How often the health of the hardware of a host is collected, 5
+	// minutes if not set
+	HealthPollInterval time.Duration
+	// Reports the hosts whose power state changed, so that they are
+	// reconciled before the next poll
+	PowerStateWatcher provisioner.PowerStateWatcher
+	// Validates the image of a host before provisioning it, if set
+	ImageValidator *imagevalidation.Validator
+
+	healthSchedule           pollSchedule
+	firmwareSettingsSchedule pollSchedule
 }
 
 // Instead of passing a zillion arguments to the action of a phase,
@@ -77,6 +104,8 @@ type reconcileInfo struct {
 	events            []corev1.Event
 	errorMessage      string
 	postSaveCallbacks []func()
+	// firmware settings requested through the HostFirmwareSettings
+	firmwareSettings map[string]string
 }
 
 // match the provisioner.EventPublisher interface
@@ -87,7 +116,9 @@ func (info *reconcileInfo) publishEvent(reason, message string) {
 // +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=metal3.io,resources=baremetalhosts/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups=metal3.io,resources=preprovisioningimages,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=metal3.io,resources=hostfirmwaresettings,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update
+// +kubebuilder:rbac:groups="",resources=configmaps,verbs=get;list;watch
 // +kubebuilder:rbac:groups="",resources=events,verbs=get;list;watch;create;update;patch
 
 // Reconcile handles changes to BareMetalHost resources
@@ -214,12 +245,18 @@ func (r *BareMetalHostReconciler) Reconcile(ctx context.Context, request ctrl.Re
 		}
 	}
 
+	firmwareSettings, err := r.getHostFirmwareSettings(ctx, host)
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "could not load host firmware settings")
+	}
+
 	initialState := host.Status.Provisioning.State
 	info := &reconcileInfo{
-		log:            reqLogger.WithValues("provisioningState", initialState),
-		host:           host,
-		request:        request,
-		bmcCredsSecret: bmcCredsSecret,
+		log:              reqLogger.WithValues("provisioningState", initialState),
+		host:             host,
+		request:          request,
+		bmcCredsSecret:   bmcCredsSecret,
+		firmwareSettings: firmwareSettings,
 	}
 
 	prov, err := r.ProvisionerFactory.NewProvisioner(provisioner.BuildHostData(*host, *bmcCreds), info.publishEvent)
@@ -242,6 +279,12 @@ func (r *BareMetalHostReconciler) Reconcile(ctx context.Context, request ctrl.Re
 
 	if err != nil {
 		err = errors.Wrap(err, fmt.Sprintf("action %q failed", initialState))
+		if actResult.Dirty() {
+			// Keep the conditions in line with the error
+			if saveErr := r.saveHostStatus(host); saveErr != nil {
+				info.log.Info("failed to save host status", "error", saveErr.Error())
+			}
+		}
 		return
 	}
 
@@ -275,6 +318,20 @@ func (r *BareMetalHostReconciler) Reconcile(ctx context.Context, request ctrl.Re
 	return
 }
 
+// getHostFirmwareSettings returns the firmware settings requested in
+// the HostFirmwareSettings of the host, if there is one.
+func (r *BareMetalHostReconciler) getHostFirmwareSettings(ctx context.Context, host *metal3v1alpha1.BareMetalHost) (map[string]string, error) {
+	hfs := &metal3v1alpha1.HostFirmwareSettings{}
+	err := r.Get(ctx, client.ObjectKeyFromObject(host), hfs)
+	if err != nil {
+		if k8serrors.IsNotFound(err) || meta.IsNoMatchError(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return hfs.Spec.Settings, nil
+}
+
 // Consume inspect.metal3.io/hardwaredetails when either
 // inspect.metal3.io=disabled or there are no existing HardwareDetails
 func (r *BareMetalHostReconciler) updateHardwareDetails(request ctrl.Request, host *metal3v1alpha1.BareMetalHost) (bool, error) {
@@ -335,6 +392,9 @@ func recordActionFailure(info *reconcileInfo, errorType metal3v1alpha1.ErrorType
 		metal3v1alpha1.InspectionError:              "InspectionError",
 		metal3v1alpha1.ProvisioningError:            "ProvisioningError",
 		metal3v1alpha1.PowerManagementError:         "PowerManagementError",
+		metal3v1alpha1.FirmwareUpdateError:          "FirmwareUpdateError",
+		metal3v1alpha1.ServicingError:               "ServicingError",
+		metal3v1alpha1.UserDataError:                "UserDataError",
 	}[errorType]
 
 	counter := actionFailureCounters.WithLabelValues(eventType)
@@ -345,7 +405,7 @@ func recordActionFailure(info *reconcileInfo, errorType metal3v1alpha1.ErrorType
 	return actionFailed{dirty: true, ErrorType: errorType, errorCount: info.host.Status.ErrorCount}
 }
 
-func recordActionDelayed(info *reconcileInfo, state metal3v1alpha1.ProvisioningState) actionResult {
+func recordActionDelayed(info *reconcileInfo, state metal3v1alpha1.ProvisioningState, reason string) actionResult {
 	var counter prometheus.Counter
 
 	switch state {
@@ -355,9 +415,16 @@ func recordActionDelayed(info *reconcileInfo, state metal3v1alpha1.ProvisioningS
 		counter = delayedProvisioningHostCounters.With(hostMetricLabels(info.request))
 	}
 
-	info.postSaveCallbacks = append(info.postSaveCallbacks, counter.Inc)
+	// Only count the operation once, not every time the capacity is
+	// checked again while it is delayed.
+	if info.host.Status.OperationalStatus != metal3v1alpha1.OperationalStatusDelayed ||
+		info.host.Status.DelayedState != state {
+		info.postSaveCallbacks = append(info.postSaveCallbacks, counter.Inc)
+	}
 
 	info.host.SetOperationalStatus(metal3v1alpha1.OperationalStatusDelayed)
+	info.host.Status.DelayReason = reason
+	info.host.Status.DelayedState = state
 	return actionDelayed{}
 }
 
@@ -366,7 +433,7 @@ func (r *BareMetalHostReconciler) credentialsErrorResult(err error, request ctrl
 	// In the event a credential secret is defined, but we cannot find it
 	// we requeue the host as we will not know if they create the secret
 	// at some point in the future.
-	case *ResolveBMCSecretRefError:
+	case *ResolveBMCSecretRefError, *ResolveBMCCACertificateRefError:
 		credentialsMissing.Inc()
 		saveErr := r.setErrorCondition(request, host, metal3v1alpha1.RegistrationError, err.Error())
 		if saveErr != nil {
@@ -381,7 +448,7 @@ func (r *BareMetalHostReconciler) credentialsErrorResult(err error, request ctrl
 	// host into an error state but we do not Requeue it
 	// as fixing the secret or the host BMC info will trigger
 	// the host to be reconciled again
-	case *EmptyBMCAddressError, *EmptyBMCSecretError,
+	case *EmptyBMCAddressError, *EmptyBMCSecretError, *EmptyBMCCACertificateError,
 		*bmc.CredentialsValidationError, *bmc.UnknownBMCTypeError:
 		credentialsInvalid.Inc()
 		saveErr := r.setErrorCondition(request, host, metal3v1alpha1.RegistrationError, err.Error())
@@ -462,6 +529,15 @@ func inspectionDisabled(host *metal3v1alpha1.BareMetalHost) bool {
 	return false
 }
 
+// hasServicingAnnotation checks for existence of the servicing.metal3.io
+// annotation, which requests that the pending firmware changes be
+// applied to a provisioned host, or that it be booted into the agent if
+// there are none
+func hasServicingAnnotation(host *metal3v1alpha1.BareMetalHost) bool {
+	_, ok := host.GetAnnotations()[servicingAnnotation]
+	return ok
+}
+
 // hasInspectAnnotation checks for existence of inspect.metal3.io annotation
 // and returns true if it exist
 func hasInspectAnnotation(host *metal3v1alpha1.BareMetalHost) bool {
@@ -486,6 +562,14 @@ func clearError(host *metal3v1alpha1.BareMetalHost) (dirty bool) {
 		host.Status.ErrorMessage = ""
 		dirty = true
 	}
+	if host.Status.DelayReason != "" {
+		host.Status.DelayReason = ""
+		dirty = true
+	}
+	if host.Status.DelayedState != "" {
+		host.Status.DelayedState = ""
+		dirty = true
+	}
 	return dirty
 }
 
@@ -527,6 +611,9 @@ func (r *BareMetalHostReconciler) actionDeleting(prov provisioner.Provisioner, i
 	if err := r.Update(context.Background(), info.host); err != nil {
 		return actionError{errors.Wrap(err, "failed to remove finalizer")}
 	}
+	deleteHealthMetrics(info)
+	r.healthSchedule.forget(info.request.NamespacedName)
+	r.firmwareSettingsSchedule.forget(info.request.NamespacedName)
 
 	return deleteComplete{}
 }
@@ -605,6 +692,14 @@ func (ibe *imageBuildError) Error() string {
 }
 
 func (r *BareMetalHostReconciler) preprovImageCurrent(info *reconcileInfo, image *metal3v1alpha1.PreprovisioningImage) (bool, error) {
+	if image.Status.Format != "" && len(image.Spec.AcceptFormats) > 0 &&
+		!formatAccepted(image.Spec.AcceptFormats, image.Status.Format) {
+		info.log.Info("pre-provisioning image format not acceptable",
+			"wanted", image.Spec.AcceptFormats,
+			"current", image.Status.Format)
+		return false, nil
+	}
+
 	if image.Status.Architecture != image.Spec.Architecture {
 		info.log.Info("pre-provisioning image architecture mismatch",
 			"wanted", image.Spec.Architecture,
@@ -635,12 +730,29 @@ func (r *BareMetalHostReconciler) preprovImageCurrent(info *reconcileInfo, image
 	return true, nil
 }
 
+func formatAccepted(formats []metal3v1alpha1.ImageFormat, format metal3v1alpha1.ImageFormat) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// getHostArchitecture returns the CPU architecture of the host, or an
+// empty string while it is unknown.
 func getHostArchitecture(host *metal3v1alpha1.BareMetalHost) string {
 	if host.Status.HardwareDetails != nil &&
 		host.Status.HardwareDetails.CPU.Arch != "" {
 		return host.Status.HardwareDetails.CPU.Arch
 	}
-	if hwprof, err := hardware.GetProfile(getHardwareProfileName(host)); err == nil {
+	// The default profile assumes x86_64 for any host not yet
+	// inspected, so the architecture is unknown until then
+	name := getHardwareProfileName(host)
+	if name == hardware.DefaultProfileName {
+		return ""
+	}
+	if hwprof, err := hardware.GetProfile(name); err == nil {
 		return hwprof.CPUArch
 	}
 	return ""
@@ -659,6 +771,7 @@ func (r *BareMetalHostReconciler) getPreprovImage(info *reconcileInfo, formats [
 	expectedSpec := metal3v1alpha1.PreprovisioningImageSpec{
 		NetworkDataName: info.host.Spec.PreprovisioningNetworkDataName,
 		Architecture:    getHostArchitecture(info.host),
+		AcceptFormats:   formats,
 	}
 
 	preprovImage := metal3v1alpha1.PreprovisioningImage{}
@@ -724,6 +837,14 @@ func (r *BareMetalHostReconciler) registerHost(prov provisioner.Provisioner, inf
 		"credentials", info.host.Status.TriedCredentials)
 	dirty := false
 
+	// Record the backend before the host is first registered with it,
+	// so that the host is not routed elsewhere if its labels change
+	if info.host.Status.Provisioning.ID == "" && info.host.Status.Provisioning.Backend != prov.Backend() {
+		info.log.Info("setting provisioning backend", "backend", prov.Backend())
+		info.host.Status.Provisioning.Backend = prov.Backend()
+		return actionUpdate{}
+	}
+
 	credsChanged := !info.host.Status.TriedCredentials.Match(*info.bmcCredsSecret)
 	if credsChanged {
 		info.log.Info("new credentials")
@@ -753,6 +874,7 @@ func (r *BareMetalHostReconciler) registerHost(prov provisioner.Provisioner, inf
 			CurrentImage:          getCurrentImage(info.host),
 			PreprovisioningImage:  preprovImg,
 			HasCustomDeploy:       hasCustomDeploy(info.host),
+			CPUArchitecture:       getHostArchitecture(info.host),
 		},
 		credsChanged,
 		info.host.Status.ErrorType == metal3v1alpha1.RegistrationError)
@@ -878,8 +1000,9 @@ func getHardwareProfileName(host *metal3v1alpha1.BareMetalHost) string {
 		return host.Spec.HardwareProfile
 	}
 
-	// FIXME(dhellmann): Insert more robust logic to match
-	// hardware profiles here.
+	if name, found := hardware.MatchProfile(host.Status.HardwareDetails); found {
+		return name
+	}
 	if strings.HasPrefix(host.Spec.BMC.Address, "libvirt") {
 		return "libvirt"
 	}
@@ -910,7 +1033,7 @@ func (r *BareMetalHostReconciler) actionMatchProfile(prov provisioner.Provisione
 func (r *BareMetalHostReconciler) actionPreparing(prov provisioner.Provisioner, info *reconcileInfo) actionResult {
 	info.log.Info("preparing")
 
-	dirty, newStatus, err := getHostProvisioningSettings(info.host)
+	dirty, newStatus, err := getHostProvisioningSettings(info.host, info.firmwareSettings)
 	if err != nil {
 		return actionError{err}
 	}
@@ -919,9 +1042,11 @@ func (r *BareMetalHostReconciler) actionPreparing(prov provisioner.Provisioner,
 		RAIDConfig:      newStatus.Provisioning.RAID.DeepCopy(),
 		RootDeviceHints: newStatus.Provisioning.RootDeviceHints.DeepCopy(),
 		FirmwareConfig:  newStatus.Provisioning.Firmware.DeepCopy(),
+		FirmwareUpdates: newStatus.Provisioning.FirmwareUpdates,
 	}
 	provResult, started, err := prov.Prepare(prepareData,
-		dirty || info.host.Status.ErrorType == metal3v1alpha1.PreparationError)
+		dirty || info.host.Status.ErrorType == metal3v1alpha1.PreparationError ||
+			info.host.Status.ErrorType == metal3v1alpha1.FirmwareUpdateError)
 	if err != nil {
 		return actionError{errors.Wrap(err, "error preparing host")}
 	}
@@ -929,12 +1054,16 @@ func (r *BareMetalHostReconciler) actionPreparing(prov provisioner.Provisioner,
 	if provResult.ErrorMessage != "" {
 		info.log.Info("handling cleaning error in controller")
 		clearHostProvisioningSettings(info.host)
-		return recordActionFailure(info, metal3v1alpha1.PreparationError, provResult.ErrorMessage)
+		errorType := metal3v1alpha1.PreparationError
+		if provResult.ErrorType != "" {
+			errorType = provResult.ErrorType
+		}
+		return recordActionFailure(info, errorType, provResult.ErrorMessage)
 	}
 
 	if dirty && started {
 		info.log.Info("saving host provisioning settings")
-		_, err := saveHostProvisioningSettings(info.host)
+		_, err := saveHostProvisioningSettings(info.host, info.firmwareSettings)
 		if err != nil {
 			return actionError{errors.Wrap(err, "could not save the host provisioning settings")}
 		}
@@ -950,9 +1079,78 @@ func (r *BareMetalHostReconciler) actionPreparing(prov provisioner.Provisioner,
 		return result
 	}
 
+	if err := updateFirmwareSettingsStatus(prov, info.host); err != nil {
+		return actionError{errors.Wrap(err, "could not read the firmware settings")}
+	}
+	if err := updateFirmwareComponentsStatus(prov, info.host); err != nil {
+		return actionError{errors.Wrap(err, "could not read the firmware versions")}
+	}
+
 	return actionComplete{}
 }
 
+// updateFirmwareSettingsStatus records the actual values of the
+// requested firmware settings in the host status.
+func updateFirmwareSettingsStatus(prov provisioner.Provisioner, host *metal3v1alpha1.BareMetalHost) error {
+	firmware := host.Status.Provisioning.Firmware
+	if firmware == nil || len(firmware.Settings) == 0 {
+		host.Status.FirmwareSettings = nil
+		return nil
+	}
+
+	current, _, err := prov.GetFirmwareSettings(false)
+	if err != nil {
+		return err
+	}
+
+	host.Status.FirmwareSettings = make(map[string]string, len(firmware.Settings))
+	for name := range firmware.Settings {
+		if value, ok := current[name]; ok {
+			host.Status.FirmwareSettings[name] = value
+		}
+	}
+	return nil
+}
+
+// refreshFirmwareSettings reads the requested firmware settings from
+// the host again at the refresh cadence of the HostFirmwareSettings,
+// so that changes made out of band show up in the status. It returns
+// whether the status changed.
+func (r *BareMetalHostReconciler) refreshFirmwareSettings(prov provisioner.Provisioner, info *reconcileInfo) bool {
+	firmware := info.host.Status.Provisioning.Firmware
+	if firmware == nil || len(firmware.Settings) == 0 {
+		return false
+	}
+	if !r.firmwareSettingsSchedule.due(info.request.NamespacedName, firmwareSettingsRefreshDelay, time.Now()) {
+		return false
+	}
+
+	previous := info.host.Status.FirmwareSettings
+	if err := updateFirmwareSettingsStatus(prov, info.host); err != nil {
+		info.log.Info("failed to refresh the firmware settings", "error", err.Error())
+		return false
+	}
+	return !apiequality.Semantic.DeepEqual(previous, info.host.Status.FirmwareSettings)
+}
+
+// updateFirmwareComponentsStatus records the firmware versions of the
+// components of the host in the hardware details, if the provisioner
+// is able to report them.
+func updateFirmwareComponentsStatus(prov provisioner.Provisioner, host *metal3v1alpha1.BareMetalHost) error {
+	if host.Status.HardwareDetails == nil {
+		return nil
+	}
+
+	components, err := prov.GetFirmwareComponents()
+	if err != nil {
+		return err
+	}
+	if components != nil {
+		host.Status.HardwareDetails.Firmware.Components = components
+	}
+	return nil
+}
+
 // Start/continue provisioning if we need to.
 func (r *BareMetalHostReconciler) actionProvisioning(prov provisioner.Provisioner, info *reconcileInfo) actionResult {
 	hostConf := &hostConfigData{
@@ -976,18 +1174,14 @@ func (r *BareMetalHostReconciler) actionProvisioning(prov provisioner.Provisione
 		return actionContinue{}
 	}
 
-	var image metal3v1alpha1.Image
-	if info.host.Spec.Image != nil {
-		image = *info.host.Spec.Image.DeepCopy()
-	}
-
 	provResult, err := prov.Provision(provisioner.ProvisionData{
-		Image:           image,
+		Image:           validatedImage(info.host),
 		CustomDeploy:    info.host.Spec.CustomDeploy.DeepCopy(),
 		HostConfig:      hostConf,
 		BootMode:        info.host.Status.Provisioning.BootMode,
 		HardwareProfile: hwProf,
 		RootDeviceHints: info.host.Status.Provisioning.RootDeviceHints.DeepCopy(),
+		CPUArchitecture: getHostArchitecture(info.host),
 	})
 	if err != nil {
 		return actionError{errors.Wrap(err, "failed to provision")}
@@ -1010,9 +1204,16 @@ func (r *BareMetalHostReconciler) actionProvisioning(prov provisioner.Provisione
 	}
 
 	// If the provisioner had no work, ensure the image settings match.
-	if info.host.Spec.Image != nil && info.host.Status.Provisioning.Image != *(info.host.Spec.Image) {
-		info.log.Info("updating deployed image in status")
-		info.host.Status.Provisioning.Image = *(info.host.Spec.Image)
+	if info.host.Spec.Image != nil {
+		image := *(info.host.Spec.Image)
+		if provResult.ImageReference != "" {
+			// Record the manifest an OCI image was deployed from
+			image.URL = provResult.ImageReference
+		}
+		if info.host.Status.Provisioning.Image != image {
+			info.log.Info("updating deployed image in status")
+			info.host.Status.Provisioning.Image = image
+		}
 	}
 
 	if info.host.Spec.CustomDeploy != nil && (info.host.Status.Provisioning.CustomDeploy == nil || !reflect.DeepEqual(*info.host.Spec.CustomDeploy, *info.host.Status.Provisioning.CustomDeploy)) {
@@ -1031,7 +1232,88 @@ func (r *BareMetalHostReconciler) actionProvisioning(prov provisioner.Provisione
 func clearHostProvisioningSettings(host *metal3v1alpha1.BareMetalHost) {
 	host.Status.Provisioning.RootDeviceHints = nil
 	host.Status.Provisioning.RAID = nil
+	clearHostServicingSettings(host)
+}
+
+func clearHostServicingSettings(host *metal3v1alpha1.BareMetalHost) {
 	host.Status.Provisioning.Firmware = nil
+	host.Status.Provisioning.FirmwareUpdates = nil
+}
+
+// Start/continue servicing if we need to.
+func (r *BareMetalHostReconciler) actionServicing(prov provisioner.Provisioner, info *reconcileInfo) actionResult {
+	info.log.Info("servicing")
+
+	provResult, err := prov.Adopt(
+		provisioner.AdoptData{State: info.host.Status.Provisioning.State},
+		info.host.Status.ErrorType == metal3v1alpha1.ProvisionedRegistrationError)
+	if err != nil {
+		return actionError{err}
+	}
+	if provResult.ErrorMessage != "" {
+		return recordActionFailure(info, metal3v1alpha1.ProvisionedRegistrationError, provResult.ErrorMessage)
+	}
+	if provResult.Dirty {
+		return actionContinue{provResult.RequeueAfter}
+	}
+
+	dirty, newStatus := getHostServicingSettings(info.host, info.firmwareSettings)
+	rebootToAgent := hasServicingAnnotation(info.host)
+
+	servicingData := provisioner.ServicingData{
+		FirmwareConfig:  newStatus.Provisioning.Firmware.DeepCopy(),
+		FirmwareUpdates: newStatus.Provisioning.FirmwareUpdates,
+		RebootToAgent:   rebootToAgent,
+	}
+	provResult, started, err := prov.Service(servicingData,
+		dirty || rebootToAgent || info.host.Status.ErrorType == metal3v1alpha1.ServicingError ||
+			info.host.Status.ErrorType == metal3v1alpha1.FirmwareUpdateError)
+	if err != nil {
+		return actionError{errors.Wrap(err, "error servicing host")}
+	}
+
+	if provResult.ErrorMessage != "" {
+		info.log.Info("handling servicing error in controller")
+		clearHostServicingSettings(info.host)
+		errorType := metal3v1alpha1.ServicingError
+		if provResult.ErrorType != "" {
+			errorType = provResult.ErrorType
+		}
+		return recordActionFailure(info, errorType, provResult.ErrorMessage)
+	}
+
+	// Remove the annotation before changing the status, as the update
+	// overwrites the host with the version stored in the API.
+	if started && rebootToAgent {
+		delete(info.host.Annotations, servicingAnnotation)
+		if err := r.Update(context.TODO(), info.host); err != nil {
+			return actionError{errors.Wrap(err, "failed to remove servicing annotation from host")}
+		}
+	}
+
+	if dirty && started {
+		info.log.Info("saving host servicing settings")
+		saveHostServicingSettings(info.host, info.firmwareSettings)
+	}
+	if started && clearError(info.host) {
+		dirty = true
+	}
+	if provResult.Dirty {
+		result := actionContinue{provResult.RequeueAfter}
+		if dirty {
+			return actionUpdate{result}
+		}
+		return result
+	}
+
+	if err := updateFirmwareSettingsStatus(prov, info.host); err != nil {
+		return actionError{errors.Wrap(err, "could not read the firmware settings")}
+	}
+	if err := updateFirmwareComponentsStatus(prov, info.host); err != nil {
+		return actionError{errors.Wrap(err, "could not read the firmware versions")}
+	}
+
+	return actionComplete{}
 }
 
 func (r *BareMetalHostReconciler) actionDeprovisioning(prov provisioner.Provisioner, info *reconcileInfo) actionResult {
@@ -1091,6 +1373,50 @@ func (r *BareMetalHostReconciler) actionDeprovisioning(prov provisioner.Provisio
 	return actionComplete{}
 }
 
+func (r *BareMetalHostReconciler) powerPollInterval() time.Duration {
+	if r.PowerPollInterval > 0 {
+		return r.PowerPollInterval
+	}
+	return defaultPowerPollInterval
+}
+
+// pollSchedule records when some data of each host was last read, so
+// that it is read at its own interval rather than each time the power
+// state is checked.
+type pollSchedule struct {
+	lock     sync.Mutex
+	lastRead map[types.NamespacedName]time.Time
+}
+
+// due returns whether the data of a host should be read at now, and
+// records the read when it should.
+func (s *pollSchedule) due(name types.NamespacedName, interval time.Duration, now time.Time) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if last, ok := s.lastRead[name]; ok && now.Sub(last) < interval {
+		return false
+	}
+	if s.lastRead == nil {
+		s.lastRead = make(map[types.NamespacedName]time.Time)
+	}
+	s.lastRead[name] = now
+	return true
+}
+
+// forget drops the schedule of a deleted host.
+func (s *pollSchedule) forget(name types.NamespacedName) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	delete(s.lastRead, name)
+}
+
+func (r *BareMetalHostReconciler) healthPollInterval() time.Duration {
+	if r.HealthPollInterval > 0 {
+		return r.HealthPollInterval
+	}
+	return defaultHealthPollInterval
+}
+
 // Check the current power status against the desired power status.
 func (r *BareMetalHostReconciler) manageHostPower(prov provisioner.Provisioner, info *reconcileInfo) actionResult {
 	var provResult provisioner.Result
@@ -1108,6 +1434,10 @@ func (r *BareMetalHostReconciler) manageHostPower(prov provisioner.Provisioner,
 		return actionUpdate{}
 	}
 
+	if r.collectHealth(prov, info) || r.refreshFirmwareSettings(prov, info) {
+		return actionUpdate{}
+	}
+
 	desiredPowerOnState := info.host.Spec.Online
 
 	if !info.host.Status.PoweredOn {
@@ -1130,10 +1460,24 @@ func (r *BareMetalHostReconciler) manageHostPower(prov provisioner.Provisioner,
 		desiredPowerOnState = false
 	}
 
+	policyChanged, policyPoweredOff, policyRecheck, err := managePowerPolicy(info, time.Now())
+	if err != nil {
+		return actionError{errors.Wrap(err, "failed to evaluate the power policy")}
+	}
+	if policyChanged {
+		return actionUpdate{}
+	}
+	if policyPoweredOff {
+		desiredPowerOnState = false
+	}
+
 	// Power state needs to be monitored regularly, so if we leave
 	// this function without an error we always want to requeue after
 	// a delay.
-	steadyStateResult := actionContinue{time.Second * 60}
+	steadyStateResult := actionContinue{r.powerPollInterval()}
+	if policyRecheck > 0 && policyRecheck < steadyStateResult.delay {
+		steadyStateResult.delay = policyRecheck
+	}
 	if info.host.Status.PoweredOn == desiredPowerOnState {
 		return steadyStateResult
 	}
@@ -1142,7 +1486,8 @@ func (r *BareMetalHostReconciler) manageHostPower(prov provisioner.Provisioner,
 		"expected", desiredPowerOnState,
 		"actual", info.host.Status.PoweredOn,
 		"reboot mode", desiredRebootMode,
-		"reboot process", desiredPowerOnState != info.host.Spec.Online)
+		"reboot process", desiredReboot && isProvisioned,
+		"power policy", policyPoweredOff)
 
 	if desiredPowerOnState {
 		provResult, err = prov.PowerOn()
@@ -1177,7 +1522,7 @@ func (r *BareMetalHostReconciler) manageHostPower(prov provisioner.Provisioner,
 	// The provisioner did not have to do anything to change the power
 	// state and there were no errors, so reflect the new state in the
 	// host status field.
-	info.host.Status.PoweredOn = info.host.Spec.Online
+	info.host.Status.PoweredOn = desiredPowerOnState
 	info.host.Status.ErrorCount = 0
 	return actionUpdate{steadyStateResult}
 }
@@ -1213,15 +1558,49 @@ func (r *BareMetalHostReconciler) actionManageSteadyState(prov provisioner.Provi
 // having been provisioned. Then we monitor its power status.
 func (r *BareMetalHostReconciler) actionManageReady(prov provisioner.Provisioner, info *reconcileInfo) actionResult {
 	if info.host.NeedsProvisioning() {
+		if r.ImageValidator != nil {
+			if result := r.validateImage(info); result != nil {
+				return result
+			}
+		}
+		if result := r.checkUserDataTemplate(info); result != nil {
+			return result
+		}
 		clearError(info.host)
 		return actionComplete{}
 	}
 	return r.manageHostPower(prov, info)
 }
 
-func getHostProvisioningSettings(host *metal3v1alpha1.BareMetalHost) (dirty bool, status *metal3v1alpha1.BareMetalHostStatus, err error) {
+// checkUserDataTemplate renders the user data template of the host
+// before provisioning starts, so that errors in it are reported as such
+// rather than retried by the provisioner. The host stays ready until the
+// template is fixed, and an unchanged error is only recorded once. It
+// returns nil when there is no error in the template.
+func (r *BareMetalHostReconciler) checkUserDataTemplate(info *reconcileInfo) actionResult {
+	if info.host.Spec.UserData == nil {
+		return nil
+	}
+	hostConf := &hostConfigData{
+		host:          info.host,
+		log:           info.log.WithName("host_config_data"),
+		secretManager: r.secretManager(info.log),
+	}
+	var templateErr *UserDataTemplateError
+	if _, err := hostConf.UserData(); !errors.As(err, &templateErr) {
+		// Other errors are reported by the provisioner
+		return nil
+	}
+	if info.host.Status.ErrorType == metal3v1alpha1.UserDataError &&
+		info.host.Status.ErrorMessage == templateErr.Error() {
+		return actionFailed{ErrorType: metal3v1alpha1.UserDataError, errorCount: info.host.Status.ErrorCount}
+	}
+	return recordActionFailure(info, metal3v1alpha1.UserDataError, templateErr.Error())
+}
+
+func getHostProvisioningSettings(host *metal3v1alpha1.BareMetalHost, firmwareSettings map[string]string) (dirty bool, status *metal3v1alpha1.BareMetalHostStatus, err error) {
 	hostCopy := host.DeepCopy()
-	dirty, err = saveHostProvisioningSettings(hostCopy)
+	dirty, err = saveHostProvisioningSettings(hostCopy, firmwareSettings)
 	if err != nil {
 		err = errors.Wrap(err, "could not determine the host provisioning settings")
 	}
@@ -1231,8 +1610,9 @@ func getHostProvisioningSettings(host *metal3v1alpha1.BareMetalHost) (dirty bool
 
 // saveHostProvisioningSettings copies the values related to
 // provisioning that do not trigger re-provisioning into the status
-// fields of the host.
-func saveHostProvisioningSettings(host *metal3v1alpha1.BareMetalHost) (dirty bool, err error) {
+// fields of the host. Any firmware settings requested through the
+// HostFirmwareSettings are merged with those in the host spec.
+func saveHostProvisioningSettings(host *metal3v1alpha1.BareMetalHost, firmwareSettings map[string]string) (dirty bool, err error) {
 
 	// Ensure the root device hints we're going to use are stored.
 	//
@@ -1289,16 +1669,71 @@ func saveHostProvisioningSettings(host *metal3v1alpha1.BareMetalHost) (dirty boo
 		}
 	}
 
+	if saveHostServicingSettings(host, firmwareSettings) {
+		dirty = true
+	}
+
+	return
+}
+
+func getHostServicingSettings(host *metal3v1alpha1.BareMetalHost, firmwareSettings map[string]string) (dirty bool, status *metal3v1alpha1.BareMetalHostStatus) {
+	hostCopy := host.DeepCopy()
+	dirty = saveHostServicingSettings(hostCopy, firmwareSettings)
+	status = &hostCopy.Status
+	return
+}
+
+// saveHostServicingSettings copies the values related to the firmware,
+// which can be changed on a provisioned host, into the status fields
+// of the host.
+func saveHostServicingSettings(host *metal3v1alpha1.BareMetalHost, firmwareSettings map[string]string) (dirty bool) {
 	// Copy BIOS settings
-	if !reflect.DeepEqual(host.Status.Provisioning.Firmware, host.Spec.Firmware) {
-		host.Status.Provisioning.Firmware = host.Spec.Firmware.DeepCopy()
+	firmware := desiredFirmwareConfig(host, firmwareSettings)
+	if !reflect.DeepEqual(host.Status.Provisioning.Firmware, firmware) {
+		host.Status.Provisioning.Firmware = firmware
+		dirty = true
+	}
+
+	// Copy firmware updates
+	if !reflect.DeepEqual(host.Status.Provisioning.FirmwareUpdates, host.Spec.FirmwareUpdates) {
+		host.Status.Provisioning.FirmwareUpdates = nil
+		if host.Spec.FirmwareUpdates != nil {
+			host.Status.Provisioning.FirmwareUpdates = make([]metal3v1alpha1.FirmwareUpdate, len(host.Spec.FirmwareUpdates))
+			copy(host.Status.Provisioning.FirmwareUpdates, host.Spec.FirmwareUpdates)
+		}
 		dirty = true
 	}
 
 	return
 }
 
+// desiredFirmwareConfig returns the firmware config from the host spec
+// with the firmware settings from the HostFirmwareSettings added. The
+// settings in the host spec take precedence.
+func desiredFirmwareConfig(host *metal3v1alpha1.BareMetalHost, firmwareSettings map[string]string) *metal3v1alpha1.FirmwareConfig {
+	firmware := host.Spec.Firmware.DeepCopy()
+	if len(firmwareSettings) == 0 {
+		return firmware
+	}
+
+	if firmware == nil {
+		firmware = &metal3v1alpha1.FirmwareConfig{}
+	}
+	settings := make(map[string]string, len(firmwareSettings)+len(firmware.Settings))
+	for name, value := range firmwareSettings {
+		settings[name] = value
+	}
+	for name, value := range firmware.Settings {
+		settings[name] = value
+	}
+	firmware.Settings = settings
+	return firmware
+}
+
+// saveHostStatus writes the status of the host, with the conditions
+// recalculated from it.
 func (r *BareMetalHostReconciler) saveHostStatus(host *metal3v1alpha1.BareMetalHost) error {
+	updateHostConditions(host)
 	t := metav1.Now()
 	host.Status.LastUpdated = &t
 
@@ -1423,9 +1858,92 @@ func (r *BareMetalHostReconciler) buildAndValidateBMCCredentials(request ctrl.Re
 		return nil, bmcCredsSecret, err
 	}
 
+	bmcCreds.CACertificate, err = r.getBMCCACertificate(request, host)
+	if err != nil {
+		return nil, bmcCredsSecret, err
+	}
+
 	return bmcCreds, bmcCredsSecret, nil
 }
 
+// getBMCCACertificate loads the CA bundle referenced by the BMC details
+// of the host, if any.
+func (r *BareMetalHostReconciler) getBMCCACertificate(request ctrl.Request, host *metal3v1alpha1.BareMetalHost) ([]byte, error) {
+	ref := host.Spec.BMC.CACertificateRef
+	if ref == nil || host.Spec.BMC.DisableCertificateVerification {
+		return nil, nil
+	}
+
+	key := types.NamespacedName{Namespace: host.Namespace, Name: ref.Name}
+	dataKey := ref.Key
+	if dataKey == "" {
+		dataKey = metal3v1alpha1.DefaultCACertificateKey
+	}
+
+	kind := caCertificateKind(ref)
+
+	var data []byte
+	switch kind {
+	case metal3v1alpha1.CACertificateInConfigMap:
+		configMap := &corev1.ConfigMap{}
+		err := r.APIReader.Get(context.TODO(), key, configMap)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				return nil, &ResolveBMCCACertificateRefError{message: fmt.Sprintf("The ConfigMap %s does not exist", key)}
+			}
+			return nil, errors.Wrap(err, "failed to load BMC CA certificate")
+		}
+		data = []byte(configMap.Data[dataKey])
+	default:
+		secretManager := r.secretManager(r.Log.WithValues("baremetalhost", request.NamespacedName))
+		secret, err := secretManager.AcquireSecret(key, host, false)
+		if err != nil {
+			if k8serrors.IsNotFound(err) {
+				return nil, &ResolveBMCCACertificateRefError{message: fmt.Sprintf("The Secret %s does not exist", key)}
+			}
+			return nil, errors.Wrap(err, "failed to load BMC CA certificate")
+		}
+		data = secret.Data[dataKey]
+	}
+
+	if len(data) == 0 {
+		return nil, &EmptyBMCCACertificateError{message: fmt.Sprintf("%s %s has no key %s", kind, key, dataKey)}
+	}
+	return data, nil
+}
+
+func caCertificateKind(ref *metal3v1alpha1.CACertificateReference) metal3v1alpha1.CACertificateReferenceKind {
+	if ref.Kind == "" {
+		return metal3v1alpha1.CACertificateInSecret
+	}
+	return ref.Kind
+}
+
+// hostsForCACertificate returns a function mapping a Secret or a
+// ConfigMap to requests for the hosts using it as the CA bundle of
+// their BMC, so that a new bundle is applied without waiting for a
+// change of the hosts.
+func (r *BareMetalHostReconciler) hostsForCACertificate(kind metal3v1alpha1.CACertificateReferenceKind) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		hosts := &metal3v1alpha1.BareMetalHostList{}
+		if err := r.List(context.Background(), hosts, client.InNamespace(obj.GetNamespace())); err != nil {
+			r.Log.Error(err, "failed to list hosts")
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, host := range hosts.Items {
+			ref := host.Spec.BMC.CACertificateRef
+			if ref != nil && ref.Name == obj.GetName() && caCertificateKind(ref) == kind {
+				requests = append(requests, reconcile.Request{
+					NamespacedName: types.NamespacedName{Namespace: host.Namespace, Name: host.Name},
+				})
+			}
+		}
+		return requests
+	}
+}
+
 func (r *BareMetalHostReconciler) publishEvent(request ctrl.Request, event corev1.Event) {
 	reqLogger := r.Log.WithValues("baremetalhost", request.NamespacedName)
 	reqLogger.Info("publishing event", "reason", event.Reason, "message", event.Message)
@@ -1498,7 +2016,7 @@ func (r *BareMetalHostReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		MaxConcurrentReconciles: maxConcurrentReconciles,
 	}
 
-	return ctrl.NewControllerManagedBy(mgr).
+	controllerBuilder := ctrl.NewControllerManagedBy(mgr).
 		For(&metal3v1alpha1.BareMetalHost{}).
 		WithEventFilter(
 			predicate.Funcs{
@@ -1507,5 +2025,30 @@ func (r *BareMetalHostReconciler) SetupWithManager(mgr ctrl.Manager) error {
 		WithOptions(opts).
 		Owns(&corev1.Secret{}).
 		Owns(&metal3v1alpha1.PreprovisioningImage{}).
-		Complete(r)
+		Owns(&metal3v1alpha1.HostFirmwareSettings{}).
+		Watches(&source.Kind{Type: &corev1.ConfigMap{}},
+			handler.EnqueueRequestsFromMapFunc(r.hostsForCACertificate(metal3v1alpha1.CACertificateInConfigMap)),
+			builder.OnlyMetadata).
+		Watches(&source.Kind{Type: &corev1.Secret{}},
+			handler.EnqueueRequestsFromMapFunc(r.hostsForCACertificate(metal3v1alpha1.CACertificateInSecret)))
+
+	if r.PowerStateWatcher != nil {
+		powerEvents := make(chan event.GenericEvent)
+		if err := mgr.Add(manager.RunnableFunc(func(ctx context.Context) error {
+			return r.PowerStateWatcher.WatchPowerState(ctx, func(name types.NamespacedName) {
+				host := &metal3v1alpha1.BareMetalHost{
+					ObjectMeta: metav1.ObjectMeta{Name: name.Name, Namespace: name.Namespace},
+				}
+				select {
+				case powerEvents <- event.GenericEvent{Object: host}:
+				case <-ctx.Done():
+				}
+			})
+		})); err != nil {
+			return errors.Wrap(err, "failed to add the power state watcher")
+		}
+		controllerBuilder = controllerBuilder.Watches(&source.Channel{Source: powerEvents}, &handler.EnqueueRequestForObject{})
+	}
+
+	return controllerBuilder.Complete(r)
 }
@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
@@ -1168,6 +1169,51 @@ func TestProvision(t *testing.T) {
 	)
 }
 
+// TestServiceProvisionedHost ensures that changing the firmware settings
+// of a provisioned host applies them without removing the image.
+func TestServiceProvisionedHost(t *testing.T) {
+	host := newDefaultHost(t)
+	host.Spec.Image = &metal3v1alpha1.Image{
+		URL:      "https://example.com/image-name",
+		Checksum: "12345",
+	}
+	host.Spec.Online = true
+	r := newTestReconciler(host)
+
+	waitForProvisioningState(t, r, host, metal3v1alpha1.StateProvisioned)
+
+	host.Spec.Firmware = &metal3v1alpha1.FirmwareConfig{
+		Settings: map[string]string{"ProcVirtualization": "Disabled"},
+	}
+	err := r.Update(goctx.TODO(), host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The changes are pending until servicing is requested
+	tryReconcile(t, r, host,
+		func(host *metal3v1alpha1.BareMetalHost, result reconcile.Result) bool {
+			return true
+		},
+	)
+	assert.Equal(t, metal3v1alpha1.StateProvisioned, host.Status.Provisioning.State)
+	assert.Nil(t, host.Status.Provisioning.Firmware)
+
+	host.Annotations = map[string]string{servicingAnnotation: ""}
+	err = r.Update(goctx.TODO(), host)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waitForProvisioningState(t, r, host, metal3v1alpha1.StateServicing)
+	waitForProvisioningState(t, r, host, metal3v1alpha1.StateProvisioned)
+
+	assert.NotContains(t, host.Annotations, servicingAnnotation)
+	assert.Equal(t, "https://example.com/image-name", host.Status.Provisioning.Image.URL)
+	assert.Equal(t, host.Spec.Firmware, host.Status.Provisioning.Firmware)
+	assert.Equal(t, map[string]string{"ProcVirtualization": "Disabled"}, host.Status.FirmwareSettings)
+}
+
 // TestProvisionCustomDeploy ensures that the Provisioning.CustomDeploy portion
 // of the status block is filled in for provisioned hosts.
 func TestProvisionCustomDeploy(t *testing.T) {
@@ -1296,6 +1342,83 @@ func TestPowerOff(t *testing.T) {
 	)
 }
 
+// TestPowerPollInterval verifies that the power state of a host in a
+// steady state is checked at the configured interval.
+func TestPowerPollInterval(t *testing.T) {
+	host := newDefaultHost(t)
+	host.Spec.Online = false
+	r := newTestReconciler(host)
+	r.PowerPollInterval = time.Minute * 5
+
+	tryReconcile(t, r, host,
+		func(host *metal3v1alpha1.BareMetalHost, result reconcile.Result) bool {
+			t.Logf("provisioning state: %s requeue after: %s", host.Status.Provisioning.State, result.RequeueAfter)
+			return host.Status.Provisioning.State == metal3v1alpha1.StateReady &&
+				result.RequeueAfter == time.Minute*5
+		},
+	)
+}
+
+// TestManageHostPowerStatus verifies that the power status recorded
+// once the provisioner is done is the state it was asked for.
+func TestManageHostPowerStatus(t *testing.T) {
+	testCases := []struct {
+		Scenario        string
+		Online          bool
+		Reboot          bool
+		PoweredOn       bool
+		ExpectPoweredOn bool
+	}{
+		{
+			Scenario:        "power on",
+			Online:          true,
+			ExpectPoweredOn: true,
+		},
+		{
+			Scenario:  "power off",
+			PoweredOn: true,
+		},
+		{
+			Scenario:  "reboot",
+			Online:    true,
+			Reboot:    true,
+			PoweredOn: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			host := host(metal3v1alpha1.StateProvisioned).build()
+			host.Spec.Online = tc.Online
+			host.Status.PoweredOn = tc.PoweredOn
+			if tc.Reboot {
+				host.Annotations = map[string]string{rebootAnnotationPrefix + "/foo": ""}
+			}
+			r := newTestReconciler()
+
+			result := r.manageHostPower(newMockProvisioner(), makeReconcileInfo(host))
+
+			assert.True(t, result.Dirty())
+			assert.Equal(t, tc.ExpectPoweredOn, host.Status.PoweredOn)
+		})
+	}
+}
+
+func TestPollSchedule(t *testing.T) {
+	name := types.NamespacedName{Namespace: "myns", Name: "myhost"}
+	other := types.NamespacedName{Namespace: "myns", Name: "other"}
+	now := time.Now()
+	s := pollSchedule{}
+
+	assert.True(t, s.due(name, time.Minute, now))
+	assert.False(t, s.due(name, time.Minute, now.Add(time.Second*30)))
+	assert.True(t, s.due(other, time.Minute, now.Add(time.Second*30)))
+	assert.True(t, s.due(name, time.Minute, now.Add(time.Minute)))
+
+	s.forget(name)
+	assert.True(t, s.due(name, time.Minute, now.Add(time.Minute)))
+}
+
 // TestDeleteHost verifies several delete cases
 func TestDeleteHost(t *testing.T) {
 	now := metav1.Now()
@@ -1466,14 +1589,14 @@ func TestUpdateRootDeviceHints(t *testing.T) {
 
 	for _, tc := range testCases {
 		t.Run(tc.Scenario, func(t *testing.T) {
-			dirty, newStatus, err := getHostProvisioningSettings(&tc.Host)
+			dirty, newStatus, err := getHostProvisioningSettings(&tc.Host, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
 			assert.Equal(t, tc.Dirty, dirty, "dirty flag did not match")
 			assert.Equal(t, tc.Expected, newStatus.Provisioning.RootDeviceHints)
 
-			dirty, err = saveHostProvisioningSettings(&tc.Host)
+			dirty, err = saveHostProvisioningSettings(&tc.Host, nil)
 			if err != nil {
 				t.Fatal(err)
 			}
@@ -2130,7 +2253,7 @@ func TestUpdateRAID(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			host.Spec.RAID = c.specRAID
 			host.Status.Provisioning.RAID = c.statusRAID
-			dirty, _ := saveHostProvisioningSettings(&host)
+			dirty, _ := saveHostProvisioningSettings(&host, nil)
 			assert.Equal(t, c.dirty, dirty)
 			assert.Equal(t, c.expected, host.Status.Provisioning.RAID)
 		})
@@ -2223,3 +2346,318 @@ func TestCredentialsFromSecret(t *testing.T) {
 		})
 	}
 }
+
+func TestGetBMCCACertificate(t *testing.T) {
+	caSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "bmc-ca", Namespace: namespace},
+		Data:       map[string][]byte{"ca.crt": []byte("secret CA"), "other.crt": []byte("other CA")},
+	}
+	caConfigMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "bmc-ca", Namespace: namespace},
+		Data:       map[string]string{"ca.crt": "configmap CA"},
+	}
+
+	cases := []struct {
+		name                  string
+		ref                   *metal3v1alpha1.CACertificateReference
+		disableVerification   bool
+		expected              string
+		expectedErrorContains string
+	}{
+		{
+			name: "no reference",
+		},
+		{
+			name:     "secret",
+			ref:      &metal3v1alpha1.CACertificateReference{Name: "bmc-ca"},
+			expected: "secret CA",
+		},
+		{
+			name:     "secret with key",
+			ref:      &metal3v1alpha1.CACertificateReference{Kind: metal3v1alpha1.CACertificateInSecret, Name: "bmc-ca", Key: "other.crt"},
+			expected: "other CA",
+		},
+		{
+			name:     "configmap",
+			ref:      &metal3v1alpha1.CACertificateReference{Kind: metal3v1alpha1.CACertificateInConfigMap, Name: "bmc-ca"},
+			expected: "configmap CA",
+		},
+		{
+			name:                "verification disabled",
+			ref:                 &metal3v1alpha1.CACertificateReference{Name: "bmc-ca"},
+			disableVerification: true,
+		},
+		{
+			name:                  "missing secret",
+			ref:                   &metal3v1alpha1.CACertificateReference{Name: "missing"},
+			expectedErrorContains: "The Secret test-namespace/missing does not exist",
+		},
+		{
+			name:                  "missing configmap",
+			ref:                   &metal3v1alpha1.CACertificateReference{Kind: metal3v1alpha1.CACertificateInConfigMap, Name: "missing"},
+			expectedErrorContains: "The ConfigMap test-namespace/missing does not exist",
+		},
+		{
+			name:                  "missing key",
+			ref:                   &metal3v1alpha1.CACertificateReference{Name: "bmc-ca", Key: "missing.crt"},
+			expectedErrorContains: "Secret test-namespace/bmc-ca has no key missing.crt",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := newDefaultHost(t)
+			host.Spec.BMC.CACertificateRef = tc.ref
+			host.Spec.BMC.DisableCertificateVerification = tc.disableVerification
+			r := newTestReconciler(host, caSecret.DeepCopy(), caConfigMap.DeepCopy())
+
+			data, err := r.getBMCCACertificate(newRequest(host), host)
+			if tc.expectedErrorContains != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedErrorContains)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(data))
+		})
+	}
+}
+
+func TestHostsForCACertificate(t *testing.T) {
+	inSecret := newDefaultNamedHost("in-secret", t)
+	inSecret.Spec.BMC.CACertificateRef = &metal3v1alpha1.CACertificateReference{Name: "bmc-ca"}
+	inConfigMap := newDefaultNamedHost("in-configmap", t)
+	inConfigMap.Spec.BMC.CACertificateRef = &metal3v1alpha1.CACertificateReference{
+		Kind: metal3v1alpha1.CACertificateInConfigMap, Name: "bmc-ca"}
+	otherCA := newDefaultNamedHost("other-ca", t)
+	otherCA.Spec.BMC.CACertificateRef = &metal3v1alpha1.CACertificateReference{Name: "other-ca"}
+	noCA := newDefaultNamedHost("no-ca", t)
+	r := newTestReconciler(inSecret, inConfigMap, otherCA, noCA)
+
+	caObject := &metav1.PartialObjectMetadata{ObjectMeta: metav1.ObjectMeta{Name: "bmc-ca", Namespace: namespace}}
+
+	requests := r.hostsForCACertificate(metal3v1alpha1.CACertificateInConfigMap)(caObject)
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "in-configmap"}}}, requests)
+
+	requests = r.hostsForCACertificate(metal3v1alpha1.CACertificateInSecret)(caObject)
+	assert.Equal(t, []reconcile.Request{{NamespacedName: types.NamespacedName{Namespace: namespace, Name: "in-secret"}}}, requests)
+
+	caObject.Namespace = "other-namespace"
+	assert.Empty(t, r.hostsForCACertificate(metal3v1alpha1.CACertificateInSecret)(caObject))
+}
+
+func TestUpdateFirmwareSettingsStatus(t *testing.T) {
+	cases := []struct {
+		name      string
+		requested map[string]string
+		current   map[string]string
+		expected  map[string]string
+	}{
+		{
+			name:    "no settings requested",
+			current: map[string]string{"ProcVirtualization": "Enabled"},
+		},
+		{
+			name:      "requested settings only",
+			requested: map[string]string{"ProcVirtualization": "Disabled"},
+			current: map[string]string{
+				"ProcVirtualization": "Disabled",
+				"NumCores":           "8",
+			},
+			expected: map[string]string{"ProcVirtualization": "Disabled"},
+		},
+		{
+			name:      "setting not reported",
+			requested: map[string]string{"ProcVirtualization": "Disabled"},
+			current:   map[string]string{"NumCores": "8"},
+			expected:  map[string]string{},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := host(metal3v1alpha1.StatePreparing).build()
+			host.Status.FirmwareSettings = map[string]string{"Stale": "value"}
+			if tc.requested != nil {
+				host.Status.Provisioning.Firmware = &metal3v1alpha1.FirmwareConfig{
+					Settings: tc.requested,
+				}
+			}
+			prov := newMockProvisioner()
+			prov.firmwareSettings = tc.current
+
+			err := updateFirmwareSettingsStatus(prov, host)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, host.Status.FirmwareSettings)
+		})
+	}
+}
+
+func TestRefreshFirmwareSettings(t *testing.T) {
+	host := host(metal3v1alpha1.StateProvisioned).build()
+	host.Status.Provisioning.Firmware = &metal3v1alpha1.FirmwareConfig{
+		Settings: map[string]string{"ProcVirtualization": "Disabled"},
+	}
+	host.Status.FirmwareSettings = map[string]string{"ProcVirtualization": "Disabled"}
+	info := makeDefaultReconcileInfo(host)
+	r := newTestReconciler()
+	prov := newMockProvisioner()
+
+	// Changed out of band since the host was prepared
+	prov.firmwareSettings = map[string]string{"ProcVirtualization": "Enabled"}
+	assert.True(t, r.refreshFirmwareSettings(prov, info))
+	assert.Equal(t, map[string]string{"ProcVirtualization": "Enabled"}, host.Status.FirmwareSettings)
+
+	// Not read again before the refresh delay elapsed
+	prov.firmwareSettings = map[string]string{"ProcVirtualization": "Disabled"}
+	assert.False(t, r.refreshFirmwareSettings(prov, info))
+	assert.Equal(t, map[string]string{"ProcVirtualization": "Enabled"}, host.Status.FirmwareSettings)
+
+	r.firmwareSettingsSchedule.forget(info.request.NamespacedName)
+	assert.True(t, r.refreshFirmwareSettings(prov, info))
+	assert.Equal(t, map[string]string{"ProcVirtualization": "Disabled"}, host.Status.FirmwareSettings)
+	r.firmwareSettingsSchedule.forget(info.request.NamespacedName)
+	assert.False(t, r.refreshFirmwareSettings(prov, info), "unchanged settings")
+}
+
+func TestUpdateFirmwareComponentsStatus(t *testing.T) {
+	components := []metal3v1alpha1.FirmwareComponentStatus{
+		{Component: "bios", InitialVersion: "1.0", CurrentVersion: "1.2"},
+	}
+
+	host := host(metal3v1alpha1.StatePreparing).build()
+	host.Status.HardwareDetails = &metal3v1alpha1.HardwareDetails{}
+	prov := newMockProvisioner()
+
+	err := updateFirmwareComponentsStatus(prov, host)
+	assert.NoError(t, err)
+	assert.Nil(t, host.Status.HardwareDetails.Firmware.Components)
+
+	prov.firmwareVersions = components
+	err = updateFirmwareComponentsStatus(prov, host)
+	assert.NoError(t, err)
+	assert.Equal(t, components, host.Status.HardwareDetails.Firmware.Components)
+
+	// The versions are kept when the provisioner cannot report them
+	prov.firmwareVersions = nil
+	err = updateFirmwareComponentsStatus(prov, host)
+	assert.NoError(t, err)
+	assert.Equal(t, components, host.Status.HardwareDetails.Firmware.Components)
+}
+
+func TestSaveHostProvisioningSettingsFirmwareUpdates(t *testing.T) {
+	host := host(metal3v1alpha1.StateReady).build()
+	host.Spec.FirmwareUpdates = []metal3v1alpha1.FirmwareUpdate{
+		{
+			Component: metal3v1alpha1.FirmwareComponentBIOS,
+			URL:       "http://example.com/bios.bin",
+			Checksum:  "abcdef",
+		},
+	}
+
+	dirty, err := saveHostProvisioningSettings(host, nil)
+	assert.NoError(t, err)
+	assert.True(t, dirty)
+	assert.Equal(t, host.Spec.FirmwareUpdates, host.Status.Provisioning.FirmwareUpdates)
+
+	dirty, err = saveHostProvisioningSettings(host, nil)
+	assert.NoError(t, err)
+	assert.False(t, dirty)
+
+	host.Spec.FirmwareUpdates = nil
+	dirty, err = saveHostProvisioningSettings(host, nil)
+	assert.NoError(t, err)
+	assert.True(t, dirty)
+	assert.Nil(t, host.Status.Provisioning.FirmwareUpdates)
+}
+
+func TestDesiredFirmwareConfig(t *testing.T) {
+	cases := []struct {
+		name             string
+		spec             *metal3v1alpha1.FirmwareConfig
+		firmwareSettings map[string]string
+		expected         *metal3v1alpha1.FirmwareConfig
+	}{
+		{
+			name: "no settings",
+		},
+		{
+			name:             "settings resource only",
+			firmwareSettings: map[string]string{"ProcVirtualization": "Disabled"},
+			expected: &metal3v1alpha1.FirmwareConfig{
+				Settings: map[string]string{"ProcVirtualization": "Disabled"},
+			},
+		},
+		{
+			name: "host spec wins",
+			spec: &metal3v1alpha1.FirmwareConfig{
+				Settings: map[string]string{"ProcVirtualization": "Enabled"},
+			},
+			firmwareSettings: map[string]string{
+				"ProcVirtualization": "Disabled",
+				"NumCores":           "8",
+			},
+			expected: &metal3v1alpha1.FirmwareConfig{
+				Settings: map[string]string{
+					"ProcVirtualization": "Enabled",
+					"NumCores":           "8",
+				},
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := host(metal3v1alpha1.StateReady).build()
+			host.Spec.Firmware = tc.spec
+
+			assert.Equal(t, tc.expected, desiredFirmwareConfig(host, tc.firmwareSettings))
+		})
+	}
+}
+
+func TestGetHostArchitecture(t *testing.T) {
+	cases := []struct {
+		name     string
+		host     func() *metal3v1alpha1.BareMetalHost
+		expected string
+	}{
+		{
+			name: "uninspected host",
+			host: func() *metal3v1alpha1.BareMetalHost {
+				host := host(metal3v1alpha1.StateRegistering).build()
+				host.Status.HardwareProfile = ""
+				host.Status.HardwareDetails = nil
+				return host
+			},
+			expected: "",
+		},
+		{
+			name: "inspected host",
+			host: func() *metal3v1alpha1.BareMetalHost {
+				host := host(metal3v1alpha1.StateAvailable).build()
+				host.Status.HardwareDetails = &metal3v1alpha1.HardwareDetails{
+					CPU: metal3v1alpha1.CPU{Arch: "aarch64"},
+				}
+				return host
+			},
+			expected: "aarch64",
+		},
+		{
+			name: "profile with an architecture",
+			host: func() *metal3v1alpha1.BareMetalHost {
+				host := host(metal3v1alpha1.StateRegistering).build()
+				host.Status.HardwareProfile = "libvirt"
+				host.Status.HardwareDetails = nil
+				return host
+			},
+			expected: "x86_64",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, getHostArchitecture(tc.host()))
+		})
+	}
+}
@@ -37,6 +37,28 @@ func (e ResolveBMCSecretRefError) Error() string {
 		e.message)
 }
 
+// ResolveBMCCACertificateRefError is returned when the resource
+// holding the BMC CA certificate for a host cannot be found
+type ResolveBMCCACertificateRefError struct {
+	message string
+}
+
+func (e ResolveBMCCACertificateRefError) Error() string {
+	return fmt.Sprintf("BMC CA certificate doesn't exist %s",
+		e.message)
+}
+
+// EmptyBMCCACertificateError is returned when the resource holding
+// the BMC CA certificate for a host does not contain it
+type EmptyBMCCACertificateError struct {
+	message string
+}
+
+func (e EmptyBMCCACertificateError) Error() string {
+	return fmt.Sprintf("BMC CA certificate is empty %s",
+		e.message)
+}
+
 // NoDataInSecretError is returned when host configuration
 // data were not found in referenced secret
 type NoDataInSecretError struct {
@@ -47,3 +69,14 @@ type NoDataInSecretError struct {
 func (e NoDataInSecretError) Error() string {
 	return fmt.Sprintf("Secret %s does not contain key %s", e.secret, e.key)
 }
+
+// UserDataTemplateError is returned when the user data template of a
+// host cannot be rendered
+type UserDataTemplateError struct {
+	secret string
+	err    error
+}
+
+func (e *UserDataTemplateError) Error() string {
+	return fmt.Sprintf("Failed to render the user data template of Secret %s: %s", e.secret, e.err)
+}
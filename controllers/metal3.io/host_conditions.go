@@ -0,0 +1,233 @@
+package controllers
+
+import (
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+const (
+	reasonNotRegistered                 conditionReason = "NotRegistered"
+	reasonNoBMCDetails                  conditionReason = "NoBMCDetails"
+	reasonRegistering                   conditionReason = "Registering"
+	reasonRegistered                    conditionReason = "Registered"
+	reasonRegistrationFailed            conditionReason = "RegistrationFailed"
+	reasonProvisionedRegistrationFailed conditionReason = "ProvisionedRegistrationFailed"
+	reasonDeleting                      conditionReason = "Deleting"
+
+	reasonCredentialsNotApplicable conditionReason = "CredentialsNotApplicable"
+	reasonCredentialsNotValidated  conditionReason = "CredentialsNotValidated"
+	reasonCredentialsValidated     conditionReason = "CredentialsValidated"
+
+	reasonNotInspected       conditionReason = "NotInspected"
+	reasonInspecting         conditionReason = "Inspecting"
+	reasonInspected          conditionReason = "Inspected"
+	reasonInspectionDisabled conditionReason = "InspectionDisabled"
+	reasonInspectionFailed   conditionReason = "InspectionFailed"
+
+	reasonNotPrepared       conditionReason = "NotPrepared"
+	reasonPreparing         conditionReason = "Preparing"
+	reasonPrepared          conditionReason = "Prepared"
+	reasonPreparationFailed conditionReason = "PreparationFailed"
+
+	reasonFirmwareUpdateFailed conditionReason = "FirmwareUpdateFailed"
+
+	reasonNotProvisioned        conditionReason = "NotProvisioned"
+	reasonProvisioning          conditionReason = "Provisioning"
+	reasonProvisioned           conditionReason = "Provisioned"
+	reasonExternallyProvisioned conditionReason = "ExternallyProvisioned"
+	reasonProvisioningFailed    conditionReason = "ProvisioningFailed"
+	reasonUserDataFailed        conditionReason = "UserDataFailed"
+	reasonDeprovisioning        conditionReason = "Deprovisioning"
+	reasonServicing             conditionReason = "Servicing"
+	reasonServicingFailed       conditionReason = "ServicingFailed"
+
+	reasonPowerStateNotManaged    conditionReason = "PowerStateNotManaged"
+	reasonPowerStateChangePending conditionReason = "PowerStateChangePending"
+	reasonPowerStateMatches       conditionReason = "PowerStateMatches"
+	reasonPowerManagementFailed   conditionReason = "PowerManagementFailed"
+	reasonPoweredOffByPolicy      conditionReason = "PoweredOffByPolicy"
+)
+
+type hostCondition struct {
+	status  metav1.ConditionStatus
+	reason  conditionReason
+	message string
+}
+
+func conditionTrue(reason conditionReason) hostCondition {
+	return hostCondition{status: metav1.ConditionTrue, reason: reason}
+}
+
+func conditionFalse(reason conditionReason) hostCondition {
+	return hostCondition{status: metav1.ConditionFalse, reason: reason}
+}
+
+func conditionUnknown(reason conditionReason) hostCondition {
+	return hostCondition{status: metav1.ConditionUnknown, reason: reason}
+}
+
+// hostConditionFailed reports a failure using the error message
+// currently saved in the host status.
+func hostConditionFailed(host *metal3.BareMetalHost, reason conditionReason) hostCondition {
+	return hostCondition{
+		status:  metav1.ConditionFalse,
+		reason:  reason,
+		message: host.Status.ErrorMessage,
+	}
+}
+
+func registeredCondition(host *metal3.BareMetalHost) hostCondition {
+	switch host.Status.ErrorType {
+	case metal3.RegistrationError:
+		return hostConditionFailed(host, reasonRegistrationFailed)
+	case metal3.ProvisionedRegistrationError:
+		return hostConditionFailed(host, reasonProvisionedRegistrationFailed)
+	}
+
+	switch host.Status.Provisioning.State {
+	case metal3.StateNone:
+		return conditionFalse(reasonNotRegistered)
+	case metal3.StateUnmanaged:
+		return conditionFalse(reasonNoBMCDetails)
+	case metal3.StateRegistering:
+		return conditionFalse(reasonRegistering)
+	case metal3.StateDeleting:
+		return conditionFalse(reasonDeleting)
+	}
+	return conditionTrue(reasonRegistered)
+}
+
+func credentialsValidCondition(host *metal3.BareMetalHost) hostCondition {
+	switch host.Status.Provisioning.State {
+	case metal3.StateNone, metal3.StateUnmanaged:
+		return conditionUnknown(reasonCredentialsNotApplicable)
+	}
+
+	if host.Status.ErrorType == metal3.RegistrationError {
+		return hostConditionFailed(host, reasonRegistrationFailed)
+	}
+
+	good := host.Status.GoodCredentials.Reference
+	if good != nil && good.Name == host.Spec.BMC.CredentialsName {
+		return conditionTrue(reasonCredentialsValidated)
+	}
+	return conditionUnknown(reasonCredentialsNotValidated)
+}
+
+func inspectedCondition(host *metal3.BareMetalHost) hostCondition {
+	switch {
+	case host.Status.ErrorType == metal3.InspectionError:
+		return hostConditionFailed(host, reasonInspectionFailed)
+	case host.Status.Provisioning.State == metal3.StateInspecting:
+		return conditionFalse(reasonInspecting)
+	case host.Status.HardwareDetails != nil:
+		return conditionTrue(reasonInspected)
+	case inspectionDisabled(host):
+		return conditionTrue(reasonInspectionDisabled)
+	}
+	return conditionFalse(reasonNotInspected)
+}
+
+func preparedCondition(host *metal3.BareMetalHost) hostCondition {
+	switch host.Status.ErrorType {
+	case metal3.PreparationError:
+		return hostConditionFailed(host, reasonPreparationFailed)
+	case metal3.FirmwareUpdateError:
+		return hostConditionFailed(host, reasonFirmwareUpdateFailed)
+	}
+
+	switch host.Status.Provisioning.State {
+	case metal3.StatePreparing:
+		return conditionFalse(reasonPreparing)
+	case metal3.StateReady, metal3.StateAvailable,
+		metal3.StateProvisioning, metal3.StateProvisioned,
+		metal3.StateServicing, metal3.StateDeprovisioning:
+		return conditionTrue(reasonPrepared)
+	}
+	return conditionFalse(reasonNotPrepared)
+}
+
+func provisionedCondition(host *metal3.BareMetalHost) hostCondition {
+	switch host.Status.ErrorType {
+	case metal3.ProvisioningError:
+		return hostConditionFailed(host, reasonProvisioningFailed)
+	case metal3.UserDataError:
+		return hostConditionFailed(host, reasonUserDataFailed)
+	}
+
+	switch host.Status.Provisioning.State {
+	case metal3.StateProvisioning:
+		return conditionFalse(reasonProvisioning)
+	case metal3.StateProvisioned:
+		return conditionTrue(reasonProvisioned)
+	case metal3.StateServicing:
+		// The image remains on the host while it is serviced
+		if host.Status.ErrorType == metal3.ServicingError ||
+			host.Status.ErrorType == metal3.FirmwareUpdateError {
+			return hostCondition{
+				status:  metav1.ConditionTrue,
+				reason:  reasonServicingFailed,
+				message: host.Status.ErrorMessage,
+			}
+		}
+		return conditionTrue(reasonServicing)
+	case metal3.StateExternallyProvisioned:
+		return conditionTrue(reasonExternallyProvisioned)
+	case metal3.StateDeprovisioning:
+		return conditionFalse(reasonDeprovisioning)
+	}
+	return conditionFalse(reasonNotProvisioned)
+}
+
+func poweredOnMatchesSpecCondition(host *metal3.BareMetalHost) hostCondition {
+	switch host.Status.Provisioning.State {
+	case metal3.StateNone, metal3.StateUnmanaged,
+		metal3.StateRegistering, metal3.StateDeleting:
+		return conditionUnknown(reasonPowerStateNotManaged)
+	}
+
+	switch {
+	case host.Status.ErrorType == metal3.PowerManagementError:
+		return hostConditionFailed(host, reasonPowerManagementFailed)
+	case host.Status.PoweredOn == host.Spec.Online:
+		return conditionTrue(reasonPowerStateMatches)
+	case !host.Status.PoweredOn && poweredOffByPolicy(host):
+		return conditionTrue(reasonPoweredOffByPolicy)
+	}
+	return conditionFalse(reasonPowerStateChangePending)
+}
+
+// updateHostConditions recalculates the conditions in the host status
+// from its provisioning state, error and power status. It returns
+// true if any of the conditions changed.
+func updateHostConditions(host *metal3.BareMetalHost) bool {
+	conditions := []struct {
+		condType metal3.HostStatusConditionType
+		hostCondition
+	}{
+		{metal3.ConditionRegistered, registeredCondition(host)},
+		{metal3.ConditionCredentialsValid, credentialsValidCondition(host)},
+		{metal3.ConditionInspected, inspectedCondition(host)},
+		{metal3.ConditionPrepared, preparedCondition(host)},
+		{metal3.ConditionProvisioned, provisionedCondition(host)},
+		{metal3.ConditionPoweredOnMatchesSpec, poweredOnMatchesSpecCondition(host)},
+	}
+
+	oldConditions := host.Status.DeepCopy().Conditions
+	now := metav1.Now()
+	for _, cond := range conditions {
+		meta.SetStatusCondition(&host.Status.Conditions, metav1.Condition{
+			Type:               string(cond.condType),
+			Status:             cond.status,
+			LastTransitionTime: now,
+			ObservedGeneration: host.Generation,
+			Reason:             string(cond.reason),
+			Message:            cond.message,
+		})
+	}
+
+	return !apiequality.Semantic.DeepEqual(oldConditions, host.Status.Conditions)
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+func TestUpdateHostConditions(t *testing.T) {
+	testCases := []struct {
+		Scenario string
+		Host     *metal3v1alpha1.BareMetalHost
+		Expected map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus
+	}{
+		{
+			Scenario: "unmanaged",
+			Host:     host(metal3v1alpha1.StateUnmanaged).build(),
+			Expected: map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus{
+				metal3v1alpha1.ConditionRegistered:           metav1.ConditionFalse,
+				metal3v1alpha1.ConditionCredentialsValid:     metav1.ConditionUnknown,
+				metal3v1alpha1.ConditionInspected:            metav1.ConditionFalse,
+				metal3v1alpha1.ConditionPrepared:             metav1.ConditionFalse,
+				metal3v1alpha1.ConditionProvisioned:          metav1.ConditionFalse,
+				metal3v1alpha1.ConditionPoweredOnMatchesSpec: metav1.ConditionUnknown,
+			},
+		},
+		{
+			Scenario: "registration error",
+			Host: host(metal3v1alpha1.StateRegistering).
+				SetStatusError(metal3v1alpha1.OperationalStatusError, metal3v1alpha1.RegistrationError, "bad creds", 1).
+				build(),
+			Expected: map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus{
+				metal3v1alpha1.ConditionRegistered:       metav1.ConditionFalse,
+				metal3v1alpha1.ConditionCredentialsValid: metav1.ConditionFalse,
+			},
+		},
+		{
+			Scenario: "inspecting",
+			Host:     host(metal3v1alpha1.StateInspecting).build(),
+			Expected: map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus{
+				metal3v1alpha1.ConditionRegistered: metav1.ConditionTrue,
+				metal3v1alpha1.ConditionInspected:  metav1.ConditionFalse,
+			},
+		},
+		{
+			Scenario: "preparation error",
+			Host: host(metal3v1alpha1.StatePreparing).
+				SetStatusError(metal3v1alpha1.OperationalStatusError, metal3v1alpha1.PreparationError, "no RAID", 1).
+				build(),
+			Expected: map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus{
+				metal3v1alpha1.ConditionRegistered: metav1.ConditionTrue,
+				metal3v1alpha1.ConditionPrepared:   metav1.ConditionFalse,
+			},
+		},
+		{
+			Scenario: "ready",
+			Host:     host(metal3v1alpha1.StateReady).build(),
+			Expected: map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus{
+				metal3v1alpha1.ConditionPrepared:    metav1.ConditionTrue,
+				metal3v1alpha1.ConditionProvisioned: metav1.ConditionFalse,
+			},
+		},
+		{
+			Scenario: "provisioned",
+			Host:     host(metal3v1alpha1.StateProvisioned).build(),
+			Expected: map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus{
+				metal3v1alpha1.ConditionRegistered:           metav1.ConditionTrue,
+				metal3v1alpha1.ConditionPrepared:             metav1.ConditionTrue,
+				metal3v1alpha1.ConditionProvisioned:          metav1.ConditionTrue,
+				metal3v1alpha1.ConditionPoweredOnMatchesSpec: metav1.ConditionTrue,
+			},
+		},
+		{
+			Scenario: "servicing error",
+			Host: host(metal3v1alpha1.StateServicing).
+				SetStatusError(metal3v1alpha1.OperationalStatusError, metal3v1alpha1.ServicingError, "BIOS settings not applied", 1).
+				build(),
+			Expected: map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus{
+				metal3v1alpha1.ConditionPrepared:    metav1.ConditionTrue,
+				metal3v1alpha1.ConditionProvisioned: metav1.ConditionTrue,
+			},
+		},
+		{
+			Scenario: "externally provisioned",
+			Host:     host(metal3v1alpha1.StateExternallyProvisioned).SetExternallyProvisioned().build(),
+			Expected: map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus{
+				metal3v1alpha1.ConditionProvisioned: metav1.ConditionTrue,
+			},
+		},
+		{
+			Scenario: "power change pending",
+			Host:     host(metal3v1alpha1.StateProvisioned).SetOnline(false).build(),
+			Expected: map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus{
+				metal3v1alpha1.ConditionPoweredOnMatchesSpec: metav1.ConditionFalse,
+			},
+		},
+		{
+			Scenario: "power management error",
+			Host: host(metal3v1alpha1.StateProvisioned).
+				SetStatusError(metal3v1alpha1.OperationalStatusError, metal3v1alpha1.PowerManagementError, "BMC unreachable", 1).
+				build(),
+			Expected: map[metal3v1alpha1.HostStatusConditionType]metav1.ConditionStatus{
+				metal3v1alpha1.ConditionProvisioned:          metav1.ConditionTrue,
+				metal3v1alpha1.ConditionPoweredOnMatchesSpec: metav1.ConditionFalse,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			assert.True(t, updateHostConditions(tc.Host))
+			assert.Len(t, tc.Host.Status.Conditions, 6)
+
+			for condType, status := range tc.Expected {
+				cond := meta.FindStatusCondition(tc.Host.Status.Conditions, string(condType))
+				if assert.NotNil(t, cond, condType) {
+					assert.Equal(t, status, cond.Status, condType)
+					if status == metav1.ConditionFalse && tc.Host.Status.ErrorMessage != "" &&
+						cond.Message != "" {
+						assert.Equal(t, tc.Host.Status.ErrorMessage, cond.Message)
+					}
+				}
+			}
+
+			assert.False(t, updateHostConditions(tc.Host), "conditions changed without a change to the host")
+		})
+	}
+}
+
+func TestConditionsSavedInSteadyState(t *testing.T) {
+	host := host(metal3v1alpha1.StateProvisioned).build()
+	prov := newMockProvisioner()
+	hsm := newHostStateMachine(host, &BareMetalHostReconciler{}, prov, true)
+	info := makeDefaultReconcileInfo(host)
+
+	result := hsm.ReconcileState(info)
+	assert.True(t, result.Dirty(), "new conditions not saved")
+	assert.NotEmpty(t, host.Status.Conditions)
+
+	result = hsm.ReconcileState(info)
+	assert.False(t, result.Dirty())
+}
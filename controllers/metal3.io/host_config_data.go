@@ -21,16 +21,23 @@ type hostConfigData struct {
 // parameter to detirmine which data to return in case secret contins multiple
 // keys
 func (hcd *hostConfigData) getSecretData(name, namespace, dataKey string) (string, error) {
+	secret, err := hcd.getSecret(name, namespace)
+	if err != nil {
+		return "", err
+	}
+	return secretData(secret, dataKey)
+}
+
+func (hcd *hostConfigData) getSecret(name, namespace string) (*corev1.Secret, error) {
 	key := types.NamespacedName{
 		Name:      name,
 		Namespace: namespace,
 	}
 
-	secret, err := hcd.secretManager.ObtainSecret(key)
-	if err != nil {
-		return "", err
-	}
+	return hcd.secretManager.ObtainSecret(key)
+}
 
+func secretData(secret *corev1.Secret, dataKey string) (string, error) {
 	data, ok := secret.Data[dataKey]
 	if ok {
 		return string(data), nil
@@ -39,7 +46,7 @@ func (hcd *hostConfigData) getSecretData(name, namespace, dataKey string) (strin
 	// Tring to falback to 'value' key
 	if data, ok = secret.Data["value"]; !ok {
 		hostConfigDataError.WithLabelValues(dataKey).Inc()
-		return "", NoDataInSecretError{secret: name, key: dataKey}
+		return "", NoDataInSecretError{secret: secret.Name, key: dataKey}
 	}
 
 	return string(data), nil
@@ -55,12 +62,21 @@ func (hcd *hostConfigData) UserData() (string, error) {
 	if namespace == "" {
 		namespace = hcd.host.Namespace
 	}
-	return hcd.getSecretData(
-		hcd.host.Spec.UserData.Name,
-		namespace,
-		"userData",
-	)
+	secret, err := hcd.getSecret(hcd.host.Spec.UserData.Name, namespace)
+	if err != nil {
+		return "", err
+	}
+	userData, err := secretData(secret, "userData")
+	if err != nil || secret.Annotations[metal3v1alpha1.UserDataTemplateAnnotation] != "true" {
+		return userData, err
+	}
 
+	rendered, err := renderUserData(hcd.host, userData)
+	if err != nil {
+		hostConfigDataError.WithLabelValues("userData").Inc()
+		return "", &UserDataTemplateError{secret: secret.Name, err: err}
+	}
+	return rendered, nil
 }
 
 // NetworkData get network configuration
@@ -102,3 +118,15 @@ func (hcd *hostConfigData) MetaData() (string, error) {
 		"metaData",
 	)
 }
+
+// ImagePullSecret get the registry credentials of an OCI image
+func (hcd *hostConfigData) ImagePullSecret() (string, error) {
+	if hcd.host.Spec.Image == nil || hcd.host.Spec.Image.PullSecretName == "" {
+		return "", nil
+	}
+	return hcd.getSecretData(
+		hcd.host.Spec.Image.PullSecretName,
+		hcd.host.Namespace,
+		corev1.DockerConfigJsonKey,
+	)
+}
@@ -62,6 +62,18 @@ func TestLabelSecrets(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "pull-secret",
+			getter: func(hcd *hostConfigData) (string, error) {
+				return hcd.ImagePullSecret()
+			},
+			hostSpec: &metal3v1alpha1.BareMetalHostSpec{
+				Image: &metal3v1alpha1.Image{
+					URL:            "oci://quay.io/example/os:1.0",
+					PullSecretName: "pull-secret",
+				},
+			},
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
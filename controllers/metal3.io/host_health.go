@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+)
+
+// healthMetricValue is the value of the health gauges for each status.
+var healthMetricValue = map[metal3v1alpha1.HealthStatus]float64{
+	metal3v1alpha1.HealthOK:       0,
+	metal3v1alpha1.HealthWarning:  1,
+	metal3v1alpha1.HealthCritical: 2,
+}
+
+// collectHealth collects the health of the hardware once the health
+// poll interval elapsed, and returns whether the status of the host
+// changed. Failing to collect the health is not an error, the previous
+// health is kept until the next collection.
+func (r *BareMetalHostReconciler) collectHealth(prov provisioner.Provisioner, info *reconcileInfo) bool {
+	if !r.healthSchedule.due(info.request.NamespacedName, r.healthPollInterval(), time.Now()) {
+		return false
+	}
+	health, err := prov.GetHardwareHealth()
+	if err != nil {
+		info.log.Info("failed to collect the hardware health", "error", err.Error())
+		return false
+	}
+	return updateHealth(info, health)
+}
+
+func hostHealthFromHardware(health *provisioner.HardwareHealth) *metal3v1alpha1.HostHealth {
+	status := &metal3v1alpha1.HostHealth{Status: health.Status}
+	for _, c := range health.Components {
+		status.Components = append(status.Components, metal3v1alpha1.ComponentHealth{
+			Name:   c.Name,
+			Type:   c.Type,
+			Status: c.Status,
+		})
+	}
+	return status
+}
+
+// updateHealth records the hardware health in the status of the host
+// and in the metrics, and publishes an event for each component that
+// became Critical. It returns whether the status changed.
+func updateHealth(info *reconcileInfo, health *provisioner.HardwareHealth) bool {
+	if health == nil {
+		// The provisioner does not collect the health, or failed to
+		return false
+	}
+
+	previous := info.host.Status.Health
+	current := hostHealthFromHardware(health)
+	setHealthMetrics(info.request, previous, health)
+
+	if apiequality.Semantic.DeepEqual(previous, current) {
+		return false
+	}
+
+	wasCritical := map[metal3v1alpha1.ComponentHealth]bool{}
+	if previous != nil {
+		for _, c := range previous.Components {
+			if c.Status == metal3v1alpha1.HealthCritical {
+				wasCritical[c] = true
+			}
+		}
+	}
+	for _, c := range current.Components {
+		if c.Status == metal3v1alpha1.HealthCritical && !wasCritical[c] {
+			publishHealthEvent(info, "ComponentHealthCritical",
+				fmt.Sprintf("%s %s is %s", c.Type, c.Name, c.Status))
+		}
+	}
+	if current.Status == metal3v1alpha1.HealthCritical &&
+		(previous == nil || previous.Status != metal3v1alpha1.HealthCritical) {
+		publishHealthEvent(info, "HardwareHealthCritical", "The hardware health is Critical")
+	}
+
+	info.log.Info("updating hardware health", "status", current.Status)
+	info.host.Status.Health = current
+	return true
+}
+
+func publishHealthEvent(info *reconcileInfo, reason, message string) {
+	event := info.host.NewEvent(reason, message)
+	event.Type = corev1.EventTypeWarning
+	info.events = append(info.events, event)
+}
+
+// setHealthMetrics updates the health gauges of a host, removing the
+// gauges of the components that are no longer reported.
+func setHealthMetrics(request ctrl.Request, previous *metal3v1alpha1.HostHealth, health *provisioner.HardwareHealth) {
+	reported := map[metal3v1alpha1.ComponentHealth]bool{}
+	for _, c := range health.Components {
+		reported[metal3v1alpha1.ComponentHealth{Name: c.Name, Type: c.Type}] = true
+	}
+	if previous != nil {
+		var gone []metal3v1alpha1.ComponentHealth
+		for _, c := range previous.Components {
+			if !reported[metal3v1alpha1.ComponentHealth{Name: c.Name, Type: c.Type}] {
+				gone = append(gone, c)
+			}
+		}
+		deleteComponentMetrics(request, gone)
+	}
+
+	hostHealthStatus.With(hostMetricLabels(request)).Set(healthMetricValue[health.Status])
+	for _, c := range health.Components {
+		componentHealthStatus.With(componentMetricLabels(request, c.Name, c.Type)).Set(healthMetricValue[c.Status])
+		if c.Reading != nil {
+			sensorReading.With(sensorMetricLabels(request, c.Name, c.Type, c.Units)).Set(*c.Reading)
+		}
+	}
+}
+
+func deleteComponentMetrics(request ctrl.Request, components []metal3v1alpha1.ComponentHealth) {
+	for _, c := range components {
+		componentHealthStatus.Delete(componentMetricLabels(request, c.Name, c.Type))
+		for _, units := range provisioner.AllSensorUnits {
+			sensorReading.Delete(sensorMetricLabels(request, c.Name, c.Type, units))
+		}
+	}
+}
+
+// deleteHealthMetrics removes the health gauges of a deleted host.
+func deleteHealthMetrics(info *reconcileInfo) {
+	if info.host.Status.Health == nil {
+		return
+	}
+	hostHealthStatus.Delete(hostMetricLabels(info.request))
+	deleteComponentMetrics(info.request, info.host.Status.Health.Components)
+}
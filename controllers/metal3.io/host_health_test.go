@@ -0,0 +1,94 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+)
+
+func TestUpdateHealth(t *testing.T) {
+	host := newDefaultHost(t)
+	info := makeReconcileInfo(host)
+	info.request = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: host.Namespace, Name: host.Name}}
+
+	reading := 5400.0
+	health := &provisioner.HardwareHealth{
+		Status: metal3v1alpha1.HealthOK,
+		Components: []provisioner.ComponentHealth{
+			{Name: "Fan1", Type: metal3v1alpha1.ComponentFan, Status: metal3v1alpha1.HealthOK,
+				Reading: &reading, Units: provisioner.UnitsRPM},
+			{Name: "PS1", Type: metal3v1alpha1.ComponentPowerSupply, Status: metal3v1alpha1.HealthOK},
+		},
+	}
+
+	assert.False(t, updateHealth(info, nil))
+	assert.Nil(t, host.Status.Health)
+
+	assert.True(t, updateHealth(info, health))
+	assert.Equal(t, metal3v1alpha1.HealthOK, host.Status.Health.Status)
+	assert.Len(t, host.Status.Health.Components, 2)
+	assert.Empty(t, info.events)
+	assert.Equal(t, 5400.0, testutil.ToFloat64(sensorReading.With(
+		sensorMetricLabels(info.request, "Fan1", metal3v1alpha1.ComponentFan, provisioner.UnitsRPM))))
+
+	reading = 5300
+	assert.False(t, updateHealth(info, health))
+	assert.Equal(t, 5300.0, testutil.ToFloat64(sensorReading.With(
+		sensorMetricLabels(info.request, "Fan1", metal3v1alpha1.ComponentFan, provisioner.UnitsRPM))))
+
+	health.Status = metal3v1alpha1.HealthCritical
+	health.Components[1].Status = metal3v1alpha1.HealthCritical
+	assert.True(t, updateHealth(info, health))
+	assert.Equal(t, metal3v1alpha1.HealthCritical, host.Status.Health.Status)
+	assert.Equal(t, 2.0, testutil.ToFloat64(hostHealthStatus.With(hostMetricLabels(info.request))))
+	assert.Equal(t, 2.0, testutil.ToFloat64(componentHealthStatus.With(
+		componentMetricLabels(info.request, "PS1", metal3v1alpha1.ComponentPowerSupply))))
+	if assert.Len(t, info.events, 2) {
+		assert.Equal(t, "ComponentHealthCritical", info.events[0].Reason)
+		assert.Equal(t, "PowerSupply PS1 is Critical", info.events[0].Message)
+		assert.Equal(t, "HardwareHealthCritical", info.events[1].Reason)
+		assert.Equal(t, corev1.EventTypeWarning, info.events[1].Type)
+	}
+
+	// No new event while the component stays Critical
+	health.Components = health.Components[1:]
+	assert.True(t, updateHealth(info, health))
+	assert.Len(t, info.events, 2)
+	assert.Len(t, host.Status.Health.Components, 1)
+	assert.Equal(t, 0, testutil.CollectAndCount(sensorReading))
+
+	deleteHealthMetrics(info)
+	assert.Equal(t, 0, testutil.CollectAndCount(componentHealthStatus))
+	assert.Equal(t, 0, testutil.CollectAndCount(hostHealthStatus))
+}
+
+func TestCollectHealth(t *testing.T) {
+	host := newDefaultHost(t)
+	info := makeReconcileInfo(host)
+	info.request = ctrl.Request{NamespacedName: types.NamespacedName{Namespace: host.Namespace, Name: host.Name}}
+	r := newTestReconciler()
+	r.HealthPollInterval = time.Hour
+	prov := newMockProvisioner()
+	prov.health = &provisioner.HardwareHealth{Status: metal3v1alpha1.HealthOK}
+
+	assert.True(t, r.collectHealth(prov, info))
+	assert.True(t, prov.calledNoError("GetHardwareHealth"))
+	assert.Equal(t, metal3v1alpha1.HealthOK, host.Status.Health.Status)
+
+	// Not collected again before the interval elapsed
+	prov.callsNoError = make(map[string]bool)
+	prov.health = &provisioner.HardwareHealth{Status: metal3v1alpha1.HealthWarning}
+	assert.False(t, r.collectHealth(prov, info))
+	assert.False(t, prov.calledNoError("GetHardwareHealth"))
+	assert.Equal(t, metal3v1alpha1.HealthOK, host.Status.Health.Status)
+
+	deleteHealthMetrics(info)
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/ociimage"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
 
 	"github.com/pkg/errors"
@@ -50,6 +51,7 @@ func (hsm *hostStateMachine) handlers() map[metal3v1alpha1.ProvisioningState]sta
 		metal3v1alpha1.StateReady:                 hsm.handleReady,
 		metal3v1alpha1.StateProvisioning:          hsm.handleProvisioning,
 		metal3v1alpha1.StateProvisioned:           hsm.handleProvisioned,
+		metal3v1alpha1.StateServicing:             hsm.handleServicing,
 		metal3v1alpha1.StateDeprovisioning:        hsm.handleDeprovisioning,
 		metal3v1alpha1.StateDeleting:              hsm.handleDeleting,
 	}
@@ -80,13 +82,14 @@ func recordStateEnd(info *reconcileInfo, host *metal3v1alpha1.BareMetalHost, sta
 }
 
 func (hsm *hostStateMachine) ensureCapacity(info *reconcileInfo, state metal3v1alpha1.ProvisioningState) actionResult {
-	hasCapacity, err := hsm.Provisioner.HasCapacity()
+	hasCapacity, reason, err := hsm.Provisioner.HasCapacity(state)
 	if err != nil {
 		return actionError{errors.Wrap(err, "failed to determine current provisioner capacity")}
 	}
 
 	if !hasCapacity {
-		return recordActionDelayed(info, state)
+		info.log.Info("delaying operation", "state", state, "reason", reason)
+		return recordActionDelayed(info, state, reason)
 	}
 
 	return nil
@@ -118,6 +121,7 @@ func (hsm *hostStateMachine) updateHostStateFrom(initialState metal3v1alpha1.Pro
 			stateChanges.With(stateChangeMetricLabels(initialState, hsm.NextState)).Inc()
 		})
 		hsm.Host.Status.Provisioning.State = hsm.NextState
+		clearPowerPolicyIdle(hsm.Host)
 		// Here we assume that if we're being asked to change the
 		// state, the return value of ReconcileState (our caller) is
 		// set up to ensure the change in the host is written back to
@@ -144,9 +148,14 @@ func (hsm *hostStateMachine) updateHostStateFrom(initialState metal3v1alpha1.Pro
 
 func (hsm *hostStateMachine) checkDelayedHost(info *reconcileInfo) actionResult {
 
-	// Check if there's a free slot for hosts that have been previously delayed
+	// Check if there's a free slot for hosts that have been previously
+	// delayed, for the operation they were waiting to start
 	if info.host.Status.OperationalStatus == metal3v1alpha1.OperationalStatusDelayed {
-		if actionRes := hsm.ensureCapacity(info, info.host.Status.Provisioning.State); actionRes != nil {
+		delayedState := info.host.Status.DelayedState
+		if delayedState == "" {
+			delayedState = info.host.Status.Provisioning.State
+		}
+		if actionRes := hsm.ensureCapacity(info, delayedState); actionRes != nil {
 			return actionRes
 		}
 
@@ -175,6 +184,11 @@ func (hsm *hostStateMachine) ReconcileState(info *reconcileInfo) (actionRes acti
 		if overrideAction := hsm.updateHostStateFrom(initialState, info); overrideAction != nil {
 			actionRes = overrideAction
 		}
+		// Make sure that changed conditions are written back even
+		// when the action itself had nothing new to save or failed.
+		if updateHostConditions(hsm.Host) {
+			actionRes = withStatusUpdate(actionRes)
+		}
 	}()
 
 	if delayedResult := hsm.checkDelayedHost(info); delayedResult != nil {
@@ -222,7 +236,8 @@ func (hsm *hostStateMachine) checkInitiateDelete() bool {
 	switch hsm.NextState {
 	default:
 		hsm.NextState = metal3v1alpha1.StateDeleting
-	case metal3v1alpha1.StateProvisioning, metal3v1alpha1.StateProvisioned:
+	case metal3v1alpha1.StateProvisioning, metal3v1alpha1.StateProvisioned,
+		metal3v1alpha1.StateServicing:
 		if hsm.Host.OperationalStatus() == metal3v1alpha1.OperationalStatusDetached {
 			hsm.NextState = metal3v1alpha1.StateDeleting
 		} else {
@@ -412,7 +427,7 @@ func (hsm *hostStateMachine) handleReady(info *reconcileInfo) actionResult {
 		return actionComplete{}
 	}
 
-	if dirty, _, err := getHostProvisioningSettings(info.host); err != nil {
+	if dirty, _, err := getHostProvisioningSettings(info.host, info.firmwareSettings); err != nil {
 		return actionError{err}
 	} else if dirty {
 		hsm.NextState = metal3v1alpha1.StatePreparing
@@ -437,7 +452,7 @@ func (hsm *hostStateMachine) provisioningCancelled() bool {
 		// could require a deprovisioning, but if it isn't set or empty then is fine
 		if hsm.Host.Status.Provisioning.Image.URL != "" &&
 			(hsm.Host.Spec.Image == nil ||
-				hsm.Host.Spec.Image.URL != hsm.Host.Status.Provisioning.Image.URL) {
+				!ociimage.IsPinnedFrom(hsm.Host.Status.Provisioning.Image.URL, hsm.Host.Spec.Image.URL)) {
 			return true
 		}
 
@@ -459,7 +474,8 @@ func (hsm *hostStateMachine) imageProvisioningCancelled() bool {
 	if hsm.Host.Status.Provisioning.Image.URL == "" {
 		return false
 	}
-	if hsm.Host.Spec.Image.URL != hsm.Host.Status.Provisioning.Image.URL {
+	// The URL of an OCI image is recorded pinned to a digest
+	if !ociimage.IsPinnedFrom(hsm.Host.Status.Provisioning.Image.URL, hsm.Host.Spec.Image.URL) {
 		return true
 	}
 	return false
@@ -485,10 +501,31 @@ func (hsm *hostStateMachine) handleProvisioned(info *reconcileInfo) actionResult
 		return actionComplete{}
 	}
 
+	if hsm.servicingRequested() {
+		hsm.NextState = metal3v1alpha1.StateServicing
+		return actionComplete{}
+	}
+
 	// ErrorCount is cleared when appropriate inside actionManageSteadyState
 	return hsm.Reconciler.actionManageSteadyState(hsm.Provisioner, info)
 }
 
+// servicingRequested returns true if servicing of a provisioned host
+// has been requested. Servicing reboots the host, so firmware changes
+// are left pending until it is explicitly requested.
+func (hsm *hostStateMachine) servicingRequested() bool {
+	return hasServicingAnnotation(hsm.Host)
+}
+
+func (hsm *hostStateMachine) handleServicing(info *reconcileInfo) actionResult {
+	actResult := hsm.Reconciler.actionServicing(hsm.Provisioner, info)
+	if _, complete := actResult.(actionComplete); complete {
+		hsm.NextState = metal3v1alpha1.StateProvisioned
+		hsm.Host.Status.ErrorCount = 0
+	}
+	return actResult
+}
+
 func (hsm *hostStateMachine) handleDeprovisioning(info *reconcileInfo) actionResult {
 	actResult := hsm.Reconciler.actionDeprovisioning(hsm.Provisioner, info)
 
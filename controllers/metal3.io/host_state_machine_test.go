@@ -136,6 +136,7 @@ func TestProvisioningCapacity(t *testing.T) {
 			assert.Equal(t, tc.ExpectedProvisioningState, tc.Host.Status.Provisioning.State)
 			assert.Equal(t, tc.ExpectedDelayed, metal3v1alpha1.OperationalStatusDelayed == tc.Host.Status.OperationalStatus, "Expected OperationalStatusDelayed")
 			assert.Equal(t, tc.ExpectedDelayed, assert.ObjectsAreEqual(actionDelayed{}, result), "Expected actionDelayed")
+			assert.Equal(t, tc.ExpectedDelayed, tc.Host.Status.DelayReason != "", "Expected delay reason")
 
 			if tc.ExpectedDelayed {
 				counter, _ := delayedProvisioningHostCounters.GetMetricWith(hostMetricLabels(info.request))
@@ -149,6 +150,56 @@ func TestProvisioningCapacity(t *testing.T) {
 	}
 }
 
+func TestDelayedHostCapacity(t *testing.T) {
+	host := host(metal3v1alpha1.StateReady).build()
+	host.Spec.Image = &metal3v1alpha1.Image{URL: "http://example.com/image.qcow2"}
+	prov := newMockProvisioner()
+	prov.setHasCapacity(false)
+	hsm := newHostStateMachine(host, &BareMetalHostReconciler{
+		Client: fakeclient.NewFakeClient(),
+	}, prov, true)
+
+	info := makeDefaultReconcileInfo(host)
+	hsm.ReconcileState(info)
+	assert.Equal(t, metal3v1alpha1.OperationalStatus(metal3v1alpha1.OperationalStatusDelayed), host.Status.OperationalStatus)
+	assert.Equal(t, metal3v1alpha1.StateProvisioning, host.Status.DelayedState)
+	assert.Len(t, info.postSaveCallbacks, 1)
+
+	// Still delayed: the capacity is checked for the provisioning
+	// operation and the delay is not counted again
+	info = makeDefaultReconcileInfo(host)
+	result := hsm.ReconcileState(info)
+	assert.Equal(t, actionDelayed{}, result)
+	assert.Equal(t, metal3v1alpha1.StateProvisioning, prov.capacityState)
+	assert.Equal(t, metal3v1alpha1.StateReady, host.Status.Provisioning.State)
+	assert.Empty(t, info.postSaveCallbacks)
+
+	prov.setHasCapacity(true)
+	info = makeDefaultReconcileInfo(host)
+	hsm.ReconcileState(info)
+	assert.Equal(t, metal3v1alpha1.OperationalStatus(metal3v1alpha1.OperationalStatusOK), host.Status.OperationalStatus)
+	assert.Empty(t, host.Status.DelayedState)
+	assert.Empty(t, host.Status.DelayReason)
+}
+
+func TestProvisioningPinnedImage(t *testing.T) {
+	reference := "oci://quay.io/example/os:1.0"
+	pinned := reference + "@sha256:60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+	host := host(metal3v1alpha1.StateProvisioning).build()
+	host.Spec.Image = &metal3v1alpha1.Image{URL: reference}
+	prov := newMockProvisioner()
+	hsm := newHostStateMachine(host, &BareMetalHostReconciler{}, prov, true)
+
+	prov.nextResults["Provision"] = provisioner.Result{ImageReference: pinned}
+	hsm.ReconcileState(makeDefaultReconcileInfo(host))
+	assert.Equal(t, metal3v1alpha1.StateProvisioned, host.Status.Provisioning.State)
+	assert.Equal(t, pinned, host.Status.Provisioning.Image.URL)
+
+	// The pinned image is the one requested
+	hsm.ReconcileState(makeDefaultReconcileInfo(host))
+	assert.Equal(t, metal3v1alpha1.StateProvisioned, host.Status.Provisioning.State)
+}
+
 func TestDeprovisioningCapacity(t *testing.T) {
 	testCases := []struct {
 		Scenario string
@@ -192,6 +243,7 @@ func TestDeprovisioningCapacity(t *testing.T) {
 			assert.Equal(t, tc.ExpectedDeprovisioningState, tc.Host.Status.Provisioning.State)
 			assert.Equal(t, tc.ExpectedDelayed, metal3v1alpha1.OperationalStatusDelayed == tc.Host.Status.OperationalStatus, "Expected OperationalStatusDelayed")
 			assert.Equal(t, tc.ExpectedDelayed, assert.ObjectsAreEqual(actionDelayed{}, result), "Expected actionDelayed")
+			assert.Equal(t, tc.ExpectedDelayed, tc.Host.Status.DelayReason != "", "Expected delay reason")
 
 			if tc.ExpectedDelayed {
 				counter, _ := delayedDeprovisioningHostCounters.GetMetricWith(hostMetricLabels(info.request))
@@ -367,6 +419,9 @@ func TestDetach(t *testing.T) {
 					metal3v1alpha1.DetachedAnnotation: "true",
 				}
 			}
+			// Start from up to date conditions so that only the
+			// changes made by the state machine make the host dirty.
+			updateHostConditions(tc.Host)
 			prov := newMockProvisioner()
 			hsm := newHostStateMachine(tc.Host, &BareMetalHostReconciler{
 				Client: fakeclient.NewFakeClient(),
@@ -566,6 +621,54 @@ func TestProvisioningCancelled(t *testing.T) {
 			Expected: false,
 		},
 
+		{
+			Scenario: "provisioned with pinned OCI image",
+			Host: metal3v1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "myhost",
+					Namespace: "myns",
+				},
+				Spec: metal3v1alpha1.BareMetalHostSpec{
+					Image: &metal3v1alpha1.Image{
+						URL: "oci://quay.io/example/os:1.0",
+					},
+					Online: true,
+				},
+				Status: metal3v1alpha1.BareMetalHostStatus{
+					Provisioning: metal3v1alpha1.ProvisionStatus{
+						Image: metal3v1alpha1.Image{
+							URL: "oci://quay.io/example/os:1.0@sha256:60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752",
+						},
+					},
+				},
+			},
+			Expected: false,
+		},
+
+		{
+			Scenario: "provisioned with OCI image, tag changed",
+			Host: metal3v1alpha1.BareMetalHost{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "myhost",
+					Namespace: "myns",
+				},
+				Spec: metal3v1alpha1.BareMetalHostSpec{
+					Image: &metal3v1alpha1.Image{
+						URL: "oci://quay.io/example/os:1.1",
+					},
+					Online: true,
+				},
+				Status: metal3v1alpha1.BareMetalHostStatus{
+					Provisioning: metal3v1alpha1.ProvisionStatus{
+						Image: metal3v1alpha1.Image{
+							URL: "oci://quay.io/example/os:1.0@sha256:60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752",
+						},
+					},
+				},
+			},
+			Expected: true,
+		},
+
 		{
 			Scenario: "provisioned with error",
 			Host: metal3v1alpha1.BareMetalHost{
@@ -875,6 +978,180 @@ func TestErrorCountIncreasedOnActionFailure(t *testing.T) {
 	}
 }
 
+func TestPrepareFirmwareUpdateError(t *testing.T) {
+	host := host(metal3v1alpha1.StatePreparing).build()
+	prov := newMockProvisioner()
+	hsm := newHostStateMachine(host, &BareMetalHostReconciler{
+		Client: fakeclient.NewFakeClient(),
+	}, prov, true)
+	info := makeDefaultReconcileInfo(host)
+
+	prov.nextResults["Prepare"] = provisioner.Result{
+		ErrorMessage: "image not found",
+		ErrorType:    metal3v1alpha1.FirmwareUpdateError,
+	}
+	result := hsm.ReconcileState(info)
+
+	assert.True(t, result.Dirty())
+	assert.Equal(t, metal3v1alpha1.FirmwareUpdateError, host.Status.ErrorType)
+	assert.Equal(t, "image not found", host.Status.ErrorMessage)
+	assert.Equal(t, metal3v1alpha1.StatePreparing, host.Status.Provisioning.State)
+}
+
+func TestServicingRequested(t *testing.T) {
+	tests := []struct {
+		Scenario         string
+		Host             *metal3v1alpha1.BareMetalHost
+		FirmwareSettings map[string]string
+		ExpectedState    metal3v1alpha1.ProvisioningState
+	}{
+		{
+			Scenario:      "no changes",
+			Host:          host(metal3v1alpha1.StateProvisioned).build(),
+			ExpectedState: metal3v1alpha1.StateProvisioned,
+		},
+		{
+			Scenario: "firmware settings changed",
+			Host: func() *metal3v1alpha1.BareMetalHost {
+				h := host(metal3v1alpha1.StateProvisioned).build()
+				h.Spec.Firmware = &metal3v1alpha1.FirmwareConfig{
+					Settings: map[string]string{"ProcVirtualization": "Disabled"},
+				}
+				return h
+			}(),
+			ExpectedState: metal3v1alpha1.StateProvisioned,
+		},
+		{
+			Scenario:         "host firmware settings changed",
+			Host:             host(metal3v1alpha1.StateProvisioned).build(),
+			FirmwareSettings: map[string]string{"ProcVirtualization": "Disabled"},
+			ExpectedState:    metal3v1alpha1.StateProvisioned,
+		},
+		{
+			Scenario: "firmware updates changed",
+			Host: func() *metal3v1alpha1.BareMetalHost {
+				h := host(metal3v1alpha1.StateProvisioned).build()
+				h.Spec.FirmwareUpdates = []metal3v1alpha1.FirmwareUpdate{
+					{Component: metal3v1alpha1.FirmwareComponentBIOS, URL: "http://example.com/bios.bin"},
+				}
+				return h
+			}(),
+			ExpectedState: metal3v1alpha1.StateProvisioned,
+		},
+		{
+			Scenario: "firmware changes with servicing requested",
+			Host: func() *metal3v1alpha1.BareMetalHost {
+				h := host(metal3v1alpha1.StateProvisioned).build()
+				h.Annotations = map[string]string{servicingAnnotation: ""}
+				h.Spec.Firmware = &metal3v1alpha1.FirmwareConfig{
+					Settings: map[string]string{"ProcVirtualization": "Disabled"},
+				}
+				return h
+			}(),
+			ExpectedState: metal3v1alpha1.StateServicing,
+		},
+		{
+			Scenario: "reboot into agent requested",
+			Host: func() *metal3v1alpha1.BareMetalHost {
+				h := host(metal3v1alpha1.StateProvisioned).build()
+				h.Annotations = map[string]string{servicingAnnotation: ""}
+				return h
+			}(),
+			ExpectedState: metal3v1alpha1.StateServicing,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.Scenario, func(t *testing.T) {
+			prov := newMockProvisioner()
+			hsm := newHostStateMachine(tt.Host, &BareMetalHostReconciler{}, prov, true)
+			info := makeDefaultReconcileInfo(tt.Host)
+			info.firmwareSettings = tt.FirmwareSettings
+
+			hsm.ReconcileState(info)
+
+			assert.Equal(t, tt.ExpectedState, tt.Host.Status.Provisioning.State)
+		})
+	}
+}
+
+func TestServicingError(t *testing.T) {
+	host := host(metal3v1alpha1.StateServicing).build()
+	host.Spec.Firmware = &metal3v1alpha1.FirmwareConfig{
+		Settings: map[string]string{"ProcVirtualization": "Disabled"},
+	}
+	host.Status.Provisioning.Firmware = host.Spec.Firmware.DeepCopy()
+	prov := newMockProvisioner()
+	hsm := newHostStateMachine(host, &BareMetalHostReconciler{}, prov, true)
+	info := makeDefaultReconcileInfo(host)
+
+	prov.nextResults["Service"] = provisioner.Result{
+		ErrorMessage: "BIOS settings not applied",
+	}
+	result := hsm.ReconcileState(info)
+
+	assert.True(t, result.Dirty())
+	assert.Equal(t, metal3v1alpha1.ServicingError, host.Status.ErrorType)
+	assert.Equal(t, "BIOS settings not applied", host.Status.ErrorMessage)
+	assert.Equal(t, metal3v1alpha1.StateServicing, host.Status.Provisioning.State)
+	assert.Nil(t, host.Status.Provisioning.Firmware, "settings not cleared for a retry")
+}
+
+func TestServicingFirmwareUpdateError(t *testing.T) {
+	host := host(metal3v1alpha1.StateServicing).build()
+	prov := newMockProvisioner()
+	hsm := newHostStateMachine(host, &BareMetalHostReconciler{}, prov, true)
+	info := makeDefaultReconcileInfo(host)
+
+	prov.nextResults["Service"] = provisioner.Result{
+		ErrorMessage: "image not found",
+		ErrorType:    metal3v1alpha1.FirmwareUpdateError,
+	}
+	hsm.ReconcileState(info)
+	assert.Equal(t, metal3v1alpha1.FirmwareUpdateError, host.Status.ErrorType)
+	assert.Equal(t, metal3v1alpha1.StateServicing, host.Status.Provisioning.State)
+
+	// The update is retried even though the spec did not change
+	delete(prov.nextResults, "Service")
+	prov.serviceRestart = false
+	hsm.ReconcileState(info)
+	assert.True(t, prov.serviceRestart)
+}
+
+func TestServicingDeleted(t *testing.T) {
+	host := host(metal3v1alpha1.StateServicing).setDeletion().build()
+	prov := newMockProvisioner()
+	hsm := newHostStateMachine(host, &BareMetalHostReconciler{}, prov, true)
+	info := makeDefaultReconcileInfo(host)
+
+	hsm.ReconcileState(info)
+
+	assert.Equal(t, metal3v1alpha1.StateDeprovisioning, host.Status.Provisioning.State)
+}
+
+func TestRegistrationRecordsBackend(t *testing.T) {
+	host := host(metal3v1alpha1.StateRegistering).build()
+	prov := newMockProvisioner()
+	prov.provID = "node-uuid"
+	prov.backend = "edge-1"
+	hsm := newHostStateMachine(host, &BareMetalHostReconciler{}, prov, true)
+	info := makeDefaultReconcileInfo(host)
+
+	result := hsm.ReconcileState(info)
+
+	// The backend is saved before the host is registered with it
+	assert.True(t, result.Dirty())
+	assert.Equal(t, "edge-1", host.Status.Provisioning.Backend)
+	assert.Empty(t, host.Status.Provisioning.ID)
+	assert.False(t, prov.calledNoError("ValidateManagementAccess"))
+
+	result = hsm.ReconcileState(info)
+
+	assert.True(t, result.Dirty())
+	assert.Equal(t, "node-uuid", host.Status.Provisioning.ID)
+	assert.Equal(t, "edge-1", host.Status.Provisioning.Backend)
+	assert.True(t, prov.calledNoError("ValidateManagementAccess"))
+}
+
 func TestErrorCountClearedOnStateTransition(t *testing.T) {
 
 	tests := []struct {
@@ -908,6 +1185,11 @@ func TestErrorCountClearedOnStateTransition(t *testing.T) {
 			Host:        host(metal3v1alpha1.StateProvisioning).build(),
 			TargetState: metal3v1alpha1.StateProvisioned,
 		},
+		{
+			Scenario:    "servicing-to-provisioned",
+			Host:        host(metal3v1alpha1.StateServicing).build(),
+			TargetState: metal3v1alpha1.StateProvisioned,
+		},
 		{
 			Scenario:    "deprovisioning-to-ready",
 			Host:        host(metal3v1alpha1.StateDeprovisioning).build(),
@@ -1031,7 +1313,7 @@ func (hb *hostBuilder) build() *metal3v1alpha1.BareMetalHost {
 }
 
 func (hb *hostBuilder) SaveHostProvisioningSettings() *hostBuilder {
-	saveHostProvisioningSettings(&hb.BareMetalHost)
+	saveHostProvisioningSettings(&hb.BareMetalHost, nil)
 	return hb
 }
 
@@ -1111,9 +1393,18 @@ func newMockProvisioner() *mockProvisioner {
 }
 
 type mockProvisioner struct {
-	hasCapacity  bool
-	nextResults  map[string]provisioner.Result
-	callsNoError map[string]bool
+	hasCapacity      bool
+	capacityState    metal3v1alpha1.ProvisioningState
+	provID           string
+	backend          string
+	nextResults      map[string]provisioner.Result
+	callsNoError     map[string]bool
+	firmwareSettings map[string]string
+	firmwareSchema   map[string]metal3v1alpha1.SettingSchema
+	firmwareVersions []metal3v1alpha1.FirmwareComponentStatus
+	provisionData    provisioner.ProvisionData
+	serviceRestart   bool
+	health           *provisioner.HardwareHealth
 }
 
 func (m *mockProvisioner) getNextResultByMethod(name string) (result provisioner.Result) {
@@ -1129,8 +1420,16 @@ func (m *mockProvisioner) setHasCapacity(hasCapacity bool) {
 	m.hasCapacity = hasCapacity
 }
 
-func (m *mockProvisioner) HasCapacity() (result bool, err error) {
-	return m.hasCapacity, nil
+func (m *mockProvisioner) HasCapacity(state metal3v1alpha1.ProvisioningState) (result bool, reason string, err error) {
+	m.capacityState = state
+	if !m.hasCapacity {
+		reason = "no capacity"
+	}
+	return m.hasCapacity, reason, nil
+}
+
+func (m *mockProvisioner) Backend() string {
+	return m.backend
 }
 
 func (m *mockProvisioner) setNextError(methodName, msg string) {
@@ -1148,7 +1447,7 @@ func (m *mockProvisioner) calledNoError(methodName string) bool {
 }
 
 func (m *mockProvisioner) ValidateManagementAccess(data provisioner.ManagementAccessData, credentialsChanged, force bool) (result provisioner.Result, provID string, err error) {
-	return m.getNextResultByMethod("ValidateManagementAccess"), "", err
+	return m.getNextResultByMethod("ValidateManagementAccess"), m.provID, err
 }
 
 func (m *mockProvisioner) PreprovisioningImageFormats() ([]metal3v1alpha1.ImageFormat, error) {
@@ -1164,15 +1463,37 @@ func (m *mockProvisioner) UpdateHardwareState() (hwState provisioner.HardwareSta
 	return
 }
 
+func (m *mockProvisioner) GetHardwareHealth() (health *provisioner.HardwareHealth, err error) {
+	m.callsNoError["GetHardwareHealth"] = true
+	return m.health, nil
+}
+
 func (m *mockProvisioner) Prepare(data provisioner.PrepareData, unprepared bool) (result provisioner.Result, started bool, err error) {
 	return m.getNextResultByMethod("Prepare"), m.nextResults["Prepare"].Dirty, err
 }
 
+func (m *mockProvisioner) Service(data provisioner.ServicingData, restart bool) (result provisioner.Result, started bool, err error) {
+	m.serviceRestart = restart
+	return m.getNextResultByMethod("Service"), m.nextResults["Service"].Dirty, err
+}
+
+func (m *mockProvisioner) GetFirmwareSettings(includeSchema bool) (settings map[string]string, schema map[string]metal3v1alpha1.SettingSchema, err error) {
+	if includeSchema {
+		schema = m.firmwareSchema
+	}
+	return m.firmwareSettings, schema, nil
+}
+
+func (m *mockProvisioner) GetFirmwareComponents() (components []metal3v1alpha1.FirmwareComponentStatus, err error) {
+	return m.firmwareVersions, nil
+}
+
 func (m *mockProvisioner) Adopt(data provisioner.AdoptData, force bool) (result provisioner.Result, err error) {
 	return m.getNextResultByMethod("Adopt"), err
 }
 
 func (m *mockProvisioner) Provision(data provisioner.ProvisionData) (result provisioner.Result, err error) {
+	m.provisionData = data
 	return m.getNextResultByMethod("Provision"), err
 }
 
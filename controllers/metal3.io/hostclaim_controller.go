@@ -0,0 +1,316 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/utils"
+)
+
+// How often unbound claims look for a matching host again
+const hostClaimRetryDelay = time.Minute
+
+const (
+	reasonHostBound      conditionReason = "HostBound"
+	reasonNoMatchingHost conditionReason = "NoMatchingHost"
+	reasonHostLost       conditionReason = "HostLost"
+	reasonInvalidClaim   conditionReason = "InvalidClaim"
+)
+
+// HostClaimReconciler reconciles a HostClaim object
+type HostClaimReconciler struct {
+	client.Client
+	Log logr.Logger
+}
+
+// +kubebuilder:rbac:groups=metal3.io,resources=hostclaims,verbs=get;list;watch;update;patch
+// +kubebuilder:rbac:groups=metal3.io,resources=hostclaims/status,verbs=get;update;patch
+
+func (r *HostClaimReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("hostclaim", req.NamespacedName)
+
+	claim := &metal3.HostClaim{}
+	err := r.Get(ctx, req.NamespacedName, claim)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			log.Info("HostClaim not found")
+			err = nil
+		}
+		return ctrl.Result{}, err
+	}
+
+	if !claim.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, r.releaseHost(ctx, log, claim)
+	}
+
+	if !utils.StringInList(claim.Finalizers, metal3.HostClaimFinalizer) {
+		log.Info("adding finalizer")
+		claim.Finalizers = append(claim.Finalizers, metal3.HostClaimFinalizer)
+		if err := r.Update(ctx, claim); err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "failed to add finalizer")
+		}
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	host, err := r.boundHost(ctx, claim)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	result := ctrl.Result{}
+	newStatus := claim.Status.DeepCopy()
+	switch {
+	case host != nil:
+		if err := r.syncHost(ctx, log, claim, host); err != nil {
+			return ctrl.Result{}, err
+		}
+		newStatus.HostName = host.Name
+		setHostClaimBoundCondition(claim, newStatus, metav1.ConditionTrue, reasonHostBound, "")
+	case claim.Status.HostName != "":
+		// Never rebind a claim, the consumer may rely on the host
+		setHostClaimBoundCondition(claim, newStatus, metav1.ConditionFalse, reasonHostLost,
+			"the bound host was deleted or released")
+	default:
+		host, err = r.bindHost(ctx, log, claim)
+		switch {
+		case errors.Is(err, errInvalidHostSelector):
+			setHostClaimBoundCondition(claim, newStatus, metav1.ConditionFalse, reasonInvalidClaim, err.Error())
+		case err != nil:
+			return ctrl.Result{}, err
+		case host == nil:
+			setHostClaimBoundCondition(claim, newStatus, metav1.ConditionFalse, reasonNoMatchingHost,
+				"no available host matches the claim")
+			result.RequeueAfter = hostClaimRetryDelay
+		default:
+			newStatus.HostName = host.Name
+			setHostClaimBoundCondition(claim, newStatus, metav1.ConditionTrue, reasonHostBound, "")
+		}
+	}
+
+	if !apiequality.Semantic.DeepEqual(claim.Status, *newStatus) {
+		log.Info("updating status")
+		claim.Status = *newStatus
+		err = r.Status().Update(ctx, claim)
+	}
+	return result, err
+}
+
+var errInvalidHostSelector = errors.New("invalid host selector")
+
+func setHostClaimBoundCondition(claim *metal3.HostClaim, status *metal3.HostClaimStatus,
+	newStatus metav1.ConditionStatus, reason conditionReason, message string) {
+	meta.SetStatusCondition(&status.Conditions, metav1.Condition{
+		Type:               string(metal3.ConditionHostClaimBound),
+		Status:             newStatus,
+		ObservedGeneration: claim.GetGeneration(),
+		Reason:             string(reason),
+		Message:            message,
+	})
+}
+
+// isClaimedBy returns true if the host is consumed by the claim.
+func isClaimedBy(host *metal3.BareMetalHost, claim *metal3.HostClaim) bool {
+	ref := host.Spec.ConsumerRef
+	return ref != nil && ref.Kind == "HostClaim" &&
+		ref.Namespace == claim.Namespace && ref.Name == claim.Name &&
+		ref.UID == claim.UID
+}
+
+// hostIsClaimable returns true if the host is available for binding
+// to the claim.
+func hostIsClaimable(host *metal3.BareMetalHost, claim *metal3.HostClaim) bool {
+	if !host.DeletionTimestamp.IsZero() || host.Spec.ConsumerRef != nil || host.Spec.Image != nil {
+		return false
+	}
+	switch host.Status.Provisioning.State {
+	case metal3.StateReady, metal3.StateAvailable:
+	default:
+		return false
+	}
+	if host.Status.OperationalStatus != metal3.OperationalStatusOK {
+		return false
+	}
+	return claim.Spec.HardwareRequirements.Matches(host.Status.HardwareDetails)
+}
+
+// boundHost returns the host consumed by the claim, if any. The host is
+// looked up by its consumer reference rather than the claim status, in
+// case the status could not be saved after binding.
+func (r *HostClaimReconciler) boundHost(ctx context.Context, claim *metal3.HostClaim) (*metal3.BareMetalHost, error) {
+	if claim.Status.HostName != "" {
+		host := &metal3.BareMetalHost{}
+		err := r.Get(ctx, types.NamespacedName{Namespace: claim.Namespace, Name: claim.Status.HostName}, host)
+		switch {
+		case k8serrors.IsNotFound(err):
+			return nil, nil
+		case err != nil:
+			return nil, errors.Wrap(err, "failed to load bound host")
+		case isClaimedBy(host, claim):
+			return host, nil
+		}
+	}
+
+	hosts := &metal3.BareMetalHostList{}
+	if err := r.List(ctx, hosts, client.InNamespace(claim.Namespace)); err != nil {
+		return nil, errors.Wrap(err, "failed to list hosts")
+	}
+	for i := range hosts.Items {
+		if isClaimedBy(&hosts.Items[i], claim) {
+			return &hosts.Items[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// bindHost binds the claim to the first available matching host.
+// Binding relies on the resource version of the host, so that if
+// another consumer takes the host at the same time the update fails
+// and the next candidate is tried. It returns nil if no host matches.
+func (r *HostClaimReconciler) bindHost(ctx context.Context, log logr.Logger, claim *metal3.HostClaim) (*metal3.BareMetalHost, error) {
+	selector := labels.Everything()
+	if claim.Spec.HostSelector != nil {
+		var err error
+		selector, err = metav1.LabelSelectorAsSelector(claim.Spec.HostSelector)
+		if err != nil {
+			return nil, errors.Wrap(errInvalidHostSelector, err.Error())
+		}
+	}
+
+	hosts := &metal3.BareMetalHostList{}
+	err := r.List(ctx, hosts, client.InNamespace(claim.Namespace),
+		client.MatchingLabelsSelector{Selector: selector})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list hosts")
+	}
+
+	sort.Slice(hosts.Items, func(i, j int) bool {
+		return hosts.Items[i].Name < hosts.Items[j].Name
+	})
+
+	for i := range hosts.Items {
+		host := &hosts.Items[i]
+		if !hostIsClaimable(host, claim) {
+			continue
+		}
+
+		host.Spec.ConsumerRef = &corev1.ObjectReference{
+			APIVersion: metal3.GroupVersion.String(),
+			Kind:       "HostClaim",
+			Namespace:  claim.Namespace,
+			Name:       claim.Name,
+			UID:        claim.UID,
+		}
+		host.Spec.Image = claim.Spec.Image.DeepCopy()
+		host.Spec.UserData = claim.Spec.UserData.DeepCopy()
+
+		err := r.Update(ctx, host)
+		if k8serrors.IsConflict(err) {
+			log.Info("host changed while binding, trying the next one", "host", host.Name)
+			continue
+		}
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to bind host")
+		}
+		log.Info("bound host", "host", host.Name)
+		return host, nil
+	}
+	return nil, nil
+}
+
+// syncHost copies changes to the image and user data of the claim to
+// the bound host.
+func (r *HostClaimReconciler) syncHost(ctx context.Context, log logr.Logger, claim *metal3.HostClaim, host *metal3.BareMetalHost) error {
+	if apiequality.Semantic.DeepEqual(host.Spec.Image, claim.Spec.Image) &&
+		apiequality.Semantic.DeepEqual(host.Spec.UserData, claim.Spec.UserData) {
+		return nil
+	}
+
+	log.Info("updating bound host", "host", host.Name)
+	host.Spec.Image = claim.Spec.Image.DeepCopy()
+	host.Spec.UserData = claim.Spec.UserData.DeepCopy()
+	return errors.Wrap(r.Update(ctx, host), "failed to update bound host")
+}
+
+// releaseHost removes the claim from the bound host, which causes the
+// host to be deprovisioned, then removes the finalizer of the claim.
+func (r *HostClaimReconciler) releaseHost(ctx context.Context, log logr.Logger, claim *metal3.HostClaim) error {
+	if !utils.StringInList(claim.Finalizers, metal3.HostClaimFinalizer) {
+		return nil
+	}
+
+	host, err := r.boundHost(ctx, claim)
+	if err != nil {
+		return err
+	}
+	if host != nil {
+		log.Info("releasing host", "host", host.Name)
+		host.Spec.ConsumerRef = nil
+		host.Spec.Image = nil
+		host.Spec.UserData = nil
+		if err := r.Update(ctx, host); err != nil {
+			return errors.Wrap(err, "failed to release host")
+		}
+	}
+
+	claim.Finalizers = utils.FilterStringFromList(claim.Finalizers, metal3.HostClaimFinalizer)
+	return errors.Wrap(r.Update(ctx, claim), "failed to remove finalizer")
+}
+
+// unboundClaimsForHost returns requests for the unbound claims in the
+// namespace of a host, so they are retried when the host changes.
+func (r *HostClaimReconciler) unboundClaimsForHost(obj client.Object) []reconcile.Request {
+	claims := &metal3.HostClaimList{}
+	if err := r.List(context.Background(), claims, client.InNamespace(obj.GetNamespace())); err != nil {
+		r.Log.Error(err, "failed to list host claims")
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, claim := range claims.Items {
+		if claim.Status.HostName == "" || claim.Status.HostName == obj.GetName() {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name},
+			})
+		}
+	}
+	return requests
+}
+
+func (r *HostClaimReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&metal3.HostClaim{}).
+		Watches(&source.Kind{Type: &metal3.BareMetalHost{}},
+			handler.EnqueueRequestsFromMapFunc(r.unboundClaimsForHost)).
+		Complete(r)
+}
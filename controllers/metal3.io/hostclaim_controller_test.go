@@ -0,0 +1,211 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+func newClaimableHost(name string, ramMebibytes int) *metal3v1alpha1.BareMetalHost {
+	return &metal3v1alpha1.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"rack": "r1"},
+		},
+		Status: metal3v1alpha1.BareMetalHostStatus{
+			OperationalStatus: metal3v1alpha1.OperationalStatusOK,
+			Provisioning: metal3v1alpha1.ProvisionStatus{
+				State: metal3v1alpha1.StateReady,
+			},
+			HardwareDetails: &metal3v1alpha1.HardwareDetails{
+				RAMMebibytes: ramMebibytes,
+			},
+		},
+	}
+}
+
+func newHostClaim() *metal3v1alpha1.HostClaim {
+	return &metal3v1alpha1.HostClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "claim",
+			Namespace: namespace,
+			UID:       "27720611-e5d1-45d3-ba3a-222dcfaa4ca2",
+		},
+		Spec: metal3v1alpha1.HostClaimSpec{
+			HostSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{"rack": "r1"},
+			},
+			HardwareRequirements: metal3v1alpha1.HardwareRequirements{
+				MinRAMMebibytes: 65536,
+			},
+			Image: &metal3v1alpha1.Image{
+				URL:      "http://example.com/image.qcow2",
+				Checksum: "http://example.com/image.qcow2.md5sum",
+			},
+			UserData: &corev1.SecretReference{
+				Name:      "user-data",
+				Namespace: namespace,
+			},
+		},
+	}
+}
+
+func reconcileHostClaim(t *testing.T, r *HostClaimReconciler, claim *metal3v1alpha1.HostClaim) ctrl.Result {
+	request := ctrl.Request{
+		NamespacedName: types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name},
+	}
+
+	var result ctrl.Result
+	var err error
+	for i := 0; i < 5; i++ {
+		result, err = r.Reconcile(context.Background(), request)
+		if err != nil {
+			t.Fatalf("reconcile failed: %s", err)
+		}
+		if !result.Requeue {
+			break
+		}
+	}
+	return result
+}
+
+func getHostClaimBoundCondition(t *testing.T, r *HostClaimReconciler, claim *metal3v1alpha1.HostClaim) *metav1.Condition {
+	err := r.Get(context.Background(), types.NamespacedName{Namespace: claim.Namespace, Name: claim.Name}, claim)
+	if err != nil {
+		t.Fatalf("could not load claim: %s", err)
+	}
+	return meta.FindStatusCondition(claim.Status.Conditions, string(metal3v1alpha1.ConditionHostClaimBound))
+}
+
+func newTestHostClaimReconciler(initObjs ...runtime.Object) *HostClaimReconciler {
+	return &HostClaimReconciler{
+		Client: fakeclient.NewFakeClient(initObjs...),
+		Log:    ctrl.Log.WithName("controllers").WithName("HostClaim"),
+	}
+}
+
+func TestHostClaimBindsMatchingHost(t *testing.T) {
+	consumed := newClaimableHost("host-a", 131072)
+	consumed.Spec.ConsumerRef = &corev1.ObjectReference{Kind: "Machine", Name: "other"}
+	tooSmall := newClaimableHost("host-b", 16384)
+	provisioning := newClaimableHost("host-c", 131072)
+	provisioning.Status.Provisioning.State = metal3v1alpha1.StateProvisioning
+	otherRack := newClaimableHost("host-d", 131072)
+	otherRack.Labels["rack"] = "r2"
+	matching := newClaimableHost("host-e", 131072)
+	alsoMatching := newClaimableHost("host-f", 131072)
+	claim := newHostClaim()
+
+	r := newTestHostClaimReconciler(consumed, tooSmall, provisioning, otherRack, matching, alsoMatching, claim)
+
+	reconcileHostClaim(t, r, claim)
+
+	cond := getHostClaimBoundCondition(t, r, claim)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, metav1.ConditionTrue, cond.Status)
+		assert.Equal(t, string(reasonHostBound), cond.Reason)
+	}
+	assert.Equal(t, "host-e", claim.Status.HostName)
+	assert.Contains(t, claim.Finalizers, metal3v1alpha1.HostClaimFinalizer)
+
+	host := &metal3v1alpha1.BareMetalHost{}
+	err := r.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "host-e"}, host)
+	assert.NoError(t, err)
+	if assert.NotNil(t, host.Spec.ConsumerRef) {
+		assert.Equal(t, "HostClaim", host.Spec.ConsumerRef.Kind)
+		assert.Equal(t, "claim", host.Spec.ConsumerRef.Name)
+		assert.Equal(t, claim.UID, host.Spec.ConsumerRef.UID)
+	}
+	assert.Equal(t, claim.Spec.Image, host.Spec.Image)
+	assert.Equal(t, claim.Spec.UserData, host.Spec.UserData)
+
+	other := &metal3v1alpha1.BareMetalHost{}
+	err = r.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "host-f"}, other)
+	assert.NoError(t, err)
+	assert.Nil(t, other.Spec.ConsumerRef)
+
+	// A second reconcile keeps the same binding
+	reconcileHostClaim(t, r, claim)
+	getHostClaimBoundCondition(t, r, claim)
+	assert.Equal(t, "host-e", claim.Status.HostName)
+}
+
+func TestHostClaimNoMatchingHost(t *testing.T) {
+	claim := newHostClaim()
+	r := newTestHostClaimReconciler(newClaimableHost("host-a", 16384), claim)
+
+	result := reconcileHostClaim(t, r, claim)
+
+	assert.Equal(t, hostClaimRetryDelay, result.RequeueAfter)
+	cond := getHostClaimBoundCondition(t, r, claim)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		assert.Equal(t, string(reasonNoMatchingHost), cond.Reason)
+	}
+	assert.Empty(t, claim.Status.HostName)
+}
+
+func TestHostClaimUpdatesBoundHost(t *testing.T) {
+	claim := newHostClaim()
+	r := newTestHostClaimReconciler(newClaimableHost("host-a", 131072), claim)
+	reconcileHostClaim(t, r, claim)
+	getHostClaimBoundCondition(t, r, claim)
+
+	claim.Spec.Image.URL = "http://example.com/other.qcow2"
+	assert.NoError(t, r.Update(context.Background(), claim))
+	reconcileHostClaim(t, r, claim)
+
+	host := &metal3v1alpha1.BareMetalHost{}
+	err := r.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "host-a"}, host)
+	assert.NoError(t, err)
+	assert.Equal(t, "http://example.com/other.qcow2", host.Spec.Image.URL)
+}
+
+func TestHostClaimReleasesHostOnDelete(t *testing.T) {
+	claim := newHostClaim()
+	r := newTestHostClaimReconciler(newClaimableHost("host-a", 131072), claim)
+	reconcileHostClaim(t, r, claim)
+	getHostClaimBoundCondition(t, r, claim)
+
+	assert.NoError(t, r.Delete(context.Background(), claim))
+	reconcileHostClaim(t, r, claim)
+
+	host := &metal3v1alpha1.BareMetalHost{}
+	err := r.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "host-a"}, host)
+	assert.NoError(t, err)
+	assert.Nil(t, host.Spec.ConsumerRef)
+	assert.Nil(t, host.Spec.Image)
+	assert.Nil(t, host.Spec.UserData)
+
+	err = r.Get(context.Background(), types.NamespacedName{Namespace: namespace, Name: "claim"}, claim)
+	assert.True(t, k8serrors.IsNotFound(err))
+}
+
+func TestHostClaimHostDeleted(t *testing.T) {
+	claim := newHostClaim()
+	host := newClaimableHost("host-a", 131072)
+	r := newTestHostClaimReconciler(host, claim)
+	reconcileHostClaim(t, r, claim)
+
+	assert.NoError(t, r.Delete(context.Background(), host))
+	reconcileHostClaim(t, r, claim)
+
+	cond := getHostClaimBoundCondition(t, r, claim)
+	if assert.NotNil(t, cond) {
+		assert.Equal(t, metav1.ConditionFalse, cond.Status)
+		assert.Equal(t, string(reasonHostLost), cond.Reason)
+	}
+	assert.Equal(t, "host-a", claim.Status.HostName)
+}
@@ -0,0 +1,259 @@
+/*
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-logr/logr"
+	"github.com/pkg/errors"
+	apiequality "k8s.io/apimachinery/pkg/api/equality"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+	"github.com/metal3-io/baremetal-operator/pkg/secretutils"
+)
+
+// How often the settings are read from the host again
+const firmwareSettingsRefreshDelay = time.Minute * 10
+
+const (
+	reasonSettingsValid     conditionReason = "SettingsValid"
+	reasonSettingsInvalid   conditionReason = "SettingsInvalid"
+	reasonSettingsChanged   conditionReason = "SettingsChanged"
+	reasonSettingsUnchanged conditionReason = "SettingsUnchanged"
+)
+
+// HostFirmwareSettingsReconciler reconciles a HostFirmwareSettings object
+type HostFirmwareSettingsReconciler struct {
+	client.Client
+	Log                logr.Logger
+	Scheme             *runtime.Scheme
+	ProvisionerFactory provisioner.Factory
+	APIReader          client.Reader
+}
+
+// +kubebuilder:rbac:groups=metal3.io,resources=hostfirmwaresettings,verbs=get;list;watch;create;update;patch
+// +kubebuilder:rbac:groups=metal3.io,resources=hostfirmwaresettings/status,verbs=get;update;patch
+
+func (r *HostFirmwareSettingsReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := r.Log.WithValues("hostfirmwaresettings", req.NamespacedName)
+
+	host := &metal3.BareMetalHost{}
+	err := r.Get(ctx, req.NamespacedName, host)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			// The settings are garbage collected along with the host
+			log.Info("BareMetalHost not found")
+			err = nil
+		}
+		return ctrl.Result{}, err
+	}
+	if !host.DeletionTimestamp.IsZero() {
+		return ctrl.Result{}, nil
+	}
+
+	hfs := &metal3.HostFirmwareSettings{}
+	err = r.Get(ctx, req.NamespacedName, hfs)
+	if k8serrors.IsNotFound(err) {
+		hfs, err = r.createHostFirmwareSettings(ctx, host)
+		if err != nil {
+			return ctrl.Result{}, errors.Wrap(err, "could not create host firmware settings")
+		}
+		log.Info("created host firmware settings")
+	} else if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "could not load host firmware settings")
+	}
+
+	if host.Status.Provisioning.ID == "" {
+		// We will be triggered again when the host is registered
+		log.Info("host is not registered yet")
+		return ctrl.Result{}, nil
+	}
+
+	bmcCreds, err := r.getBMCCredentials(log, host)
+	if err != nil {
+		if k8serrors.IsNotFound(err) {
+			// The host controller reports the missing secret on the host
+			log.Info("BMC credentials secret not found", "secret", host.CredentialsKey())
+			return ctrl.Result{Requeue: true, RequeueAfter: provisionerNotReadyRetryDelay}, nil
+		}
+		return ctrl.Result{}, errors.Wrap(err, "could not load BMC credentials")
+	}
+
+	prov, err := r.ProvisionerFactory.NewProvisioner(provisioner.BuildHostData(*host, *bmcCreds),
+		func(reason, message string) {})
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to create provisioner")
+	}
+
+	ready, err := prov.IsReady()
+	if err != nil {
+		return ctrl.Result{}, errors.Wrap(err, "failed to check services availability")
+	}
+	if !ready {
+		log.Info("provisioner is not ready", "RequeueAfter:", provisionerNotReadyRetryDelay)
+		return ctrl.Result{Requeue: true, RequeueAfter: provisionerNotReadyRetryDelay}, nil
+	}
+
+	settings, schema, err := prov.GetFirmwareSettings(true)
+	if err != nil {
+		if errors.Is(err, provisioner.ErrNeedsRegistration) {
+			log.Info("host is not registered yet")
+			return ctrl.Result{}, nil
+		}
+		return ctrl.Result{}, errors.Wrap(err, "could not get firmware settings")
+	}
+
+	if updateHostFirmwareSettingsStatus(hfs, settings, schema) {
+		log.Info("updating status")
+		err = r.Status().Update(ctx, hfs)
+	}
+
+	return ctrl.Result{RequeueAfter: firmwareSettingsRefreshDelay}, err
+}
+
+// getBMCCredentials loads the credentials for talking to the BMC from
+// the host's secret. Ownership of the secret is left to the host
+// controller.
+func (r *HostFirmwareSettingsReconciler) getBMCCredentials(log logr.Logger, host *metal3.BareMetalHost) (*bmc.Credentials, error) {
+	secretManager := secretutils.NewSecretManager(log, r.Client, r.APIReader)
+	secret, err := secretManager.ObtainSecret(host.CredentialsKey())
+	if err != nil {
+		return nil, err
+	}
+	return credentialsFromSecret(secret), nil
+}
+
+// createHostFirmwareSettings creates an empty HostFirmwareSettings for
+// the host, owned by it.
+func (r *HostFirmwareSettingsReconciler) createHostFirmwareSettings(ctx context.Context, host *metal3.BareMetalHost) (*metal3.HostFirmwareSettings, error) {
+	hfs := &metal3.HostFirmwareSettings{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      host.Name,
+			Namespace: host.Namespace,
+		},
+	}
+	if err := controllerutil.SetControllerReference(host, hfs, r.Scheme); err != nil {
+		return nil, err
+	}
+	if err := r.Create(ctx, hfs); err != nil {
+		return nil, err
+	}
+	return hfs, nil
+}
+
+// firmwareSettingsChanged returns true if any of the requested
+// settings differ from the current ones.
+func firmwareSettingsChanged(requested, current map[string]string) bool {
+	for name, value := range requested {
+		if current[name] != value {
+			return true
+		}
+	}
+	return false
+}
+
+func setSettingsCondition(generation int64, status *metal3.HostFirmwareSettingsStatus,
+	cond metal3.SettingsConditionType, newStatus metav1.ConditionStatus,
+	time metav1.Time, reason conditionReason, message string) {
+	newCondition := metav1.Condition{
+		Type:               string(cond),
+		Status:             newStatus,
+		LastTransitionTime: time,
+		ObservedGeneration: generation,
+		Reason:             string(reason),
+		Message:            message,
+	}
+	meta.SetStatusCondition(&status.Conditions, newCondition)
+}
+
+// updateHostFirmwareSettingsStatus records the current settings and
+// their schema in the status, and checks the requested settings
+// against them. It returns true if the status changed.
+func updateHostFirmwareSettingsStatus(hfs *metal3.HostFirmwareSettings,
+	settings map[string]string, schema map[string]metal3.SettingSchema) bool {
+	generation := hfs.GetGeneration()
+
+	newStatus := hfs.Status.DeepCopy()
+	newStatus.Settings = settings
+	newStatus.Schema = schema
+
+	time := metav1.Now()
+	if err := metal3.ValidateSettings(hfs.Spec.Settings, schema); err != nil {
+		setSettingsCondition(generation, newStatus,
+			metal3.ConditionSettingsValid, metav1.ConditionFalse,
+			time, reasonSettingsInvalid, err.Error())
+	} else {
+		setSettingsCondition(generation, newStatus,
+			metal3.ConditionSettingsValid, metav1.ConditionTrue,
+			time, reasonSettingsValid, "")
+	}
+
+	if firmwareSettingsChanged(hfs.Spec.Settings, settings) {
+		setSettingsCondition(generation, newStatus,
+			metal3.ConditionSettingsChangeDetected, metav1.ConditionTrue,
+			time, reasonSettingsChanged, "")
+	} else {
+		setSettingsCondition(generation, newStatus,
+			metal3.ConditionSettingsChangeDetected, metav1.ConditionFalse,
+			time, reasonSettingsUnchanged, "")
+	}
+
+	if apiequality.Semantic.DeepEqual(hfs.Status, *newStatus) {
+		return false
+	}
+	newStatus.LastUpdated = &time
+	hfs.Status = *newStatus
+	return true
+}
+
+// hostRegistrationChanged filters the updates of BareMetalHosts down
+// to those that may change whether the settings can be read. Reading
+// the settings is an Ironic call, so the periodic refresh relies on
+// RequeueAfter rather than on every update of the host.
+func hostRegistrationChanged(e event.UpdateEvent) bool {
+	oldHost, oldOK := e.ObjectOld.(*metal3.BareMetalHost)
+	newHost, newOK := e.ObjectNew.(*metal3.BareMetalHost)
+	if !oldOK || !newOK {
+		return true
+	}
+	return oldHost.Status.Provisioning.ID != newHost.Status.Provisioning.ID ||
+		oldHost.Status.Provisioning.State != newHost.Status.Provisioning.State ||
+		oldHost.DeletionTimestamp.IsZero() != newHost.DeletionTimestamp.IsZero()
+}
+
+func (r *HostFirmwareSettingsReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&metal3.HostFirmwareSettings{},
+			builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &metal3.BareMetalHost{}}, &handler.EnqueueRequestForObject{},
+			builder.WithPredicates(predicate.Funcs{UpdateFunc: hostRegistrationChanged})).
+		Complete(r)
+}
@@ -0,0 +1,143 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	k8serrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+func TestUpdateHostFirmwareSettingsStatus(t *testing.T) {
+	current := map[string]string{
+		"ProcVirtualization": "Enabled",
+		"NumCores":           "8",
+	}
+	schema := map[string]metal3v1alpha1.SettingSchema{
+		"ProcVirtualization": {
+			AttributeType:   "Enumeration",
+			AllowableValues: []string{"Enabled", "Disabled"},
+		},
+	}
+
+	testCases := []struct {
+		Scenario        string
+		Requested       map[string]string
+		Schema          map[string]metal3v1alpha1.SettingSchema
+		ExpectedValid   metav1.ConditionStatus
+		ExpectedChanged metav1.ConditionStatus
+	}{
+		{
+			Scenario:        "nothing requested",
+			Schema:          schema,
+			ExpectedValid:   metav1.ConditionTrue,
+			ExpectedChanged: metav1.ConditionFalse,
+		},
+		{
+			Scenario:        "current value requested",
+			Requested:       map[string]string{"ProcVirtualization": "Enabled"},
+			Schema:          schema,
+			ExpectedValid:   metav1.ConditionTrue,
+			ExpectedChanged: metav1.ConditionFalse,
+		},
+		{
+			Scenario:        "change requested",
+			Requested:       map[string]string{"ProcVirtualization": "Disabled"},
+			Schema:          schema,
+			ExpectedValid:   metav1.ConditionTrue,
+			ExpectedChanged: metav1.ConditionTrue,
+		},
+		{
+			Scenario:        "invalid value",
+			Requested:       map[string]string{"ProcVirtualization": "Maybe"},
+			Schema:          schema,
+			ExpectedValid:   metav1.ConditionFalse,
+			ExpectedChanged: metav1.ConditionTrue,
+		},
+		{
+			Scenario:        "unknown setting",
+			Requested:       map[string]string{"Unknown": "value"},
+			Schema:          schema,
+			ExpectedValid:   metav1.ConditionFalse,
+			ExpectedChanged: metav1.ConditionTrue,
+		},
+		{
+			Scenario:        "no schema",
+			Requested:       map[string]string{"Unknown": "value"},
+			ExpectedValid:   metav1.ConditionTrue,
+			ExpectedChanged: metav1.ConditionTrue,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			hfs := &metal3v1alpha1.HostFirmwareSettings{
+				Spec: metal3v1alpha1.HostFirmwareSettingsSpec{
+					Settings: tc.Requested,
+				},
+			}
+
+			assert.True(t, updateHostFirmwareSettingsStatus(hfs, current, tc.Schema))
+			assert.Equal(t, current, hfs.Status.Settings)
+			assert.Equal(t, tc.Schema, hfs.Status.Schema)
+			assert.NotNil(t, hfs.Status.LastUpdated)
+
+			valid := meta.FindStatusCondition(hfs.Status.Conditions,
+				string(metal3v1alpha1.ConditionSettingsValid))
+			if assert.NotNil(t, valid) {
+				assert.Equal(t, tc.ExpectedValid, valid.Status)
+			}
+			changed := meta.FindStatusCondition(hfs.Status.Conditions,
+				string(metal3v1alpha1.ConditionSettingsChangeDetected))
+			if assert.NotNil(t, changed) {
+				assert.Equal(t, tc.ExpectedChanged, changed.Status)
+			}
+
+			assert.False(t, updateHostFirmwareSettingsStatus(hfs, current, tc.Schema),
+				"status changed without a change to the settings")
+		})
+	}
+}
+
+func TestHostRegistrationChanged(t *testing.T) {
+	oldHost := host(metal3v1alpha1.StateReady).build()
+
+	newHost := oldHost.DeepCopy()
+	newHost.Status.PoweredOn = !oldHost.Status.PoweredOn
+	newHost.Spec.Online = !oldHost.Spec.Online
+	assert.False(t, hostRegistrationChanged(event.UpdateEvent{ObjectOld: oldHost, ObjectNew: newHost}))
+
+	newHost = oldHost.DeepCopy()
+	newHost.Status.Provisioning.State = metal3v1alpha1.StateProvisioning
+	assert.True(t, hostRegistrationChanged(event.UpdateEvent{ObjectOld: oldHost, ObjectNew: newHost}))
+
+	newHost = oldHost.DeepCopy()
+	newHost.Status.Provisioning.ID = "new-id"
+	assert.True(t, hostRegistrationChanged(event.UpdateEvent{ObjectOld: oldHost, ObjectNew: newHost}))
+}
+
+func TestHostFirmwareSettingsBMCCredentials(t *testing.T) {
+	bmhReconciler := newTestReconciler()
+	r := &HostFirmwareSettingsReconciler{
+		Client:    bmhReconciler.Client,
+		Log:       ctrl.Log.WithName("controllers").WithName("HostFirmwareSettings"),
+		APIReader: bmhReconciler.APIReader,
+	}
+
+	host := newDefaultHost(t)
+	creds, err := r.getBMCCredentials(r.Log, host)
+	if assert.NoError(t, err) {
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("User")), creds.Username)
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("Pass")), creds.Password)
+	}
+
+	host.Spec.BMC.CredentialsName = "missing"
+	_, err = r.getBMCCredentials(r.Log, host)
+	assert.True(t, k8serrors.IsNotFound(err))
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/ociimage"
+)
+
+// imageValidationRetryDelay is how long to wait before validating an
+// image that failed the validation again, in case the problem was with
+// the server rather than with the spec.
+const imageValidationRetryDelay = time.Minute * 5
+
+func stringPtrEqual(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// imageValidationCurrent returns whether the validation status applies
+// to the image.
+func imageValidationCurrent(validation *metal3v1alpha1.ImageValidation, image *metal3v1alpha1.Image) bool {
+	if validation == nil || validation.URL != image.URL ||
+		!stringPtrEqual(validation.DiskFormat, image.DiskFormat) {
+		return false
+	}
+	if image.DiskFormat != nil && *image.DiskFormat == "live-iso" {
+		return true
+	}
+	if image.ChecksumIsURL() {
+		return validation.ChecksumURL == image.Checksum
+	}
+	return validation.ChecksumURL == "" && validation.Checksum == image.Checksum
+}
+
+// validatedImage returns the image of the host to provision. When a
+// checksum URL was read during the validation, the checksum read is used,
+// so that the image is deployed with the checksum it was validated with.
+func validatedImage(host *metal3v1alpha1.BareMetalHost) (image metal3v1alpha1.Image) {
+	if host.Spec.Image == nil {
+		return
+	}
+	image = *host.Spec.Image.DeepCopy()
+	validation := host.Status.ImageValidation
+	if imageValidationCurrent(validation, &image) && validation.Error == "" &&
+		validation.ChecksumURL != "" && validation.Checksum != "" {
+		image.Checksum = validation.Checksum
+	}
+	return
+}
+
+// validateImage checks the image of the host before provisioning it, so
+// that a wrong URL, checksum or format is reported without waiting for
+// the deployment to fail. It returns nil once the image is valid.
+func (r *BareMetalHostReconciler) validateImage(info *reconcileInfo) actionResult {
+	image := info.host.Spec.Image
+	if ociimage.IsReference(image.URL) {
+		// Images in OCI registries are checked by the provisioner
+		// when it resolves them
+		return nil
+	}
+	validation := info.host.Status.ImageValidation
+	if imageValidationCurrent(validation, image) {
+		if validation.Error == "" {
+			return nil
+		}
+		if validation.ValidatedAt != nil {
+			if wait := imageValidationRetryDelay - time.Since(validation.ValidatedAt.Time); wait > 0 {
+				return actionContinue{wait}
+			}
+		}
+	}
+
+	info.log.Info("validating image", "url", image.URL)
+	checksum, err := r.ImageValidator.Validate(context.TODO(), image)
+	now := metav1.Now()
+	newValidation := &metal3v1alpha1.ImageValidation{
+		URL:         image.URL,
+		Checksum:    checksum,
+		DiskFormat:  image.DiskFormat,
+		ValidatedAt: &now,
+	}
+	if image.ChecksumIsURL() {
+		newValidation.ChecksumURL = image.Checksum
+	}
+	info.host.Status.ImageValidation = newValidation
+
+	if err != nil {
+		info.log.Info("image validation failed", "error", err.Error())
+		newValidation.Error = err.Error()
+		if validation == nil || validation.Error != newValidation.Error {
+			event := info.host.NewEvent("ImageValidationFailed", err.Error())
+			event.Type = corev1.EventTypeWarning
+			info.events = append(info.events, event)
+		}
+		return actionUpdate{actionContinue{imageValidationRetryDelay}}
+	}
+
+	info.publishEvent("ImageValidated", fmt.Sprintf("Image %s validated", image.URL))
+	return actionUpdate{}
+}
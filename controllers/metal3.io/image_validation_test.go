@@ -0,0 +1,93 @@
+package controllers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/imagevalidation"
+)
+
+func TestValidateImage(t *testing.T) {
+	checksum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	files := map[string]string{
+		"/image.img":  "raw image",
+		"/SHA256SUMS": checksum + "  image.img\n",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(data))
+	}))
+	defer server.Close()
+
+	r := &BareMetalHostReconciler{ImageValidator: imagevalidation.New()}
+	host := host(metal3v1alpha1.StateAvailable).build()
+	host.Spec.Image = &metal3v1alpha1.Image{
+		URL:      server.URL + "/missing.img",
+		Checksum: server.URL + "/SHA256SUMS",
+	}
+	info := makeReconcileInfo(host)
+
+	result := r.validateImage(info)
+	assert.Equal(t, actionUpdate{actionContinue{imageValidationRetryDelay}}, result)
+	if assert.NotNil(t, host.Status.ImageValidation) {
+		assert.Contains(t, host.Status.ImageValidation.Error, "404 Not Found")
+		assert.Equal(t, server.URL+"/SHA256SUMS", host.Status.ImageValidation.ChecksumURL)
+	}
+	if assert.Len(t, info.events, 1) {
+		assert.Equal(t, "ImageValidationFailed", info.events[0].Reason)
+		assert.Equal(t, corev1.EventTypeWarning, info.events[0].Type)
+	}
+
+	// The failed validation is not retried immediately
+	result = r.validateImage(info)
+	if assert.IsType(t, actionContinue{}, result) {
+		assert.LessOrEqual(t, int64(result.(actionContinue).delay), int64(imageValidationRetryDelay))
+	}
+
+	// A new image is validated at once
+	host.Spec.Image.URL = server.URL + "/image.img"
+	assert.Equal(t, actionUpdate{}, r.validateImage(info))
+	assert.Empty(t, host.Status.ImageValidation.Error)
+	assert.Equal(t, checksum, host.Status.ImageValidation.Checksum)
+	assert.Equal(t, "ImageValidated", info.events[1].Reason)
+
+	assert.Nil(t, r.validateImage(info))
+
+	// Provisioning starts once the image is valid
+	assert.Equal(t, actionComplete{}, r.actionManageReady(nil, info))
+}
+
+func TestProvisionValidatedChecksum(t *testing.T) {
+	checksum := "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	host := host(metal3v1alpha1.StateProvisioning).build()
+	host.Spec.Image = &metal3v1alpha1.Image{
+		URL:      "http://images.test/image.img",
+		Checksum: "http://images.test/SHA256SUMS",
+	}
+	host.Status.ImageValidation = &metal3v1alpha1.ImageValidation{
+		URL:         host.Spec.Image.URL,
+		Checksum:    checksum,
+		ChecksumURL: host.Spec.Image.Checksum,
+	}
+	r := newTestReconciler(host)
+	prov := newMockProvisioner()
+
+	// The checksum read during the validation is deployed
+	r.actionProvisioning(prov, makeReconcileInfo(host))
+	assert.Equal(t, checksum, prov.provisionData.Image.Checksum)
+	assert.Equal(t, host.Spec.Image.URL, prov.provisionData.Image.URL)
+
+	// Not once the image changed
+	host.Spec.Image.Checksum = "http://images.test/SHA512SUMS"
+	r.actionProvisioning(prov, makeReconcileInfo(host))
+	assert.Equal(t, "http://images.test/SHA512SUMS", prov.provisionData.Image.Checksum)
+}
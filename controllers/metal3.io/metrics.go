@@ -7,6 +7,7 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/metrics"
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
 )
 
 const (
@@ -17,6 +18,11 @@ const (
 	labelPrevState     = "prev_state"
 	labelNewState      = "new_state"
 	labelHostDataType  = "host_data_type"
+	labelComponent     = "component"
+	labelComponentType = "component_type"
+	labelSensor        = "sensor"
+	labelSensorType    = "sensor_type"
+	labelUnits         = "units"
 )
 
 var reconcileCounters = prometheus.NewCounterVec(prometheus.CounterOpts{
@@ -74,6 +80,19 @@ var delayedDeprovisioningHostCounters = prometheus.NewCounterVec(prometheus.Coun
 	Help: "The number of times hosts have been delayed while deprovisioning due a busy provisioner",
 }, []string{labelHostNamespace, labelHostName})
 
+var hostHealthStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "metal3_host_health_status",
+	Help: "The overall hardware health of hosts (0 for OK, 1 for Warning, 2 for Critical)",
+}, []string{labelHostNamespace, labelHostName})
+var componentHealthStatus = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "metal3_host_component_health_status",
+	Help: "The health of the hardware components of hosts (0 for OK, 1 for Warning, 2 for Critical)",
+}, []string{labelHostNamespace, labelHostName, labelComponent, labelComponentType})
+var sensorReading = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "metal3_host_sensor_reading",
+	Help: "The readings of the fan, temperature and power supply sensors of hosts",
+}, []string{labelHostNamespace, labelHostName, labelSensor, labelSensorType, labelUnits})
+
 var slowOperationBuckets = []float64{30, 90, 180, 360, 720, 1440}
 
 var stateTime = map[metal3v1alpha1.ProvisioningState]*prometheus.HistogramVec{
@@ -144,6 +163,11 @@ func init() {
 		hostRegistrationRequired,
 		hostUnmanaged,
 		deleteWithoutDeprov)
+
+	metrics.Registry.MustRegister(
+		hostHealthStatus,
+		componentHealthStatus,
+		sensorReading)
 }
 
 func hostMetricLabels(request ctrl.Request) prometheus.Labels {
@@ -153,6 +177,25 @@ func hostMetricLabels(request ctrl.Request) prometheus.Labels {
 	}
 }
 
+func componentMetricLabels(request ctrl.Request, name string, componentType metal3v1alpha1.ComponentType) prometheus.Labels {
+	return prometheus.Labels{
+		labelHostNamespace: request.Namespace,
+		labelHostName:      request.Name,
+		labelComponent:     name,
+		labelComponentType: string(componentType),
+	}
+}
+
+func sensorMetricLabels(request ctrl.Request, name string, componentType metal3v1alpha1.ComponentType, units provisioner.SensorUnits) prometheus.Labels {
+	return prometheus.Labels{
+		labelHostNamespace: request.Namespace,
+		labelHostName:      request.Name,
+		labelSensor:        name,
+		labelSensorType:    string(componentType),
+		labelUnits:         string(units),
+	}
+}
+
 func stateChangeMetricLabels(prevState, newState metal3v1alpha1.ProvisioningState) prometheus.Labels {
 	return prometheus.Labels{
 		labelPrevState: string(prevState),
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"fmt"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+const minutesPerDay = 24 * 60
+
+// windowMinute parses a HH:MM time of the day into minutes since
+// midnight.
+func windowMinute(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of the day %q", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// inWindow returns whether a time falls within one of the windows, and
+// the time until the next start or end of any of them.
+func inWindow(windows []metal3v1alpha1.PowerWindow, now time.Time) (window *metal3v1alpha1.PowerWindow, untilChange time.Duration, err error) {
+	now = now.UTC()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	minute := int(now.Sub(midnight) / time.Minute)
+	untilChange = -1
+
+	for i := range windows {
+		start, err := windowMinute(windows[i].Start)
+		if err != nil {
+			return nil, 0, err
+		}
+		end, err := windowMinute(windows[i].End)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		var inside bool
+		if start <= end {
+			inside = start <= minute && minute < end
+		} else {
+			inside = minute >= start || minute < end
+		}
+		if inside && window == nil {
+			window = &windows[i]
+		}
+
+		for _, boundary := range []int{start, end} {
+			next := midnight.Add(time.Duration(boundary) * time.Minute)
+			if boundary <= minute {
+				next = next.Add(minutesPerDay * time.Minute)
+			}
+			if until := next.Sub(now); untilChange < 0 || until < untilChange {
+				untilChange = until
+			}
+		}
+	}
+	return window, untilChange, nil
+}
+
+// powerPolicyDecision is the outcome of evaluating a power policy.
+type powerPolicyDecision struct {
+	poweredOff bool
+	reason     string
+	// the time until the decision may change, or 0 if it does not
+	// depend on time
+	recheckAfter time.Duration
+}
+
+func (d *powerPolicyDecision) recheckWithin(duration time.Duration) {
+	if duration > 0 && (d.recheckAfter == 0 || duration < d.recheckAfter) {
+		d.recheckAfter = duration
+	}
+}
+
+// evaluatePowerPolicy decides whether an idle host must be powered off.
+func evaluatePowerPolicy(policy *metal3v1alpha1.PowerPolicy, idleSince time.Time, now time.Time) (decision powerPolicyDecision, err error) {
+	window, untilChange, err := inWindow(policy.PowerOnWindows, now)
+	if err != nil {
+		return decision, err
+	}
+	decision.recheckWithin(untilChange)
+	if window != nil {
+		decision.reason = fmt.Sprintf("within the power-on window %s-%s", window.Start, window.End)
+		return decision, nil
+	}
+
+	window, untilChange, err = inWindow(policy.PowerOffWindows, now)
+	if err != nil {
+		return decision, err
+	}
+	decision.recheckWithin(untilChange)
+	if window != nil {
+		decision.poweredOff = true
+		decision.reason = fmt.Sprintf("within the power-off window %s-%s", window.Start, window.End)
+		return decision, nil
+	}
+
+	if policy.IdleTimeout == nil {
+		decision.reason = "no idle timeout"
+		return decision, nil
+	}
+	timeout := policy.IdleTimeout.Duration
+	if idle := now.Sub(idleSince); idle >= timeout {
+		decision.poweredOff = true
+		decision.reason = fmt.Sprintf("idle for more than %s", timeout)
+	} else {
+		decision.reason = fmt.Sprintf("idle for less than %s", timeout)
+		decision.recheckWithin(timeout - idle)
+	}
+	return decision, nil
+}
+
+// powerPolicyApplies returns whether the host is idle, i.e. available
+// without a consumer.
+func powerPolicyApplies(host *metal3v1alpha1.BareMetalHost) (bool, string) {
+	switch host.Status.Provisioning.State {
+	case metal3v1alpha1.StateReady, metal3v1alpha1.StateAvailable:
+	default:
+		return false, "host is not available"
+	}
+	if host.Spec.ConsumerRef != nil {
+		return false, "host has a consumer"
+	}
+	return true, ""
+}
+
+// clearPowerPolicyIdle forgets when the host became idle once it
+// leaves the states the power policy applies to, so that the idle time
+// starts again when it comes back rather than counting the time spent
+// preparing or provisioning it.
+func clearPowerPolicyIdle(host *metal3v1alpha1.BareMetalHost) {
+	if host.Status.PowerPolicy == nil {
+		return
+	}
+	if applies, reason := powerPolicyApplies(host); !applies {
+		host.Status.PowerPolicy.IdleSince = nil
+		host.Status.PowerPolicy.PoweredOff = false
+		host.Status.PowerPolicy.Reason = reason
+	}
+}
+
+// poweredOffByPolicy returns whether the power policy currently keeps
+// the host powered off.
+func poweredOffByPolicy(host *metal3v1alpha1.BareMetalHost) bool {
+	if host.Spec.PowerPolicy == nil || host.Status.PowerPolicy == nil {
+		return false
+	}
+	applies, _ := powerPolicyApplies(host)
+	return applies && host.Status.PowerPolicy.PoweredOff
+}
+
+// managePowerPolicy evaluates the power policy of the host and records
+// the decision in its status. It returns whether the status changed,
+// whether the policy keeps the host powered off, and the time after
+// which the policy must be evaluated again, or 0.
+func managePowerPolicy(info *reconcileInfo, now time.Time) (changed, poweredOff bool, recheckAfter time.Duration, err error) {
+	host := info.host
+	policy := host.Spec.PowerPolicy
+	if policy == nil {
+		if host.Status.PowerPolicy != nil {
+			host.Status.PowerPolicy = nil
+			changed = true
+		}
+		return
+	}
+
+	status := metal3v1alpha1.PowerPolicyStatus{}
+	if old := host.Status.PowerPolicy; old != nil {
+		status = *old.DeepCopy()
+	}
+
+	if applies, reason := powerPolicyApplies(host); !applies {
+		status.IdleSince = nil
+		status.PoweredOff = false
+		status.Reason = reason
+	} else {
+		if status.IdleSince == nil {
+			idleSince := metav1.NewTime(now)
+			status.IdleSince = &idleSince
+		}
+		decision, err := evaluatePowerPolicy(policy, status.IdleSince.Time, now)
+		if err != nil {
+			return false, false, 0, err
+		}
+		status.PoweredOff = decision.poweredOff
+		status.Reason = decision.reason
+		recheckAfter = decision.recheckAfter
+	}
+
+	old := host.Status.PowerPolicy
+	wasPoweredOff := old != nil && old.PoweredOff
+	if host.Spec.Online && status.PoweredOff != wasPoweredOff {
+		if status.PoweredOff {
+			info.publishEvent("PowerPolicyPowerOff", fmt.Sprintf("Powering off the host: %s", status.Reason))
+		} else {
+			info.publishEvent("PowerPolicyPowerOn", fmt.Sprintf("Powering on the host: %s", status.Reason))
+		}
+	}
+	if old == nil || old.PoweredOff != status.PoweredOff || old.Reason != status.Reason {
+		decisionTime := metav1.NewTime(now)
+		status.LastDecisionTime = &decisionTime
+	}
+	if old == nil || !old.IdleSince.Equal(status.IdleSince) || !old.LastDecisionTime.Equal(status.LastDecisionTime) {
+		host.Status.PowerPolicy = &status
+		changed = true
+	}
+	return changed, status.PoweredOff, recheckAfter, nil
+}
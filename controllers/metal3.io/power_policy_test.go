@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+func TestEvaluatePowerPolicy(t *testing.T) {
+	now := time.Date(2021, time.June, 1, 22, 30, 0, 0, time.UTC)
+	idleTimeout := &metav1.Duration{Duration: 2 * time.Hour}
+	workingHours := []metal3v1alpha1.PowerWindow{{Start: "08:00", End: "18:00"}}
+	nights := []metal3v1alpha1.PowerWindow{{Start: "22:00", End: "06:00"}}
+
+	testCases := []struct {
+		Scenario     string
+		Policy       metal3v1alpha1.PowerPolicy
+		Idle         time.Duration
+		PoweredOff   bool
+		Reason       string
+		RecheckAfter time.Duration
+	}{
+		{
+			Scenario: "no idle timeout",
+			Policy:   metal3v1alpha1.PowerPolicy{},
+			Reason:   "no idle timeout",
+		},
+		{
+			Scenario:     "recently idle",
+			Policy:       metal3v1alpha1.PowerPolicy{IdleTimeout: idleTimeout},
+			Idle:         30 * time.Minute,
+			Reason:       "idle for less than 2h0m0s",
+			RecheckAfter: 90 * time.Minute,
+		},
+		{
+			Scenario:   "idle timeout expired",
+			Policy:     metal3v1alpha1.PowerPolicy{IdleTimeout: idleTimeout},
+			Idle:       3 * time.Hour,
+			PoweredOff: true,
+			Reason:     "idle for more than 2h0m0s",
+		},
+		{
+			Scenario:     "outside power-on window",
+			Policy:       metal3v1alpha1.PowerPolicy{IdleTimeout: idleTimeout, PowerOnWindows: workingHours},
+			Idle:         3 * time.Hour,
+			PoweredOff:   true,
+			Reason:       "idle for more than 2h0m0s",
+			RecheckAfter: 9*time.Hour + 30*time.Minute,
+		},
+		{
+			Scenario: "within power-on window",
+			Policy: metal3v1alpha1.PowerPolicy{IdleTimeout: idleTimeout,
+				PowerOnWindows: []metal3v1alpha1.PowerWindow{{Start: "20:00", End: "23:00"}}},
+			Idle:         3 * time.Hour,
+			Reason:       "within the power-on window 20:00-23:00",
+			RecheckAfter: 30 * time.Minute,
+		},
+		{
+			Scenario:     "within power-off window across midnight",
+			Policy:       metal3v1alpha1.PowerPolicy{PowerOffWindows: nights},
+			PoweredOff:   true,
+			Reason:       "within the power-off window 22:00-06:00",
+			RecheckAfter: 7*time.Hour + 30*time.Minute,
+		},
+		{
+			Scenario: "power-on window takes precedence",
+			Policy: metal3v1alpha1.PowerPolicy{PowerOffWindows: nights,
+				PowerOnWindows: []metal3v1alpha1.PowerWindow{{Start: "22:15", End: "22:45"}}},
+			Reason:       "within the power-on window 22:15-22:45",
+			RecheckAfter: 15 * time.Minute,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			decision, err := evaluatePowerPolicy(&tc.Policy, now.Add(-tc.Idle), now)
+			assert.NoError(t, err)
+			assert.Equal(t, tc.PoweredOff, decision.poweredOff)
+			assert.Equal(t, tc.Reason, decision.reason)
+			assert.Equal(t, tc.RecheckAfter, decision.recheckAfter)
+		})
+	}
+}
+
+func TestManagePowerPolicy(t *testing.T) {
+	now := time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC)
+	host := host(metal3v1alpha1.StateAvailable).build()
+	host.Spec.Online = true
+	host.Spec.PowerPolicy = &metal3v1alpha1.PowerPolicy{
+		IdleTimeout: &metav1.Duration{Duration: time.Hour},
+	}
+	info := makeReconcileInfo(host)
+
+	changed, poweredOff, recheck, err := managePowerPolicy(info, now)
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.False(t, poweredOff)
+	assert.Equal(t, time.Hour, recheck)
+	assert.Equal(t, now, host.Status.PowerPolicy.IdleSince.Time)
+	assert.Empty(t, info.events)
+
+	changed, _, _, err = managePowerPolicy(info, now.Add(time.Minute))
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	changed, poweredOff, _, err = managePowerPolicy(info, now.Add(time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.True(t, poweredOff)
+	assert.Equal(t, "idle for more than 1h0m0s", host.Status.PowerPolicy.Reason)
+	assert.Equal(t, now.Add(time.Hour), host.Status.PowerPolicy.LastDecisionTime.Time)
+	if assert.Len(t, info.events, 1) {
+		assert.Equal(t, "PowerPolicyPowerOff", info.events[0].Reason)
+	}
+	host.Status.PoweredOn = false
+	assert.True(t, poweredOffByPolicy(host))
+	assert.Equal(t, metav1.ConditionTrue, poweredOnMatchesSpecCondition(host).status)
+
+	// Claiming the host powers it on again
+	host.Spec.ConsumerRef = &corev1.ObjectReference{Kind: "HostClaim", Name: "claim"}
+	changed, poweredOff, _, err = managePowerPolicy(info, now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.False(t, poweredOff)
+	assert.Nil(t, host.Status.PowerPolicy.IdleSince)
+	assert.Equal(t, "host has a consumer", host.Status.PowerPolicy.Reason)
+	if assert.Len(t, info.events, 2) {
+		assert.Equal(t, "PowerPolicyPowerOn", info.events[1].Reason)
+	}
+
+	host.Spec.PowerPolicy = nil
+	changed, _, _, err = managePowerPolicy(info, now.Add(2*time.Hour))
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Nil(t, host.Status.PowerPolicy)
+}
+
+func TestPowerPolicyIdleClearedOnStateChange(t *testing.T) {
+	idleSince := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	host := host(metal3v1alpha1.StateReady).build()
+	host.Spec.Image = &metal3v1alpha1.Image{URL: "http://example.com/image.qcow2"}
+	host.Spec.PowerPolicy = &metal3v1alpha1.PowerPolicy{
+		IdleTimeout: &metav1.Duration{Duration: time.Hour},
+	}
+	host.Status.PowerPolicy = &metal3v1alpha1.PowerPolicyStatus{IdleSince: &idleSince}
+	hsm := newHostStateMachine(host, &BareMetalHostReconciler{}, newMockProvisioner(), true)
+
+	hsm.ReconcileState(makeDefaultReconcileInfo(host))
+
+	assert.Equal(t, metal3v1alpha1.StateProvisioning, host.Status.Provisioning.State)
+	assert.Nil(t, host.Status.PowerPolicy.IdleSince)
+	assert.Equal(t, "host is not available", host.Status.PowerPolicy.Reason)
+}
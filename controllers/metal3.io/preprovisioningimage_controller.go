@@ -18,6 +18,8 @@ package controllers
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"os"
 	"time"
 
@@ -28,10 +30,14 @@ import (
 	"k8s.io/apimachinery/pkg/api/meta"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
 
 	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/deployimages"
+	"github.com/metal3-io/baremetal-operator/pkg/imagebuilder"
 	"github.com/metal3-io/baremetal-operator/pkg/secretutils"
 )
 
@@ -46,6 +52,11 @@ type PreprovisioningImageReconciler struct {
 	Log       logr.Logger
 	Scheme    *runtime.Scheme
 	APIReader client.Reader
+	// ImageBuilder builds images embedding the network data, when set.
+	// Otherwise the deploy image is used as is.
+	ImageBuilder *imagebuilder.Builder
+	// DeployImages are the deploy images loaded at startup.
+	DeployImages deployimages.Config
 }
 
 type conditionReason string
@@ -54,8 +65,19 @@ const (
 	reasonSuccess            conditionReason = "ImageSuccess"
 	reasonConfigurationError conditionReason = "ConfigurationError"
 	reasonMissingNetworkData conditionReason = "MissingNetworkData"
+	reasonImageBuildError    conditionReason = "ImageBuildError"
 )
 
+// imageBuildFailedError wraps the errors of the image builder, which
+// are retried with a delay.
+type imageBuildFailedError struct {
+	err error
+}
+
+func (e imageBuildFailedError) Error() string {
+	return e.err.Error()
+}
+
 // +kubebuilder:rbac:groups=metal3.io,resources=preprovisioningimages,verbs=get;list;watch;update;patch
 // +kubebuilder:rbac:groups=metal3.io,resources=preprovisioningimages/status,verbs=get;update;patch
 // +kubebuilder:rbac:groups="",resources=secrets,verbs=get;list;watch;update
@@ -71,16 +93,33 @@ func (r *PreprovisioningImageReconciler) Reconcile(ctx context.Context, req ctrl
 		if k8serrors.IsNotFound(err) {
 			log.Info("PreprovisioningImage not found")
 			err = nil
+			if r.ImageBuilder != nil {
+				err = r.ImageBuilder.Remove(req.Namespace, req.Name)
+			}
 		}
 		return ctrl.Result{}, err
 	}
 
-	changed, err := r.update(&img, log)
+	changed, err := r.update(ctx, &img, log)
 
-	if k8serrors.IsNotFound(err) {
+	var buildErr imageBuildFailedError
+	switch {
+	case k8serrors.IsNotFound(err):
 		delay := getErrorRetryDelay(img.Status)
 		log.Info("requeuing to check for secret", "after", delay)
 		result.RequeueAfter = delay
+	case errors.Is(err, imagebuilder.ErrBaseImageDownloading):
+		log.Info("requeuing while the base image is downloaded", "after", minRetryDelay)
+		result.RequeueAfter = minRetryDelay
+		err = nil
+	case errors.As(err, &buildErr):
+		delay := getErrorRetryDelay(img.Status)
+		log.Info("requeuing to retry the image build", "after", delay)
+		result.RequeueAfter = delay
+		err = nil
+	case err == nil && r.ImageBuilder != nil:
+		// Rebuilt when the base image changes at its URL
+		result.RequeueAfter = imagebuilder.BaseImageRevalidationInterval
 	}
 	if changed {
 		log.Info("updating status")
@@ -90,10 +129,16 @@ func (r *PreprovisioningImageReconciler) Reconcile(ctx context.Context, req ctrl
 	return result, err
 }
 
-func (r *PreprovisioningImageReconciler) update(img *metal3.PreprovisioningImage, log logr.Logger) (bool, error) {
+func (r *PreprovisioningImageReconciler) update(ctx context.Context, img *metal3.PreprovisioningImage, log logr.Logger) (bool, error) {
 	generation := img.GetGeneration()
 
-	url, format, errorMessage := getImageURL()
+	var url, errorMessage string
+	var format metal3.ImageFormat
+	if r.ImageBuilder != nil {
+		url, format, errorMessage = getBaseImageURL(r.DeployImages, img.Spec.Architecture, img.Spec.AcceptFormats)
+	} else {
+		url, format, errorMessage = getImageURL(r.DeployImages, img.Spec.Architecture)
+	}
 	if errorMessage != "" {
 		log.Info("no suitable image URL available", "preferredFormat", format)
 		return setError(generation, &img.Status, reasonConfigurationError, errorMessage), nil
@@ -101,23 +146,47 @@ func (r *PreprovisioningImageReconciler) update(img *metal3.PreprovisioningImage
 
 	log.Info("image URL available", "url", url, "format", format)
 	secretManager := secretutils.NewSecretManager(log, r.Client, r.APIReader)
-	secretStatus, err := getNetworkDataStatus(secretManager, img)
-	if err == nil {
-		return setImage(generation, &img.Status, url, format,
-			secretStatus, img.Spec.Architecture,
-			"Set default image"), nil
-	}
-
+	secretStatus, networkData, err := getNetworkData(secretManager, img)
 	if k8serrors.IsNotFound(err) {
 		log.Info("network data Secret does not exist")
 		return setError(generation, &img.Status, reasonMissingNetworkData, "NetworkData secret not found"), err
 	}
+	if err != nil {
+		return false, err
+	}
 
-	return false, err
+	if r.ImageBuilder == nil || secretStatus.Name == "" {
+		return setImage(generation, &img.Status, url, format, "", "",
+			secretStatus, img.Spec.Architecture,
+			"Set default image"), nil
+	}
+	if networkData == nil {
+		return setError(generation, &img.Status, reasonMissingNetworkData, "NetworkData secret has no networkData"), nil
+	}
+
+	built, err := r.ImageBuilder.Build(ctx, img.Namespace, img.Name, format, url, networkData)
+	if errors.Is(err, imagebuilder.ErrBaseImageDownloading) {
+		return false, err
+	}
+	if err != nil {
+		log.Error(err, "failed to build image")
+		return setError(generation, &img.Status, reasonImageBuildError, err.Error()), imageBuildFailedError{err}
+	}
+	return setImage(generation, &img.Status, built.URL, format, built.Checksum, metal3.SHA256,
+		secretStatus, img.Spec.Architecture,
+		"Built image with network data"), nil
 }
 
-func getImageURL() (url string, format metal3.ImageFormat, errorMessage string) {
+func getImageURL(deployImages deployimages.Config, arch string) (url string, format metal3.ImageFormat, errorMessage string) {
 	format = metal3.ImageFormatISO
+	if len(deployImages.ByArch) > 0 && arch != "" {
+		if images, ok := deployImages.ByArch[arch]; ok && images.ISOURL != "" {
+			url = images.ISOURL
+		} else {
+			errorMessage = fmt.Sprintf("No deploy ISO configured for the %s architecture", arch)
+		}
+		return
+	}
 	if iso := os.Getenv("DEPLOY_ISO"); iso != "" {
 		url = iso
 	} else {
@@ -126,6 +195,37 @@ func getImageURL() (url string, format metal3.ImageFormat, errorMessage string)
 	return
 }
 
+// getBaseImageURL returns the deploy image of the architecture to build
+// from, in the first of the accepted formats that is configured.
+func getBaseImageURL(deployImages deployimages.Config, arch string, acceptFormats []metal3.ImageFormat) (url string, format metal3.ImageFormat, errorMessage string) {
+	images := deployImages.Default
+	if len(deployImages.ByArch) > 0 && arch != "" {
+		var ok bool
+		if images, ok = deployImages.ByArch[arch]; !ok {
+			errorMessage = fmt.Sprintf("No deploy image configured for the %s architecture", arch)
+			return
+		}
+	}
+
+	if len(acceptFormats) == 0 {
+		acceptFormats = []metal3.ImageFormat{metal3.ImageFormatISO}
+	}
+	for _, format = range acceptFormats {
+		switch format {
+		case metal3.ImageFormatISO:
+			url = images.ISOURL
+		case metal3.ImageFormatInitRD:
+			url = images.RamdiskURL
+		}
+		if url != "" {
+			return
+		}
+	}
+	format = acceptFormats[0]
+	errorMessage = fmt.Sprintf("No deploy image configured in the accepted formats %v", acceptFormats)
+	return
+}
+
 func getErrorRetryDelay(status metal3.PreprovisioningImageStatus) time.Duration {
 	errorCond := meta.FindStatusCondition(status.Conditions, string(metal3.ConditionImageError))
 	if errorCond == nil || errorCond.Status != metav1.ConditionTrue {
@@ -141,10 +241,12 @@ func getErrorRetryDelay(status metal3.PreprovisioningImageStatus) time.Duration
 	return delay
 }
 
-func getNetworkDataStatus(secretManager secretutils.SecretManager, img *metal3.PreprovisioningImage) (metal3.SecretStatus, error) {
+// getNetworkData returns the version of the network data Secret and the
+// network data it holds, or nil if it has none.
+func getNetworkData(secretManager secretutils.SecretManager, img *metal3.PreprovisioningImage) (metal3.SecretStatus, []byte, error) {
 	networkDataSecret := img.Spec.NetworkDataName
 	if networkDataSecret == "" {
-		return metal3.SecretStatus{}, nil
+		return metal3.SecretStatus{}, nil, nil
 	}
 
 	secretKey := client.ObjectKey{
@@ -153,13 +255,17 @@ func getNetworkDataStatus(secretManager secretutils.SecretManager, img *metal3.P
 	}
 	secret, err := secretManager.AcquireSecret(secretKey, img, false)
 	if err != nil {
-		return metal3.SecretStatus{}, err
+		return metal3.SecretStatus{}, nil, err
 	}
 
+	networkData, ok := secret.Data["networkData"]
+	if !ok {
+		networkData = secret.Data["value"]
+	}
 	return metal3.SecretStatus{
 		Name:    networkDataSecret,
 		Version: secret.GetResourceVersion(),
-	}, nil
+	}, networkData, nil
 }
 
 func setCondition(generation int64, status *metal3.PreprovisioningImageStatus,
@@ -177,14 +283,15 @@ func setCondition(generation int64, status *metal3.PreprovisioningImageStatus,
 }
 
 func setImage(generation int64, status *metal3.PreprovisioningImageStatus, url string,
-	format metal3.ImageFormat, networkData metal3.SecretStatus, arch string,
+	format metal3.ImageFormat, checksum string, checksumType metal3.ChecksumType,
+	networkData metal3.SecretStatus, arch string,
 	message string) bool {
 
 	newStatus := status.DeepCopy()
 	newStatus.ImageUrl = url
 	newStatus.Format = format
-	newStatus.Checksum = ""
-	newStatus.ChecksumType = ""
+	newStatus.Checksum = checksum
+	newStatus.ChecksumType = checksumType
 	newStatus.Architecture = arch
 	newStatus.NetworkData = networkData
 
@@ -222,18 +329,76 @@ func setError(generation int64, status *metal3.PreprovisioningImageStatus, reaso
 }
 
 func (r *PreprovisioningImageReconciler) CanStart() bool {
-	deployISOURL := os.Getenv("DEPLOY_ISO_URL")
-	hasCfg := deployISOURL != ""
+	if err := r.DeployImages.Validate(true); err != nil {
+		r.Log.Error(err, "not starting preprovisioning image controller")
+		return false
+	}
+	images := r.DeployImages
+	hasCfg := images.Default.ISOURL != "" || images.ByArch.ISOs()
+	if r.ImageBuilder != nil {
+		// The builder can also build initramfs images
+		hasCfg = hasCfg || images.Default.RamdiskURL != "" || images.ByArch.Ramdisks()
+	}
 	if hasCfg {
 		r.Log.Info("have deploy image data",
-			"iso_url", deployISOURL)
+			"iso_url", images.Default.ISOURL,
+			"ramdisk_url", images.Default.RamdiskURL,
+			"images_by_arch", images.ByArch)
 	} else {
 		r.Log.Info("not starting preprovisioning image controller; no image data available")
 	}
 	return hasCfg
 }
 
+// baseImageURLs returns the URLs of all the configured deploy images the
+// builder may build from.
+func baseImageURLs(deployImages deployimages.Config) []string {
+	var urls []string
+	add := func(images deployimages.Images) {
+		for _, url := range []string{images.ISOURL, images.RamdiskURL} {
+			if url != "" {
+				urls = append(urls, url)
+			}
+		}
+	}
+	add(deployImages.Default)
+	for _, images := range deployImages.ByArch {
+		add(images)
+	}
+	return urls
+}
+
+// removeUnusedImages removes the images built for PreprovisioningImages
+// deleted while the operator was not running, and the base images that
+// are no longer configured. Failures are only logged, as the images are
+// removed again on the next start.
+func (r *PreprovisioningImageReconciler) removeUnusedImages(ctx context.Context) error {
+	if err := r.ImageBuilder.RemoveUnusedBaseImages(baseImageURLs(r.DeployImages)); err != nil {
+		r.Log.Error(err, "failed to remove unused base images")
+	}
+
+	imgs := metal3.PreprovisioningImageList{}
+	if err := r.List(ctx, &imgs); err != nil {
+		r.Log.Error(err, "failed to list preprovisioning images")
+		return nil
+	}
+	objects := make([]types.NamespacedName, 0, len(imgs.Items))
+	for _, img := range imgs.Items {
+		objects = append(objects, types.NamespacedName{Namespace: img.Namespace, Name: img.Name})
+	}
+	if err := r.ImageBuilder.RemoveUnusedImages(objects); err != nil {
+		r.Log.Error(err, "failed to remove unused preprovisioning images")
+	}
+	return nil
+}
+
 func (r *PreprovisioningImageReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.ImageBuilder != nil {
+		// Started once the cache is synced
+		if err := mgr.Add(manager.RunnableFunc(r.removeUnusedImages)); err != nil {
+			return fmt.Errorf("failed to add the removal of unused images: %w", err)
+		}
+	}
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&metal3.PreprovisioningImage{}).
 		Owns(&corev1.Secret{}).
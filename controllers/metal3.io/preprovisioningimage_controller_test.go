@@ -0,0 +1,114 @@
+package controllers
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	fakeclient "sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	metal3 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/deployimages"
+	"github.com/metal3-io/baremetal-operator/pkg/imagebuilder"
+)
+
+func TestGetBaseImageURL(t *testing.T) {
+	defaultImages := deployimages.Images{
+		KernelURL:  "http://images/ipa.kernel",
+		RamdiskURL: "http://images/ipa.initramfs",
+		ISOURL:     "http://images/ipa.iso",
+	}
+	imagesByArch := deployimages.ByArch{
+		"aarch64": {
+			KernelURL:  "http://images/aarch64.kernel",
+			RamdiskURL: "http://images/aarch64.initramfs",
+		},
+	}
+
+	testCases := []struct {
+		Scenario       string
+		ImagesByArch   deployimages.ByArch
+		Arch           string
+		AcceptFormats  []metal3.ImageFormat
+		ExpectedURL    string
+		ExpectedFormat metal3.ImageFormat
+		ExpectedError  string
+	}{
+		{
+			Scenario:       "ISO by default",
+			ExpectedURL:    "http://images/ipa.iso",
+			ExpectedFormat: metal3.ImageFormatISO,
+		},
+		{
+			Scenario:       "preferred format",
+			AcceptFormats:  []metal3.ImageFormat{metal3.ImageFormatInitRD, metal3.ImageFormatISO},
+			ExpectedURL:    "http://images/ipa.initramfs",
+			ExpectedFormat: metal3.ImageFormatInitRD,
+		},
+		{
+			Scenario:       "architecture",
+			ImagesByArch:   imagesByArch,
+			Arch:           "aarch64",
+			AcceptFormats:  []metal3.ImageFormat{metal3.ImageFormatISO, metal3.ImageFormatInitRD},
+			ExpectedURL:    "http://images/aarch64.initramfs",
+			ExpectedFormat: metal3.ImageFormatInitRD,
+		},
+		{
+			Scenario:       "no image in the accepted formats",
+			ImagesByArch:   imagesByArch,
+			Arch:           "aarch64",
+			ExpectedFormat: metal3.ImageFormatISO,
+			ExpectedError:  "No deploy image configured in the accepted formats [iso]",
+		},
+		{
+			Scenario:      "no image for the architecture",
+			ImagesByArch:  imagesByArch,
+			Arch:          "x86_64",
+			ExpectedError: "No deploy image configured for the x86_64 architecture",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			deployImages := deployimages.Config{Default: defaultImages, ByArch: tc.ImagesByArch}
+			url, format, errorMessage := getBaseImageURL(deployImages, tc.Arch, tc.AcceptFormats)
+			assert.Equal(t, tc.ExpectedError, errorMessage)
+			if tc.ExpectedError == "" {
+				assert.Equal(t, tc.ExpectedURL, url)
+				assert.Equal(t, tc.ExpectedFormat, format)
+			}
+		})
+	}
+}
+
+func TestRemoveUnusedImages(t *testing.T) {
+	dir := t.TempDir()
+	builder, err := imagebuilder.New(ctrl.Log.WithName("imagebuilder"), dir, ":0", "http://images.test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"myns_myhost_0123456789abcdef.iso", "myns_deleted_0123456789abcdef.iso"} {
+		if err := ioutil.WriteFile(filepath.Join(dir, "images", name), []byte("image"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	img := &metal3.PreprovisioningImage{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "myns", Name: "myhost"},
+	}
+	r := &PreprovisioningImageReconciler{
+		Client:       fakeclient.NewFakeClient(img),
+		Log:          ctrl.Log.WithName("controllers").WithName("PreprovisioningImage"),
+		ImageBuilder: builder,
+	}
+
+	assert.NoError(t, r.removeUnusedImages(context.Background()))
+	files, err := ioutil.ReadDir(filepath.Join(dir, "images"))
+	assert.NoError(t, err)
+	if assert.Len(t, files, 1) {
+		assert.Equal(t, "myns_myhost_0123456789abcdef.iso", files[0].Name())
+	}
+}
@@ -0,0 +1,62 @@
+package controllers
+
+import (
+	"bytes"
+	"text/template"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+// userDataTemplateData holds the facts about a host that user data
+// templates are rendered with.
+type userDataTemplateData struct {
+	Name        string
+	Namespace   string
+	Labels      map[string]string
+	Annotations map[string]string
+
+	// BootMACAddress is the MAC address of the NIC the host boots from.
+	BootMACAddress string
+	// Hostname is the host name found by the inspection, if any.
+	Hostname string
+	// HardwareDetails are nil until the host has been inspected.
+	HardwareDetails *metal3v1alpha1.HardwareDetails
+	// NICs are the NICs of the hardware details.
+	NICs []metal3v1alpha1.NIC
+	// Storage are the disks of the hardware details.
+	Storage []metal3v1alpha1.Storage
+	// RootDeviceHints select the disk the image is written to. The
+	// agent on the host picks the disk, so it is not known here.
+	RootDeviceHints *metal3v1alpha1.RootDeviceHints
+}
+
+// renderUserData renders a user data template with the facts about a
+// host. Missing map keys, such as labels, are errors rather than being
+// rendered as <no value>.
+func renderUserData(host *metal3v1alpha1.BareMetalHost, userData string) (string, error) {
+	tmpl, err := template.New("userData").Option("missingkey=error").Parse(userData)
+	if err != nil {
+		return "", err
+	}
+
+	data := userDataTemplateData{
+		Name:            host.Name,
+		Namespace:       host.Namespace,
+		Labels:          host.Labels,
+		Annotations:     host.Annotations,
+		BootMACAddress:  host.Spec.BootMACAddress,
+		HardwareDetails: host.Status.HardwareDetails,
+		RootDeviceHints: host.Status.Provisioning.RootDeviceHints,
+	}
+	if details := host.Status.HardwareDetails; details != nil {
+		data.Hostname = details.Hostname
+		data.NICs = details.NIC
+		data.Storage = details.Storage
+	}
+
+	var out bytes.Buffer
+	if err := tmpl.Execute(&out, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
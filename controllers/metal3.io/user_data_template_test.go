@@ -0,0 +1,163 @@
+package controllers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+func templateHost() *metal3v1alpha1.BareMetalHost {
+	host := host(metal3v1alpha1.StateProvisioning).build()
+	host.Namespace = namespace
+	host.Labels = map[string]string{"rack": "r1"}
+	host.Spec.BootMACAddress = "00:11:22:33:44:55"
+	host.Spec.UserData = &corev1.SecretReference{Name: "user-data"}
+	host.Status.HardwareDetails = &metal3v1alpha1.HardwareDetails{
+		Hostname: "node-1",
+		NIC: []metal3v1alpha1.NIC{
+			{Name: "eth0", MAC: "00:11:22:33:44:55"},
+			{Name: "eth1", MAC: "00:11:22:33:44:66"},
+		},
+		Storage: []metal3v1alpha1.Storage{
+			{Name: "/dev/sda", SizeBytes: 500 * metal3v1alpha1.GigaByte, Model: "Big Disk"},
+			{Name: "/dev/sdb", SizeBytes: 100 * metal3v1alpha1.GigaByte, Model: "Small Disk"},
+			{Name: "/dev/sdc", SizeBytes: metal3v1alpha1.GigaByte},
+		},
+	}
+	return host
+}
+
+func userDataSecret(userData string, template bool) *corev1.Secret {
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "user-data",
+			Namespace: namespace,
+		},
+		Data: map[string][]byte{"userData": []byte(userData)},
+	}
+	if template {
+		secret.Annotations = map[string]string{metal3v1alpha1.UserDataTemplateAnnotation: "true"}
+	}
+	return secret
+}
+
+func TestRenderUserData(t *testing.T) {
+	testCases := []struct {
+		Scenario      string
+		Template      string
+		Hints         *metal3v1alpha1.RootDeviceHints
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Scenario: "metadata and labels",
+			Template: "{{ .Name }} {{ .Namespace }} {{ .Labels.rack }} {{ .Hostname }}",
+			Expected: "myhost myns r1 node-1",
+		},
+		{
+			Scenario: "NIC MACs",
+			Template: "{{ range .NICs }}{{ .Name }}={{ .MAC }} {{ end }}{{ .BootMACAddress }}",
+			Expected: "eth0=00:11:22:33:44:55 eth1=00:11:22:33:44:66 00:11:22:33:44:55",
+		},
+		{
+			Scenario: "disks",
+			Template: "{{ range .Storage }}{{ .Name }}={{ .Model }} {{ end }}",
+			Expected: "/dev/sda=Big Disk /dev/sdb=Small Disk /dev/sdc= ",
+		},
+		{
+			Scenario: "root device hints",
+			Template: "{{ with .RootDeviceHints }}{{ .DeviceName }}{{ else }}none{{ end }}",
+			Hints:    &metal3v1alpha1.RootDeviceHints{DeviceName: "/dev/sda"},
+			Expected: "/dev/sda",
+		},
+		{
+			Scenario: "no root device hints",
+			Template: "{{ with .RootDeviceHints }}{{ .DeviceName }}{{ else }}none{{ end }}",
+			Expected: "none",
+		},
+		{
+			Scenario:      "missing label",
+			Template:      "{{ .Labels.zone }}",
+			ExpectedError: `map has no entry for key "zone"`,
+		},
+		{
+			Scenario:      "syntax error",
+			Template:      "{{ .Name ",
+			ExpectedError: "unclosed action",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			host := templateHost()
+			host.Name, host.Namespace = "myhost", "myns"
+			host.Status.Provisioning.RootDeviceHints = tc.Hints
+
+			rendered, err := renderUserData(host, tc.Template)
+			if tc.ExpectedError != "" {
+				assert.Regexp(t, tc.ExpectedError, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Expected, rendered)
+		})
+	}
+}
+
+func TestUserDataTemplate(t *testing.T) {
+	for _, template := range []bool{false, true} {
+		host := templateHost()
+		r := newTestReconciler(userDataSecret("host {{ .Hostname }}", template))
+		hcd := &hostConfigData{
+			host:          host,
+			log:           r.Log,
+			secretManager: r.secretManager(r.Log),
+		}
+		userData, err := hcd.UserData()
+		assert.NoError(t, err)
+		if template {
+			assert.Equal(t, "host node-1", userData)
+		} else {
+			assert.Equal(t, "host {{ .Hostname }}", userData)
+		}
+	}
+}
+
+func TestReadyUserDataError(t *testing.T) {
+	host := templateHost()
+	host.Status.Provisioning.State = metal3v1alpha1.StateAvailable
+	host.Spec.Online = true
+	host.Spec.Image = &metal3v1alpha1.Image{URL: "http://images.test/os.img"}
+	r := newTestReconciler(userDataSecret("{{ .Labels.zone }}", true))
+	info := makeReconcileInfo(host)
+
+	result := r.actionManageReady(nil, info)
+	if assert.IsType(t, actionFailed{}, result) {
+		assert.Equal(t, metal3v1alpha1.UserDataError, result.(actionFailed).ErrorType)
+	}
+	assert.Equal(t, metal3v1alpha1.UserDataError, host.Status.ErrorType)
+	assert.Contains(t, host.Status.ErrorMessage, "Failed to render the user data template of Secret user-data")
+	if assert.Len(t, info.events, 1) {
+		assert.Equal(t, "UserDataError", info.events[0].Reason)
+	}
+
+	// The host stays ready with the error, which is not recorded again
+	result = r.actionManageReady(nil, info)
+	assert.IsType(t, actionFailed{}, result)
+	assert.False(t, result.Dirty())
+	assert.Equal(t, 1, host.Status.ErrorCount)
+	assert.Len(t, info.events, 1)
+
+	// Provisioning starts once the template is fixed
+	secret := &corev1.Secret{}
+	assert.NoError(t, r.Get(context.TODO(), client.ObjectKey{Namespace: namespace, Name: "user-data"}, secret))
+	secret.Data["userData"] = []byte("{{ .Labels.rack }}")
+	assert.NoError(t, r.Update(context.TODO(), secret))
+	assert.Equal(t, actionComplete{}, r.actionManageReady(nil, info))
+	assert.Empty(t, host.Status.ErrorType)
+}
@@ -19,8 +19,10 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"runtime"
+	"time"
 
 	k8sruntime "k8s.io/apimachinery/pkg/runtime"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
@@ -32,12 +34,18 @@ import (
 
 	metal3iov1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	metal3iocontroller "github.com/metal3-io/baremetal-operator/controllers/metal3.io"
+	"github.com/metal3-io/baremetal-operator/pkg/deployimages"
+	"github.com/metal3-io/baremetal-operator/pkg/hardware"
+	"github.com/metal3-io/baremetal-operator/pkg/imagebuilder"
+	"github.com/metal3-io/baremetal-operator/pkg/imagevalidation"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/demo"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/fixture"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/redfish"
 	"github.com/metal3-io/baremetal-operator/pkg/secretutils"
 	"github.com/metal3-io/baremetal-operator/pkg/version"
+	metal3iowebhooks "github.com/metal3-io/baremetal-operator/webhooks/metal3.io"
 	// +kubebuilder:scaffold:imports
 )
 
@@ -62,12 +70,21 @@ func printVersion() {
 	setupLog.Info(fmt.Sprintf("Component: %s", version.String))
 }
 
-func setupChecks(mgr ctrl.Manager) {
+func setupChecks(mgr ctrl.Manager, provisionerFactory provisioner.Factory) {
 	if err := mgr.AddReadyzCheck("ping", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to create ready check")
 		os.Exit(1)
 	}
 
+	if reloading, ok := provisionerFactory.(provisioner.ReloadingFactory); ok {
+		if err := mgr.AddReadyzCheck("provisioner", func(_ *http.Request) error {
+			return reloading.Ready()
+		}); err != nil {
+			setupLog.Error(err, "unable to create provisioner ready check")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("ping", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to create health check")
 		os.Exit(1)
@@ -82,6 +99,16 @@ func main() {
 	var devLogging bool
 	var runInTestMode bool
 	var runInDemoMode bool
+	var runWithRedfish bool
+	var webhookPort int
+	var hardwareProfilesFile string
+	var powerPollInterval time.Duration
+	var healthPollInterval time.Duration
+	var watchPowerState bool
+	var preprovImgDir string
+	var preprovImgAddr string
+	var preprovImgURL string
+	var validateImages bool
 
 	// From CAPI point of view, BMO should be able to watch all namespaces
 	// in case of a deployment that is not multi-tenant. If the deployment
@@ -99,14 +126,41 @@ func main() {
 	flag.BoolVar(&runInTestMode, "test-mode", false, "disable ironic communication")
 	flag.BoolVar(&runInDemoMode, "demo-mode", false,
 		"use the demo provisioner to set host states")
+	flag.BoolVar(&runWithRedfish, "redfish-provisioner", false,
+		"use the Redfish provisioner to manage hosts through their BMC without Ironic")
 	flag.StringVar(&healthAddr, "health-addr", ":9440",
 		"The address the health endpoint binds to.")
+	flag.IntVar(&webhookPort, "webhook-port", 9443,
+		"Webhook Server port (set to 0 to disable)")
+	flag.StringVar(&hardwareProfilesFile, "hardware-profiles", os.Getenv("HARDWARE_PROFILES_FILE"),
+		"YAML file holding additional hardware profiles")
+	flag.DurationVar(&powerPollInterval, "power-poll-interval", time.Second*60,
+		"How often the power state of hosts in a steady state is checked")
+	flag.DurationVar(&healthPollInterval, "health-poll-interval", time.Minute*5,
+		"How often the health of the hardware of hosts is collected")
+	flag.BoolVar(&watchPowerState, "watch-power-state", false,
+		"Reconcile the hosts whose power state changed without waiting for the next poll")
+	flag.BoolVar(&validateImages, "validate-images", false,
+		"Check that the image of a host can be downloaded and matches its format and checksum before provisioning it")
+	flag.StringVar(&preprovImgDir, "preprov-image-dir", os.Getenv("PREPROV_IMAGE_DIR"),
+		"Directory in which to build preprovisioning images embedding the network data (requires -build-preprov-image)")
+	flag.StringVar(&preprovImgAddr, "preprov-image-addr", ":6190",
+		"The address the preprovisioning image server binds to.")
+	flag.StringVar(&preprovImgURL, "preprov-image-url", os.Getenv("PREPROV_IMAGE_URL"),
+		"The URL at which the hosts download the built preprovisioning images")
 	flag.Parse()
 
 	ctrl.SetLogger(zap.New(zap.UseDevMode(devLogging)))
 
 	printVersion()
 
+	if hardwareProfilesFile != "" {
+		if err := hardware.LoadProfiles(hardwareProfilesFile); err != nil {
+			setupLog.Error(err, "unable to load hardware profiles")
+			os.Exit(1)
+		}
+	}
+
 	leaderElectionNamespace := os.Getenv("POD_NAMESPACE")
 	if leaderElectionNamespace == "" {
 		leaderElectionNamespace = watchNamespace
@@ -115,7 +169,7 @@ func main() {
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme:                  scheme,
 		MetricsBindAddress:      metricsAddr,
-		Port:                    0, // Add flag with default of 9443 when adding webhooks
+		Port:                    webhookPort,
 		LeaderElection:          enableLeaderElection,
 		LeaderElectionID:        "baremetal-operator",
 		LeaderElectionNamespace: leaderElectionNamespace,
@@ -131,6 +185,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	deployImages, err := deployimages.FromEnv()
+	if err != nil {
+		setupLog.Error(err, "unable to load deploy images")
+		os.Exit(1)
+	}
+
 	var provisionerFactory provisioner.Factory
 	if runInTestMode {
 		ctrl.Log.Info("using test provisioner")
@@ -138,8 +198,32 @@ func main() {
 	} else if runInDemoMode {
 		ctrl.Log.Info("using demo provisioner")
 		provisionerFactory = &demo.Demo{}
+	} else if runWithRedfish {
+		ctrl.Log.Info("using redfish provisioner")
+		provisionerFactory = redfish.NewProvisionerFactory()
 	} else {
-		provisionerFactory = ironic.NewProvisionerFactory(preprovImgEnable)
+		ironicFactory := ironic.NewProvisionerFactory(preprovImgEnable, deployImages)
+		// Reload the Ironic credentials and certificates when they
+		// change
+		if err := mgr.Add(ironicFactory); err != nil {
+			setupLog.Error(err, "unable to watch ironic configuration")
+			os.Exit(1)
+		}
+		provisionerFactory = ironicFactory
+	}
+
+	var powerStateWatcher provisioner.PowerStateWatcher
+	if watchPowerState {
+		var ok bool
+		if powerStateWatcher, ok = provisionerFactory.(provisioner.PowerStateWatcher); !ok {
+			setupLog.Error(nil, "the provisioner cannot watch power states")
+			os.Exit(1)
+		}
+	}
+
+	var imageValidator *imagevalidation.Validator
+	if validateImages {
+		imageValidator = imagevalidation.New()
 	}
 
 	if err = (&metal3iocontroller.BareMetalHostReconciler{
@@ -147,28 +231,70 @@ func main() {
 		Log:                ctrl.Log.WithName("controllers").WithName("BareMetalHost"),
 		ProvisionerFactory: provisionerFactory,
 		APIReader:          mgr.GetAPIReader(),
+		PowerPollInterval:  powerPollInterval,
+		HealthPollInterval: healthPollInterval,
+		PowerStateWatcher:  powerStateWatcher,
+		ImageValidator:     imageValidator,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "BareMetalHost")
 		os.Exit(1)
 	}
 
+	if err = (&metal3iocontroller.HostFirmwareSettingsReconciler{
+		Client:             mgr.GetClient(),
+		Log:                ctrl.Log.WithName("controllers").WithName("HostFirmwareSettings"),
+		Scheme:             mgr.GetScheme(),
+		ProvisionerFactory: provisionerFactory,
+		APIReader:          mgr.GetAPIReader(),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HostFirmwareSettings")
+		os.Exit(1)
+	}
+
+	if err = (&metal3iocontroller.HostClaimReconciler{
+		Client: mgr.GetClient(),
+		Log:    ctrl.Log.WithName("controllers").WithName("HostClaim"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "HostClaim")
+		os.Exit(1)
+	}
+
 	if preprovImgEnable {
 		imgReconciler := metal3iocontroller.PreprovisioningImageReconciler{
-			Client:    mgr.GetClient(),
-			Log:       ctrl.Log.WithName("controllers").WithName("PreprovisioningImage"),
-			APIReader: mgr.GetAPIReader(),
-			Scheme:    mgr.GetScheme(),
+			Client:       mgr.GetClient(),
+			Log:          ctrl.Log.WithName("controllers").WithName("PreprovisioningImage"),
+			APIReader:    mgr.GetAPIReader(),
+			Scheme:       mgr.GetScheme(),
+			DeployImages: deployImages,
+		}
+		if preprovImgDir != "" {
+			imgReconciler.ImageBuilder, err = imagebuilder.New(
+				ctrl.Log.WithName("imagebuilder"), preprovImgDir, preprovImgAddr, preprovImgURL)
+			if err != nil {
+				setupLog.Error(err, "unable to create preprovisioning image builder")
+				os.Exit(1)
+			}
 		}
 		if imgReconciler.CanStart() {
 			if err = (&imgReconciler).SetupWithManager(mgr); err != nil {
 				setupLog.Error(err, "unable to create controller", "controller", "PreprovisioningImage")
 				os.Exit(1)
 			}
+			if imgReconciler.ImageBuilder != nil {
+				if err := mgr.Add(imgReconciler.ImageBuilder); err != nil {
+					setupLog.Error(err, "unable to serve preprovisioning images")
+					os.Exit(1)
+				}
+			}
 		}
 	}
 	// +kubebuilder:scaffold:builder
 
-	setupChecks(mgr)
+	if webhookPort != 0 {
+		metal3iowebhooks.SetupWebhookWithManager(mgr)
+	}
+
+	setupChecks(mgr, provisionerFactory)
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net"
 	"net/url"
+	"sort"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -65,11 +66,19 @@ type AccessDetails interface {
 	// Whether the driver supports changing secure boot state.
 	SupportsSecureBoot() bool
 
+	// Whether the driver can verify the BMC certificate against a
+	// custom CA bundle.
+	SupportsCACertificate() bool
+
 	// Whether the driver supports booting a preprovisioning image in ISO format
 	SupportsISOPreprovisioningImage() bool
 
 	// Build bios clean steps for ironic
 	BuildBIOSSettings(firmwareConfig *metal3v1alpha1.FirmwareConfig) (settings []map[string]string, err error)
+
+	// Build the arguments of the update_firmware clean step of the
+	// management interface for ironic
+	BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error)
 }
 
 func getParsedURL(address string) (parsedURL *url.URL, err error) {
@@ -136,3 +145,100 @@ func NewAccessDetails(address string, disableCertificateVerification bool) (Acce
 
 	return factory(parsedURL, disableCertificateVerification)
 }
+
+// hasVendorBIOSSettings returns true if any of the settings that need
+// a vendor specific mapping to a BIOS setting name are set.
+func hasVendorBIOSSettings(firmwareConfig *metal3v1alpha1.FirmwareConfig) bool {
+	if firmwareConfig == nil {
+		return false
+	}
+	return firmwareConfig.VirtualizationEnabled != nil ||
+		firmwareConfig.SimultaneousMultithreadingEnabled != nil ||
+		firmwareConfig.SriovEnabled != nil
+}
+
+// addCustomBIOSSettings adds the arbitrary settings from the firmware
+// config to the vendor specific settings already built, replacing any
+// of them with the same name. The custom settings are added in order
+// of their names so that the clean steps are stable.
+func addCustomBIOSSettings(settings []map[string]string, firmwareConfig *metal3v1alpha1.FirmwareConfig) []map[string]string {
+	if firmwareConfig == nil || len(firmwareConfig.Settings) == 0 {
+		return settings
+	}
+
+	result := []map[string]string{}
+	for _, setting := range settings {
+		if _, overridden := firmwareConfig.Settings[setting["name"]]; !overridden {
+			result = append(result, setting)
+		}
+	}
+
+	names := make([]string, 0, len(firmwareConfig.Settings))
+	for name := range firmwareConfig.Settings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		result = append(result,
+			map[string]string{
+				"name":  name,
+				"value": firmwareConfig.Settings[name],
+			},
+		)
+	}
+	return result
+}
+
+// buildRedfishFirmwareUpdateArgs builds the arguments of the
+// update_firmware step of the redfish management interface. The BMC
+// works out which component each image applies to.
+func buildRedfishFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) map[string]interface{} {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	images := []map[string]interface{}{}
+	for _, update := range updates {
+		images = append(images,
+			map[string]interface{}{
+				"url":      update.URL,
+				"checksum": update.Checksum,
+			},
+		)
+	}
+	return map[string]interface{}{
+		"firmware_images": images,
+	}
+}
+
+// buildILOFirmwareUpdateArgs builds the arguments of the
+// update_firmware step of the iLO management interfaces.
+func buildILOFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (map[string]interface{}, error) {
+	if len(updates) == 0 {
+		return nil, nil
+	}
+
+	images := []map[string]interface{}{}
+	for _, update := range updates {
+		var component string
+		switch update.Component {
+		case metal3v1alpha1.FirmwareComponentBIOS:
+			component = "bios"
+		case metal3v1alpha1.FirmwareComponentBMC:
+			component = "ilo"
+		default:
+			return nil, fmt.Errorf("firmware updates for component %s are not supported by iLO", update.Component)
+		}
+		images = append(images,
+			map[string]interface{}{
+				"url":       update.URL,
+				"checksum":  update.Checksum,
+				"component": component,
+			},
+		)
+	}
+	return map[string]interface{}{
+		"firmware_update_mode": "ilo",
+		"firmware_images":      images,
+	}, nil
+}
@@ -1,13 +1,18 @@
 package bmc
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
 	logf "sigs.k8s.io/controller-runtime/pkg/log"
 	logz "sigs.k8s.io/controller-runtime/pkg/log/zap"
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc/emulator"
 )
 
 func init() {
@@ -1132,6 +1137,156 @@ func TestDriverInfo(t *testing.T) {
 	}
 }
 
+func TestDriverInfoCACertificate(t *testing.T) {
+	for _, tc := range []struct {
+		Scenario  string
+		input     string
+		disableCV bool
+		supported bool
+		key       string
+		expects   interface{}
+	}{
+		{
+			Scenario:  "redfish",
+			input:     "redfish://192.168.122.1",
+			supported: true,
+			key:       "redfish_verify_ca",
+			expects:   "/certs/ca.crt",
+		},
+		{
+			Scenario:  "redfish verification disabled",
+			input:     "redfish://192.168.122.1",
+			disableCV: true,
+			supported: true,
+			key:       "redfish_verify_ca",
+			expects:   false,
+		},
+		{
+			Scenario:  "idrac-redfish",
+			input:     "idrac-redfish://192.168.122.1",
+			supported: true,
+			key:       "redfish_verify_ca",
+			expects:   "/certs/ca.crt",
+		},
+		{
+			Scenario:  "redfish virtual media",
+			input:     "redfish-virtualmedia://192.168.122.1",
+			supported: true,
+			key:       "redfish_verify_ca",
+			expects:   "/certs/ca.crt",
+		},
+		{
+			Scenario:  "idrac virtual media",
+			input:     "idrac-virtualmedia://192.168.122.1",
+			supported: true,
+			key:       "redfish_verify_ca",
+			expects:   "/certs/ca.crt",
+		},
+		{
+			Scenario:  "ilo5",
+			input:     "ilo5://192.168.122.1",
+			supported: true,
+			key:       "ilo_verify_ca",
+			expects:   "/certs/ca.crt",
+		},
+		{
+			Scenario:  "irmc",
+			input:     "irmc://192.168.122.1",
+			supported: true,
+			key:       "irmc_verify_ca",
+			expects:   "/certs/ca.crt",
+		},
+		{
+			Scenario: "ilo4",
+			input:    "ilo4://192.168.122.1",
+			key:      "ilo_verify_ca",
+		},
+		{
+			Scenario: "idrac",
+			input:    "idrac://192.168.122.1",
+			key:      "drac_verify_ca",
+		},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			acc, err := NewAccessDetails(tc.input, tc.disableCV)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			if acc.SupportsCACertificate() != tc.supported {
+				t.Fatalf("unexpected CA certificate support %v", acc.SupportsCACertificate())
+			}
+			di := acc.DriverInfo(Credentials{CACertificatePath: "/certs/ca.crt"})
+			value, ok := di[tc.key]
+			if tc.expects == nil {
+				if ok {
+					t.Fatalf("unexpected value for %s: %v", tc.key, value)
+				}
+				return
+			}
+			if value != tc.expects {
+				t.Fatalf("unexpected value for %s: %v, expected %v", tc.key, value, tc.expects)
+			}
+		})
+	}
+}
+
+func TestRedfishDriverInfoEmulator(t *testing.T) {
+	bmcServer := httptest.NewServer(emulator.New("admin", "password").
+		AddSystem(emulator.DefaultSystem("1")).
+		AddSystem(emulator.DefaultSystem("System.Embedded.1")))
+	defer bmcServer.Close()
+	host := strings.TrimPrefix(bmcServer.URL, "http://")
+
+	for _, tc := range []struct {
+		Scenario string
+		input    string
+	}{
+		{
+			Scenario: "redfish",
+			input:    "redfish+http://" + host + "/redfish/v1/Systems/1",
+		},
+		{
+			Scenario: "redfish virtual media",
+			input:    "redfish-virtualmedia+http://" + host + "/redfish/v1/Systems/1",
+		},
+		{
+			Scenario: "idrac-redfish",
+			input:    "idrac-redfish+http://" + host + "/redfish/v1/Systems/System.Embedded.1",
+		},
+		{
+			Scenario: "idrac virtual media",
+			input:    "idrac-virtualmedia+http://" + host + "/redfish/v1/Systems/System.Embedded.1",
+		},
+		{
+			Scenario: "ilo5-redfish",
+			input:    "ilo5-redfish+http://" + host + "/redfish/v1/Systems/1",
+		},
+	} {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			acc, err := NewAccessDetails(tc.input, false)
+			if err != nil {
+				t.Fatalf("unexpected parse error: %v", err)
+			}
+			di := acc.DriverInfo(Credentials{Username: "admin", Password: "password"})
+
+			url := fmt.Sprintf("%s%s", di["redfish_address"], di["redfish_system_id"])
+			req, err := http.NewRequest(http.MethodGet, url, nil)
+			if err != nil {
+				t.Fatalf("unexpected request error: %v", err)
+			}
+			req.SetBasicAuth(di["redfish_username"].(string), di["redfish_password"].(string))
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatalf("failed to reach the system: %v", err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("unexpected status %d for %s", resp.StatusCode, url)
+			}
+		})
+	}
+}
+
 func TestUnknownType(t *testing.T) {
 	acc, err := NewAccessDetails("foo://192.168.122.1", false)
 	if err == nil || acc != nil {
@@ -1243,6 +1398,77 @@ func TestBuildBIOSCleanSteps(t *testing.T) {
 			firmware: &metal3v1alpha1.FirmwareConfig{},
 			expected: nil,
 		},
+		// custom settings
+		{
+			name:    "ilo5, custom settings",
+			address: "ilo5://192.168.122.1",
+			firmware: &metal3v1alpha1.FirmwareConfig{
+				VirtualizationEnabled:             &True,
+				SimultaneousMultithreadingEnabled: &False,
+				Settings: map[string]string{
+					"WorkloadProfile":    "Virtualization-MaxPerformance",
+					"ProcHyperthreading": "Enabled",
+				},
+			},
+			expected: []map[string]string{
+				{
+					"name":  "ProcVirtualization",
+					"value": "Enabled",
+				},
+				{
+					"name":  "ProcHyperthreading",
+					"value": "Enabled",
+				},
+				{
+					"name":  "WorkloadProfile",
+					"value": "Virtualization-MaxPerformance",
+				},
+			},
+		},
+		{
+			name:    "redfish, custom settings",
+			address: "redfish://192.168.122.1",
+			firmware: &metal3v1alpha1.FirmwareConfig{
+				Settings: map[string]string{
+					"ProcTurboMode": "Disabled",
+					"BootMode":      "Uefi",
+				},
+			},
+			expected: []map[string]string{
+				{
+					"name":  "BootMode",
+					"value": "Uefi",
+				},
+				{
+					"name":  "ProcTurboMode",
+					"value": "Disabled",
+				},
+			},
+		},
+		{
+			name:     "redfish, firmware is empty",
+			address:  "redfish://192.168.122.1",
+			firmware: &metal3v1alpha1.FirmwareConfig{},
+			expected: nil,
+		},
+		{
+			name:    "redfish, vendor settings",
+			address: "redfish://192.168.122.1",
+			firmware: &metal3v1alpha1.FirmwareConfig{
+				VirtualizationEnabled: &True,
+			},
+			expectedError: true,
+		},
+		{
+			name:    "ipmi, custom settings",
+			address: "ipmi://192.168.122.1",
+			firmware: &metal3v1alpha1.FirmwareConfig{
+				Settings: map[string]string{
+					"BootMode": "Uefi",
+				},
+			},
+			expectedError: true,
+		},
 	}
 
 	for _, c := range cases {
@@ -1263,3 +1489,118 @@ func TestBuildBIOSCleanSteps(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildFirmwareUpdateArgs(t *testing.T) {
+	bios := metal3v1alpha1.FirmwareUpdate{
+		Component: metal3v1alpha1.FirmwareComponentBIOS,
+		URL:       "http://example.com/bios.bin",
+		Checksum:  "abcdef",
+	}
+	bmcUpdate := metal3v1alpha1.FirmwareUpdate{
+		Component: metal3v1alpha1.FirmwareComponentBMC,
+		URL:       "http://example.com/bmc.bin",
+		Checksum:  "123456",
+	}
+	nic := metal3v1alpha1.FirmwareUpdate{
+		Component: metal3v1alpha1.FirmwareComponentNIC,
+		URL:       "http://example.com/nic.bin",
+		Checksum:  "fedcba",
+	}
+
+	cases := []struct {
+		name          string
+		address       string
+		updates       []metal3v1alpha1.FirmwareUpdate
+		expected      map[string]interface{}
+		expectedError bool
+	}{
+		{
+			name:    "redfish, no updates",
+			address: "redfish://192.168.122.1",
+		},
+		{
+			name:    "redfish",
+			address: "redfish://192.168.122.1",
+			updates: []metal3v1alpha1.FirmwareUpdate{bios, nic},
+			expected: map[string]interface{}{
+				"firmware_images": []map[string]interface{}{
+					{
+						"url":      "http://example.com/bios.bin",
+						"checksum": "abcdef",
+					},
+					{
+						"url":      "http://example.com/nic.bin",
+						"checksum": "fedcba",
+					},
+				},
+			},
+		},
+		{
+			name:    "idrac-virtualmedia",
+			address: "idrac-virtualmedia://192.168.122.1",
+			updates: []metal3v1alpha1.FirmwareUpdate{bmcUpdate},
+			expected: map[string]interface{}{
+				"firmware_images": []map[string]interface{}{
+					{
+						"url":      "http://example.com/bmc.bin",
+						"checksum": "123456",
+					},
+				},
+			},
+		},
+		{
+			name:    "ilo5",
+			address: "ilo5://192.168.122.1",
+			updates: []metal3v1alpha1.FirmwareUpdate{bios, bmcUpdate},
+			expected: map[string]interface{}{
+				"firmware_update_mode": "ilo",
+				"firmware_images": []map[string]interface{}{
+					{
+						"url":       "http://example.com/bios.bin",
+						"checksum":  "abcdef",
+						"component": "bios",
+					},
+					{
+						"url":       "http://example.com/bmc.bin",
+						"checksum":  "123456",
+						"component": "ilo",
+					},
+				},
+			},
+		},
+		{
+			name:          "ilo4, nic",
+			address:       "ilo4://192.168.122.1",
+			updates:       []metal3v1alpha1.FirmwareUpdate{nic},
+			expectedError: true,
+		},
+		{
+			name:    "ipmi, no updates",
+			address: "ipmi://192.168.122.1",
+		},
+		{
+			name:          "ipmi",
+			address:       "ipmi://192.168.122.1",
+			updates:       []metal3v1alpha1.FirmwareUpdate{bios},
+			expectedError: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			acc, err := NewAccessDetails(c.address, false)
+			if err != nil {
+				t.Fatalf("new AccessDetails failed: %v", err)
+			}
+
+			args, err := acc.BuildFirmwareUpdateArgs(c.updates)
+			if (err != nil) != c.expectedError {
+				t.Fatalf("got unexpected error: %v", err)
+			}
+
+			if !reflect.DeepEqual(c.expected, args) {
+				t.Errorf("expected args: %v, got: %v", c.expected, args)
+			}
+		})
+	}
+}
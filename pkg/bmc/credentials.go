@@ -4,6 +4,14 @@ package bmc
 type Credentials struct {
 	Username string
 	Password string
+
+	// CACertificate holds the PEM-encoded CA bundle for verifying the
+	// server certificate of the BMC, if one is configured.
+	CACertificate []byte
+	// CACertificatePath is the location of the CA bundle as seen by
+	// the driver. It is set by the provisioner once the bundle has
+	// been stored.
+	CACertificatePath string
 }
 
 // Validate returns an error if the credentials are invalid
@@ -0,0 +1,397 @@
+/*
+Package emulator provides a Redfish service emulating a BMC, for unit
+tests and local development.
+
+The emulator implements the Systems, Managers, Chassis and
+SessionService resources used to manage hosts, including power actions,
+boot overrides, virtual media, BIOS settings and the health of the
+hardware. Its state can be read
+and changed directly, and failures can be injected into the responses
+to requests.
+*/
+package emulator
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const serviceRoot = "/redfish/v1"
+
+// Processor is a processor of an emulated system.
+type Processor struct {
+	Model          string
+	InstructionSet string
+	MaxSpeedMHz    int
+	TotalCores     int
+	TotalThreads   int
+}
+
+// NIC is an Ethernet interface of an emulated system.
+type NIC struct {
+	ID            string
+	MACAddress    string
+	SpeedMbps     int
+	IPv4Addresses []string
+}
+
+// Drive is a drive of the storage controller of an emulated system.
+type Drive struct {
+	ID            string
+	Model         string
+	SerialNumber  string
+	Manufacturer  string
+	MediaType     string
+	Protocol      string
+	CapacityBytes int64
+	// "OK", "Warning" or "Critical", or "OK" if empty
+	Health string
+}
+
+// Sensor is a fan, temperature sensor or power supply of the chassis
+// of an emulated system. The reading of fans is in RPM, of temperature
+// sensors in degrees Celsius, and of power supplies in Watts.
+type Sensor struct {
+	Name    string
+	Reading float64
+	// "OK", "Warning" or "Critical", or "OK" if empty
+	Health string
+}
+
+// VirtualMedia is the state of a virtual media device.
+type VirtualMedia struct {
+	Image    string
+	Inserted bool
+}
+
+// System is the state of an emulated computer system. Each system has
+// its own manager and chassis with the same ID.
+type System struct {
+	ID           string
+	Manufacturer string
+	Model        string
+	SerialNumber string
+	UUID         string
+	HostName     string
+	BiosVersion  string
+
+	// "On" or "Off"
+	PowerState string
+
+	BootSourceOverrideTarget  string
+	BootSourceOverrideEnabled string
+
+	Processors []Processor
+	MemoryGiB  float64
+	NICs       []NIC
+	Drives     []Drive
+
+	// The health of the processors and of the memory, "OK" if empty
+	ProcessorHealth string
+	MemoryHealth    string
+
+	// The sensors of the chassis
+	Fans          []Sensor
+	Temperatures  []Sensor
+	PowerSupplies []Sensor
+
+	// The current BIOS attributes, and the changes applied on the
+	// next boot
+	BiosAttributes        map[string]interface{}
+	PendingBiosAttributes map[string]interface{}
+
+	// The virtual CD drive of the manager of the system
+	VirtualCD VirtualMedia
+	// The ResetType of the Reset actions requested so far
+	Resets []string
+}
+
+func (s *System) deepCopy() *System {
+	c := *s
+	c.Processors = append([]Processor{}, s.Processors...)
+	c.NICs = append([]NIC{}, s.NICs...)
+	c.Drives = append([]Drive{}, s.Drives...)
+	c.Fans = append([]Sensor{}, s.Fans...)
+	c.Temperatures = append([]Sensor{}, s.Temperatures...)
+	c.PowerSupplies = append([]Sensor{}, s.PowerSupplies...)
+	c.Resets = append([]string{}, s.Resets...)
+	c.BiosAttributes = copyAttributes(s.BiosAttributes)
+	c.PendingBiosAttributes = copyAttributes(s.PendingBiosAttributes)
+	return &c
+}
+
+func copyAttributes(attributes map[string]interface{}) map[string]interface{} {
+	if attributes == nil {
+		return nil
+	}
+	c := make(map[string]interface{}, len(attributes))
+	for name, value := range attributes {
+		c[name] = value
+	}
+	return c
+}
+
+// DefaultSystem returns a powered off system with a typical inventory.
+func DefaultSystem(id string) System {
+	return System{
+		ID:                        id,
+		Manufacturer:              "Metal3",
+		Model:                     "Emulated System",
+		SerialNumber:              "SN-" + id,
+		UUID:                      fmt.Sprintf("00000000-0000-0000-0000-%012s", id),
+		BiosVersion:               "1.2.3",
+		PowerState:                "Off",
+		BootSourceOverrideTarget:  "None",
+		BootSourceOverrideEnabled: "Disabled",
+		Processors: []Processor{
+			{Model: "Emulated CPU", InstructionSet: "x86-64", MaxSpeedMHz: 2400, TotalCores: 8, TotalThreads: 16},
+			{Model: "Emulated CPU", InstructionSet: "x86-64", MaxSpeedMHz: 2400, TotalCores: 8, TotalThreads: 16},
+		},
+		MemoryGiB: 16,
+		NICs: []NIC{
+			{ID: "NIC1", MACAddress: "52:54:00:AA:BB:01", SpeedMbps: 10000, IPv4Addresses: []string{"192.168.111.20"}},
+			{ID: "NIC2", MACAddress: "52:54:00:AA:BB:02", SpeedMbps: 1000},
+		},
+		Drives: []Drive{
+			{ID: "Disk1", Model: "Emulated SSD", SerialNumber: "S1", MediaType: "SSD", Protocol: "SATA", CapacityBytes: 480103981056},
+			{ID: "Disk2", Model: "Emulated HDD", SerialNumber: "S2", MediaType: "HDD", Protocol: "SAS", CapacityBytes: 2000398934016},
+		},
+		Fans: []Sensor{
+			{Name: "Fan1", Reading: 5400},
+			{Name: "Fan2", Reading: 5520},
+		},
+		Temperatures: []Sensor{
+			{Name: "CPU1 Temp", Reading: 45},
+			{Name: "Inlet Temp", Reading: 22},
+		},
+		PowerSupplies: []Sensor{
+			{Name: "PS1", Reading: 180},
+			{Name: "PS2", Reading: 175},
+		},
+		BiosAttributes: map[string]interface{}{
+			"BootMode":             "Uefi",
+			"ProcVirtualization":   "Enabled",
+			"NumCoresPerProcessor": 8,
+		},
+	}
+}
+
+// HealthRollup returns the health of the system as a whole, which is
+// the worst health of its components.
+func (s *System) HealthRollup() string {
+	rollup := healthOf("")
+	worse := func(health string) {
+		if healthSeverity[healthOf(health)] > healthSeverity[rollup] {
+			rollup = healthOf(health)
+		}
+	}
+	worse(s.ProcessorHealth)
+	worse(s.MemoryHealth)
+	for _, d := range s.Drives {
+		worse(d.Health)
+	}
+	for _, sensors := range [][]Sensor{s.Fans, s.Temperatures, s.PowerSupplies} {
+		for _, sensor := range sensors {
+			worse(sensor.Health)
+		}
+	}
+	return rollup
+}
+
+var healthSeverity = map[string]int{"OK": 0, "Warning": 1, "Critical": 2}
+
+func healthOf(health string) string {
+	if health == "" {
+		return "OK"
+	}
+	return health
+}
+
+// Failure makes the emulator respond to matching requests with an
+// error.
+type Failure struct {
+	// The HTTP method to match, or any method if empty
+	Method string
+	// The path to match. A trailing "*" matches any path with the
+	// prefix.
+	Path string
+	// The status code returned
+	StatusCode int
+	// The message of the Redfish error returned
+	Message string
+	// The number of requests to fail, or all of them if 0
+	Count int
+}
+
+func (f *Failure) matches(r *http.Request) bool {
+	if f.Method != "" && f.Method != r.Method {
+		return false
+	}
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	if strings.HasSuffix(f.Path, "*") {
+		return strings.HasPrefix(path, strings.TrimSuffix(f.Path, "*"))
+	}
+	return path == strings.TrimSuffix(f.Path, "/")
+}
+
+// Request is a request received by the emulator.
+type Request struct {
+	Method string
+	Path   string
+}
+
+// Emulator is a Redfish service emulating a BMC. It implements
+// http.Handler.
+type Emulator struct {
+	lock     sync.Mutex
+	username string
+	password string
+
+	systems  []*System
+	sessions map[string]string
+	failures []*Failure
+	requests []Request
+}
+
+// New returns an emulator without systems, accepting the given
+// credentials.
+func New(username, password string) *Emulator {
+	return &Emulator{
+		username: username,
+		password: password,
+		sessions: map[string]string{},
+	}
+}
+
+// AddSystem adds a system to the emulator.
+func (e *Emulator) AddSystem(system System) *Emulator {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.systems = append(e.systems, system.deepCopy())
+	return e
+}
+
+// System returns a copy of the current state of a system.
+func (e *Emulator) System(id string) (System, bool) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	if s := e.findSystem(id); s != nil {
+		return *s.deepCopy(), true
+	}
+	return System{}, false
+}
+
+// UpdateSystem changes the state of a system, as if it were changed
+// out of band.
+func (e *Emulator) UpdateSystem(id string, update func(*System)) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	s := e.findSystem(id)
+	if s == nil {
+		return fmt.Errorf("no system %s", id)
+	}
+	update(s)
+	return nil
+}
+
+// SystemPath returns the path of a system in the Redfish API.
+func SystemPath(id string) string {
+	return serviceRoot + "/Systems/" + id
+}
+
+// InjectFailure makes the emulator fail the matching requests.
+func (e *Emulator) InjectFailure(failure Failure) *Emulator {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.failures = append(e.failures, &failure)
+	return e
+}
+
+// ClearFailures removes all the injected failures.
+func (e *Emulator) ClearFailures() {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.failures = nil
+}
+
+// Requests returns the requests received so far.
+func (e *Emulator) Requests() []Request {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	return append([]Request{}, e.requests...)
+}
+
+func (e *Emulator) findSystem(id string) *System {
+	for _, s := range e.systems {
+		if s.ID == id {
+			return s
+		}
+	}
+	return nil
+}
+
+// injectedFailure returns the first failure matching the request, and
+// counts it.
+func (e *Emulator) injectedFailure(r *http.Request) *Failure {
+	for i, f := range e.failures {
+		if !f.matches(r) {
+			continue
+		}
+		if f.Count > 0 {
+			f.Count--
+			if f.Count == 0 {
+				e.failures = append(e.failures[:i], e.failures[i+1:]...)
+			}
+		}
+		return f
+	}
+	return nil
+}
+
+// authenticated checks the basic auth credentials or the session token
+// of a request.
+func (e *Emulator) authenticated(r *http.Request) bool {
+	if token := r.Header.Get("X-Auth-Token"); token != "" {
+		_, ok := e.sessions[token]
+		return ok
+	}
+	username, password, ok := r.BasicAuth()
+	return ok && username == e.username && password == e.password
+}
+
+func newToken() string {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(buf)
+}
+
+// ServeHTTP handles a Redfish request.
+func (e *Emulator) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	path := strings.TrimSuffix(r.URL.Path, "/")
+	e.requests = append(e.requests, Request{Method: r.Method, Path: path})
+
+	if f := e.injectedFailure(r); f != nil {
+		sendError(w, f.StatusCode, f.Message)
+		return
+	}
+
+	// The service root and the creation of sessions do not require
+	// authentication
+	public := path == serviceRoot ||
+		(path == serviceRoot+"/SessionService/Sessions" && r.Method == http.MethodPost)
+	if !public && !e.authenticated(r) {
+		sendError(w, http.StatusUnauthorized, "Invalid credentials")
+		return
+	}
+
+	e.route(w, r, path)
+}
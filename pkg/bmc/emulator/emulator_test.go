@@ -0,0 +1,232 @@
+package emulator
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type testClient struct {
+	t      *testing.T
+	server *httptest.Server
+	token  string
+}
+
+func newTestClient(t *testing.T, e *Emulator) *testClient {
+	return &testClient{t: t, server: httptest.NewServer(e)}
+}
+
+func (c *testClient) do(method, path, body string, result interface{}) int {
+	req, err := http.NewRequest(method, c.server.URL+path, strings.NewReader(body))
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	if c.token != "" {
+		req.Header.Set("X-Auth-Token", c.token)
+	} else {
+		req.SetBasicAuth("admin", "password")
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		c.t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if result != nil && resp.StatusCode == http.StatusOK {
+		if err := json.NewDecoder(resp.Body).Decode(result); err != nil {
+			c.t.Fatal(err)
+		}
+	}
+	if token := resp.Header.Get("X-Auth-Token"); token != "" {
+		c.token = token
+	}
+	return resp.StatusCode
+}
+
+func newTestEmulator() *Emulator {
+	return New("admin", "password").AddSystem(DefaultSystem("1"))
+}
+
+func TestAuthentication(t *testing.T) {
+	c := newTestClient(t, newTestEmulator())
+	defer c.server.Close()
+
+	if status := c.do(http.MethodGet, "/redfish/v1", "", nil); status != http.StatusOK {
+		t.Fatalf("unexpected status %d for the service root", status)
+	}
+
+	c.token = "invalid"
+	if status := c.do(http.MethodGet, SystemPath("1"), "", nil); status != http.StatusUnauthorized {
+		t.Fatalf("unexpected status %d with an invalid token", status)
+	}
+
+	c.token = ""
+	status := c.do(http.MethodPost, "/redfish/v1/SessionService/Sessions",
+		`{"UserName": "admin", "Password": "password"}`, nil)
+	if status != http.StatusCreated || c.token == "" {
+		t.Fatalf("failed to create a session: status %d", status)
+	}
+	if status := c.do(http.MethodGet, SystemPath("1"), "", nil); status != http.StatusOK {
+		t.Fatalf("unexpected status %d with a session", status)
+	}
+
+	var sessions struct {
+		Members []struct {
+			ID string `json:"@odata.id"`
+		}
+	}
+	c.do(http.MethodGet, "/redfish/v1/SessionService/Sessions", "", &sessions)
+	if len(sessions.Members) != 1 {
+		t.Fatalf("unexpected sessions %v", sessions.Members)
+	}
+	if status := c.do(http.MethodDelete, sessions.Members[0].ID, "", nil); status != http.StatusNoContent {
+		t.Fatalf("unexpected status %d deleting the session", status)
+	}
+	if status := c.do(http.MethodGet, SystemPath("1"), "", nil); status != http.StatusUnauthorized {
+		t.Fatalf("unexpected status %d after deleting the session", status)
+	}
+}
+
+func TestReset(t *testing.T) {
+	e := newTestEmulator()
+	c := newTestClient(t, e)
+	defer c.server.Close()
+	action := SystemPath("1") + "/Actions/ComputerSystem.Reset"
+
+	c.do(http.MethodPatch, SystemPath("1"),
+		`{"Boot": {"BootSourceOverrideTarget": "Pxe", "BootSourceOverrideEnabled": "Once"}}`, nil)
+	c.do(http.MethodPatch, SystemPath("1")+"/Bios/Settings", `{"Attributes": {"BootMode": "Bios"}}`, nil)
+
+	if status := c.do(http.MethodPost, action, `{"ResetType": "On"}`, nil); status != http.StatusNoContent {
+		t.Fatalf("unexpected status %d powering on", status)
+	}
+	if status := c.do(http.MethodPost, action, `{"ResetType": "On"}`, nil); status != http.StatusConflict {
+		t.Fatalf("unexpected status %d powering on twice", status)
+	}
+
+	s, _ := e.System("1")
+	if s.PowerState != "On" {
+		t.Errorf("unexpected power state %s", s.PowerState)
+	}
+	if s.BootSourceOverrideEnabled != "Disabled" {
+		t.Errorf("the one-time boot override was not cleared")
+	}
+	if s.BiosAttributes["BootMode"] != "Bios" || s.PendingBiosAttributes != nil {
+		t.Errorf("the pending BIOS settings were not applied: %v", s.BiosAttributes)
+	}
+
+	if status := c.do(http.MethodPatch, SystemPath("1")+"/Bios/Settings",
+		`{"Attributes": {"Unknown": "1"}}`, nil); status != http.StatusBadRequest {
+		t.Errorf("unexpected status %d for an unknown BIOS attribute", status)
+	}
+}
+
+func TestVirtualMedia(t *testing.T) {
+	e := newTestEmulator()
+	c := newTestClient(t, e)
+	defer c.server.Close()
+	cd := "/redfish/v1/Managers/1/VirtualMedia/Cd"
+
+	if status := c.do(http.MethodPost, cd+"/Actions/VirtualMedia.InsertMedia",
+		`{"Image": "http://images.test/live.iso"}`, nil); status != http.StatusNoContent {
+		t.Fatalf("unexpected status %d inserting media", status)
+	}
+	if status := c.do(http.MethodPost, cd+"/Actions/VirtualMedia.InsertMedia",
+		`{"Image": "http://images.test/live.iso"}`, nil); status != http.StatusConflict {
+		t.Fatalf("unexpected status %d inserting media twice", status)
+	}
+
+	var media struct {
+		Image    string
+		Inserted bool
+	}
+	c.do(http.MethodGet, cd, "", &media)
+	if media.Image != "http://images.test/live.iso" || !media.Inserted {
+		t.Fatalf("unexpected virtual media %+v", media)
+	}
+
+	c.do(http.MethodPost, cd+"/Actions/VirtualMedia.EjectMedia", `{}`, nil)
+	if s, _ := e.System("1"); s.VirtualCD.Inserted {
+		t.Fatalf("the media was not ejected")
+	}
+}
+
+func TestInjectFailure(t *testing.T) {
+	e := newTestEmulator().InjectFailure(Failure{
+		Method:     http.MethodGet,
+		Path:       "/redfish/v1/Systems/*",
+		StatusCode: http.StatusServiceUnavailable,
+		Count:      2,
+	})
+	c := newTestClient(t, e)
+	defer c.server.Close()
+
+	for i, expected := range []int{http.StatusServiceUnavailable, http.StatusServiceUnavailable, http.StatusOK} {
+		if status := c.do(http.MethodGet, SystemPath("1"), "", nil); status != expected {
+			t.Errorf("unexpected status %d for request %d", status, i)
+		}
+	}
+
+	e.InjectFailure(Failure{Path: "/redfish/v1/Chassis/1", StatusCode: http.StatusInternalServerError})
+	if status := c.do(http.MethodGet, "/redfish/v1/Chassis/1", "", nil); status != http.StatusInternalServerError {
+		t.Errorf("unexpected status %d for the chassis", status)
+	}
+	e.ClearFailures()
+	if status := c.do(http.MethodGet, "/redfish/v1/Chassis/1", "", nil); status != http.StatusOK {
+		t.Errorf("unexpected status %d after clearing the failures", status)
+	}
+
+	if n := len(e.Requests()); n != 5 {
+		t.Errorf("unexpected number of requests %d", n)
+	}
+}
+
+func TestUpdateSystem(t *testing.T) {
+	e := newTestEmulator()
+	if err := e.UpdateSystem("1", func(s *System) { s.PowerState = "On" }); err != nil {
+		t.Fatal(err)
+	}
+	if s, _ := e.System("1"); s.PowerState != "On" {
+		t.Errorf("unexpected power state %s", s.PowerState)
+	}
+	if err := e.UpdateSystem("2", func(s *System) {}); err == nil {
+		t.Errorf("expected an error for an unknown system")
+	}
+}
+
+func TestHealth(t *testing.T) {
+	e := newTestEmulator()
+	c := newTestClient(t, e)
+	defer c.server.Close()
+
+	var system struct {
+		Status struct{ HealthRollup string }
+	}
+	c.do(http.MethodGet, SystemPath("1"), "", &system)
+	if system.Status.HealthRollup != "OK" {
+		t.Errorf("unexpected health rollup %s", system.Status.HealthRollup)
+	}
+
+	if err := e.UpdateSystem("1", func(s *System) { s.Fans[1].Health = "Critical" }); err != nil {
+		t.Fatal(err)
+	}
+	c.do(http.MethodGet, SystemPath("1"), "", &system)
+	if system.Status.HealthRollup != "Critical" {
+		t.Errorf("unexpected health rollup %s", system.Status.HealthRollup)
+	}
+
+	var thermal struct {
+		Fans []struct {
+			Name    string
+			Reading float64
+			Status  struct{ Health string }
+		}
+	}
+	if status := c.do(http.MethodGet, "/redfish/v1/Chassis/1/Thermal", "", &thermal); status != http.StatusOK {
+		t.Fatalf("unexpected status %d", status)
+	}
+	if len(thermal.Fans) != 2 || thermal.Fans[1].Status.Health != "Critical" || thermal.Fans[0].Reading != 5400 {
+		t.Errorf("unexpected fans %+v", thermal.Fans)
+	}
+}
@@ -0,0 +1,692 @@
+package emulator
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type object map[string]interface{}
+
+func link(path string) object {
+	return object{"@odata.id": path}
+}
+
+func collection(path, name string, members []string) object {
+	links := []object{}
+	for _, member := range members {
+		links = append(links, link(path+"/"+member))
+	}
+	return object{
+		"@odata.id":           path,
+		"Name":                name,
+		"Members":             links,
+		"Members@odata.count": len(links),
+	}
+}
+
+func sendJSON(w http.ResponseWriter, status int, payload interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func sendError(w http.ResponseWriter, status int, message string) {
+	sendJSON(w, status, object{
+		"error": object{
+			"code":    "Base.1.0.GeneralError",
+			"message": message,
+		},
+	})
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, body interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(body); err != nil {
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("Invalid request body: %s", err))
+		return false
+	}
+	return true
+}
+
+func methodNotAllowed(w http.ResponseWriter) {
+	sendError(w, http.StatusMethodNotAllowed, "Method not allowed")
+}
+
+// route dispatches a request to the handler of the resource.
+func (e *Emulator) route(w http.ResponseWriter, r *http.Request, path string) {
+	if !strings.HasPrefix(path, serviceRoot) {
+		http.NotFound(w, r)
+		return
+	}
+	segments := strings.Split(strings.TrimPrefix(path, serviceRoot), "/")[1:]
+	if len(segments) == 0 {
+		e.serviceRoot(w, r)
+		return
+	}
+
+	switch segments[0] {
+	case "Systems":
+		if len(segments) == 1 {
+			e.getCollection(w, r, path, "Computer System Collection")
+			return
+		}
+		if s := e.findSystem(segments[1]); s != nil {
+			e.systemResource(w, r, s, segments[2:])
+			return
+		}
+	case "Managers":
+		if len(segments) == 1 {
+			e.getCollection(w, r, path, "Manager Collection")
+			return
+		}
+		if s := e.findSystem(segments[1]); s != nil {
+			e.managerResource(w, r, s, segments[2:])
+			return
+		}
+	case "Chassis":
+		if len(segments) == 1 {
+			e.getCollection(w, r, path, "Chassis Collection")
+			return
+		}
+		if s := e.findSystem(segments[1]); s != nil {
+			e.chassisResource(w, r, s, segments[2:])
+			return
+		}
+	case "SessionService":
+		e.sessionService(w, r, segments[1:])
+		return
+	}
+	sendError(w, http.StatusNotFound, fmt.Sprintf("Resource %s not found", path))
+}
+
+func (e *Emulator) serviceRoot(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	sendJSON(w, http.StatusOK, object{
+		"@odata.id":      serviceRoot,
+		"Id":             "RootService",
+		"Name":           "Root Service",
+		"RedfishVersion": "1.6.0",
+		"Systems":        link(serviceRoot + "/Systems"),
+		"Managers":       link(serviceRoot + "/Managers"),
+		"Chassis":        link(serviceRoot + "/Chassis"),
+		"SessionService": link(serviceRoot + "/SessionService"),
+		"Links": object{
+			"Sessions": link(serviceRoot + "/SessionService/Sessions"),
+		},
+	})
+}
+
+func (e *Emulator) getCollection(w http.ResponseWriter, r *http.Request, path, name string) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	ids := []string{}
+	for _, s := range e.systems {
+		ids = append(ids, s.ID)
+	}
+	sendJSON(w, http.StatusOK, collection(path, name, ids))
+}
+
+func (e *Emulator) systemResource(w http.ResponseWriter, r *http.Request, s *System, segments []string) {
+	path := SystemPath(s.ID)
+	switch strings.Join(segments, "/") {
+	case "":
+		e.system(w, r, s)
+		return
+	case "Actions/ComputerSystem.Reset":
+		e.reset(w, r, s)
+		return
+	case "Bios":
+		e.bios(w, r, s)
+		return
+	case "Bios/Settings":
+		e.biosSettings(w, r, s)
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+
+	switch {
+	case len(segments) == 1 && segments[0] == "Processors":
+		ids := []string{}
+		for i := range s.Processors {
+			ids = append(ids, fmt.Sprintf("CPU%d", i+1))
+		}
+		sendJSON(w, http.StatusOK, collection(path+"/Processors", "Processors Collection", ids))
+		return
+	case len(segments) == 2 && segments[0] == "Processors":
+		for i, proc := range s.Processors {
+			if segments[1] == fmt.Sprintf("CPU%d", i+1) {
+				sendJSON(w, http.StatusOK, object{
+					"@odata.id":      path + "/Processors/" + segments[1],
+					"Id":             segments[1],
+					"ProcessorType":  "CPU",
+					"Model":          proc.Model,
+					"InstructionSet": proc.InstructionSet,
+					"MaxSpeedMHz":    proc.MaxSpeedMHz,
+					"TotalCores":     proc.TotalCores,
+					"TotalThreads":   proc.TotalThreads,
+				})
+				return
+			}
+		}
+	case len(segments) == 1 && segments[0] == "EthernetInterfaces":
+		ids := []string{}
+		for _, nic := range s.NICs {
+			ids = append(ids, nic.ID)
+		}
+		sendJSON(w, http.StatusOK, collection(path+"/EthernetInterfaces", "Ethernet Interface Collection", ids))
+		return
+	case len(segments) == 2 && segments[0] == "EthernetInterfaces":
+		for _, nic := range s.NICs {
+			if nic.ID == segments[1] {
+				addresses := []object{}
+				for _, address := range nic.IPv4Addresses {
+					addresses = append(addresses, object{"Address": address})
+				}
+				sendJSON(w, http.StatusOK, object{
+					"@odata.id":     path + "/EthernetInterfaces/" + nic.ID,
+					"Id":            nic.ID,
+					"MACAddress":    nic.MACAddress,
+					"SpeedMbps":     nic.SpeedMbps,
+					"IPv4Addresses": addresses,
+				})
+				return
+			}
+		}
+	case len(segments) == 1 && segments[0] == "Storage":
+		sendJSON(w, http.StatusOK, collection(path+"/Storage", "Storage Collection", []string{"1"}))
+		return
+	case len(segments) == 2 && segments[0] == "Storage" && segments[1] == "1":
+		drives := []object{}
+		for _, d := range s.Drives {
+			drives = append(drives, link(path+"/Storage/1/Drives/"+d.ID))
+		}
+		sendJSON(w, http.StatusOK, object{
+			"@odata.id": path + "/Storage/1",
+			"Id":        "1",
+			"Drives":    drives,
+		})
+		return
+	case len(segments) == 4 && segments[0] == "Storage" && segments[1] == "1" && segments[2] == "Drives":
+		for _, d := range s.Drives {
+			if d.ID == segments[3] {
+				sendJSON(w, http.StatusOK, object{
+					"@odata.id":     path + "/Storage/1/Drives/" + d.ID,
+					"Id":            d.ID,
+					"Name":          d.ID,
+					"Model":         d.Model,
+					"SerialNumber":  d.SerialNumber,
+					"Manufacturer":  d.Manufacturer,
+					"MediaType":     d.MediaType,
+					"Protocol":      d.Protocol,
+					"CapacityBytes": d.CapacityBytes,
+					"Status":        object{"State": "Enabled", "Health": healthOf(d.Health)},
+				})
+				return
+			}
+		}
+	}
+	sendError(w, http.StatusNotFound, fmt.Sprintf("Resource %s not found", r.URL.Path))
+}
+
+func (e *Emulator) system(w http.ResponseWriter, r *http.Request, s *System) {
+	path := SystemPath(s.ID)
+	switch r.Method {
+	case http.MethodGet:
+		var model string
+		if len(s.Processors) > 0 {
+			model = s.Processors[0].Model
+		}
+		sendJSON(w, http.StatusOK, object{
+			"@odata.id":    path,
+			"Id":           s.ID,
+			"Name":         s.ID,
+			"Manufacturer": s.Manufacturer,
+			"Model":        s.Model,
+			"SerialNumber": s.SerialNumber,
+			"UUID":         s.UUID,
+			"HostName":     s.HostName,
+			"BiosVersion":  s.BiosVersion,
+			"PowerState":   s.PowerState,
+			"Boot": object{
+				"BootSourceOverrideTarget":                         s.BootSourceOverrideTarget,
+				"BootSourceOverrideEnabled":                        s.BootSourceOverrideEnabled,
+				"BootSourceOverrideTarget@Redfish.AllowableValues": []string{"None", "Pxe", "Cd", "Hdd", "BiosSetup"},
+			},
+			"Status": object{
+				"State":        "Enabled",
+				"Health":       "OK",
+				"HealthRollup": s.HealthRollup(),
+			},
+			"ProcessorSummary": object{
+				"Count":  len(s.Processors),
+				"Model":  model,
+				"Status": object{"State": "Enabled", "Health": healthOf(s.ProcessorHealth)},
+			},
+			"MemorySummary": object{
+				"TotalSystemMemoryGiB": s.MemoryGiB,
+				"Status":               object{"State": "Enabled", "Health": healthOf(s.MemoryHealth)},
+			},
+			"Processors":         link(path + "/Processors"),
+			"EthernetInterfaces": link(path + "/EthernetInterfaces"),
+			"Storage":            link(path + "/Storage"),
+			"Bios":               link(path + "/Bios"),
+			"Links": object{
+				"ManagedBy": []object{link(serviceRoot + "/Managers/" + s.ID)},
+				"Chassis":   []object{link(serviceRoot + "/Chassis/" + s.ID)},
+			},
+			"Actions": object{
+				"#ComputerSystem.Reset": object{
+					"target": path + "/Actions/ComputerSystem.Reset",
+					"ResetType@Redfish.AllowableValues": []string{
+						"On", "ForceOn", "ForceOff", "GracefulShutdown", "ForceRestart", "GracefulRestart",
+					},
+				},
+			},
+		})
+	case http.MethodPatch:
+		var body struct {
+			Boot *struct {
+				BootSourceOverrideTarget  string
+				BootSourceOverrideEnabled string
+			}
+		}
+		if !readJSON(w, r, &body) {
+			return
+		}
+		if body.Boot != nil {
+			if body.Boot.BootSourceOverrideTarget != "" {
+				s.BootSourceOverrideTarget = body.Boot.BootSourceOverrideTarget
+			}
+			if body.Boot.BootSourceOverrideEnabled != "" {
+				s.BootSourceOverrideEnabled = body.Boot.BootSourceOverrideEnabled
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+// reset changes the power state. Booting the system applies the pending
+// BIOS settings and clears a one-time boot override.
+func (e *Emulator) reset(w http.ResponseWriter, r *http.Request, s *System) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	var body struct {
+		ResetType string
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+
+	switch body.ResetType {
+	case "On", "ForceOn":
+		if s.PowerState == "On" {
+			sendError(w, http.StatusConflict, "The system is already powered on")
+			return
+		}
+	case "ForceOff", "GracefulShutdown":
+	case "ForceRestart", "GracefulRestart":
+	default:
+		sendError(w, http.StatusBadRequest, fmt.Sprintf("Unsupported ResetType %s", body.ResetType))
+		return
+	}
+	s.Resets = append(s.Resets, body.ResetType)
+
+	if body.ResetType == "ForceOff" || body.ResetType == "GracefulShutdown" {
+		s.PowerState = "Off"
+	} else {
+		s.PowerState = "On"
+		if s.BootSourceOverrideEnabled == "Once" {
+			s.BootSourceOverrideTarget = "None"
+			s.BootSourceOverrideEnabled = "Disabled"
+		}
+		for name, value := range s.PendingBiosAttributes {
+			if s.BiosAttributes == nil {
+				s.BiosAttributes = map[string]interface{}{}
+			}
+			s.BiosAttributes[name] = value
+		}
+		s.PendingBiosAttributes = nil
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Emulator) bios(w http.ResponseWriter, r *http.Request, s *System) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	path := SystemPath(s.ID) + "/Bios"
+	attributes := s.BiosAttributes
+	if attributes == nil {
+		attributes = map[string]interface{}{}
+	}
+	sendJSON(w, http.StatusOK, object{
+		"@odata.id":  path,
+		"Id":         "Bios",
+		"Attributes": attributes,
+		"@Redfish.Settings": object{
+			"SettingsObject": link(path + "/Settings"),
+		},
+	})
+}
+
+func (e *Emulator) biosSettings(w http.ResponseWriter, r *http.Request, s *System) {
+	switch r.Method {
+	case http.MethodGet:
+		attributes := s.PendingBiosAttributes
+		if attributes == nil {
+			attributes = map[string]interface{}{}
+		}
+		sendJSON(w, http.StatusOK, object{
+			"@odata.id":  SystemPath(s.ID) + "/Bios/Settings",
+			"Id":         "Settings",
+			"Attributes": attributes,
+		})
+	case http.MethodPatch:
+		var body struct {
+			Attributes map[string]interface{}
+		}
+		if !readJSON(w, r, &body) {
+			return
+		}
+		for name := range body.Attributes {
+			if _, ok := s.BiosAttributes[name]; !ok {
+				sendError(w, http.StatusBadRequest, fmt.Sprintf("Unknown BIOS attribute %s", name))
+				return
+			}
+		}
+		if s.PendingBiosAttributes == nil {
+			s.PendingBiosAttributes = map[string]interface{}{}
+		}
+		for name, value := range body.Attributes {
+			s.PendingBiosAttributes[name] = value
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+func (e *Emulator) managerResource(w http.ResponseWriter, r *http.Request, s *System, segments []string) {
+	path := serviceRoot + "/Managers/" + s.ID
+	switch strings.Join(segments, "/") {
+	case "":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		sendJSON(w, http.StatusOK, object{
+			"@odata.id":       path,
+			"Id":              s.ID,
+			"ManagerType":     "BMC",
+			"FirmwareVersion": "1.0.0",
+			"Status":          object{"State": "Enabled", "Health": "OK"},
+			"VirtualMedia":    link(path + "/VirtualMedia"),
+			"Links": object{
+				"ManagerForServers": []object{link(SystemPath(s.ID))},
+				"ManagerForChassis": []object{link(serviceRoot + "/Chassis/" + s.ID)},
+			},
+		})
+	case "VirtualMedia":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		sendJSON(w, http.StatusOK, collection(path+"/VirtualMedia", "Virtual Media Collection", []string{"Floppy", "Cd"}))
+	case "VirtualMedia/Floppy":
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		sendJSON(w, http.StatusOK, object{
+			"@odata.id":  path + "/VirtualMedia/Floppy",
+			"Id":         "Floppy",
+			"MediaTypes": []string{"Floppy", "USBStick"},
+			"Inserted":   false,
+		})
+	case "VirtualMedia/Cd":
+		e.virtualCD(w, r, s, path+"/VirtualMedia/Cd")
+	case "VirtualMedia/Cd/Actions/VirtualMedia.InsertMedia":
+		e.insertMedia(w, r, s)
+	case "VirtualMedia/Cd/Actions/VirtualMedia.EjectMedia":
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w)
+			return
+		}
+		s.VirtualCD = VirtualMedia{}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		sendError(w, http.StatusNotFound, fmt.Sprintf("Resource %s not found", r.URL.Path))
+	}
+}
+
+func (e *Emulator) virtualCD(w http.ResponseWriter, r *http.Request, s *System, path string) {
+	switch r.Method {
+	case http.MethodGet:
+		sendJSON(w, http.StatusOK, object{
+			"@odata.id":  path,
+			"Id":         "Cd",
+			"MediaTypes": []string{"CD", "DVD"},
+			"Image":      s.VirtualCD.Image,
+			"Inserted":   s.VirtualCD.Inserted,
+			"Actions": object{
+				"#VirtualMedia.InsertMedia": object{
+					"target": path + "/Actions/VirtualMedia.InsertMedia",
+				},
+				"#VirtualMedia.EjectMedia": object{
+					"target": path + "/Actions/VirtualMedia.EjectMedia",
+				},
+			},
+		})
+	case http.MethodPatch:
+		var body struct {
+			Image    *string
+			Inserted *bool
+		}
+		if !readJSON(w, r, &body) {
+			return
+		}
+		if body.Image != nil {
+			s.VirtualCD.Image = *body.Image
+		}
+		if body.Inserted != nil {
+			s.VirtualCD.Inserted = *body.Inserted
+		}
+		if !s.VirtualCD.Inserted {
+			s.VirtualCD.Image = ""
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w)
+	}
+}
+
+func (e *Emulator) insertMedia(w http.ResponseWriter, r *http.Request, s *System) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w)
+		return
+	}
+	var body struct {
+		Image string
+	}
+	if !readJSON(w, r, &body) {
+		return
+	}
+	if body.Image == "" {
+		sendError(w, http.StatusBadRequest, "Missing Image")
+		return
+	}
+	if s.VirtualCD.Inserted {
+		sendError(w, http.StatusConflict, "Media is already inserted")
+		return
+	}
+	s.VirtualCD = VirtualMedia{Image: body.Image, Inserted: true}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (e *Emulator) chassisResource(w http.ResponseWriter, r *http.Request, s *System, segments []string) {
+	path := serviceRoot + "/Chassis/" + s.ID
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w)
+		return
+	}
+	switch strings.Join(segments, "/") {
+	case "":
+		sendJSON(w, http.StatusOK, object{
+			"@odata.id":    path,
+			"Id":           s.ID,
+			"ChassisType":  "RackMount",
+			"Manufacturer": s.Manufacturer,
+			"Model":        s.Model,
+			"SerialNumber": s.SerialNumber,
+			"PowerState":   s.PowerState,
+			"Status":       object{"State": "Enabled", "Health": "OK", "HealthRollup": s.HealthRollup()},
+			"Thermal":      link(path + "/Thermal"),
+			"Power":        link(path + "/Power"),
+			"Links": object{
+				"ComputerSystems": []object{link(SystemPath(s.ID))},
+				"ManagedBy":       []object{link(serviceRoot + "/Managers/" + s.ID)},
+			},
+		})
+	case "Thermal":
+		fans := []object{}
+		for i, fan := range s.Fans {
+			fans = append(fans, object{
+				"@odata.id":    fmt.Sprintf("%s/Thermal#/Fans/%d", path, i),
+				"MemberId":     fmt.Sprint(i),
+				"Name":         fan.Name,
+				"Reading":      fan.Reading,
+				"ReadingUnits": "RPM",
+				"Status":       object{"State": "Enabled", "Health": healthOf(fan.Health)},
+			})
+		}
+		temperatures := []object{}
+		for i, temp := range s.Temperatures {
+			temperatures = append(temperatures, object{
+				"@odata.id":      fmt.Sprintf("%s/Thermal#/Temperatures/%d", path, i),
+				"MemberId":       fmt.Sprint(i),
+				"Name":           temp.Name,
+				"ReadingCelsius": temp.Reading,
+				"Status":         object{"State": "Enabled", "Health": healthOf(temp.Health)},
+			})
+		}
+		sendJSON(w, http.StatusOK, object{
+			"@odata.id":    path + "/Thermal",
+			"Id":           "Thermal",
+			"Fans":         fans,
+			"Temperatures": temperatures,
+		})
+	case "Power":
+		supplies := []object{}
+		for i, psu := range s.PowerSupplies {
+			supplies = append(supplies, object{
+				"@odata.id":        fmt.Sprintf("%s/Power#/PowerSupplies/%d", path, i),
+				"MemberId":         fmt.Sprint(i),
+				"Name":             psu.Name,
+				"PowerOutputWatts": psu.Reading,
+				"Status":           object{"State": "Enabled", "Health": healthOf(psu.Health)},
+			})
+		}
+		sendJSON(w, http.StatusOK, object{
+			"@odata.id":     path + "/Power",
+			"Id":            "Power",
+			"PowerSupplies": supplies,
+		})
+	default:
+		sendError(w, http.StatusNotFound, fmt.Sprintf("Resource %s not found", r.URL.Path))
+	}
+}
+
+func (e *Emulator) sessionService(w http.ResponseWriter, r *http.Request, segments []string) {
+	path := serviceRoot + "/SessionService"
+	switch len(segments) {
+	case 0:
+		if r.Method != http.MethodGet {
+			methodNotAllowed(w)
+			return
+		}
+		sendJSON(w, http.StatusOK, object{
+			"@odata.id":      path,
+			"Id":             "SessionService",
+			"ServiceEnabled": true,
+			"SessionTimeout": 1800,
+			"Sessions":       link(path + "/Sessions"),
+		})
+		return
+	case 1:
+		if segments[0] != "Sessions" {
+			break
+		}
+		switch r.Method {
+		case http.MethodGet:
+			ids := []string{}
+			for _, id := range e.sessions {
+				ids = append(ids, id)
+			}
+			sendJSON(w, http.StatusOK, collection(path+"/Sessions", "Session Collection", ids))
+		case http.MethodPost:
+			var body struct {
+				UserName string
+				Password string
+			}
+			if !readJSON(w, r, &body) {
+				return
+			}
+			if body.UserName != e.username || body.Password != e.password {
+				sendError(w, http.StatusUnauthorized, "Invalid credentials")
+				return
+			}
+			token := newToken()
+			id := token[:8]
+			e.sessions[token] = id
+			w.Header().Set("X-Auth-Token", token)
+			w.Header().Set("Location", path+"/Sessions/"+id)
+			sendJSON(w, http.StatusCreated, object{
+				"@odata.id": path + "/Sessions/" + id,
+				"Id":        id,
+				"UserName":  body.UserName,
+			})
+		default:
+			methodNotAllowed(w)
+		}
+		return
+	case 2:
+		if segments[0] != "Sessions" {
+			break
+		}
+		for token, id := range e.sessions {
+			if id != segments[1] {
+				continue
+			}
+			switch r.Method {
+			case http.MethodGet:
+				sendJSON(w, http.StatusOK, object{
+					"@odata.id": path + "/Sessions/" + id,
+					"Id":        id,
+					"UserName":  e.username,
+				})
+			case http.MethodDelete:
+				delete(e.sessions, token)
+				w.WriteHeader(http.StatusNoContent)
+			default:
+				methodNotAllowed(w)
+			}
+			return
+		}
+	}
+	sendError(w, http.StatusNotFound, fmt.Sprintf("Resource %s not found", r.URL.Path))
+}
@@ -105,6 +105,10 @@ func (a *ibmcAccessDetails) SupportsSecureBoot() bool {
 	return false
 }
 
+func (a *ibmcAccessDetails) SupportsCACertificate() bool {
+	return false
+}
+
 func (a *ibmcAccessDetails) SupportsISOPreprovisioningImage() bool {
 	return false
 }
@@ -115,3 +119,10 @@ func (a *ibmcAccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.Fir
 	}
 	return nil, nil
 }
+
+func (a *ibmcAccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	if len(updates) != 0 {
+		return nil, fmt.Errorf("firmware updates for %s are not supported", a.Driver())
+	}
+	return nil, nil
+}
@@ -1,6 +1,7 @@
 package bmc
 
 import (
+	"fmt"
 	"net/url"
 	"strings"
 
@@ -104,6 +105,10 @@ func (a *iDracAccessDetails) SupportsSecureBoot() bool {
 	return false
 }
 
+func (a *iDracAccessDetails) SupportsCACertificate() bool {
+	return false
+}
+
 func (a *iDracAccessDetails) SupportsISOPreprovisioningImage() bool {
 	return false
 }
@@ -154,5 +159,12 @@ func (a *iDracAccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.Fi
 		)
 	}
 
-	return
+	return addCustomBIOSSettings(settings, firmwareConfig), nil
+}
+
+func (a *iDracAccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	if len(updates) != 0 {
+		return nil, fmt.Errorf("firmware updates for %s are not supported", a.Driver())
+	}
+	return nil, nil
 }
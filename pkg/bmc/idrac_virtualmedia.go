@@ -59,6 +59,8 @@ func (a *redfishiDracVirtualMediaAccessDetails) DriverInfo(bmcCreds Credentials)
 
 	if a.disableCertificateVerification {
 		result["redfish_verify_ca"] = false
+	} else if bmcCreds.CACertificatePath != "" {
+		result["redfish_verify_ca"] = bmcCreds.CACertificatePath
 	}
 
 	return result
@@ -94,13 +96,21 @@ func (a *redfishiDracVirtualMediaAccessDetails) SupportsSecureBoot() bool {
 	return true
 }
 
+func (a *redfishiDracVirtualMediaAccessDetails) SupportsCACertificate() bool {
+	return true
+}
+
 func (a *redfishiDracVirtualMediaAccessDetails) SupportsISOPreprovisioningImage() bool {
 	return true
 }
 
 func (a *redfishiDracVirtualMediaAccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.FirmwareConfig) (settings []map[string]string, err error) {
-	if firmwareConfig != nil {
+	if hasVendorBIOSSettings(firmwareConfig) {
 		return nil, fmt.Errorf("firmware settings for %s are not supported", a.Driver())
 	}
-	return nil, nil
+	return addCustomBIOSSettings(nil, firmwareConfig), nil
+}
+
+func (a *redfishiDracVirtualMediaAccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	return buildRedfishFirmwareUpdateArgs(updates), nil
 }
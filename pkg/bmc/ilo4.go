@@ -96,6 +96,10 @@ func (a *iLOAccessDetails) SupportsSecureBoot() bool {
 	return true
 }
 
+func (a *iLOAccessDetails) SupportsCACertificate() bool {
+	return false
+}
+
 func (a *iLOAccessDetails) SupportsISOPreprovisioningImage() bool {
 	return false
 }
@@ -146,5 +150,9 @@ func (a *iLOAccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.Firm
 		)
 	}
 
-	return
+	return addCustomBIOSSettings(settings, firmwareConfig), nil
+}
+
+func (a *iLOAccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	return buildILOFirmwareUpdateArgs(updates)
 }
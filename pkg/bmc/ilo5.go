@@ -63,6 +63,8 @@ func (a *iLO5AccessDetails) DriverInfo(bmcCreds Credentials) map[string]interfac
 
 	if a.disableCertificateVerification {
 		result["ilo_verify_ca"] = false
+	} else if bmcCreds.CACertificatePath != "" {
+		result["ilo_verify_ca"] = bmcCreds.CACertificatePath
 	}
 
 	if a.portNum != "" {
@@ -98,6 +100,10 @@ func (a *iLO5AccessDetails) SupportsSecureBoot() bool {
 	return true
 }
 
+func (a *iLO5AccessDetails) SupportsCACertificate() bool {
+	return true
+}
+
 func (a *iLO5AccessDetails) SupportsISOPreprovisioningImage() bool {
 	return false
 }
@@ -148,5 +154,9 @@ func (a *iLO5AccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.Fir
 		)
 	}
 
-	return
+	return addCustomBIOSSettings(settings, firmwareConfig), nil
+}
+
+func (a *iLO5AccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	return buildILOFirmwareUpdateArgs(updates)
 }
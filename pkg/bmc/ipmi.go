@@ -115,6 +115,10 @@ func (a *ipmiAccessDetails) SupportsSecureBoot() bool {
 	return false
 }
 
+func (a *ipmiAccessDetails) SupportsCACertificate() bool {
+	return false
+}
+
 func (a *ipmiAccessDetails) SupportsISOPreprovisioningImage() bool {
 	return false
 }
@@ -125,3 +129,10 @@ func (a *ipmiAccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.Fir
 	}
 	return nil, nil
 }
+
+func (a *ipmiAccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	if len(updates) != 0 {
+		return nil, fmt.Errorf("firmware updates for %s are not supported", a.Driver())
+	}
+	return nil, nil
+}
@@ -1,6 +1,7 @@
 package bmc
 
 import (
+	"fmt"
 	"net/url"
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
@@ -61,6 +62,8 @@ func (a *iRMCAccessDetails) DriverInfo(bmcCreds Credentials) map[string]interfac
 
 	if a.disableCertificateVerification {
 		result["irmc_verify_ca"] = false
+	} else if bmcCreds.CACertificatePath != "" {
+		result["irmc_verify_ca"] = bmcCreds.CACertificatePath
 	}
 
 	if a.portNum != "" {
@@ -94,6 +97,10 @@ func (a *iRMCAccessDetails) SupportsSecureBoot() bool {
 	return true
 }
 
+func (a *iRMCAccessDetails) SupportsCACertificate() bool {
+	return true
+}
+
 func (a *iRMCAccessDetails) SupportsISOPreprovisioningImage() bool {
 	return false
 }
@@ -148,5 +155,12 @@ func (a *iRMCAccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.Fir
 		)
 	}
 
-	return
+	return addCustomBIOSSettings(settings, firmwareConfig), nil
+}
+
+func (a *iRMCAccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	if len(updates) != 0 {
+		return nil, fmt.Errorf("firmware updates for %s are not supported", a.Driver())
+	}
+	return nil, nil
 }
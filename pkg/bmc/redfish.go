@@ -95,6 +95,8 @@ func (a *redfishAccessDetails) DriverInfo(bmcCreds Credentials) map[string]inter
 
 	if a.disableCertificateVerification {
 		result["redfish_verify_ca"] = false
+	} else if bmcCreds.CACertificatePath != "" {
+		result["redfish_verify_ca"] = bmcCreds.CACertificatePath
 	}
 
 	return result
@@ -125,15 +127,23 @@ func (a *redfishAccessDetails) SupportsSecureBoot() bool {
 	return true
 }
 
+func (a *redfishAccessDetails) SupportsCACertificate() bool {
+	return true
+}
+
 func (a *redfishAccessDetails) SupportsISOPreprovisioningImage() bool {
 	return false
 }
 
 func (a *redfishAccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.FirmwareConfig) (settings []map[string]string, err error) {
-	if firmwareConfig != nil {
+	if hasVendorBIOSSettings(firmwareConfig) {
 		return nil, fmt.Errorf("firmware settings for %s are not supported", a.Driver())
 	}
-	return nil, nil
+	return addCustomBIOSSettings(nil, firmwareConfig), nil
+}
+
+func (a *redfishAccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	return buildRedfishFirmwareUpdateArgs(updates), nil
 }
 
 // iDrac Redfish Overrides
@@ -163,8 +173,12 @@ func (a *redfishiDracAccessDetails) VendorInterface() string {
 }
 
 func (a *redfishiDracAccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.FirmwareConfig) (settings []map[string]string, err error) {
-	if firmwareConfig != nil {
+	if hasVendorBIOSSettings(firmwareConfig) {
 		return nil, fmt.Errorf("firmware settings for %s are not supported", a.Driver())
 	}
-	return nil, nil
+	return addCustomBIOSSettings(nil, firmwareConfig), nil
+}
+
+func (a *redfishiDracAccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	return buildRedfishFirmwareUpdateArgs(updates), nil
 }
@@ -64,6 +64,8 @@ func (a *redfishVirtualMediaAccessDetails) DriverInfo(bmcCreds Credentials) map[
 
 	if a.disableCertificateVerification {
 		result["redfish_verify_ca"] = false
+	} else if bmcCreds.CACertificatePath != "" {
+		result["redfish_verify_ca"] = bmcCreds.CACertificatePath
 	}
 
 	return result
@@ -93,13 +95,21 @@ func (a *redfishVirtualMediaAccessDetails) SupportsSecureBoot() bool {
 	return true
 }
 
+func (a *redfishVirtualMediaAccessDetails) SupportsCACertificate() bool {
+	return true
+}
+
 func (a *redfishVirtualMediaAccessDetails) SupportsISOPreprovisioningImage() bool {
 	return true
 }
 
 func (a *redfishVirtualMediaAccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.FirmwareConfig) (settings []map[string]string, err error) {
-	if firmwareConfig != nil {
+	if hasVendorBIOSSettings(firmwareConfig) {
 		return nil, fmt.Errorf("firmware settings for %s are not supported", a.Driver())
 	}
-	return nil, nil
+	return addCustomBIOSSettings(nil, firmwareConfig), nil
+}
+
+func (a *redfishVirtualMediaAccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	return buildRedfishFirmwareUpdateArgs(updates), nil
 }
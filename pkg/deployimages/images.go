@@ -0,0 +1,123 @@
+package deployimages
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// FileEnvVar names the environment variable holding the path of the
+// file listing the deploy images for each CPU architecture.
+const FileEnvVar = "DEPLOY_IMAGES_FILE"
+
+// Images holds the URLs of the images booting the Ironic agent on
+// hosts of one CPU architecture.
+type Images struct {
+	// KernelURL is the URL of the kernel to go with the ramdisk.
+	KernelURL string `json:"kernelURL,omitempty"`
+
+	// RamdiskURL is the URL of the ramdisk containing the agent.
+	RamdiskURL string `json:"ramdiskURL,omitempty"`
+
+	// ISOURL is the URL of the ISO containing the agent, for drivers
+	// that support ISO boot.
+	ISOURL string `json:"isoURL,omitempty"`
+}
+
+// ByArch holds the deploy images keyed by CPU architecture, such as
+// x86_64 or aarch64.
+type ByArch map[string]Images
+
+// Config holds the deploy images used for hosts of any CPU
+// architecture, set by DEPLOY_KERNEL_URL, DEPLOY_RAMDISK_URL and
+// DEPLOY_ISO_URL, and those of each architecture.
+type Config struct {
+	Default Images
+	ByArch  ByArch
+}
+
+// Load reads the deploy images for each CPU architecture from a YAML
+// file, such as one mounted from a ConfigMap.
+func Load(path string) (ByArch, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read deploy images: %w", err)
+	}
+
+	var images ByArch
+	if err := yaml.UnmarshalStrict(data, &images); err != nil {
+		return nil, fmt.Errorf("failed to parse deploy images: %w", err)
+	}
+	return images, nil
+}
+
+// FromEnv loads the deploy images from the DEPLOY_* variables and the
+// file named by DEPLOY_IMAGES_FILE, when set.
+func FromEnv() (c Config, err error) {
+	c.Default = Images{
+		KernelURL:  os.Getenv("DEPLOY_KERNEL_URL"),
+		RamdiskURL: os.Getenv("DEPLOY_RAMDISK_URL"),
+		ISOURL:     os.Getenv("DEPLOY_ISO_URL"),
+	}
+	if path := os.Getenv(FileEnvVar); path != "" {
+		c.ByArch, err = Load(path)
+	}
+	return c, err
+}
+
+// Validate checks that the deploy images can boot the agent. A ramdisk
+// always requires a kernel. Unless a PreprovisioningImage controller
+// builds the images, a kernel also requires a ramdisk and some image
+// must be configured.
+func (c Config) Validate(havePreprovImgBuilder bool) error {
+	if err := c.Default.validate("DEPLOY_KERNEL_URL", "DEPLOY_RAMDISK_URL", havePreprovImgBuilder); err != nil {
+		return err
+	}
+	for arch, img := range c.ByArch {
+		err := img.validate("kernelURL", "ramdiskURL", havePreprovImgBuilder)
+		if err == nil && img.KernelURL == "" && img.ISOURL == "" {
+			err = errors.New("either kernelURL or isoURL must be set")
+		}
+		if err != nil {
+			return fmt.Errorf("deploy images for the %s architecture: %w", arch, err)
+		}
+	}
+	if !havePreprovImgBuilder && len(c.ByArch) == 0 &&
+		c.Default.KernelURL == "" && c.Default.ISOURL == "" {
+		return errors.New("Either DEPLOY_KERNEL_URL and DEPLOY_RAMDISK_URL or DEPLOY_ISO_URL must be set")
+	}
+	return nil
+}
+
+func (img Images) validate(kernel, ramdisk string, havePreprovImgBuilder bool) error {
+	switch {
+	case img.KernelURL == "" && img.RamdiskURL != "":
+		return fmt.Errorf("%s requires %s to be set also", ramdisk, kernel)
+	case img.KernelURL != "" && img.RamdiskURL == "" && !havePreprovImgBuilder:
+		return fmt.Errorf("%s and %s can only be set together", kernel, ramdisk)
+	}
+	return nil
+}
+
+// ISOs returns whether any architecture has an ISO.
+func (b ByArch) ISOs() bool {
+	for _, img := range b {
+		if img.ISOURL != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Ramdisks returns whether any architecture has a ramdisk.
+func (b ByArch) Ramdisks() bool {
+	for _, img := range b {
+		if img.RamdiskURL != "" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package deployimages
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "images.yaml")
+	err := ioutil.WriteFile(path, []byte(`
+x86_64:
+  kernelURL: http://images.test/ipa-x86_64.kernel
+  ramdiskURL: http://images.test/ipa-x86_64.initramfs
+  isoURL: http://images.test/ipa-x86_64.iso
+aarch64:
+  isoURL: http://images.test/ipa-aarch64.iso
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	images, err := Load(path)
+	assert.NoError(t, err)
+	assert.Len(t, images, 2)
+	assert.Equal(t, "http://images.test/ipa-x86_64.kernel", images["x86_64"].KernelURL)
+	assert.Equal(t, "http://images.test/ipa-aarch64.iso", images["aarch64"].ISOURL)
+	assert.Empty(t, images["aarch64"].KernelURL)
+	assert.True(t, images.ISOs())
+}
+
+func TestLoadInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "images.yaml")
+	if err := ioutil.WriteFile(path, []byte("aarch64:\n  iso: http://iso\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := Load(path)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "failed to parse deploy images")
+	}
+
+	_, err = Load(filepath.Join(os.TempDir(), "does-not-exist.yaml"))
+	assert.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	testCases := []struct {
+		Scenario              string
+		Config                Config
+		ExpectedError         string
+		ExpectedImgBuildError string
+	}{
+		{
+			Scenario: "kernel and ramdisk",
+			Config:   Config{Default: Images{KernelURL: "http://kernel", RamdiskURL: "http://ramdisk"}},
+		},
+		{
+			Scenario: "ISO only",
+			Config:   Config{Default: Images{ISOURL: "http://iso"}},
+		},
+		{
+			Scenario:      "no image",
+			ExpectedError: "Either DEPLOY_KERNEL_URL and DEPLOY_RAMDISK_URL or DEPLOY_ISO_URL must be set",
+		},
+		{
+			Scenario:      "kernel without ramdisk",
+			Config:        Config{Default: Images{KernelURL: "http://kernel", ISOURL: "http://iso"}},
+			ExpectedError: "DEPLOY_KERNEL_URL and DEPLOY_RAMDISK_URL can only be set together",
+		},
+		{
+			Scenario:              "ramdisk without kernel",
+			Config:                Config{Default: Images{RamdiskURL: "http://ramdisk", ISOURL: "http://iso"}},
+			ExpectedError:         "DEPLOY_RAMDISK_URL requires DEPLOY_KERNEL_URL to be set also",
+			ExpectedImgBuildError: "DEPLOY_RAMDISK_URL requires DEPLOY_KERNEL_URL to be set also",
+		},
+		{
+			Scenario: "images by architecture only",
+			Config: Config{ByArch: ByArch{
+				"x86_64":  {KernelURL: "http://kernel", RamdiskURL: "http://ramdisk"},
+				"aarch64": {ISOURL: "http://iso"},
+			}},
+		},
+		{
+			Scenario:      "kernel without ramdisk by architecture",
+			Config:        Config{ByArch: ByArch{"aarch64": {KernelURL: "http://kernel", ISOURL: "http://iso"}}},
+			ExpectedError: "deploy images for the aarch64 architecture: kernelURL and ramdiskURL can only be set together",
+		},
+		{
+			Scenario:              "ramdisk without kernel by architecture",
+			Config:                Config{ByArch: ByArch{"aarch64": {RamdiskURL: "http://ramdisk"}}},
+			ExpectedError:         "deploy images for the aarch64 architecture: ramdiskURL requires kernelURL to be set also",
+			ExpectedImgBuildError: "deploy images for the aarch64 architecture: ramdiskURL requires kernelURL to be set also",
+		},
+		{
+			Scenario:              "no image by architecture",
+			Config:                Config{ByArch: ByArch{"aarch64": {}}},
+			ExpectedError:         "deploy images for the aarch64 architecture: either kernelURL or isoURL must be set",
+			ExpectedImgBuildError: "deploy images for the aarch64 architecture: either kernelURL or isoURL must be set",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			for _, imgBuild := range []bool{false, true} {
+				err := tc.Config.Validate(imgBuild)
+				expectedError := tc.ExpectedError
+				if imgBuild {
+					expectedError = tc.ExpectedImgBuildError
+				}
+				if expectedError == "" {
+					assert.NoError(t, err, "with img builder: %v", imgBuild)
+				} else {
+					assert.EqualError(t, err, expectedError, "with img builder: %v", imgBuild)
+				}
+			}
+		})
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	for _, name := range []string{"DEPLOY_KERNEL_URL", "DEPLOY_RAMDISK_URL", "DEPLOY_ISO_URL", FileEnvVar} {
+		orig, wasSet := os.LookupEnv(name)
+		defer func(name string) {
+			if wasSet {
+				os.Setenv(name, orig)
+			} else {
+				os.Unsetenv(name)
+			}
+		}(name)
+		os.Unsetenv(name)
+	}
+
+	images, err := FromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, Config{}, images)
+	assert.False(t, images.ByArch.ISOs())
+
+	os.Setenv("DEPLOY_ISO_URL", "http://iso")
+	images, err = FromEnv()
+	assert.NoError(t, err)
+	assert.Equal(t, "http://iso", images.Default.ISOURL)
+	assert.Nil(t, images.ByArch)
+}
@@ -2,6 +2,10 @@ package hardware
 
 import (
 	"fmt"
+	"io/ioutil"
+	"regexp"
+
+	"sigs.k8s.io/yaml"
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 )
@@ -15,24 +19,71 @@ const (
 // Profile holds the settings for a class of hardware.
 type Profile struct {
 	// Name holds the profile name
-	Name string
+	Name string `json:"name"`
 
 	// RootDeviceHints holds the suggestions for placing the storage
 	// for the root filesystem.
-	RootDeviceHints metal3v1alpha1.RootDeviceHints
+	RootDeviceHints metal3v1alpha1.RootDeviceHints `json:"rootDeviceHints,omitempty"`
 
 	// RootGB is the size of the root volume in GB
-	RootGB int
+	RootGB int `json:"rootGB,omitempty"`
 
 	// LocalGB is the size of something(?)
-	LocalGB int
+	LocalGB int `json:"localGB,omitempty"`
 
 	// CPUArch is the architecture of the CPU.
-	CPUArch string
+	CPUArch string `json:"cpuArch,omitempty"`
+
+	// Match holds the rules for choosing the profile automatically
+	// for hosts that do not name one. Profiles without rules are
+	// only used when named.
+	Match *ProfileMatch `json:"match,omitempty"`
+}
+
+// ProfileMatch holds the rules a host has to fulfill for a profile to
+// be chosen automatically. All of the rules that are set must match.
+type ProfileMatch struct {
+	// Manufacturer is a regular expression matched against the system
+	// vendor.
+	Manufacturer string `json:"manufacturer,omitempty"`
+
+	// ProductName is a regular expression matched against the product
+	// name of the system.
+	ProductName string `json:"productName,omitempty"`
+
+	// CPUArch must equal the architecture of the CPU.
+	CPUArch string `json:"cpuArch,omitempty"`
+
+	// Disks lists the storage devices the host must have. Each entry
+	// must match a different device.
+	Disks []DiskMatch `json:"disks,omitempty"`
+
+	manufacturer *regexp.Regexp
+	productName  *regexp.Regexp
+}
+
+// DiskMatch describes a storage device. All of the fields that are set
+// must match.
+type DiskMatch struct {
+	// Name is the Linux device name, e.g. "/dev/sda".
+	Name string `json:"name,omitempty"`
+
+	// HCTL is the SCSI location of the device.
+	HCTL string `json:"hctl,omitempty"`
+
+	// Type is the device type, one of: HDD, SSD, NVME.
+	Type metal3v1alpha1.DiskType `json:"type,omitempty"`
+
+	// MinSizeGigabytes is the minimum size of the device.
+	MinSizeGigabytes int `json:"minSizeGigabytes,omitempty"`
 }
 
 var profiles = make(map[string]Profile)
 
+// matchOrder holds the names of the profiles with match rules, in the
+// order they are tried.
+var matchOrder []string
+
 func init() {
 	profiles[DefaultProfileName] = Profile{
 		Name: DefaultProfileName,
@@ -83,16 +134,6 @@ func init() {
 		LocalGB: 50,
 		CPUArch: "x86_64",
 	}
-
-	profiles["openstack"] = Profile{
-		Name: "openstack",
-		RootDeviceHints: metal3v1alpha1.RootDeviceHints{
-			DeviceName: "/dev/vdb",
-		},
-		RootGB:  10,
-		LocalGB: 50,
-		CPUArch: "x86_64",
-	}
 }
 
 // GetProfile returns the named profile
@@ -103,3 +144,126 @@ func GetProfile(name string) (Profile, error) {
 	}
 	return profile, nil
 }
+
+func (disk *DiskMatch) matches(storage metal3v1alpha1.Storage) bool {
+	switch {
+	case disk.Name != "" && disk.Name != storage.Name:
+		return false
+	case disk.HCTL != "" && disk.HCTL != storage.HCTL:
+		return false
+	case disk.Type != "" && disk.Type != storage.Type:
+		return false
+	}
+	return storage.SizeBytes >= metal3v1alpha1.Capacity(disk.MinSizeGigabytes)*metal3v1alpha1.GigaByte
+}
+
+func (match *ProfileMatch) compile() (err error) {
+	if match.Manufacturer != "" {
+		match.manufacturer, err = regexp.Compile(match.Manufacturer)
+		if err != nil {
+			return fmt.Errorf("invalid manufacturer rule: %w", err)
+		}
+	}
+	if match.ProductName != "" {
+		match.productName, err = regexp.Compile(match.ProductName)
+		if err != nil {
+			return fmt.Errorf("invalid product name rule: %w", err)
+		}
+	}
+	return nil
+}
+
+// Matches returns true if the hardware details fulfill all of the
+// rules.
+func (match *ProfileMatch) Matches(details *metal3v1alpha1.HardwareDetails) bool {
+	if details == nil {
+		details = &metal3v1alpha1.HardwareDetails{}
+	}
+
+	if match.manufacturer != nil && !match.manufacturer.MatchString(details.SystemVendor.Manufacturer) {
+		return false
+	}
+	if match.productName != nil && !match.productName.MatchString(details.SystemVendor.ProductName) {
+		return false
+	}
+	if match.CPUArch != "" && match.CPUArch != details.CPU.Arch {
+		return false
+	}
+
+	used := make([]bool, len(details.Storage))
+	for _, disk := range match.Disks {
+		found := false
+		for i, storage := range details.Storage {
+			if !used[i] && disk.matches(storage) {
+				used[i] = true
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// MatchProfile returns the name of the first profile whose rules are
+// fulfilled by the hardware details.
+func MatchProfile(details *metal3v1alpha1.HardwareDetails) (name string, found bool) {
+	for _, name := range matchOrder {
+		if profiles[name].Match.Matches(details) {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// AddProfile registers a profile, replacing any existing profile with
+// the same name. Profiles with match rules are tried in the order they
+// are added.
+func AddProfile(profile Profile) error {
+	if profile.Name == "" {
+		return fmt.Errorf("hardware profile has no name")
+	}
+	if profile.Match != nil {
+		if err := profile.Match.compile(); err != nil {
+			return fmt.Errorf("hardware profile %q: %w", profile.Name, err)
+		}
+	}
+
+	order := make([]string, 0, len(matchOrder)+1)
+	for _, name := range matchOrder {
+		if name != profile.Name {
+			order = append(order, name)
+		}
+	}
+	if profile.Match != nil {
+		order = append(order, profile.Name)
+	}
+
+	profiles[profile.Name] = profile
+	matchOrder = order
+	return nil
+}
+
+// LoadProfiles reads a list of profiles from a YAML file, such as one
+// mounted from a ConfigMap, and registers them.
+func LoadProfiles(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read hardware profiles: %w", err)
+	}
+
+	var loaded []Profile
+	if err := yaml.UnmarshalStrict(data, &loaded); err != nil {
+		return fmt.Errorf("failed to parse hardware profiles: %w", err)
+	}
+
+	for _, profile := range loaded {
+		if err := AddProfile(profile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
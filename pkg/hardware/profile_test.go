@@ -0,0 +1,210 @@
+package hardware
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+// saveProfiles restores the registered profiles at the end of a test.
+func saveProfiles(t *testing.T) {
+	savedProfiles := make(map[string]Profile, len(profiles))
+	for name, profile := range profiles {
+		savedProfiles[name] = profile
+	}
+	savedOrder := matchOrder
+	t.Cleanup(func() {
+		profiles = savedProfiles
+		matchOrder = savedOrder
+	})
+}
+
+func TestProfileMatch(t *testing.T) {
+	details := &metal3v1alpha1.HardwareDetails{
+		SystemVendor: metal3v1alpha1.HardwareSystemVendor{
+			Manufacturer: "Dell Inc.",
+			ProductName:  "PowerEdge R640",
+		},
+		CPU: metal3v1alpha1.CPU{Arch: "x86_64"},
+		Storage: []metal3v1alpha1.Storage{
+			{Name: "/dev/sda", HCTL: "0:2:0:0", Type: metal3v1alpha1.HDD, SizeBytes: 2 * metal3v1alpha1.TeraByte},
+			{Name: "/dev/sdb", HCTL: "0:2:1:0", Type: metal3v1alpha1.SSD, SizeBytes: 400 * metal3v1alpha1.GigaByte},
+		},
+	}
+
+	testCases := []struct {
+		Scenario string
+		Match    ProfileMatch
+		Details  *metal3v1alpha1.HardwareDetails
+		Expected bool
+	}{
+		{
+			Scenario: "no rules",
+			Details:  details,
+			Expected: true,
+		},
+		{
+			Scenario: "no rules or details",
+			Expected: true,
+		},
+		{
+			Scenario: "vendor and product",
+			Match:    ProfileMatch{Manufacturer: "^Dell", ProductName: "R6[0-9]0$"},
+			Details:  details,
+			Expected: true,
+		},
+		{
+			Scenario: "other vendor",
+			Match:    ProfileMatch{Manufacturer: "^HPE"},
+			Details:  details,
+			Expected: false,
+		},
+		{
+			Scenario: "no details",
+			Match:    ProfileMatch{Manufacturer: "^Dell"},
+			Expected: false,
+		},
+		{
+			Scenario: "other architecture",
+			Match:    ProfileMatch{CPUArch: "aarch64"},
+			Details:  details,
+			Expected: false,
+		},
+		{
+			Scenario: "disk layout",
+			Match: ProfileMatch{Disks: []DiskMatch{
+				{HCTL: "0:2:0:0"},
+				{Type: metal3v1alpha1.SSD, MinSizeGigabytes: 200},
+			}},
+			Details:  details,
+			Expected: true,
+		},
+		{
+			Scenario: "disk too small",
+			Match: ProfileMatch{Disks: []DiskMatch{
+				{Type: metal3v1alpha1.SSD, MinSizeGigabytes: 1000},
+			}},
+			Details:  details,
+			Expected: false,
+		},
+		{
+			Scenario: "more disks than present",
+			Match: ProfileMatch{Disks: []DiskMatch{
+				{}, {}, {},
+			}},
+			Details:  details,
+			Expected: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			assert.NoError(t, tc.Match.compile())
+			assert.Equal(t, tc.Expected, tc.Match.Matches(tc.Details))
+		})
+	}
+}
+
+func TestLoadProfiles(t *testing.T) {
+	saveProfiles(t)
+
+	path := filepath.Join(t.TempDir(), "profiles.yaml")
+	err := ioutil.WriteFile(path, []byte(`
+- name: dell-raid
+  rootDeviceHints:
+    hctl: "0:2:0:0"
+  cpuArch: x86_64
+  match:
+    manufacturer: "^Dell"
+    disks:
+    - hctl: "0:2:0:0"
+- name: arm
+  rootDeviceHints:
+    deviceName: /dev/nvme0n1
+  cpuArch: aarch64
+  match:
+    cpuArch: aarch64
+- name: catch-all
+  rootDeviceHints:
+    deviceName: /dev/sda
+  match: {}
+`), 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, LoadProfiles(path))
+
+	profile, err := GetProfile("arm")
+	assert.NoError(t, err)
+	assert.Equal(t, "aarch64", profile.CPUArch)
+	assert.Equal(t, "/dev/nvme0n1", profile.RootDeviceHints.DeviceName)
+
+	name, found := MatchProfile(&metal3v1alpha1.HardwareDetails{
+		SystemVendor: metal3v1alpha1.HardwareSystemVendor{Manufacturer: "Dell Inc."},
+		Storage:      []metal3v1alpha1.Storage{{HCTL: "0:2:0:0"}},
+	})
+	assert.True(t, found)
+	assert.Equal(t, "dell-raid", name)
+
+	name, found = MatchProfile(&metal3v1alpha1.HardwareDetails{
+		CPU: metal3v1alpha1.CPU{Arch: "aarch64"},
+	})
+	assert.True(t, found)
+	assert.Equal(t, "arm", name)
+
+	name, found = MatchProfile(nil)
+	assert.True(t, found)
+	assert.Equal(t, "catch-all", name)
+
+	// Built-in profiles are still available
+	_, err = GetProfile(DefaultProfileName)
+	assert.NoError(t, err)
+}
+
+func TestLoadProfilesInvalid(t *testing.T) {
+	saveProfiles(t)
+
+	testCases := []struct {
+		Scenario      string
+		Content       string
+		ExpectedError string
+	}{
+		{
+			Scenario:      "no name",
+			Content:       "- cpuArch: x86_64\n",
+			ExpectedError: "hardware profile has no name",
+		},
+		{
+			Scenario:      "invalid regexp",
+			Content:       "- name: bad\n  match:\n    manufacturer: \"[\"\n",
+			ExpectedError: "hardware profile \"bad\": invalid manufacturer rule",
+		},
+		{
+			Scenario:      "unknown field",
+			Content:       "- name: bad\n  rootDisk: /dev/sda\n",
+			ExpectedError: "failed to parse hardware profiles",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "profiles.yaml")
+			if err := ioutil.WriteFile(path, []byte(tc.Content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			err := LoadProfiles(path)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), tc.ExpectedError)
+			}
+		})
+	}
+
+	err := LoadProfiles(filepath.Join(os.TempDir(), "does-not-exist.yaml"))
+	assert.Error(t, err)
+}
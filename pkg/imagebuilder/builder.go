@@ -0,0 +1,637 @@
+package imagebuilder
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/types"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+// NetworkDataPath is the path of the network data in the initramfs of
+// the built images.
+const NetworkDataPath = "/etc/metal3/network_data.json"
+
+// ConfigDriveNetworkDataPath is the path at which the network data is
+// also stored in the initramfs, in the layout of a mounted config drive.
+// glean, which configures the network of agent images built with the
+// simple-init element, reads it from there on boot.
+const ConfigDriveNetworkDataPath = "/mnt/config/openstack/latest/network_data.json"
+
+const (
+	imagesDir = "images"
+	cacheDir  = "cache"
+
+	downloadTimeout = 30 * time.Minute
+	shutdownTimeout = 10 * time.Second
+
+	// The images are large and may be downloaded over slow links
+	serverReadTimeout  = time.Minute
+	serverWriteTimeout = 30 * time.Minute
+	serverIdleTimeout  = 2 * time.Minute
+)
+
+// BaseImageRevalidationInterval is the interval at which the cached base
+// images are checked for changes at their URL.
+const BaseImageRevalidationInterval = time.Hour
+
+// ErrBaseImageDownloading is returned by Build while the base image is
+// being downloaded. The build should be retried later.
+var ErrBaseImageDownloading = errors.New("the base image is being downloaded")
+
+// Image is an image built for a host.
+type Image struct {
+	// URL is the URL from which the image can be downloaded.
+	URL string
+	// Checksum is the SHA256 checksum of the image.
+	Checksum string
+}
+
+// Builder builds images of the agent embedding the network data of a
+// host, and serves them over HTTP.
+type Builder struct {
+	log logr.Logger
+	// the directory holding the built images and the cache of the
+	// base images
+	dir string
+	// the address the HTTP server listens to
+	addr string
+	// the URL at which the HTTP server is reachable by the hosts
+	url    string
+	client *http.Client
+	// the context of the downloads, cancelled when the server stops
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards the maps and the metadata of the base images. Files are
+	// copied and hashed without holding it.
+	mu sync.Mutex
+	// the checksums of the built images, by file name
+	checksums map[string]string
+	// the downloads of base images, by URL
+	downloads map[string]*download
+}
+
+// download is the download of a base image, which runs in the
+// background so that slow downloads do not hold up other builds.
+type download struct {
+	done chan struct{}
+	err  error
+}
+
+// baseImage is the metadata of a base image in the cache, stored next to
+// it. The file name contains the checksum, so that a new version does
+// not replace the file under builds reading the previous one.
+type baseImage struct {
+	URL          string    `json:"url"`
+	File         string    `json:"file"`
+	Checksum     string    `json:"checksum"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"lastModified,omitempty"`
+	CheckedAt    time.Time `json:"checkedAt"`
+}
+
+// New returns a Builder storing its images in a directory and serving
+// them on an address, reachable by the hosts at a URL.
+func New(log logr.Logger, dir, addr, url string) (*Builder, error) {
+	if url == "" {
+		return nil, fmt.Errorf("the URL of the preprovisioning images must be set")
+	}
+	for _, sub := range []string{imagesDir, cacheDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create the preprovisioning image directory: %w", err)
+		}
+	}
+	// Left behind by a restart during a download or a build
+	for _, pattern := range []string{"download-*", "build-*", "*.tmp"} {
+		files, _ := filepath.Glob(filepath.Join(dir, cacheDir, pattern))
+		for _, f := range files {
+			os.Remove(f)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Builder{
+		log:       log,
+		dir:       dir,
+		addr:      addr,
+		url:       strings.TrimSuffix(url, "/"),
+		client:    &http.Client{Timeout: downloadTimeout},
+		ctx:       ctx,
+		cancel:    cancel,
+		checksums: map[string]string{},
+		downloads: map[string]*download{},
+	}, nil
+}
+
+// filePrefix returns the prefix of the names of the images of a
+// PreprovisioningImage. Underscores are not valid in object names, so
+// prefixes of different objects never overlap.
+func filePrefix(namespace, name string) string {
+	return fmt.Sprintf("%s_%s_", namespace, name)
+}
+
+func hashOf(parts ...[]byte) string {
+	h := sha256.New()
+	for _, p := range parts {
+		fmt.Fprintf(h, "%d:", len(p))
+		h.Write(p)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Build builds an image of the PreprovisioningImage in the given format
+// from a base image, embedding the network data. Images are only rebuilt
+// when their inputs change, and the previous images of the object are
+// removed, as they are when the base image changes at its URL.
+// ErrBaseImageDownloading is returned until the base image has been
+// downloaded.
+func (b *Builder) Build(ctx context.Context, namespace, name string, format metal3v1alpha1.ImageFormat, baseURL string, networkData []byte) (Image, error) {
+	var ext string
+	switch format {
+	case metal3v1alpha1.ImageFormatISO:
+		ext = "iso"
+	case metal3v1alpha1.ImageFormatInitRD:
+		ext = "initrd"
+	default:
+		return Image{}, fmt.Errorf("unsupported image format %q", format)
+	}
+
+	b.mu.Lock()
+	base, err := b.baseImage(baseURL)
+	b.mu.Unlock()
+	if err != nil {
+		return Image{}, err
+	}
+	hash := hashOf([]byte(format), []byte(baseURL), []byte(base.Checksum), networkData)[:16]
+	fileName := fmt.Sprintf("%s%s.%s", filePrefix(namespace, name), hash, ext)
+	path := filepath.Join(b.dir, imagesDir, fileName)
+
+	checksum, err := b.checksum(fileName)
+	switch {
+	case err == nil:
+	case os.IsNotExist(err):
+		b.log.Info("building preprovisioning image", "image", fileName, "base", baseURL)
+		basePath := filepath.Join(b.dir, cacheDir, base.File)
+		if checksum, err = b.build(path, format, basePath, baseURL, networkData); err != nil {
+			return Image{}, err
+		}
+		b.mu.Lock()
+		b.checksums[fileName] = checksum
+		b.mu.Unlock()
+	default:
+		return Image{}, err
+	}
+
+	if err := b.remove(filePrefix(namespace, name), fileName); err != nil {
+		b.log.Error(err, "failed to remove stale preprovisioning images")
+	}
+
+	return Image{
+		URL:      fmt.Sprintf("%s/%s", b.url, fileName),
+		Checksum: checksum,
+	}, nil
+}
+
+// Remove removes the images of a deleted PreprovisioningImage.
+func (b *Builder) Remove(namespace, name string) error {
+	return b.remove(filePrefix(namespace, name), "")
+}
+
+// RemoveUnusedImages removes the images of the PreprovisioningImages
+// that are not in the given list, which were deleted while the operator
+// was not running. Images written after the call started are kept, as
+// they may belong to objects created since the list was made.
+func (b *Builder) RemoveUnusedImages(objects []types.NamespacedName) error {
+	started := time.Now()
+	prefixes := make([]string, 0, len(objects))
+	for _, obj := range objects {
+		prefixes = append(prefixes, filePrefix(obj.Namespace, obj.Name))
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(b.dir, imagesDir))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if hasAnyPrefix(f.Name(), prefixes) || f.ModTime().After(started) {
+			continue
+		}
+		b.log.Info("removing unused preprovisioning image", "image", f.Name())
+		if err := os.Remove(filepath.Join(b.dir, imagesDir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		b.mu.Lock()
+		delete(b.checksums, f.Name())
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+// remove removes the images with a prefix, except one.
+func (b *Builder) remove(prefix, keep string) error {
+	files, err := ioutil.ReadDir(filepath.Join(b.dir, imagesDir))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if !strings.HasPrefix(f.Name(), prefix) || f.Name() == keep {
+			continue
+		}
+		b.log.Info("removing stale preprovisioning image", "image", f.Name())
+		if err := os.Remove(filepath.Join(b.dir, imagesDir, f.Name())); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		b.mu.Lock()
+		delete(b.checksums, f.Name())
+		b.mu.Unlock()
+	}
+	return nil
+}
+
+func (b *Builder) checksum(fileName string) (string, error) {
+	b.mu.Lock()
+	checksum, ok := b.checksums[fileName]
+	b.mu.Unlock()
+	if ok {
+		if _, err := os.Stat(filepath.Join(b.dir, imagesDir, fileName)); err != nil {
+			b.mu.Lock()
+			delete(b.checksums, fileName)
+			b.mu.Unlock()
+			return "", err
+		}
+		return checksum, nil
+	}
+
+	// Built before a restart
+	f, err := os.Open(filepath.Join(b.dir, imagesDir, fileName))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	checksum = hex.EncodeToString(h.Sum(nil))
+	b.mu.Lock()
+	b.checksums[fileName] = checksum
+	b.mu.Unlock()
+	return checksum, nil
+}
+
+// build writes the image to a temporary file that is renamed once
+// complete, so that partial images are never served.
+func (b *Builder) build(path string, format metal3v1alpha1.ImageFormat, base, baseURL string, networkData []byte) (checksum string, err error) {
+	overlay, err := networkDataOverlay(networkData)
+	if err != nil {
+		return "", err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Join(b.dir, cacheDir), "build-")
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		tmp.Close()
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	src, err := os.Open(base)
+	if err != nil {
+		return "", err
+	}
+	_, err = io.Copy(tmp, src)
+	src.Close()
+	if err != nil {
+		return "", err
+	}
+
+	switch format {
+	case metal3v1alpha1.ImageFormatISO:
+		if err = appendToISOInitrd(tmp, overlay); err != nil {
+			return "", fmt.Errorf("failed to build the ISO image from %s: %w", baseURL, err)
+		}
+	case metal3v1alpha1.ImageFormatInitRD:
+		if err = appendToInitrd(tmp, overlay); err != nil {
+			return "", err
+		}
+	}
+
+	if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err = io.Copy(h, tmp); err != nil {
+		return "", err
+	}
+	if err = tmp.Sync(); err != nil {
+		return "", err
+	}
+	if err = os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// appendToInitrd appends data to an initramfs, aligned on 4 bytes as
+// expected by the kernel for concatenated archives.
+func appendToInitrd(f *os.File, data []byte) error {
+	size, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if rem := size % 4; rem != 0 {
+		if _, err := f.Write(make([]byte, 4-rem)); err != nil {
+			return err
+		}
+	}
+	_, err = f.Write(data)
+	return err
+}
+
+func baseName(url string) string {
+	return "base-" + hashOf([]byte(url))[:16]
+}
+
+// readBase returns the metadata of a base image in the cache, or nil if
+// it is not there.
+func (b *Builder) readBase(url string) (*baseImage, error) {
+	data, err := ioutil.ReadFile(filepath.Join(b.dir, cacheDir, baseName(url)+".json"))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	base := &baseImage{}
+	if err := json.Unmarshal(data, base); err != nil {
+		return nil, fmt.Errorf("invalid metadata of base image %s: %w", url, err)
+	}
+	if _, err := os.Stat(filepath.Join(b.dir, cacheDir, base.File)); err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return base, nil
+}
+
+// writeBase writes the metadata of a base image, replacing the previous
+// version atomically.
+func (b *Builder) writeBase(base *baseImage) error {
+	data, err := json.Marshal(base)
+	if err != nil {
+		return err
+	}
+	path := filepath.Join(b.dir, cacheDir, baseName(base.URL)+".json")
+	if err := ioutil.WriteFile(path+".tmp", data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(path+".tmp", path)
+}
+
+// baseImage returns a base image in the cache. If it is not there, a
+// download is started in the background and ErrBaseImageDownloading is
+// returned until it completes. The error of a failed download is
+// returned once, and the next call starts a new one. Cached images are
+// revalidated in the background once BaseImageRevalidationInterval has
+// passed, and used until a new version is downloaded. It must be called
+// with the lock held.
+func (b *Builder) baseImage(url string) (*baseImage, error) {
+	var running bool
+	var downloadErr error
+	if d, ok := b.downloads[url]; ok {
+		select {
+		case <-d.done:
+			delete(b.downloads, url)
+			downloadErr = d.err
+		default:
+			running = true
+		}
+	}
+
+	base, err := b.readBase(url)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case base == nil && running:
+		return nil, ErrBaseImageDownloading
+	case base == nil && downloadErr != nil:
+		return nil, downloadErr
+	case base == nil:
+		b.startDownload(url, nil)
+		return nil, ErrBaseImageDownloading
+	case !running && time.Since(base.CheckedAt) >= BaseImageRevalidationInterval:
+		b.startDownload(url, base)
+	}
+	return base, nil
+}
+
+// startDownload starts downloading a base image, or revalidating the
+// cached version when there is one. It must be called with the lock
+// held.
+func (b *Builder) startDownload(url string, cached *baseImage) {
+	d := &download{done: make(chan struct{})}
+	b.downloads[url] = d
+	go func() {
+		defer close(d.done)
+		d.err = b.fetchBase(url, cached)
+		if d.err == nil {
+			return
+		}
+		if cached == nil {
+			b.log.Error(d.err, "failed to download base image", "url", url)
+			return
+		}
+		// The cached version is used until the next revalidation
+		b.log.Error(d.err, "failed to revalidate base image, using the cached version", "url", url)
+		checked := *cached
+		checked.CheckedAt = time.Now()
+		d.err = b.writeBase(&checked)
+	}()
+}
+
+// fetchBase downloads a base image to the cache. When a version is
+// already cached, it is only downloaded again if it changed.
+func (b *Builder) fetchBase(url string, cached *baseImage) (err error) {
+	req, err := http.NewRequestWithContext(b.ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if cached != nil {
+		b.log.Info("revalidating base image", "url", url)
+		if cached.ETag != "" {
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+		if cached.LastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.LastModified)
+		}
+	} else {
+		b.log.Info("downloading base image", "url", url)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to download base image %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if cached != nil && resp.StatusCode == http.StatusNotModified {
+		checked := *cached
+		checked.CheckedAt = time.Now()
+		return b.writeBase(&checked)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download base image %s: %s", url, resp.Status)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Join(b.dir, cacheDir), "download-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		tmp.Close()
+		if err != nil {
+			os.Remove(tmp.Name())
+		}
+	}()
+	h := sha256.New()
+	if _, err = io.Copy(io.MultiWriter(tmp, h), resp.Body); err != nil {
+		return fmt.Errorf("failed to download base image %s: %w", url, err)
+	}
+	base := &baseImage{
+		URL:          url,
+		Checksum:     hex.EncodeToString(h.Sum(nil)),
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		CheckedAt:    time.Now(),
+	}
+	base.File = fmt.Sprintf("%s-%s", baseName(url), base.Checksum[:16])
+	if err = os.Rename(tmp.Name(), filepath.Join(b.dir, cacheDir, base.File)); err != nil {
+		return err
+	}
+
+	// Builds choose their base image with the lock held
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if err = b.writeBase(base); err != nil {
+		return err
+	}
+	if cached != nil && cached.File != base.File {
+		b.log.Info("base image changed", "url", url)
+		// Builds still reading the previous version keep it open
+		os.Remove(filepath.Join(b.dir, cacheDir, cached.File))
+	} else {
+		b.log.Info("downloaded base image", "url", url)
+	}
+	return nil
+}
+
+// RemoveUnusedBaseImages removes the base images of the cache that are
+// not downloaded from one of the given URLs, which are no longer
+// configured.
+func (b *Builder) RemoveUnusedBaseImages(urls []string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	keep := map[string]bool{}
+	for _, url := range urls {
+		if base, err := b.readBase(url); err == nil && base != nil {
+			keep[base.File] = true
+		}
+		keep[baseName(url)+".json"] = true
+	}
+	// Running downloads may not have written their metadata yet
+	var downloading []string
+	for url, d := range b.downloads {
+		select {
+		case <-d.done:
+		default:
+			downloading = append(downloading, baseName(url))
+		}
+	}
+
+	files, err := filepath.Glob(filepath.Join(b.dir, cacheDir, "base-*"))
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		name := filepath.Base(f)
+		if keep[name] || hasAnyPrefix(name, downloading) {
+			continue
+		}
+		b.log.Info("removing unused base image", "file", name)
+		if err := os.Remove(f); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasAnyPrefix(s string, prefixes []string) bool {
+	for _, p := range prefixes {
+		if strings.HasPrefix(s, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeHTTP serves the built images. Directory listings are refused, so
+// that the images can only be found from their URL.
+func (b *Builder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/")
+	if name == "" || strings.Contains(name, "/") || strings.HasPrefix(name, ".") {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	http.ServeFile(w, r, filepath.Join(b.dir, imagesDir, name))
+}
+
+// Start runs the HTTP server until the context is done. It implements
+// the Runnable interface of the controller manager.
+func (b *Builder) Start(ctx context.Context) error {
+	server := &http.Server{
+		Addr:         b.addr,
+		Handler:      b,
+		ReadTimeout:  serverReadTimeout,
+		WriteTimeout: serverWriteTimeout,
+		IdleTimeout:  serverIdleTimeout,
+	}
+	errs := make(chan error, 1)
+	go func() {
+		b.log.Info("serving preprovisioning images", "addr", b.addr, "url", b.url)
+		errs <- server.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+		b.cancel()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
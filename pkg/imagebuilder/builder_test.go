@@ -0,0 +1,493 @@
+package imagebuilder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+// readCPIO returns the files of a newc archive, by path.
+func readCPIO(t *testing.T, data []byte) map[string][]byte {
+	files := map[string][]byte{}
+	field := func(header []byte, i int) int {
+		v, err := strconv.ParseUint(string(header[6+8*i:14+8*i]), 16, 32)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return int(v)
+	}
+	align := func(n int) int { return (n + 3) &^ 3 }
+
+	for pos := 0; ; {
+		header := data[pos : pos+110]
+		if string(header[:6]) != cpioMagic {
+			t.Fatalf("invalid cpio header at %d", pos)
+		}
+		mode, size, nameSize := field(header, 1), field(header, 6), field(header, 11)
+		name := string(data[pos+110 : pos+110+nameSize-1])
+		pos = align(pos + 110 + nameSize)
+		if name == cpioTrailer {
+			return files
+		}
+		if mode&modeFile != 0 {
+			files[name] = data[pos : pos+size]
+		}
+		pos = align(pos + size)
+	}
+}
+
+func gunzip(t *testing.T, data []byte) []byte {
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestNetworkDataOverlay(t *testing.T) {
+	overlay, err := networkDataOverlay([]byte(`{"links": []}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := readCPIO(t, gunzip(t, overlay))
+	assert.Equal(t, map[string][]byte{
+		"etc/metal3/network_data.json":                  []byte(`{"links": []}`),
+		"mnt/config/openstack/latest/network_data.json": []byte(`{"links": []}`),
+	}, files)
+}
+
+// isoDirRecord returns a directory record of an ISO 9660 file system.
+func isoDirRecord(name []byte, extent, size uint32, dir bool) []byte {
+	length := 33 + len(name)
+	length += length % 2
+	record := make([]byte, length)
+	record[0] = byte(length)
+	putBothEndian(record[2:10], extent)
+	putBothEndian(record[10:18], size)
+	if dir {
+		record[isoRecordFlagsOffset] = isoFlagDirectory
+	}
+	record[32] = byte(len(name))
+	copy(record[33:], name)
+	return record
+}
+
+func jolietName(name string) []byte {
+	var out []byte
+	for _, u := range utf16.Encode([]rune(name)) {
+		out = append(out, byte(u>>8), byte(u))
+	}
+	return out
+}
+
+// makeISO returns a minimal ISO image with primary and Joliet volumes
+// holding an initrd file.
+func makeISO(initrd []byte) []byte {
+	const (
+		primaryRoot = 19
+		jolietRoot  = 20
+		initrdData  = 21
+	)
+	image := make([]byte, (initrdData+1)*isoSectorSize)
+	sector := func(n int) []byte { return image[n*isoSectorSize : (n+1)*isoSectorSize] }
+
+	descriptor := func(n int, kind byte, root uint32) {
+		d := sector(n)
+		d[0] = kind
+		copy(d[1:6], "CD001")
+		d[6] = 1
+		putBothEndian(d[isoVolumeSizeOffset:], uint32(len(image)/isoSectorSize))
+		copy(d[isoRootRecordOffset:], isoDirRecord([]byte{0}, root, isoSectorSize, true))
+	}
+	descriptor(16, isoTypePrimary, primaryRoot)
+	descriptor(17, isoTypeSupplementary, jolietRoot)
+	copy(sector(17)[88:], "%/E")
+	copy(sector(18), "\xffCD001\x01")
+
+	dir := func(n int, root uint32, name []byte) {
+		d := sector(n)
+		pos := copy(d, isoDirRecord([]byte{0}, root, isoSectorSize, true))
+		pos += copy(d[pos:], isoDirRecord([]byte{1}, root, isoSectorSize, true))
+		copy(d[pos:], isoDirRecord(name, initrdData, uint32(len(initrd)), false))
+	}
+	dir(primaryRoot, primaryRoot, []byte("INITRD.;1"))
+	dir(jolietRoot, jolietRoot, jolietName("initrd"))
+
+	copy(sector(initrdData), initrd)
+	return image
+}
+
+// readISOInitrd returns the content of the initrd of each volume.
+func readISOInitrd(t *testing.T, image []byte) [][]byte {
+	r := bytes.NewReader(image)
+	volumes, err := readISOVolumes(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents [][]byte
+	for _, v := range volumes {
+		record, err := v.find(r, "initrd")
+		if err != nil || record == nil {
+			t.Fatalf("initrd not found: %v", err)
+		}
+		start := int(record.extent) * isoSectorSize
+		contents = append(contents, image[start:start+int(record.size)])
+		assert.Equal(t, uint32(len(image)/isoSectorSize),
+			binary.LittleEndian.Uint32(image[v.offset+isoVolumeSizeOffset:]))
+	}
+	return contents
+}
+
+func newTestBuilder(t *testing.T, images map[string][]byte) (*Builder, *int) {
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := images[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		downloads++
+		w.Write(data)
+	}))
+	t.Cleanup(server.Close)
+
+	builder, err := New(logf.Log, t.TempDir(), ":0", server.URL+"/built/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	builder.url = server.URL
+	return builder, &downloads
+}
+
+// build builds an image, waiting for the base image to be downloaded.
+func build(t *testing.T, builder *Builder, ns, name string, format metal3v1alpha1.ImageFormat, baseURL string, networkData []byte) (Image, error) {
+	for {
+		image, err := builder.Build(context.Background(), ns, name, format, baseURL, networkData)
+		if !errors.Is(err, ErrBaseImageDownloading) {
+			return image, err
+		}
+		waitForDownload(t, builder, baseURL)
+	}
+}
+
+func waitForDownload(t *testing.T, builder *Builder, url string) {
+	builder.mu.Lock()
+	d := builder.downloads[url]
+	builder.mu.Unlock()
+	if d == nil {
+		return
+	}
+	select {
+	case <-d.done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("timed out waiting for the base image download")
+	}
+}
+
+// expireBase makes the cached base image due for revalidation.
+func expireBase(t *testing.T, builder *Builder, url string) {
+	base, err := builder.readBase(url)
+	if err != nil || base == nil {
+		t.Fatalf("base image not cached: %v", err)
+	}
+	base.CheckedAt = time.Now().Add(-BaseImageRevalidationInterval)
+	if err := builder.writeBase(base); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestBaseImageRevalidation(t *testing.T) {
+	var mu sync.Mutex
+	content, etag := "base v1", `"v1"`
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		downloads++
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(content))
+	}))
+	t.Cleanup(server.Close)
+	builder, err := New(logf.Log, t.TempDir(), ":0", server.URL+"/built/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	baseURL := server.URL + "/ipa.initramfs"
+	buildImage := func() Image {
+		image, err := build(t, builder, "myns", "myhost",
+			metal3v1alpha1.ImageFormatInitRD, baseURL, []byte("net"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return image
+	}
+
+	image := buildImage()
+	oldBase, _ := builder.readBase(baseURL)
+
+	// Unchanged, the base image is not downloaded again
+	expireBase(t, builder, baseURL)
+	assert.Equal(t, image, buildImage())
+	waitForDownload(t, builder, baseURL)
+	assert.Equal(t, 1, downloads)
+	base, _ := builder.readBase(baseURL)
+	assert.WithinDuration(t, time.Now(), base.CheckedAt, time.Minute)
+
+	// Changed, the cached version is used until the new one is
+	// downloaded, and the image is then rebuilt
+	mu.Lock()
+	content, etag = "base v2", `"v2"`
+	mu.Unlock()
+	expireBase(t, builder, baseURL)
+	assert.Equal(t, image, buildImage())
+	waitForDownload(t, builder, baseURL)
+	assert.Equal(t, 2, downloads)
+
+	rebuilt := buildImage()
+	assert.NotEqual(t, image.URL, rebuilt.URL)
+	data, err := ioutil.ReadFile(filepath.Join(builder.dir, imagesDir, filepath.Base(rebuilt.URL)))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("base v2"), data[:7])
+	_, err = os.Stat(filepath.Join(builder.dir, imagesDir, filepath.Base(image.URL)))
+	assert.True(t, os.IsNotExist(err))
+	_, err = os.Stat(filepath.Join(builder.dir, cacheDir, oldBase.File))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestRemoveUnusedBaseImages(t *testing.T) {
+	builder, _ := newTestBuilder(t, map[string][]byte{
+		"/ipa.initramfs": []byte("base"),
+		"/ipa.iso":       makeISO([]byte("base")),
+	})
+	initrdURL := builder.url + "/ipa.initramfs"
+	isoURL := builder.url + "/ipa.iso"
+	_, err := build(t, builder, "myns", "myhost", metal3v1alpha1.ImageFormatInitRD, initrdURL, []byte("net"))
+	assert.NoError(t, err)
+	_, err = build(t, builder, "myns", "myhost2", metal3v1alpha1.ImageFormatISO, isoURL, []byte("net"))
+	assert.NoError(t, err)
+	iso, _ := builder.readBase(isoURL)
+
+	assert.NoError(t, builder.RemoveUnusedBaseImages([]string{isoURL, builder.url + "/other.iso"}))
+	files, err := ioutil.ReadDir(filepath.Join(builder.dir, cacheDir))
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.ElementsMatch(t, []string{iso.File, baseName(isoURL) + ".json"}, names)
+	base, err := builder.readBase(initrdURL)
+	assert.NoError(t, err)
+	assert.Nil(t, base)
+}
+
+func TestRemoveUnusedImages(t *testing.T) {
+	builder, _ := newTestBuilder(t, map[string][]byte{
+		"/ipa.initramfs": []byte("base"),
+	})
+	initrdURL := builder.url + "/ipa.initramfs"
+	kept, err := build(t, builder, "myns", "myhost", metal3v1alpha1.ImageFormatInitRD, initrdURL, []byte("net"))
+	assert.NoError(t, err)
+	_, err = build(t, builder, "myns", "deleted", metal3v1alpha1.ImageFormatInitRD, initrdURL, []byte("net"))
+	assert.NoError(t, err)
+	// Written after the list of objects was made
+	recent := filepath.Join(builder.dir, imagesDir, filePrefix("myns", "new")+"0123456789abcdef.initrd")
+	if err := ioutil.WriteFile(recent, []byte("image"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	if err := os.Chtimes(recent, future, future); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.NoError(t, builder.RemoveUnusedImages([]types.NamespacedName{{Namespace: "myns", Name: "myhost"}}))
+	files, err := ioutil.ReadDir(filepath.Join(builder.dir, imagesDir))
+	assert.NoError(t, err)
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	assert.ElementsMatch(t, []string{path.Base(kept.URL), filepath.Base(recent)}, names)
+}
+
+func TestBuildDuringDownload(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Write([]byte("base"))
+	}))
+	t.Cleanup(server.Close)
+	builder, err := New(logf.Log, t.TempDir(), ":0", server.URL+"/built/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Builds do not wait for the download, including those of other
+	// hosts from the same base image
+	for _, name := range []string{"myhost", "myhost2"} {
+		_, err = builder.Build(context.Background(), "myns", name,
+			metal3v1alpha1.ImageFormatInitRD, server.URL+"/ipa.initramfs", []byte("net"))
+		assert.ErrorIs(t, err, ErrBaseImageDownloading)
+	}
+	assert.Len(t, builder.downloads, 1)
+
+	close(release)
+	image, err := build(t, builder, "myns", "myhost",
+		metal3v1alpha1.ImageFormatInitRD, server.URL+"/ipa.initramfs", []byte("net"))
+	assert.NoError(t, err)
+	assert.NotEmpty(t, image.URL)
+	assert.Empty(t, builder.downloads)
+}
+
+func checksumOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestBuildInitrd(t *testing.T) {
+	base := []byte("base initramfs")
+	builder, downloads := newTestBuilder(t, map[string][]byte{"/ipa.initramfs": base})
+	baseURL := builder.url + "/ipa.initramfs"
+
+	image, err := build(t, builder, "myns", "myhost",
+		metal3v1alpha1.ImageFormatInitRD, baseURL, []byte("net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Regexp(t, `/myns_myhost_[0-9a-f]{16}\.initrd$`, image.URL)
+
+	path := filepath.Join(builder.dir, imagesDir, filepath.Base(image.URL))
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, checksumOf(data), image.Checksum)
+	assert.Equal(t, base, data[:len(base)])
+	// The overlay is aligned on 4 bytes
+	assert.Equal(t, []byte{0, 0}, data[len(base):16])
+	files := readCPIO(t, gunzip(t, data[16:]))
+	assert.Equal(t, []byte("net"), files["etc/metal3/network_data.json"])
+
+	// Not rebuilt when nothing changed
+	again, err := build(t, builder, "myns", "myhost",
+		metal3v1alpha1.ImageFormatInitRD, baseURL, []byte("net"))
+	assert.NoError(t, err)
+	assert.Equal(t, image, again)
+
+	// The previous image is removed when the network data change, and
+	// the base image is not downloaded again
+	changed, err := build(t, builder, "myns", "myhost",
+		metal3v1alpha1.ImageFormatInitRD, baseURL, []byte("new net"))
+	assert.NoError(t, err)
+	assert.NotEqual(t, image.URL, changed.URL)
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+	assert.Equal(t, 1, *downloads)
+
+	// Images of other hosts are kept
+	other, err := build(t, builder, "myns", "myhost2",
+		metal3v1alpha1.ImageFormatInitRD, baseURL, []byte("net"))
+	assert.NoError(t, err)
+	assert.NoError(t, builder.Remove("myns", "myhost"))
+	files2, err := ioutil.ReadDir(filepath.Join(builder.dir, imagesDir))
+	assert.NoError(t, err)
+	if assert.Len(t, files2, 1) {
+		assert.Equal(t, filepath.Base(other.URL), files2[0].Name())
+	}
+}
+
+func TestBuildISO(t *testing.T) {
+	initrd := []byte("base initramfs!")
+	builder, _ := newTestBuilder(t, map[string][]byte{"/ipa.iso": makeISO(initrd)})
+
+	image, err := build(t, builder, "myns", "myhost",
+		metal3v1alpha1.ImageFormatISO, builder.url+"/ipa.iso", []byte("net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Regexp(t, `/myns_myhost_[0-9a-f]{16}\.iso$`, image.URL)
+
+	data, err := ioutil.ReadFile(filepath.Join(builder.dir, imagesDir, filepath.Base(image.URL)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, checksumOf(data), image.Checksum)
+	assert.Zero(t, len(data)%isoSectorSize)
+
+	contents := readISOInitrd(t, data)
+	if assert.Len(t, contents, 2) {
+		assert.Equal(t, contents[0], contents[1])
+		assert.Equal(t, initrd, contents[0][:len(initrd)])
+		files := readCPIO(t, gunzip(t, contents[0][16:]))
+		assert.Equal(t, []byte("net"), files["etc/metal3/network_data.json"])
+	}
+}
+
+func TestBuildErrors(t *testing.T) {
+	builder, _ := newTestBuilder(t, map[string][]byte{"/not-an.iso": []byte("garbage")})
+
+	_, err := build(t, builder, "myns", "myhost",
+		metal3v1alpha1.ImageFormatISO, builder.url+"/missing.iso", []byte("net"))
+	assert.Regexp(t, "failed to download base image .*404", err)
+
+	_, err = build(t, builder, "myns", "myhost",
+		metal3v1alpha1.ImageFormatISO, builder.url+"/not-an.iso", []byte("net"))
+	assert.Error(t, err)
+
+	files, err := ioutil.ReadDir(filepath.Join(builder.dir, imagesDir))
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestServeHTTP(t *testing.T) {
+	builder, _ := newTestBuilder(t, map[string][]byte{"/ipa.initramfs": []byte("base")})
+	image, err := build(t, builder, "myns", "myhost",
+		metal3v1alpha1.ImageFormatInitRD, builder.url+"/ipa.initramfs", []byte("net"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, expected := range map[string]int{
+		"/" + filepath.Base(image.URL): http.StatusOK,
+		"/":                            http.StatusNotFound,
+		"/../cache":                    http.StatusNotFound,
+		"/myns_other_0.iso":            http.StatusNotFound,
+	} {
+		w := httptest.NewRecorder()
+		builder.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, expected, w.Code, path)
+		if expected == http.StatusOK {
+			body, _ := io.ReadAll(w.Body)
+			assert.Equal(t, image.Checksum, checksumOf(body))
+		}
+	}
+}
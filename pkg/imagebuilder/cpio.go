@@ -0,0 +1,103 @@
+package imagebuilder
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+const (
+	cpioMagic   = "070701"
+	cpioTrailer = "TRAILER!!!"
+
+	modeDir  = 0040000
+	modeFile = 0100000
+)
+
+// cpioFile is a regular file to store in a cpio archive.
+type cpioFile struct {
+	path string
+	perm uint32
+	data []byte
+}
+
+func pad4(w io.Writer, n int) error {
+	if rem := n % 4; rem != 0 {
+		_, err := w.Write(make([]byte, 4-rem))
+		return err
+	}
+	return nil
+}
+
+func writeCPIOEntry(w io.Writer, ino int, name string, mode uint32, data []byte) error {
+	nlink := 1
+	if mode&modeDir != 0 {
+		nlink = 2
+	}
+	header := fmt.Sprintf("%s%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X%08X",
+		cpioMagic, ino, mode, 0, 0, nlink, 0, len(data), 0, 0, 0, 0, len(name)+1, 0)
+	if _, err := io.WriteString(w, header+name+"\x00"); err != nil {
+		return err
+	}
+	if err := pad4(w, len(header)+len(name)+1); err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	return pad4(w, len(data))
+}
+
+// writeCPIO writes an archive in the newc format, as used for the
+// initramfs, holding the files and their parent directories.
+func writeCPIO(w io.Writer, files []cpioFile) error {
+	ino := 1
+	dirs := map[string]bool{}
+	for _, f := range files {
+		name := strings.TrimPrefix(path.Clean(f.path), "/")
+
+		var parents []string
+		for dir := path.Dir(name); dir != "." && !dirs[dir]; dir = path.Dir(dir) {
+			parents = append([]string{dir}, parents...)
+		}
+		for _, dir := range parents {
+			if err := writeCPIOEntry(w, ino, dir, modeDir|0755, nil); err != nil {
+				return err
+			}
+			dirs[dir] = true
+			ino++
+		}
+
+		if err := writeCPIOEntry(w, ino, name, modeFile|f.perm, f.data); err != nil {
+			return err
+		}
+		ino++
+	}
+	return writeCPIOEntry(w, 0, cpioTrailer, 0, nil)
+}
+
+// networkDataOverlay returns a compressed initramfs archive holding the
+// network data, to append to the initramfs of the agent.
+func networkDataOverlay(networkData []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	err := writeCPIO(gz, []cpioFile{{
+		path: NetworkDataPath,
+		perm: 0600,
+		data: networkData,
+	}, {
+		path: ConfigDriveNetworkDataPath,
+		perm: 0600,
+		data: networkData,
+	}})
+	if err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
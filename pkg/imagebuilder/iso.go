@@ -0,0 +1,258 @@
+package imagebuilder
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode/utf16"
+)
+
+const (
+	isoSectorSize = 2048
+	// the volume descriptors start after the system area
+	isoFirstDescriptor = 16
+	isoMaxDescriptors  = 32
+
+	isoTypePrimary       = 1
+	isoTypeSupplementary = 2
+	isoTypeTerminator    = 255
+
+	isoRootRecordOffset  = 156
+	isoVolumeSizeOffset  = 80
+	isoRecordMinLength   = 34
+	isoRecordFlagsOffset = 25
+	isoFlagDirectory     = 0x02
+)
+
+// isoInitrdPaths are the paths where ISOs commonly store the initramfs
+// booted by their boot loaders.
+var isoInitrdPaths = []string{
+	"initrd",
+	"initrd.img",
+	"images/initrd.img",
+	"images/pxeboot/initrd.img",
+	"isolinux/initrd.img",
+}
+
+// isoRecord is a directory record of an ISO 9660 file system.
+type isoRecord struct {
+	// the offset of the record in the image
+	offset int64
+	extent uint32
+	size   uint32
+	dir    bool
+}
+
+func parseISORecord(buf []byte, offset int64) isoRecord {
+	return isoRecord{
+		offset: offset,
+		extent: binary.LittleEndian.Uint32(buf[2:6]),
+		size:   binary.LittleEndian.Uint32(buf[10:14]),
+		dir:    buf[isoRecordFlagsOffset]&isoFlagDirectory != 0,
+	}
+}
+
+func putBothEndian(buf []byte, value uint32) {
+	binary.LittleEndian.PutUint32(buf[0:4], value)
+	binary.BigEndian.PutUint32(buf[4:8], value)
+}
+
+// isoVolume is the directory tree of a volume descriptor, either the
+// primary one or a Joliet one.
+type isoVolume struct {
+	// the offset of the descriptor in the image
+	offset int64
+	root   isoRecord
+	joliet bool
+}
+
+func readISOVolumes(f io.ReaderAt) ([]isoVolume, error) {
+	var volumes []isoVolume
+	buf := make([]byte, isoSectorSize)
+	for i := 0; i < isoMaxDescriptors; i++ {
+		offset := int64(isoFirstDescriptor+i) * isoSectorSize
+		if _, err := f.ReadAt(buf, offset); err != nil {
+			return nil, fmt.Errorf("failed to read volume descriptor: %w", err)
+		}
+		if string(buf[1:6]) != "CD001" {
+			return nil, fmt.Errorf("not an ISO 9660 image")
+		}
+		switch buf[0] {
+		case isoTypePrimary, isoTypeSupplementary:
+			escape := string(buf[88:91])
+			volumes = append(volumes, isoVolume{
+				offset: offset,
+				root:   parseISORecord(buf[isoRootRecordOffset:isoRootRecordOffset+isoRecordMinLength], offset+isoRootRecordOffset),
+				joliet: buf[0] == isoTypeSupplementary && (escape == "%/@" || escape == "%/C" || escape == "%/E"),
+			})
+		case isoTypeTerminator:
+			if len(volumes) == 0 || volumes[0].joliet {
+				return nil, fmt.Errorf("no primary volume descriptor")
+			}
+			return volumes, nil
+		}
+	}
+	return nil, fmt.Errorf("no volume descriptor set terminator")
+}
+
+// isoName returns the name of a file without its version number.
+func isoName(raw []byte, joliet bool) string {
+	var name string
+	if joliet {
+		units := make([]uint16, len(raw)/2)
+		for i := range units {
+			units[i] = binary.BigEndian.Uint16(raw[2*i:])
+		}
+		name = string(utf16.Decode(units))
+	} else {
+		name = string(raw)
+	}
+	if i := strings.LastIndex(name, ";"); i >= 0 {
+		name = name[:i]
+	}
+	return strings.TrimSuffix(name, ".")
+}
+
+func (v isoVolume) lookup(f io.ReaderAt, dir isoRecord, name string) (*isoRecord, error) {
+	data := make([]byte, dir.size)
+	if _, err := f.ReadAt(data, int64(dir.extent)*isoSectorSize); err != nil {
+		return nil, fmt.Errorf("failed to read directory: %w", err)
+	}
+	for pos := 0; pos < len(data); {
+		length := int(data[pos])
+		if length == 0 {
+			// records do not cross sector boundaries
+			pos = (pos/isoSectorSize + 1) * isoSectorSize
+			continue
+		}
+		if length < isoRecordMinLength || pos+length > len(data) {
+			return nil, fmt.Errorf("invalid directory record")
+		}
+		record := data[pos : pos+length]
+		nameLength := int(record[32])
+		if 33+nameLength > length {
+			return nil, fmt.Errorf("invalid directory record")
+		}
+		if strings.EqualFold(isoName(record[33:33+nameLength], v.joliet), name) {
+			r := parseISORecord(record, int64(dir.extent)*isoSectorSize+int64(pos))
+			return &r, nil
+		}
+		pos += length
+	}
+	return nil, nil
+}
+
+// find returns the record of a file, or nil if it does not exist.
+func (v isoVolume) find(f io.ReaderAt, filePath string) (*isoRecord, error) {
+	record := &v.root
+	for _, name := range strings.Split(filePath, "/") {
+		if !record.dir {
+			return nil, nil
+		}
+		var err error
+		if record, err = v.lookup(f, *record, name); err != nil || record == nil {
+			return nil, err
+		}
+	}
+	if record.dir {
+		return nil, nil
+	}
+	return record, nil
+}
+
+// appendToISOInitrd appends data to the initramfs of the ISO image in
+// the file. Since files are stored in contiguous extents, the initramfs
+// is moved to the end of the image and the directory records of all the
+// volumes pointing to it are updated. Boot loaders find the initramfs
+// through the file system, so they boot the moved one.
+func appendToISOInitrd(f *os.File, data []byte) error {
+	volumes, err := readISOVolumes(f)
+	if err != nil {
+		return err
+	}
+
+	var initrdPath string
+	var initrd *isoRecord
+	for _, initrdPath = range isoInitrdPaths {
+		if initrd, err = volumes[0].find(f, initrdPath); err != nil {
+			return err
+		}
+		if initrd != nil {
+			break
+		}
+	}
+	if initrd == nil {
+		return fmt.Errorf("no initramfs found in the ISO image")
+	}
+	records := []isoRecord{*initrd}
+	for _, v := range volumes[1:] {
+		record, err := v.find(f, initrdPath)
+		if err != nil {
+			return err
+		}
+		if record != nil && record.extent == initrd.extent {
+			records = append(records, *record)
+		}
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	sectors := (info.Size() + isoSectorSize - 1) / isoSectorSize
+	newOffset := sectors * isoSectorSize
+	if newOffset/isoSectorSize > int64(^uint32(0)) {
+		return fmt.Errorf("ISO image too large")
+	}
+
+	// Copy the initramfs, followed by the padding expected between
+	// concatenated archives and by the data
+	src := io.NewSectionReader(f, int64(initrd.extent)*isoSectorSize, int64(initrd.size))
+	if _, err := f.Seek(newOffset, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, src); err != nil {
+		return fmt.Errorf("failed to copy the initramfs: %w", err)
+	}
+	size := int64(initrd.size)
+	if rem := size % 4; rem != 0 {
+		if _, err := f.Write(make([]byte, 4-rem)); err != nil {
+			return err
+		}
+		size += 4 - rem
+	}
+	if _, err := f.Write(data); err != nil {
+		return err
+	}
+	size += int64(len(data))
+	if size > int64(^uint32(0)) {
+		return fmt.Errorf("initramfs too large")
+	}
+
+	end := newOffset + size
+	totalSectors := (end + isoSectorSize - 1) / isoSectorSize
+	if err := f.Truncate(totalSectors * isoSectorSize); err != nil {
+		return err
+	}
+
+	field := make([]byte, 8)
+	for _, r := range records {
+		putBothEndian(field, uint32(newOffset/isoSectorSize))
+		if _, err := f.WriteAt(field, r.offset+2); err != nil {
+			return err
+		}
+		putBothEndian(field, uint32(size))
+		if _, err := f.WriteAt(field, r.offset+10); err != nil {
+			return err
+		}
+	}
+	putBothEndian(field, uint32(totalSectors))
+	for _, v := range volumes {
+		if _, err := f.WriteAt(field, v.offset+isoVolumeSizeOffset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
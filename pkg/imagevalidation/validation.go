@@ -0,0 +1,206 @@
+package imagevalidation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+const (
+	requestTimeout = 30 * time.Second
+
+	// the largest checksum file read
+	maxChecksumFileSize = 1 << 20
+
+	// the ISO 9660 identifier is at the start of the first volume
+	// descriptor, after 32KiB of system area
+	isoIdentifierOffset = 32769
+	headerSize          = isoIdentifierOffset + 5
+)
+
+var checksumLengths = map[string]int{
+	string(metal3v1alpha1.MD5):    32,
+	string(metal3v1alpha1.SHA256): 64,
+	string(metal3v1alpha1.SHA512): 128,
+}
+
+// bsdChecksumLine matches the lines of the checksum files written by
+// the BSD tools and by sha256sum --tag, e.g. SHA256 (image.qcow2) = ...
+var bsdChecksumLine = regexp.MustCompile(`^[A-Z0-9]+ \((.+)\) = ([0-9a-fA-F]+)$`)
+
+// Validator checks that images can be downloaded, that they are in the
+// expected format and that their checksum is available.
+type Validator struct {
+	client *http.Client
+}
+
+// New returns a Validator.
+func New() *Validator {
+	return &Validator{
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+func (v *Validator) get(ctx context.Context, rawURL string, limit int64, partial bool) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if partial {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", limit-1))
+	}
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusPartialContent:
+	default:
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, limit))
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Validate checks that the image can be downloaded and is in its disk
+// format, and returns its checksum, read from the checksum URL if
+// needed. Live ISOs do not need a checksum.
+func (v *Validator) Validate(ctx context.Context, image *metal3v1alpha1.Image) (checksum string, err error) {
+	header, err := v.get(ctx, image.URL, headerSize, true)
+	if err != nil {
+		return "", fmt.Errorf("failed to download image %s: %w", image.URL, err)
+	}
+	if image.DiskFormat != nil {
+		if err := checkFormat(header, *image.DiskFormat); err != nil {
+			return "", err
+		}
+	}
+
+	if image.DiskFormat != nil && *image.DiskFormat == "live-iso" {
+		return "", nil
+	}
+	checksum, checksumType, ok := image.GetChecksum()
+	if !ok {
+		return "", fmt.Errorf("no valid checksum for image %s", image.URL)
+	}
+	if image.ChecksumIsURL() {
+		data, err := v.get(ctx, checksum, maxChecksumFileSize, false)
+		if err != nil {
+			return "", fmt.Errorf("failed to download checksum %s: %w", checksum, err)
+		}
+		if checksum, err = findChecksum(data, imageFileName(image.URL)); err != nil {
+			return "", fmt.Errorf("checksum %s: %w", image.Checksum, err)
+		}
+		if checksumType == "" {
+			checksumType = string(metal3v1alpha1.DetectChecksumType(checksum))
+		}
+	}
+	if err := checkChecksum(checksum, checksumType); err != nil {
+		return "", err
+	}
+	return checksum, nil
+}
+
+// detectFormat returns the disk format of an image from its first
+// bytes.
+func detectFormat(header []byte) string {
+	switch {
+	case bytes.HasPrefix(header, []byte("QFI\xfb")):
+		return "qcow2"
+	case bytes.HasPrefix(header, []byte("KDMV")), bytes.HasPrefix(header, []byte("# Disk DescriptorFile")):
+		return "vmdk"
+	case len(header) >= 0x44 && bytes.Equal(header[0x40:0x44], []byte{0x7f, 0x10, 0xda, 0xbe}):
+		return "vdi"
+	case len(header) >= headerSize && string(header[isoIdentifierOffset:headerSize]) == "CD001":
+		return "iso"
+	}
+	return "raw"
+}
+
+func checkFormat(header []byte, diskFormat string) error {
+	detected := detectFormat(header)
+	switch diskFormat {
+	case "raw":
+		// ISO images can be written to disks as is
+		if detected == "raw" || detected == "iso" {
+			return nil
+		}
+	case "live-iso":
+		if detected == "iso" {
+			return nil
+		}
+	default:
+		if detected == diskFormat {
+			return nil
+		}
+	}
+	return fmt.Errorf("image is in the %s format, not %s", detected, diskFormat)
+}
+
+// imageFileName returns the name of the image file, as listed in
+// checksum files.
+func imageFileName(rawURL string) string {
+	if u, err := url.Parse(rawURL); err == nil {
+		return path.Base(u.Path)
+	}
+	return path.Base(rawURL)
+}
+
+// findChecksum returns the checksum of a file from a checksum file in
+// the formats written by sha256sum and similar tools. A file holding a
+// single checksum without a file name applies to any image.
+func findChecksum(data []byte, fileName string) (string, error) {
+	var single []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if m := bsdChecksumLine.FindStringSubmatch(line); m != nil {
+			if path.Base(m[1]) == fileName {
+				return m[2], nil
+			}
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 1 {
+			single = append(single, fields[0])
+			continue
+		}
+		name := strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(line, fields[0])), "*")
+		if path.Base(name) == fileName {
+			return fields[0], nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	if len(single) == 1 {
+		return single[0], nil
+	}
+	return "", fmt.Errorf("no checksum found for %s", fileName)
+}
+
+func checkChecksum(checksum, checksumType string) error {
+	if _, err := hex.DecodeString(checksum); err != nil || len(checksum) != checksumLengths[checksumType] {
+		return fmt.Errorf("invalid %s checksum %q", checksumType, checksum)
+	}
+	return nil
+}
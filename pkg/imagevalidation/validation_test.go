@@ -0,0 +1,211 @@
+package imagevalidation
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+const (
+	sha256Hash = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	otherHash  = "60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+	sha512Hash = "ee26b0dd4af7e749aa1a8ee3c10ae9923f618980772e473f8819a5d4940e0db27ac185f8a0e1d5f84f88bc887fd67b143732c304cc5fa9ad8e6f57f50028a8ff"
+)
+
+func format(value string) *string {
+	return &value
+}
+
+func isoHeader() []byte {
+	header := make([]byte, headerSize)
+	copy(header[isoIdentifierOffset:], "CD001")
+	return header
+}
+
+func TestDetectFormat(t *testing.T) {
+	vdi := make([]byte, 0x50)
+	copy(vdi[0x40:], []byte{0x7f, 0x10, 0xda, 0xbe})
+
+	for expected, header := range map[string][]byte{
+		"qcow2": []byte("QFI\xfb\x00\x00\x00\x03"),
+		"vmdk":  []byte("KDMV\x01\x00\x00\x00"),
+		"vdi":   vdi,
+		"iso":   isoHeader(),
+		"raw":   make([]byte, 512),
+	} {
+		assert.Equal(t, expected, detectFormat(header))
+	}
+}
+
+func TestFindChecksum(t *testing.T) {
+	testCases := []struct {
+		Scenario      string
+		Content       string
+		Expected      string
+		ExpectedError string
+	}{
+		{
+			Scenario: "sha256sum output",
+			Content:  otherHash + "  other.qcow2\n" + sha256Hash + "  image.qcow2\n",
+			Expected: sha256Hash,
+		},
+		{
+			Scenario: "binary mode and path",
+			Content:  sha256Hash + " *images/image.qcow2\n",
+			Expected: sha256Hash,
+		},
+		{
+			Scenario: "BSD format",
+			Content:  "# comment\nSHA256 (image.qcow2) = " + sha256Hash + "\n",
+			Expected: sha256Hash,
+		},
+		{
+			Scenario: "single checksum",
+			Content:  sha256Hash + "\n",
+			Expected: sha256Hash,
+		},
+		{
+			Scenario:      "missing file",
+			Content:       otherHash + "  other.qcow2\n",
+			ExpectedError: "no checksum found for image.qcow2",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			checksum, err := findChecksum([]byte(tc.Content), "image.qcow2")
+			if tc.ExpectedError != "" {
+				assert.EqualError(t, err, tc.ExpectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.Expected, checksum)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	qcow2 := append([]byte("QFI\xfb"), make([]byte, 100)...)
+	files := map[string][]byte{
+		"/image.qcow2": qcow2,
+		"/live.iso":    isoHeader(),
+		"/SHA256SUMS":  []byte(sha256Hash + "  image.qcow2\n"),
+		"/checksums":   []byte(sha512Hash + "  image.qcow2\n"),
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, ok := files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(data)
+	}))
+	defer server.Close()
+
+	testCases := []struct {
+		Scenario         string
+		Image            metal3v1alpha1.Image
+		ExpectedChecksum string
+		ExpectedError    string
+	}{
+		{
+			Scenario: "checksum URL",
+			Image: metal3v1alpha1.Image{
+				URL:        server.URL + "/image.qcow2",
+				Checksum:   server.URL + "/SHA256SUMS",
+				DiskFormat: format("qcow2"),
+			},
+			ExpectedChecksum: sha256Hash,
+		},
+		{
+			Scenario: "checksum type detected from the checksum read",
+			Image: metal3v1alpha1.Image{
+				URL:      server.URL + "/image.qcow2",
+				Checksum: server.URL + "/checksums",
+			},
+			ExpectedChecksum: sha512Hash,
+		},
+		{
+			Scenario: "checksum",
+			Image: metal3v1alpha1.Image{
+				URL:          server.URL + "/image.qcow2",
+				Checksum:     sha256Hash,
+				ChecksumType: metal3v1alpha1.SHA256,
+			},
+			ExpectedChecksum: sha256Hash,
+		},
+		{
+			Scenario: "live ISO",
+			Image: metal3v1alpha1.Image{
+				URL:        server.URL + "/live.iso",
+				DiskFormat: format("live-iso"),
+			},
+		},
+		{
+			Scenario: "missing image",
+			Image: metal3v1alpha1.Image{
+				URL:      server.URL + "/missing.qcow2",
+				Checksum: sha256Hash,
+			},
+			ExpectedError: "failed to download image .*/missing.qcow2: 404 Not Found",
+		},
+		{
+			Scenario: "wrong format",
+			Image: metal3v1alpha1.Image{
+				URL:        server.URL + "/image.qcow2",
+				Checksum:   server.URL + "/SHA256SUMS",
+				DiskFormat: format("raw"),
+			},
+			ExpectedError: "image is in the qcow2 format, not raw",
+		},
+		{
+			Scenario: "missing checksum file",
+			Image: metal3v1alpha1.Image{
+				URL:      server.URL + "/image.qcow2",
+				Checksum: server.URL + "/SHA512SUMS",
+			},
+			ExpectedError: "failed to download checksum .*/SHA512SUMS: 404 Not Found",
+		},
+		{
+			Scenario: "checksum of the wrong type",
+			Image: metal3v1alpha1.Image{
+				URL:          server.URL + "/image.qcow2",
+				Checksum:     sha256Hash,
+				ChecksumType: metal3v1alpha1.MD5,
+			},
+			ExpectedError: "invalid md5 checksum",
+		},
+		{
+			Scenario: "no checksum",
+			Image: metal3v1alpha1.Image{
+				URL: server.URL + "/image.qcow2",
+			},
+			ExpectedError: "no valid checksum for image",
+		},
+	}
+
+	validator := New()
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			checksum, err := validator.Validate(context.Background(), &tc.Image)
+			if tc.ExpectedError != "" {
+				assert.Regexp(t, tc.ExpectedError, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.ExpectedChecksum, checksum)
+			}
+		})
+	}
+
+	// Only the start of the image is downloaded
+	files["/image.qcow2"] = append(qcow2, []byte(strings.Repeat("x", 2*headerSize))...)
+	header, err := validator.get(context.Background(), server.URL+"/image.qcow2", headerSize, true)
+	assert.NoError(t, err)
+	assert.Len(t, header, headerSize)
+}
@@ -0,0 +1,583 @@
+package ociimage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// Scheme is the prefix of the image URLs referencing an OCI registry.
+const Scheme = "oci://"
+
+const (
+	mediaTypeOCIIndex       = "application/vnd.oci.image.index.v1+json"
+	mediaTypeOCIManifest    = "application/vnd.oci.image.manifest.v1+json"
+	mediaTypeDockerList     = "application/vnd.docker.distribution.manifest.list.v2+json"
+	mediaTypeDockerManifest = "application/vnd.docker.distribution.manifest.v2+json"
+
+	// titleAnnotation holds the file name of a layer, as set by oras
+	// and similar tools when pushing files to a registry.
+	titleAnnotation = "org.opencontainers.image.title"
+
+	requestTimeout  = 30 * time.Second
+	maxManifestSize = 4 << 20
+
+	defaultTag = "latest"
+)
+
+// architectures maps the CPU architectures reported for the hosts to
+// the names used in OCI image indexes.
+var architectures = map[string]string{
+	"x86_64":  "amd64",
+	"aarch64": "arm64",
+}
+
+// diskFormats maps the file extensions of the layers to the disk
+// formats of the images. Compressed images cannot be written as is and
+// are not listed.
+var diskFormats = map[string]string{
+	".qcow2": "qcow2",
+	".raw":   "raw",
+	".img":   "raw",
+	".iso":   "iso",
+	".vmdk":  "vmdk",
+	".vdi":   "vdi",
+}
+
+// IsReference returns whether an image URL references an OCI registry.
+func IsReference(imageURL string) bool {
+	return strings.HasPrefix(imageURL, Scheme)
+}
+
+// Reference is a parsed reference to an image in an OCI registry,
+// e.g. oci://quay.io/example/os:1.0 or oci://quay.io/example/os@sha256:...
+type Reference struct {
+	Registry   string
+	Repository string
+	Tag        string
+	Digest     string
+}
+
+// ParseReference parses an oci:// image URL. The tag defaults to
+// latest when neither a tag nor a digest is given.
+func ParseReference(imageURL string) (Reference, error) {
+	if !IsReference(imageURL) {
+		return Reference{}, fmt.Errorf("%s is not an OCI reference", imageURL)
+	}
+	rest := strings.TrimPrefix(imageURL, Scheme)
+
+	slash := strings.Index(rest, "/")
+	if slash <= 0 {
+		return Reference{}, fmt.Errorf("no registry in OCI reference %s", imageURL)
+	}
+	ref := Reference{Registry: rest[:slash]}
+	rest = rest[slash+1:]
+
+	if at := strings.Index(rest, "@"); at >= 0 {
+		ref.Digest = rest[at+1:]
+		rest = rest[:at]
+		if _, _, err := splitDigest(ref.Digest); err != nil {
+			return Reference{}, fmt.Errorf("invalid OCI reference %s: %w", imageURL, err)
+		}
+	}
+	if colon := strings.LastIndex(rest, ":"); colon > strings.LastIndex(rest, "/") {
+		ref.Tag = rest[colon+1:]
+		rest = rest[:colon]
+		if ref.Tag == "" {
+			return Reference{}, fmt.Errorf("empty tag in OCI reference %s", imageURL)
+		}
+	}
+	if rest == "" {
+		return Reference{}, fmt.Errorf("no repository in OCI reference %s", imageURL)
+	}
+	ref.Repository = rest
+	if ref.Tag == "" && ref.Digest == "" {
+		ref.Tag = defaultTag
+	}
+
+	// Docker Hub is known by its index name, but served elsewhere
+	if ref.Registry == "docker.io" || ref.Registry == "index.docker.io" {
+		ref.Registry = "registry-1.docker.io"
+		if !strings.Contains(ref.Repository, "/") {
+			ref.Repository = "library/" + ref.Repository
+		}
+	}
+	return ref, nil
+}
+
+// PinReference returns an image URL pinned to the digest of the
+// manifest it resolved to. The tag is kept for readability, e.g.
+// oci://quay.io/example/os:1.0@sha256:...
+func PinReference(imageURL, digest string) string {
+	if at := strings.Index(imageURL, "@"); at >= 0 {
+		imageURL = imageURL[:at]
+	}
+	return imageURL + "@" + digest
+}
+
+// IsPinnedFrom returns whether pinned is imageURL, or imageURL pinned
+// to a digest by PinReference.
+func IsPinnedFrom(pinned, imageURL string) bool {
+	if pinned == imageURL {
+		return true
+	}
+	if !IsReference(pinned) || strings.Contains(imageURL, "@") {
+		return false
+	}
+	at := strings.Index(pinned, "@")
+	return at >= 0 && pinned[:at] == imageURL
+}
+
+func (r Reference) manifestRef() string {
+	if r.Digest != "" {
+		return r.Digest
+	}
+	return r.Tag
+}
+
+// splitDigest returns the algorithm and the hex encoded hash of a
+// digest, e.g. sha256:0123...
+func splitDigest(digest string) (algorithm, hash string, err error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid digest %q", digest)
+	}
+	lengths := map[string]int{"sha256": 64, "sha512": 128}
+	length, ok := lengths[parts[0]]
+	if !ok {
+		return "", "", fmt.Errorf("unsupported digest algorithm in %q", digest)
+	}
+	if _, err := hex.DecodeString(parts[1]); err != nil || len(parts[1]) != length {
+		return "", "", fmt.Errorf("invalid digest %q", digest)
+	}
+	return parts[0], parts[1], nil
+}
+
+// Image is an image resolved from an OCI reference.
+type Image struct {
+	// URL is the URL from which the image can be downloaded without
+	// credentials.
+	URL string
+	// Signed is whether the URL is a signed URL of the storage of the
+	// registry, which usually expires within minutes.
+	Signed bool
+	// ManifestDigest is the digest of the manifest the tag resolved to.
+	ManifestDigest string
+	// Checksum and ChecksumType are taken from the digest of the layer.
+	Checksum     string
+	ChecksumType string
+	// DiskFormat is the format of the layer.
+	DiskFormat string
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	} `json:"platform,omitempty"`
+}
+
+type manifest struct {
+	MediaType string       `json:"mediaType"`
+	Manifests []descriptor `json:"manifests"`
+	Layers    []descriptor `json:"layers"`
+}
+
+// Resolver resolves OCI references to images that Ironic can download.
+type Resolver struct {
+	client *http.Client
+}
+
+// NewResolver returns a Resolver.
+func NewResolver() *Resolver {
+	return &Resolver{
+		client: &http.Client{Timeout: requestTimeout},
+	}
+}
+
+// Resolve resolves an OCI reference to the layer of the image for a CPU
+// architecture and a disk format, which may be empty if the image has
+// only one. The tag is resolved to the digest of its manifest, and the
+// checksum of the image is the digest of the layer. The credentials are
+// read from a pull secret in the format of ~/.docker/config.json, and
+// may be empty for public images.
+func (r *Resolver) Resolve(ctx context.Context, imageURL, arch, diskFormat, dockerConfig string) (*Image, error) {
+	ref, err := ParseReference(imageURL)
+	if err != nil {
+		return nil, err
+	}
+	auth, err := newAuthorizer(r.client, ref, dockerConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	m, digest, err := r.getManifest(ctx, auth, ref, ref.manifestRef())
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Manifests) > 0 {
+		platform, err := selectPlatform(m.Manifests, arch)
+		if err != nil {
+			return nil, err
+		}
+		if m, digest, err = r.getManifest(ctx, auth, ref, platform.Digest); err != nil {
+			return nil, err
+		}
+	}
+
+	layer, format, err := selectLayer(m.Layers, diskFormat)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", imageURL, err)
+	}
+	algorithm, checksum, err := splitDigest(layer.Digest)
+	if err != nil {
+		return nil, err
+	}
+	blobURL, signed, err := r.blobURL(ctx, auth, ref, layer.Digest)
+	if err != nil {
+		return nil, err
+	}
+	return &Image{
+		URL:            blobURL,
+		Signed:         signed,
+		ManifestDigest: digest,
+		Checksum:       checksum,
+		ChecksumType:   algorithm,
+		DiskFormat:     format,
+	}, nil
+}
+
+func registryURL(ref Reference, kind, name string) string {
+	return fmt.Sprintf("https://%s/v2/%s/%s/%s", ref.Registry, ref.Repository, kind, name)
+}
+
+// getManifest returns a manifest or an index and its digest, checked
+// against the requested one if any.
+func (r *Resolver) getManifest(ctx context.Context, auth *authorizer, ref Reference, name string) (*manifest, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, registryURL(ref, "manifests", name), nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Accept", strings.Join([]string{
+		mediaTypeOCIIndex, mediaTypeOCIManifest, mediaTypeDockerList, mediaTypeDockerManifest,
+	}, ", "))
+	resp, err := auth.do(ctx, req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get manifest %s of %s: %w", name, ref.Repository, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("failed to get manifest %s of %s: %s", name, ref.Repository, resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxManifestSize))
+	if err != nil {
+		return nil, "", err
+	}
+
+	sum := sha256.Sum256(data)
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	if strings.HasPrefix(name, "sha256:") && name != digest {
+		return nil, "", fmt.Errorf("manifest %s of %s does not match its digest", name, ref.Repository)
+	}
+
+	var m manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, "", fmt.Errorf("invalid manifest %s of %s: %w", name, ref.Repository, err)
+	}
+	return &m, digest, nil
+}
+
+// selectPlatform returns the manifest of an index for a CPU
+// architecture. An index with a single manifest is used for any host.
+func selectPlatform(manifests []descriptor, arch string) (*descriptor, error) {
+	if len(manifests) == 1 && manifests[0].Platform == nil {
+		return &manifests[0], nil
+	}
+	name := arch
+	if mapped, ok := architectures[arch]; ok {
+		name = mapped
+	}
+	for i, m := range manifests {
+		if m.Platform != nil && m.Platform.Architecture == name {
+			return &manifests[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no image for the %s architecture", arch)
+}
+
+// layerFormat returns the disk format of a layer from its file name.
+func layerFormat(layer descriptor) string {
+	title := layer.Annotations[titleAnnotation]
+	return diskFormats[strings.ToLower(path.Ext(title))]
+}
+
+// selectLayer returns the layer holding the image in a disk format. Raw
+// disk images may also be ISOs, and live ISOs must be.
+func selectLayer(layers []descriptor, diskFormat string) (*descriptor, string, error) {
+	var found []int
+	for i, l := range layers {
+		format := layerFormat(l)
+		switch {
+		case format == "":
+		case diskFormat == "", diskFormat == format,
+			diskFormat == "live-iso" && format == "iso",
+			diskFormat == "raw" && format == "iso":
+			found = append(found, i)
+		}
+	}
+	switch {
+	case len(found) == 1:
+		layer := &layers[found[0]]
+		if diskFormat != "" {
+			return layer, diskFormat, nil
+		}
+		format := layerFormat(*layer)
+		if format == "iso" {
+			// ISOs are written to disk as raw images unless they are
+			// booted as live ISOs, which the disk format must request
+			format = "raw"
+		}
+		return layer, format, nil
+	case len(found) > 1 && diskFormat == "":
+		return nil, "", fmt.Errorf("several disk images, the format must be set")
+	case len(found) > 1:
+		return nil, "", fmt.Errorf("several %s disk images", diskFormat)
+	case diskFormat != "":
+		return nil, "", fmt.Errorf("no %s disk image", diskFormat)
+	}
+	return nil, "", fmt.Errorf("no disk image, layers need a %s annotation with a file name ending in one of .qcow2, .raw, .img, .iso, .vmdk or .vdi", titleAnnotation)
+}
+
+// blobURL returns a URL from which a blob can be downloaded without
+// credentials. Most registries redirect blob downloads to a signed URL
+// of their storage, which is used when they do. Signed URLs are only
+// valid for a while, and a new one is returned each time.
+func (r *Resolver) blobURL(ctx context.Context, auth *authorizer, ref Reference, digest string) (blobURL string, signed bool, err error) {
+	blob := registryURL(ref, "blobs", digest)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, blob, nil)
+	if err != nil {
+		return "", false, err
+	}
+	resp, err := auth.do(ctx, req)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to get blob %s of %s: %w", digest, ref.Repository, err)
+	}
+	resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if auth.used {
+			return "", false, fmt.Errorf("the registry %s requires credentials to download blobs and does not redirect them to its storage", ref.Registry)
+		}
+		return blob, false, nil
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		location, err := resp.Location()
+		if err != nil {
+			return "", false, fmt.Errorf("invalid redirection of blob %s of %s: %w", digest, ref.Repository, err)
+		}
+		return location.String(), true, nil
+	}
+	return "", false, fmt.Errorf("failed to get blob %s of %s: %s", digest, ref.Repository, resp.Status)
+}
+
+// authorizer authenticates requests to a registry, with a bearer token
+// or basic authentication as requested by its challenges.
+type authorizer struct {
+	client   *http.Client
+	ref      Reference
+	username string
+	password string
+	// the Authorization header, once authenticated
+	header string
+	// whether requests needed authentication
+	used bool
+}
+
+type dockerConfigJSON struct {
+	Auths map[string]struct {
+		Auth     string `json:"auth"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+	} `json:"auths"`
+}
+
+// registryHost returns the host name of a registry key of a Docker
+// config, which may be a URL.
+func registryHost(key string) string {
+	if u, err := url.Parse(key); err == nil && u.Host != "" {
+		key = u.Host
+	}
+	if key == "index.docker.io" || key == "docker.io" {
+		return "registry-1.docker.io"
+	}
+	return key
+}
+
+func newAuthorizer(client *http.Client, ref Reference, dockerConfig string) (*authorizer, error) {
+	// Redirections of blobs are returned rather than followed
+	noRedirect := *client
+	noRedirect.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	a := &authorizer{client: &noRedirect, ref: ref}
+	if dockerConfig == "" {
+		return a, nil
+	}
+
+	var config dockerConfigJSON
+	if err := json.Unmarshal([]byte(dockerConfig), &config); err != nil {
+		return nil, fmt.Errorf("invalid pull secret: %w", err)
+	}
+	for key, entry := range config.Auths {
+		if registryHost(key) != ref.Registry {
+			continue
+		}
+		a.username, a.password = entry.Username, entry.Password
+		if entry.Auth != "" {
+			decoded, err := base64.StdEncoding.DecodeString(entry.Auth)
+			if err != nil {
+				return nil, fmt.Errorf("invalid pull secret for %s: %w", key, err)
+			}
+			parts := strings.SplitN(string(decoded), ":", 2)
+			if len(parts) != 2 {
+				return nil, fmt.Errorf("invalid pull secret for %s", key)
+			}
+			a.username, a.password = parts[0], parts[1]
+		}
+		break
+	}
+	return a, nil
+}
+
+// do sends a request, authenticating when the registry asks for it.
+func (a *authorizer) do(ctx context.Context, req *http.Request) (*http.Response, error) {
+	if a.header != "" {
+		req.Header.Set("Authorization", a.header)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil || resp.StatusCode != http.StatusUnauthorized || a.header != "" {
+		return resp, err
+	}
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+
+	if err := a.authenticate(ctx, challenge); err != nil {
+		return nil, err
+	}
+	retry := req.Clone(ctx)
+	retry.Header.Set("Authorization", a.header)
+	return a.client.Do(retry)
+}
+
+// parseChallenge returns the scheme and the parameters of a
+// WWW-Authenticate header.
+func parseChallenge(challenge string) (scheme string, params map[string]string) {
+	params = map[string]string{}
+	parts := strings.SplitN(strings.TrimSpace(challenge), " ", 2)
+	scheme = strings.ToLower(parts[0])
+	if len(parts) < 2 {
+		return scheme, params
+	}
+	rest := parts[1]
+	for rest != "" {
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = strings.TrimSpace(rest[eq+1:])
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else if comma := strings.Index(rest, ","); comma >= 0 {
+			value, rest = rest[:comma], rest[comma:]
+		} else {
+			value, rest = rest, ""
+		}
+		params[key] = value
+		rest = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+		rest = strings.TrimSpace(rest)
+	}
+	return scheme, params
+}
+
+func (a *authorizer) authenticate(ctx context.Context, challenge string) error {
+	a.used = true
+	scheme, params := parseChallenge(challenge)
+	switch scheme {
+	case "basic":
+		if a.username == "" {
+			return fmt.Errorf("the registry %s requires credentials", a.ref.Registry)
+		}
+		a.header = "Basic " + base64.StdEncoding.EncodeToString([]byte(a.username+":"+a.password))
+		return nil
+	case "bearer":
+	default:
+		return fmt.Errorf("unsupported authentication %q requested by the registry %s", scheme, a.ref.Registry)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || realm.Scheme == "" {
+		return fmt.Errorf("invalid token realm %q of the registry %s", params["realm"], a.ref.Registry)
+	}
+	query := realm.Query()
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	scope := params["scope"]
+	if scope == "" {
+		scope = fmt.Sprintf("repository:%s:pull", a.ref.Repository)
+	}
+	query.Set("scope", scope)
+	realm.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+	if err != nil {
+		return err
+	}
+	if a.username != "" {
+		req.SetBasicAuth(a.username, a.password)
+	}
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to get a token for the registry %s: %w", a.ref.Registry, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get a token for the registry %s: %s", a.ref.Registry, resp.Status)
+	}
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&token); err != nil {
+		return fmt.Errorf("invalid token from the registry %s: %w", a.ref.Registry, err)
+	}
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+	if token.Token == "" {
+		return fmt.Errorf("no token from the registry %s", a.ref.Registry)
+	}
+	a.header = "Bearer " + token.Token
+	return nil
+}
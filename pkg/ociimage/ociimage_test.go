@@ -0,0 +1,362 @@
+package ociimage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	layerDigest = "sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+	otherDigest = "sha256:60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752"
+)
+
+func TestParseReference(t *testing.T) {
+	testCases := []struct {
+		Scenario      string
+		URL           string
+		Expected      Reference
+		ExpectedError string
+	}{
+		{
+			Scenario: "tag",
+			URL:      "oci://quay.io/example/os:1.0",
+			Expected: Reference{Registry: "quay.io", Repository: "example/os", Tag: "1.0"},
+		},
+		{
+			Scenario: "default tag and port",
+			URL:      "oci://registry.example.com:5000/os",
+			Expected: Reference{Registry: "registry.example.com:5000", Repository: "os", Tag: "latest"},
+		},
+		{
+			Scenario: "digest",
+			URL:      "oci://quay.io/example/os@" + layerDigest,
+			Expected: Reference{Registry: "quay.io", Repository: "example/os", Digest: layerDigest},
+		},
+		{
+			Scenario: "tag and digest",
+			URL:      "oci://quay.io/example/os:1.0@" + layerDigest,
+			Expected: Reference{Registry: "quay.io", Repository: "example/os", Tag: "1.0", Digest: layerDigest},
+		},
+		{
+			Scenario: "docker hub",
+			URL:      "oci://docker.io/os:1.0",
+			Expected: Reference{Registry: "registry-1.docker.io", Repository: "library/os", Tag: "1.0"},
+		},
+		{
+			Scenario:      "no registry",
+			URL:           "oci://os:1.0",
+			ExpectedError: "no registry",
+		},
+		{
+			Scenario:      "empty tag",
+			URL:           "oci://quay.io/example/os:",
+			ExpectedError: "empty tag",
+		},
+		{
+			Scenario:      "invalid digest",
+			URL:           "oci://quay.io/example/os@sha256:1234",
+			ExpectedError: "invalid digest",
+		},
+		{
+			Scenario:      "http URL",
+			URL:           "http://example.com/os.qcow2",
+			ExpectedError: "not an OCI reference",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			ref, err := ParseReference(tc.URL)
+			if tc.ExpectedError != "" {
+				assert.Regexp(t, tc.ExpectedError, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Expected, ref)
+		})
+	}
+}
+
+func TestPinReference(t *testing.T) {
+	pinned := PinReference("oci://quay.io/example/os:1.0", layerDigest)
+	assert.Equal(t, "oci://quay.io/example/os:1.0@"+layerDigest, pinned)
+	assert.Equal(t, pinned, PinReference(pinned, layerDigest))
+
+	assert.True(t, IsPinnedFrom(pinned, "oci://quay.io/example/os:1.0"))
+	assert.True(t, IsPinnedFrom(pinned, pinned))
+	assert.False(t, IsPinnedFrom(pinned, "oci://quay.io/example/os:1.1"))
+	assert.False(t, IsPinnedFrom(pinned, "oci://quay.io/example/os@"+layerDigest))
+	assert.False(t, IsPinnedFrom("http://example.com/os.qcow2", "oci://quay.io/example/os:1.0"))
+}
+
+func layer(digest, title string) descriptor {
+	return descriptor{
+		MediaType:   "application/octet-stream",
+		Digest:      digest,
+		Annotations: map[string]string{titleAnnotation: title},
+	}
+}
+
+func TestSelectLayer(t *testing.T) {
+	testCases := []struct {
+		Scenario       string
+		Layers         []descriptor
+		DiskFormat     string
+		ExpectedDigest string
+		ExpectedFormat string
+		ExpectedError  string
+	}{
+		{
+			Scenario:       "single layer",
+			Layers:         []descriptor{layer(layerDigest, "os.qcow2")},
+			ExpectedDigest: layerDigest,
+			ExpectedFormat: "qcow2",
+		},
+		{
+			Scenario:       "by format",
+			Layers:         []descriptor{layer(otherDigest, "os.qcow2"), layer(layerDigest, "os.raw")},
+			DiskFormat:     "raw",
+			ExpectedDigest: layerDigest,
+			ExpectedFormat: "raw",
+		},
+		{
+			Scenario:       "compressed layers skipped",
+			Layers:         []descriptor{layer(otherDigest, "os.qcow2.gz"), layer(layerDigest, "os.qcow2")},
+			ExpectedDigest: layerDigest,
+			ExpectedFormat: "qcow2",
+		},
+		{
+			Scenario:       "live ISO",
+			Layers:         []descriptor{layer(otherDigest, "os.qcow2"), layer(layerDigest, "os.iso")},
+			DiskFormat:     "live-iso",
+			ExpectedDigest: layerDigest,
+			ExpectedFormat: "live-iso",
+		},
+		{
+			Scenario:       "ISO written to disk",
+			Layers:         []descriptor{layer(layerDigest, "os.iso")},
+			ExpectedDigest: layerDigest,
+			ExpectedFormat: "raw",
+		},
+		{
+			Scenario:      "ambiguous",
+			Layers:        []descriptor{layer(otherDigest, "os.qcow2"), layer(layerDigest, "os.raw")},
+			ExpectedError: "the format must be set",
+		},
+		{
+			Scenario:      "missing format",
+			Layers:        []descriptor{layer(layerDigest, "os.raw")},
+			DiskFormat:    "qcow2",
+			ExpectedError: "no qcow2 disk image",
+		},
+		{
+			Scenario:      "no title",
+			Layers:        []descriptor{{Digest: layerDigest}},
+			ExpectedError: "no disk image",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			l, format, err := selectLayer(tc.Layers, tc.DiskFormat)
+			if tc.ExpectedError != "" {
+				assert.Regexp(t, tc.ExpectedError, err)
+				return
+			}
+			if assert.NoError(t, err) {
+				assert.Equal(t, tc.ExpectedDigest, l.Digest)
+				assert.Equal(t, tc.ExpectedFormat, format)
+			}
+		})
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:os:pull"`)
+	assert.Equal(t, "bearer", scheme)
+	assert.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:os:pull",
+	}, params)
+}
+
+// testRegistry is a registry serving a multi-architecture image, behind
+// bearer token authentication.
+type testRegistry struct {
+	server   *httptest.Server
+	storage  *httptest.Server
+	username string
+	password string
+	// whether blobs are redirected to the storage
+	redirect bool
+	// how long the signed URLs of the storage are valid
+	urlLifetime time.Duration
+	// the manifests and indexes, by tag or digest
+	manifests map[string][]byte
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{
+		username:    "user",
+		password:    "secret",
+		redirect:    true,
+		urlLifetime: time.Hour,
+		manifests:   map[string][]byte{},
+	}
+	r.storage = httptest.NewServer(http.HandlerFunc(r.serveStorage))
+	t.Cleanup(r.storage.Close)
+	r.server = httptest.NewTLSServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.server.Close)
+
+	addManifest := func(layers ...descriptor) descriptor {
+		data, _ := json.Marshal(manifest{MediaType: mediaTypeOCIManifest, Layers: layers})
+		r.manifests[digestOf(data)] = data
+		return descriptor{MediaType: mediaTypeOCIManifest, Digest: digestOf(data)}
+	}
+	amd64 := addManifest(layer(layerDigest, "os.qcow2"))
+	amd64.Platform = &struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	}{"amd64", "linux"}
+	arm64 := addManifest(layer(otherDigest, "os.qcow2"))
+	arm64.Platform = &struct {
+		Architecture string `json:"architecture"`
+		OS           string `json:"os"`
+	}{"arm64", "linux"}
+	index, _ := json.Marshal(manifest{MediaType: mediaTypeOCIIndex, Manifests: []descriptor{amd64, arm64}})
+	r.manifests["1.0"] = index
+	return r
+}
+
+func (r *testRegistry) host() string {
+	return strings.TrimPrefix(r.server.URL, "https://")
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		if user, password, ok := req.BasicAuth(); !ok || user != r.username || password != r.password {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"token": "t0ken"}`)
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer t0ken" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="test"`, r.server.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	const prefix = "/v2/example/os/"
+	switch {
+	case strings.HasPrefix(req.URL.Path, prefix+"manifests/"):
+		data, ok := r.manifests[strings.TrimPrefix(req.URL.Path, prefix+"manifests/")]
+		if !ok {
+			http.NotFound(w, req)
+			return
+		}
+		w.Write(data)
+	case strings.HasPrefix(req.URL.Path, prefix+"blobs/"):
+		if r.redirect {
+			http.Redirect(w, req, fmt.Sprintf("%s/signed/%s?Expires=%d", r.storage.URL,
+				strings.TrimPrefix(req.URL.Path, prefix+"blobs/"), time.Now().Add(r.urlLifetime).Unix()),
+				http.StatusTemporaryRedirect)
+			return
+		}
+		fmt.Fprint(w, "image")
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// serveStorage serves the blobs at signed URLs, until they expire.
+func (r *testRegistry) serveStorage(w http.ResponseWriter, req *http.Request) {
+	expires, err := strconv.ParseInt(req.URL.Query().Get("Expires"), 10, 64)
+	if err != nil || !strings.HasPrefix(req.URL.Path, "/signed/") {
+		http.NotFound(w, req)
+		return
+	}
+	if time.Now().Unix() > expires {
+		http.Error(w, "Request has expired", http.StatusForbidden)
+		return
+	}
+	fmt.Fprint(w, "image")
+}
+
+func (r *testRegistry) dockerConfig() string {
+	auth := base64.StdEncoding.EncodeToString([]byte(r.username + ":" + r.password))
+	return fmt.Sprintf(`{"auths": {"https://%s": {"auth": "%s"}}}`, r.host(), auth)
+}
+
+func TestResolve(t *testing.T) {
+	registry := newTestRegistry(t)
+	resolver := &Resolver{client: registry.server.Client()}
+	ref := fmt.Sprintf("oci://%s/example/os:1.0", registry.host())
+
+	image, err := resolver.Resolve(context.Background(), ref, "aarch64", "", registry.dockerConfig())
+	if assert.NoError(t, err) {
+		assert.Regexp(t, "^"+registry.storage.URL+"/signed/"+otherDigest+`\?Expires=\d+$`, image.URL)
+		assert.True(t, image.Signed)
+		assert.Equal(t, strings.TrimPrefix(otherDigest, "sha256:"), image.Checksum)
+		assert.Equal(t, "sha256", image.ChecksumType)
+		assert.Equal(t, "qcow2", image.DiskFormat)
+		assert.Contains(t, registry.manifests, image.ManifestDigest)
+	}
+
+	_, err = resolver.Resolve(context.Background(), ref, "ppc64le", "", registry.dockerConfig())
+	assert.Regexp(t, "no image for the ppc64le architecture", err)
+
+	_, err = resolver.Resolve(context.Background(), ref, "x86_64", "", "")
+	assert.Regexp(t, "failed to get a token .*401", err)
+
+	registry.redirect = false
+	_, err = resolver.Resolve(context.Background(), ref, "x86_64", "", registry.dockerConfig())
+	assert.Regexp(t, "requires credentials to download blobs", err)
+}
+
+func TestResolveExpiredURL(t *testing.T) {
+	registry := newTestRegistry(t)
+	resolver := &Resolver{client: registry.server.Client()}
+	ref := fmt.Sprintf("oci://%s/example/os:1.0", registry.host())
+	download := func(url string) int {
+		resp, err := http.Get(url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	registry.urlLifetime = -time.Minute
+	expired, err := resolver.Resolve(context.Background(), ref, "x86_64", "", registry.dockerConfig())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, http.StatusForbidden, download(expired.URL))
+
+	// Each resolution returns a new signed URL of the same blob
+	registry.urlLifetime = time.Hour
+	image, err := resolver.Resolve(context.Background(), ref, "x86_64", "", registry.dockerConfig())
+	if assert.NoError(t, err) {
+		assert.True(t, image.Signed)
+		assert.NotEqual(t, expired.URL, image.URL)
+		assert.Equal(t, expired.Checksum, image.Checksum)
+		assert.Equal(t, http.StatusOK, download(image.URL))
+	}
+}
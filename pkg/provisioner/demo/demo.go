@@ -79,8 +79,12 @@ func (d Demo) NewProvisioner(hostData provisioner.HostData, publisher provisione
 	return p, nil
 }
 
-func (p *demoProvisioner) HasCapacity() (result bool, err error) {
-	return true, nil
+func (p *demoProvisioner) HasCapacity(state metal3v1alpha1.ProvisioningState) (result bool, reason string, err error) {
+	return true, "", nil
+}
+
+func (p *demoProvisioner) Backend() string {
+	return ""
 }
 
 // ValidateManagementAccess tests the connection information for the
@@ -192,6 +196,11 @@ func (p *demoProvisioner) UpdateHardwareState() (hwState provisioner.HardwareSta
 	return
 }
 
+// GetHardwareHealth returns nil, as the demo hosts have no sensors.
+func (p *demoProvisioner) GetHardwareHealth() (health *provisioner.HardwareHealth, err error) {
+	return
+}
+
 // Prepare remove existing configuration and set new configuration
 func (p *demoProvisioner) Prepare(data provisioner.PrepareData, unprepared bool) (result provisioner.Result, started bool, err error) {
 	hostName := p.objectMeta.Name
@@ -216,6 +225,24 @@ func (p *demoProvisioner) Prepare(data provisioner.PrepareData, unprepared bool)
 	return
 }
 
+// Service applies changes to the firmware of a provisioned host
+func (p *demoProvisioner) Service(data provisioner.ServicingData, restart bool) (result provisioner.Result, started bool, err error) {
+	p.log.Info("servicing host")
+	started = restart
+	return
+}
+
+// GetFirmwareSettings returns the current BIOS settings of the host
+func (p *demoProvisioner) GetFirmwareSettings(includeSchema bool) (settings map[string]string, schema map[string]metal3v1alpha1.SettingSchema, err error) {
+	return nil, nil, nil
+}
+
+// GetFirmwareComponents returns the firmware versions of the
+// components of the host
+func (p *demoProvisioner) GetFirmwareComponents() (components []metal3v1alpha1.FirmwareComponentStatus, err error) {
+	return nil, nil
+}
+
 // Adopt notifies the provisioner that the state machine believes the host
 // to be currently provisioned, and that it should be managed as such.
 func (p *demoProvisioner) Adopt(data provisioner.AdoptData, force bool) (result provisioner.Result, err error) {
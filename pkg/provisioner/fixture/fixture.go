@@ -42,6 +42,10 @@ func (cd *fixtureHostConfigData) MetaData() (string, error) {
 	return cd.metaData, nil
 }
 
+func (cd *fixtureHostConfigData) ImagePullSecret() (string, error) {
+	return "", nil
+}
+
 // fixtureProvisioner implements the provisioning.fixtureProvisioner interface
 // and uses Ironic to manage the host.
 type fixtureProvisioner struct {
@@ -72,6 +76,9 @@ type Fixture struct {
 
 	validateError string
 
+	// state to manage firmware settings
+	firmwareSettings map[string]string
+
 	customDeploy *metal3v1alpha1.CustomDeploy
 }
 
@@ -91,8 +98,12 @@ func (f *Fixture) SetValidateError(message string) {
 	f.validateError = message
 }
 
-func (p *fixtureProvisioner) HasCapacity() (result bool, err error) {
-	return true, nil
+func (p *fixtureProvisioner) HasCapacity(state metal3v1alpha1.ProvisioningState) (result bool, reason string, err error) {
+	return true, "", nil
+}
+
+func (p *fixtureProvisioner) Backend() string {
+	return ""
 }
 
 // ValidateManagementAccess tests the connection information for the
@@ -190,13 +201,43 @@ func (p *fixtureProvisioner) UpdateHardwareState() (hwState provisioner.Hardware
 	return
 }
 
+// GetHardwareHealth returns nil, as the fixture hosts have no sensors.
+func (p *fixtureProvisioner) GetHardwareHealth() (health *provisioner.HardwareHealth, err error) {
+	return
+}
+
 // Prepare remove existing configuration and set new configuration
 func (p *fixtureProvisioner) Prepare(data provisioner.PrepareData, unprepared bool) (result provisioner.Result, started bool, err error) {
 	p.log.Info("preparing host")
 	started = unprepared
+	if started && data.FirmwareConfig != nil {
+		p.state.firmwareSettings = data.FirmwareConfig.Settings
+	}
 	return
 }
 
+// Service applies changes to the firmware of a provisioned host
+func (p *fixtureProvisioner) Service(data provisioner.ServicingData, restart bool) (result provisioner.Result, started bool, err error) {
+	p.log.Info("servicing host")
+	started = restart
+	if started && data.FirmwareConfig != nil {
+		p.state.firmwareSettings = data.FirmwareConfig.Settings
+	}
+	return
+}
+
+// GetFirmwareSettings returns the BIOS settings applied by the last
+// call to Prepare or Service.
+func (p *fixtureProvisioner) GetFirmwareSettings(includeSchema bool) (settings map[string]string, schema map[string]metal3v1alpha1.SettingSchema, err error) {
+	return p.state.firmwareSettings, nil, nil
+}
+
+// GetFirmwareComponents returns the firmware versions of the
+// components of the host
+func (p *fixtureProvisioner) GetFirmwareComponents() (components []metal3v1alpha1.FirmwareComponentStatus, err error) {
+	return nil, nil
+}
+
 // Adopt notifies the provisioner that the state machine believes the host
 // to be currently provisioned, and that it should be managed as such.
 func (p *fixtureProvisioner) Adopt(data provisioner.AdoptData, force bool) (result provisioner.Result, err error) {
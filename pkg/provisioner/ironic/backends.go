@@ -0,0 +1,196 @@
+package ironic
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/gophercloud/gophercloud"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"sigs.k8s.io/yaml"
+
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
+	"github.com/metal3-io/baremetal-operator/pkg/utils"
+)
+
+// backendSettings is the configuration of a named Ironic backend as
+// read from the backends file.
+type backendSettings struct {
+	// The name recorded in the status of the hosts using the backend
+	Name string `json:"name"`
+
+	IronicEndpoint    string `json:"ironicEndpoint"`
+	InspectorEndpoint string `json:"inspectorEndpoint"`
+
+	// A directory with "ironic" and "ironic-inspector" subdirectories
+	// holding the username and password files, as in
+	// METAL3_AUTH_ROOT_DIR. No authentication is used if not set.
+	AuthDir string `json:"authDir,omitempty"`
+
+	CACertFile           string `json:"caCertFile,omitempty"`
+	ClientCertFile       string `json:"clientCertFile,omitempty"`
+	ClientPrivateKeyFile string `json:"clientPrivateKeyFile,omitempty"`
+	Insecure             bool   `json:"insecure,omitempty"`
+	SkipClientSANVerify  bool   `json:"skipClientSANVerify,omitempty"`
+
+	// Overrides of the global settings
+	ProvisioningLimit *int `json:"provisioningLimit,omitempty"`
+
+	// A directory shared with the Ironic of the backend for the CA
+	// certificates of BMCs. BMC_CACERTS_DIR is not inherited.
+	BMCCACertsDir string `json:"bmcCACertsDir,omitempty"`
+
+	// The hosts routed to the backend, by namespace and labels. Both
+	// must match when set.
+	Namespaces   []string              `json:"namespaces,omitempty"`
+	HostSelector *metav1.LabelSelector `json:"hostSelector,omitempty"`
+}
+
+// ironicBackend is an Ironic service the provisioner can register
+// hosts with.
+type ironicBackend struct {
+	// the name of the backend, empty for the default backend
+	name   string
+	config ironicConfig
+
+	clients *ironicClients
+
+	namespaces []string
+	selector   labels.Selector
+}
+
+// matches returns true if a new host should be routed to the backend.
+func (b *ironicBackend) matches(hostData provisioner.HostData) bool {
+	if len(b.namespaces) > 0 && !utils.StringInList(b.namespaces, hostData.ObjectMeta.Namespace) {
+		return false
+	}
+	return b.selector == nil || b.selector.Matches(labels.Set(hostData.ObjectMeta.Labels))
+}
+
+// loadBackendSettings reads the list of named backends from a YAML file.
+func loadBackendSettings(path string) ([]backendSettings, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Ironic backends: %w", err)
+	}
+
+	var settings []backendSettings
+	if err := yaml.UnmarshalStrict(data, &settings); err != nil {
+		return nil, fmt.Errorf("failed to parse Ironic backends: %w", err)
+	}
+
+	names := map[string]bool{}
+	for _, s := range settings {
+		if s.Name == "" {
+			return nil, fmt.Errorf("Ironic backend without a name")
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("duplicate Ironic backend %s", s.Name)
+		}
+		names[s.Name] = true
+		if s.ProvisioningLimit != nil && *s.ProvisioningLimit < 0 {
+			return nil, fmt.Errorf("invalid provisioningLimit %d for Ironic backend %s", *s.ProvisioningLimit, s.Name)
+		}
+	}
+	return settings, nil
+}
+
+// newBackend creates the clients of a named backend. The global
+// configuration applies unless overridden by the backend settings.
+// Failures to create the clients are reported by the readiness check
+// rather than returned, as they may be fixed by updating the files.
+func newBackend(settings backendSettings, config ironicConfig) (*ironicBackend, error) {
+	backend := &ironicBackend{
+		name:       settings.Name,
+		config:     config,
+		namespaces: settings.Namespaces,
+	}
+
+	if settings.ProvisioningLimit != nil {
+		backend.config.capacity.maxBusyHosts = *settings.ProvisioningLimit
+		if backend.config.capacity.priorityReservedHosts >= *settings.ProvisioningLimit {
+			return nil, fmt.Errorf("provisioningLimit of Ironic backend %s must be higher than PROVISIONING_PRIORITY_RESERVED",
+				settings.Name)
+		}
+	}
+	// BMC_CACERTS_DIR is only shared with the local Ironic, so named
+	// backends need a directory of their own to accept CA certificates
+	// of BMCs.
+	backend.config.bmcCACertsDir = settings.BMCCACertsDir
+
+	if settings.HostSelector != nil {
+		selector, err := metav1.LabelSelectorAsSelector(settings.HostSelector)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hostSelector for Ironic backend %s: %w", settings.Name, err)
+		}
+		backend.selector = selector
+	}
+
+	tlsConf := clients.TLSConfig{
+		TrustedCAFile:         settings.CACertFile,
+		ClientCertificateFile: settings.ClientCertFile,
+		ClientPrivateKeyFile:  settings.ClientPrivateKeyFile,
+		InsecureSkipVerify:    settings.Insecure,
+		SkipClientSANVerify:   settings.SkipClientSANVerify,
+	}
+	watched := tlsFiles(tlsConf)
+	if settings.AuthDir != "" {
+		watched = append(watched, authDirs(settings.AuthDir)...)
+	}
+
+	build := func() (clientIronic, clientInspector *gophercloud.ServiceClient, err error) {
+		ironicAuth := clients.AuthConfig{Type: clients.NoAuth}
+		inspectorAuth := clients.AuthConfig{Type: clients.NoAuth}
+		if settings.AuthDir != "" {
+			ironicAuth, inspectorAuth, err = clients.LoadAuthFromDir(settings.AuthDir)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to load auth for Ironic backend %s: %w", settings.Name, err)
+			}
+		}
+
+		// Endpoints are optional when they can be looked up in the
+		// Keystone catalog
+		if (settings.IronicEndpoint == "" && !ironicAuth.IsKeystone()) ||
+			(settings.InspectorEndpoint == "" && !inspectorAuth.IsKeystone()) {
+			return nil, nil, fmt.Errorf("Ironic backend %s requires ironicEndpoint and inspectorEndpoint", settings.Name)
+		}
+
+		return newClients(settings.IronicEndpoint, ironicAuth, settings.InspectorEndpoint, inspectorAuth, tlsConf)
+	}
+	backend.clients = newIronicClients(build, watched)
+	return backend, nil
+}
+
+// backendForHost returns the backend managing a host. Hosts stay with
+// the backend recorded in their status, which the controller sets
+// before they are first registered. New hosts are routed to the first
+// named backend they match, or to the default backend. Hosts
+// registered before named backends were configured have no backend
+// recorded and remain with the default backend.
+func (f ironicProvisionerFactory) backendForHost(hostData provisioner.HostData) (*ironicBackend, error) {
+	if hostData.ProvisionerBackend != "" {
+		for _, backend := range f.backends {
+			if backend.name == hostData.ProvisionerBackend {
+				return backend, nil
+			}
+		}
+		return nil, fmt.Errorf("the Ironic backend %s of the host is not configured", hostData.ProvisionerBackend)
+	}
+
+	if hostData.ProvisionerID == "" {
+		for _, backend := range f.backends {
+			if backend.matches(hostData) {
+				return backend, nil
+			}
+		}
+	}
+
+	if f.noDefaultBackend {
+		return nil, fmt.Errorf("no Ironic backend matches the host")
+	}
+	return &ironicBackend{
+		config:  f.config,
+		clients: f.clients,
+	}, nil
+}
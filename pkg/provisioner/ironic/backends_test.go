@@ -0,0 +1,321 @@
+package ironic
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/testserver"
+)
+
+func TestLoadBackendSettings(t *testing.T) {
+	cases := []struct {
+		name          string
+		content       string
+		expectedNames []string
+		expectedError string
+	}{
+		{
+			name: "valid",
+			content: `
+- name: edge-1
+  ironicEndpoint: https://edge-1.test:6385/v1/
+  inspectorEndpoint: https://edge-1.test:5050/v1/
+  namespaces: [site-1]
+- name: edge-2
+  ironicEndpoint: https://edge-2.test:6385/v1/
+  inspectorEndpoint: https://edge-2.test:5050/v1/
+  provisioningLimit: 5
+  hostSelector:
+    matchLabels:
+      example.com/site: edge-2
+`,
+			expectedNames: []string{"edge-1", "edge-2"},
+		},
+		{
+			name: "missing name",
+			content: `
+- ironicEndpoint: https://edge-1.test:6385/v1/
+  inspectorEndpoint: https://edge-1.test:5050/v1/
+`,
+			expectedError: "Ironic backend without a name",
+		},
+		{
+			name: "duplicate name",
+			content: `
+- name: edge-1
+  ironicEndpoint: https://edge-1.test:6385/v1/
+  inspectorEndpoint: https://edge-1.test:5050/v1/
+- name: edge-1
+  ironicEndpoint: https://edge-2.test:6385/v1/
+  inspectorEndpoint: https://edge-2.test:5050/v1/
+`,
+			expectedError: "duplicate Ironic backend edge-1",
+		},
+		{
+			name: "negative limit",
+			content: `
+- name: edge-1
+  ironicEndpoint: https://edge-1.test:6385/v1/
+  inspectorEndpoint: https://edge-1.test:5050/v1/
+  provisioningLimit: -1
+`,
+			expectedError: "invalid provisioningLimit -1",
+		},
+		{
+			name: "unknown field",
+			content: `
+- name: edge-1
+  ironicEndpoint: https://edge-1.test:6385/v1/
+  inspectorEndpoint: https://edge-1.test:5050/v1/
+  namespace: site-1
+`,
+			expectedError: "failed to parse Ironic backends",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "backends.yaml")
+			if err := ioutil.WriteFile(path, []byte(tc.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			settings, err := loadBackendSettings(path)
+			if tc.expectedError != "" {
+				assert.Error(t, err)
+				assert.Contains(t, err.Error(), tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			var names []string
+			for _, s := range settings {
+				names = append(names, s.Name)
+			}
+			assert.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func TestNewBackend(t *testing.T) {
+	limit := 5
+	backend, err := newBackend(backendSettings{
+		Name:              "edge-1",
+		IronicEndpoint:    "https://edge-1.test:6385/v1/",
+		InspectorEndpoint: "https://edge-1.test:5050/v1/",
+		ProvisioningLimit: &limit,
+		BMCCACertsDir:     "/certs/edge-1",
+		HostSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"example.com/site": "edge-1"},
+		},
+	}, newTestProvisionerFactory().config)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 5, backend.config.capacity.maxBusyHosts)
+	assert.Equal(t, "/certs/edge-1", backend.config.bmcCACertsDir)
+	assert.Equal(t, "http://deploy.test/ipa.iso", backend.config.deployISOURL)
+	clientIronic, _, err := backend.clients.get()
+	assert.NoError(t, err)
+	assert.Equal(t, "https://edge-1.test:6385/v1/", clientIronic.Endpoint)
+
+	config := newTestProvisionerFactory().config
+	config.bmcCACertsDir = "/certs/local"
+	backend, err = newBackend(backendSettings{
+		Name:              "edge-2",
+		IronicEndpoint:    "https://edge-2.test:6385/v1/",
+		InspectorEndpoint: "https://edge-2.test:5050/v1/",
+	}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, "", backend.config.bmcCACertsDir, "BMC_CACERTS_DIR inherited by a remote backend")
+
+	config = newTestProvisionerFactory().config
+	config.capacity.priorityReservedHosts = 5
+	_, err = newBackend(backendSettings{
+		Name:              "edge-1",
+		IronicEndpoint:    "https://edge-1.test:6385/v1/",
+		InspectorEndpoint: "https://edge-1.test:5050/v1/",
+		ProvisioningLimit: &limit,
+	}, config)
+	assert.Error(t, err)
+
+	backend, err = newBackend(backendSettings{
+		Name:           "edge-1",
+		IronicEndpoint: "https://edge-1.test:6385/v1/",
+	}, newTestProvisionerFactory().config)
+	assert.NoError(t, err)
+	_, _, err = backend.clients.get()
+	assert.EqualError(t, err, "Ironic backend edge-1 requires ironicEndpoint and inspectorEndpoint")
+}
+
+func TestBackendForHost(t *testing.T) {
+	selector := func(labels map[string]string) *metav1.LabelSelector {
+		return &metav1.LabelSelector{MatchLabels: labels}
+	}
+	newTestBackend := func(settings backendSettings) *ironicBackend {
+		settings.IronicEndpoint = "https://" + settings.Name + ".test:6385/v1/"
+		settings.InspectorEndpoint = "https://" + settings.Name + ".test:5050/v1/"
+		backend, err := newBackend(settings, newTestProvisionerFactory().config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return backend
+	}
+	backends := []*ironicBackend{
+		newTestBackend(backendSettings{Name: "site-1", Namespaces: []string{"site-1"}}),
+		newTestBackend(backendSettings{Name: "site-2", HostSelector: selector(map[string]string{"site": "2"})}),
+		newTestBackend(backendSettings{Name: "site-3", Namespaces: []string{"site-3"},
+			HostSelector: selector(map[string]string{"rack": "3"})}),
+	}
+
+	cases := []struct {
+		name            string
+		namespace       string
+		labels          map[string]string
+		provisionerID   string
+		backend         string
+		noDefault       bool
+		expectedBackend string
+		expectedError   string
+	}{
+		{
+			name:      "default",
+			namespace: "myns",
+		},
+		{
+			name:            "by namespace",
+			namespace:       "site-1",
+			expectedBackend: "site-1",
+		},
+		{
+			name:            "by label",
+			namespace:       "myns",
+			labels:          map[string]string{"site": "2"},
+			expectedBackend: "site-2",
+		},
+		{
+			name:            "by namespace and label",
+			namespace:       "site-3",
+			labels:          map[string]string{"rack": "3"},
+			expectedBackend: "site-3",
+		},
+		{
+			name:      "namespace without label",
+			namespace: "site-3",
+		},
+		{
+			name:            "recorded backend",
+			namespace:       "site-1",
+			provisionerID:   "node-uuid",
+			backend:         "site-2",
+			expectedBackend: "site-2",
+		},
+		{
+			name:          "registered before backends",
+			namespace:     "site-1",
+			provisionerID: "node-uuid",
+		},
+		{
+			name:          "unknown recorded backend",
+			namespace:     "myns",
+			backend:       "site-4",
+			expectedError: "the Ironic backend site-4 of the host is not configured",
+		},
+		{
+			name:          "no default",
+			namespace:     "myns",
+			noDefault:     true,
+			expectedError: "no Ironic backend matches the host",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			factory := newTestProvisionerFactory()
+			factory.backends = backends
+			factory.noDefaultBackend = tc.noDefault
+
+			backend, err := factory.backendForHost(provisioner.HostData{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      "myhost",
+					Namespace: tc.namespace,
+					Labels:    tc.labels,
+				},
+				ProvisionerID:      tc.provisionerID,
+				ProvisionerBackend: tc.backend,
+			})
+			if tc.expectedError != "" {
+				assert.Error(t, err)
+				assert.Equal(t, tc.expectedError, err.Error())
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedBackend, backend.name)
+		})
+	}
+}
+
+func TestHasCapacityPerBackend(t *testing.T) {
+	busyNode := func(name string) nodes.Node {
+		return nodes.Node{Name: "myns" + nameSeparator + name, ProvisionState: string(nodes.Deploying)}
+	}
+
+	defaultIronic := testserver.NewIronic(t).Nodes([]nodes.Node{busyNode("node-0"), busyNode("node-1")}).Start()
+	defer defaultIronic.Stop()
+	edgeIronic := testserver.NewIronic(t).Nodes([]nodes.Node{busyNode("node-2")}).Start()
+	defer edgeIronic.Stop()
+
+	limit := 2
+	edge, err := newBackend(backendSettings{
+		Name:              "edge-1",
+		IronicEndpoint:    edgeIronic.Endpoint(),
+		InspectorEndpoint: "https://inspector.test/",
+		ProvisioningLimit: &limit,
+		HostSelector: &metav1.LabelSelector{
+			MatchLabels: map[string]string{"example.com/site": "edge-1"},
+		},
+	}, newTestProvisionerFactory().config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	factory := newTestProvisionerFactory()
+	factory.config.capacity.maxBusyHosts = 2
+	factory.backends = []*ironicBackend{edge}
+	clientIronic, err := clients.IronicClient(defaultIronic.Endpoint(),
+		clients.AuthConfig{Type: clients.NoAuth}, clients.TLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory.clients = &ironicClients{ironic: clientIronic, inspector: clientIronic}
+
+	host := makeHost()
+	prov, err := factory.ironicProvisioner(provisioner.BuildHostData(host, bmc.Credentials{}), nullEventPublisher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "", prov.Backend())
+	result, reason, err := prov.HasCapacity(metal3v1alpha1.StateProvisioning)
+	assert.NoError(t, err)
+	assert.False(t, result)
+	assert.Equal(t, "2 hosts are busy, the limit is 2", reason)
+
+	host.Status.Provisioning.ID = ""
+	host.Labels = map[string]string{"example.com/site": "edge-1"}
+	prov, err = factory.ironicProvisioner(provisioner.BuildHostData(host, bmc.Credentials{}), nullEventPublisher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "edge-1", prov.Backend())
+	result, reason, err = prov.HasCapacity(metal3v1alpha1.StateProvisioning)
+	assert.NoError(t, err)
+	assert.True(t, result)
+	assert.Equal(t, "", reason)
+}
@@ -0,0 +1,138 @@
+package ironic
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/pkg/errors"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+)
+
+// The version of the API that returns the settings registry
+// (allowable values, limits and so on) along with the BIOS settings.
+const biosRegistryMicroversion = "1.74"
+
+// biosSetting is a BIOS setting of a node, as returned by
+// /v1/nodes/{node}/bios. The fields other than the name and value are
+// only filled in when the registry is requested and the driver of the
+// node reports it.
+type biosSetting struct {
+	Name            string   `json:"name"`
+	Value           string   `json:"value"`
+	AttributeType   string   `json:"attribute_type,omitempty"`
+	AllowableValues []string `json:"allowable_values,omitempty"`
+	LowerBound      *int     `json:"lower_bound,omitempty"`
+	UpperBound      *int     `json:"upper_bound,omitempty"`
+	MinLength       *int     `json:"min_length,omitempty"`
+	MaxLength       *int     `json:"max_length,omitempty"`
+	ReadOnly        *bool    `json:"read_only,omitempty"`
+}
+
+type biosSettingsResult struct {
+	BIOS []biosSetting `json:"bios"`
+}
+
+// listBIOSSettings returns the BIOS settings cached by Ironic for the
+// node. When withRegistry is true, the settings registry is requested
+// too, falling back to the plain settings if Ironic is too old to
+// provide it.
+func (p *ironicProvisioner) listBIOSSettings(withRegistry bool) (settings []biosSetting, err error) {
+	url := p.client.ServiceURL("nodes", p.nodeID, "bios")
+	var result biosSettingsResult
+
+	if withRegistry {
+		client := *p.client
+		client.Microversion = biosRegistryMicroversion
+		_, err = client.Get(url+"?detail=True", &result, nil)
+		if err == nil {
+			return result.BIOS, nil
+		}
+		respErr, ok := err.(gophercloud.ErrUnexpectedResponseCode)
+		if !ok || respErr.Actual != http.StatusNotAcceptable {
+			return nil, err
+		}
+		p.log.Info("BIOS settings registry is not supported by ironic")
+	}
+
+	_, err = p.client.Get(url, &result, nil)
+	if err != nil {
+		return nil, err
+	}
+	return result.BIOS, nil
+}
+
+// schema returns the registry entry of a setting.
+func (setting *biosSetting) schema() metal3v1alpha1.SettingSchema {
+	return metal3v1alpha1.SettingSchema{
+		AttributeType:   setting.AttributeType,
+		AllowableValues: setting.AllowableValues,
+		LowerBound:      setting.LowerBound,
+		UpperBound:      setting.UpperBound,
+		MinLength:       setting.MinLength,
+		MaxLength:       setting.MaxLength,
+		ReadOnly:        setting.ReadOnly,
+	}
+}
+
+// validateBIOSSettings checks the requested settings against the BIOS
+// settings reported for the node. If the node does not report any
+// settings there is nothing to check against, and the settings are
+// left for the BMC to validate when they are applied.
+func validateBIOSSettings(current []biosSetting, requested map[string]string) error {
+	schema := make(map[string]metal3v1alpha1.SettingSchema, len(current))
+	for _, setting := range current {
+		schema[setting.Name] = setting.schema()
+	}
+
+	if err := metal3v1alpha1.ValidateSettings(requested, schema); err != nil {
+		return fmt.Errorf("invalid firmware settings: %s", err)
+	}
+	return nil
+}
+
+// checkBIOSSettings checks the requested BIOS settings against the
+// registry reported by the BMC. The result holds an error message if
+// the settings cannot be applied.
+func (p *ironicProvisioner) checkBIOSSettings(firmwareConfig *metal3v1alpha1.FirmwareConfig) (result provisioner.Result, err error) {
+	if firmwareConfig == nil || len(firmwareConfig.Settings) == 0 {
+		return operationComplete()
+	}
+
+	biosSettings, err := p.listBIOSSettings(true)
+	if err != nil {
+		return transientError(errors.Wrap(err, "failed to get BIOS settings"))
+	}
+	if err = validateBIOSSettings(biosSettings, firmwareConfig.Settings); err != nil {
+		return operationFailed(err.Error())
+	}
+	return operationComplete()
+}
+
+// GetFirmwareSettings returns the current BIOS settings of the host,
+// as last cached by Ironic, and optionally the registry of the
+// settings.
+func (p *ironicProvisioner) GetFirmwareSettings(includeSchema bool) (settings map[string]string, schema map[string]metal3v1alpha1.SettingSchema, err error) {
+	if p.nodeID == "" {
+		return nil, nil, provisioner.ErrNeedsRegistration
+	}
+
+	biosSettings, err := p.listBIOSSettings(includeSchema)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get BIOS settings")
+	}
+
+	settings = make(map[string]string, len(biosSettings))
+	if includeSchema {
+		schema = make(map[string]metal3v1alpha1.SettingSchema, len(biosSettings))
+	}
+	for _, setting := range biosSettings {
+		settings[setting.Name] = setting.Value
+		if includeSchema && setting.AttributeType != "" {
+			schema[setting.Name] = setting.schema()
+		}
+	}
+	return settings, schema, nil
+}
@@ -0,0 +1,241 @@
+package ironic
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/stretchr/testify/assert"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/testserver"
+)
+
+func intPtr(i int) *int {
+	return &i
+}
+
+func TestValidateBIOSSettings(t *testing.T) {
+	readOnly := true
+	current := []biosSetting{
+		{Name: "ProcVirtualization", Value: "Enabled", AttributeType: "Enumeration",
+			AllowableValues: []string{"Enabled", "Disabled"}},
+		{Name: "NumCores", Value: "8", AttributeType: "Integer",
+			LowerBound: intPtr(1), UpperBound: intPtr(16)},
+		{Name: "AssetTag", Value: "", AttributeType: "String", MaxLength: intPtr(8)},
+		{Name: "SecureBoot", Value: "false", AttributeType: "Boolean"},
+		{Name: "SerialNumber", Value: "1234", AttributeType: "String", ReadOnly: &readOnly},
+	}
+
+	cases := []struct {
+		name          string
+		current       []biosSetting
+		requested     map[string]string
+		expectedError string
+	}{
+		{
+			name:      "no settings requested",
+			current:   current,
+			requested: nil,
+		},
+		{
+			name:      "no registry",
+			requested: map[string]string{"Unknown": "value"},
+		},
+		{
+			name:    "valid",
+			current: current,
+			requested: map[string]string{
+				"ProcVirtualization": "Disabled",
+				"NumCores":           "16",
+				"AssetTag":           "rack1",
+				"SecureBoot":         "true",
+			},
+		},
+		{
+			name:          "unknown setting",
+			current:       current,
+			requested:     map[string]string{"Unknown": "value"},
+			expectedError: "invalid firmware settings: unknown setting Unknown",
+		},
+		{
+			name:          "value not allowed",
+			current:       current,
+			requested:     map[string]string{"ProcVirtualization": "Maybe"},
+			expectedError: "invalid firmware settings: value \"Maybe\" of setting ProcVirtualization is not one of Enabled, Disabled",
+		},
+		{
+			name:          "not an integer",
+			current:       current,
+			requested:     map[string]string{"NumCores": "many"},
+			expectedError: "invalid firmware settings: value \"many\" of setting NumCores is not an integer",
+		},
+		{
+			name:          "integer out of bounds",
+			current:       current,
+			requested:     map[string]string{"NumCores": "32"},
+			expectedError: "invalid firmware settings: value 32 of setting NumCores is higher than 16",
+		},
+		{
+			name:          "string too long",
+			current:       current,
+			requested:     map[string]string{"AssetTag": "a-very-long-tag"},
+			expectedError: "invalid firmware settings: value of setting AssetTag is longer than 8 characters",
+		},
+		{
+			name:          "not a boolean",
+			current:       current,
+			requested:     map[string]string{"SecureBoot": "perhaps"},
+			expectedError: "invalid firmware settings: value \"perhaps\" of setting SecureBoot is not a boolean",
+		},
+		{
+			name:          "read-only",
+			current:       current,
+			requested:     map[string]string{"SerialNumber": "5678"},
+			expectedError: "invalid firmware settings: setting SerialNumber is read-only",
+		},
+		{
+			name:    "multiple errors",
+			current: current,
+			requested: map[string]string{
+				"SerialNumber": "5678",
+				"NumCores":     "0",
+			},
+			expectedError: "invalid firmware settings: value 0 of setting NumCores is lower than 1; setting SerialNumber is read-only",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := validateBIOSSettings(tc.current, tc.requested)
+			if tc.expectedError == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedError)
+			}
+		})
+	}
+}
+
+func TestGetFirmwareSettings(t *testing.T) {
+	nodeUUID := "33ce8659-7400-4c68-9535-d10766f07a58"
+	ironic := testserver.NewIronic(t).WithDefaultResponses().
+		BIOSSettings(nodeUUID, []map[string]interface{}{
+			{"name": "ProcVirtualization", "value": "Enabled"},
+			{"name": "NumCores", "value": "8"},
+		})
+	ironic.Start()
+	defer ironic.Stop()
+
+	host := makeHost()
+	host.Status.Provisioning.ID = nodeUUID
+
+	publisher := func(reason, message string) {}
+	auth := clients.AuthConfig{Type: clients.NoAuth}
+	prov, err := newProvisionerWithSettings(host, bmc.Credentials{}, publisher,
+		ironic.Endpoint(), auth, "https://inspector.test/", auth,
+	)
+	if err != nil {
+		t.Fatalf("could not create provisioner: %s", err)
+	}
+
+	settings, schema, err := prov.GetFirmwareSettings(false)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"ProcVirtualization": "Enabled",
+		"NumCores":           "8",
+	}, settings)
+	assert.Nil(t, schema)
+}
+
+func TestGetFirmwareSettingsSchema(t *testing.T) {
+	nodeUUID := "33ce8659-7400-4c68-9535-d10766f07a58"
+	ironic := testserver.NewIronic(t).WithDefaultResponses().
+		BIOSSettings(nodeUUID, []map[string]interface{}{
+			{"name": "ProcVirtualization", "value": "Enabled", "attribute_type": "Enumeration",
+				"allowable_values": []string{"Enabled", "Disabled"}, "read_only": false},
+			{"name": "NumCores", "value": "8", "attribute_type": "Integer",
+				"lower_bound": 1, "upper_bound": 16},
+			{"name": "SerialNumber", "value": "1234"},
+		})
+	ironic.Start()
+	defer ironic.Stop()
+
+	host := makeHost()
+	host.Status.Provisioning.ID = nodeUUID
+
+	publisher := func(reason, message string) {}
+	auth := clients.AuthConfig{Type: clients.NoAuth}
+	prov, err := newProvisionerWithSettings(host, bmc.Credentials{}, publisher,
+		ironic.Endpoint(), auth, "https://inspector.test/", auth,
+	)
+	if err != nil {
+		t.Fatalf("could not create provisioner: %s", err)
+	}
+
+	readOnly := false
+	settings, schema, err := prov.GetFirmwareSettings(true)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"ProcVirtualization": "Enabled",
+		"NumCores":           "8",
+		"SerialNumber":       "1234",
+	}, settings)
+	assert.Equal(t, map[string]metal3v1alpha1.SettingSchema{
+		"ProcVirtualization": {
+			AttributeType:   "Enumeration",
+			AllowableValues: []string{"Enabled", "Disabled"},
+			ReadOnly:        &readOnly,
+		},
+		"NumCores": {
+			AttributeType: "Integer",
+			LowerBound:    intPtr(1),
+			UpperBound:    intPtr(16),
+		},
+	}, schema)
+}
+
+func TestPrepareInvalidBIOSSettings(t *testing.T) {
+	bmc.RegisterFactory("raid-test", func(u *url.URL, dcv bool) (bmc.AccessDetails, error) {
+		return &RAIDTestBMC{}, nil
+	}, []string{})
+
+	nodeUUID := "33ce8659-7400-4c68-9535-d10766f07a58"
+	ironic := testserver.NewIronic(t).WithDefaultResponses().
+		Node(nodes.Node{
+			ProvisionState: string(nodes.Manageable),
+			UUID:           nodeUUID,
+		}).
+		BIOSSettings(nodeUUID, []map[string]interface{}{
+			{"name": "ProcVirtualization", "value": "Enabled", "attribute_type": "Enumeration",
+				"allowable_values": []string{"Enabled", "Disabled"}},
+		})
+	ironic.Start()
+	defer ironic.Stop()
+
+	host := makeHost()
+	host.Spec.BMC.Address = "raid-test://test.bmc/"
+	host.Status.Provisioning.ID = nodeUUID
+
+	publisher := func(reason, message string) {}
+	auth := clients.AuthConfig{Type: clients.NoAuth}
+	prov, err := newProvisionerWithSettings(host, bmc.Credentials{}, publisher,
+		ironic.Endpoint(), auth, "https://inspector.test/", auth,
+	)
+	if err != nil {
+		t.Fatalf("could not create provisioner: %s", err)
+	}
+
+	result, started, err := prov.Prepare(provisioner.PrepareData{
+		FirmwareConfig: &metal3v1alpha1.FirmwareConfig{
+			Settings: map[string]string{"ProcVirtualization": "Maybe"},
+		},
+	}, true)
+
+	assert.NoError(t, err)
+	assert.False(t, started)
+	assert.Contains(t, result.ErrorMessage, "ProcVirtualization")
+}
@@ -0,0 +1,63 @@
+package ironic
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+)
+
+// bmcCACertificatePath returns the location of the CA bundle of the
+// BMC in the directory shared with Ironic.
+func (p *ironicProvisioner) bmcCACertificatePath() string {
+	return filepath.Join(p.config.bmcCACertsDir, ironicNodeName(p.objectMeta)+".crt")
+}
+
+// storeBMCCACertificate writes the CA bundle of the BMC where Ironic
+// can read it and returns its path. The file is only replaced when the
+// bundle changes, so that Ironic never sees a partially written file.
+func (p *ironicProvisioner) storeBMCCACertificate() (string, error) {
+	path := p.bmcCACertificatePath()
+	current, err := ioutil.ReadFile(path)
+	if err == nil && bytes.Equal(current, p.bmcCreds.CACertificate) {
+		return path, nil
+	}
+
+	tmp, err := ioutil.TempFile(p.config.bmcCACertsDir, ".tmp-")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to store BMC CA certificate")
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(p.bmcCreds.CACertificate)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return "", errors.Wrap(err, "failed to store BMC CA certificate")
+	}
+
+	p.log.Info("stored BMC CA certificate", "path", path)
+	return path, nil
+}
+
+// removeBMCCACertificate deletes the stored CA bundle of the BMC, if
+// any.
+func (p *ironicProvisioner) removeBMCCACertificate() error {
+	if p.config.bmcCACertsDir == "" {
+		return nil
+	}
+	err := os.Remove(p.bmcCACertificatePath())
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove BMC CA certificate")
+	}
+	return nil
+}
@@ -4,9 +4,11 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack"
 	"github.com/gophercloud/gophercloud/openstack/baremetal/httpbasic"
 	"github.com/gophercloud/gophercloud/openstack/baremetal/noauth"
 	httpbasicintrospection "github.com/gophercloud/gophercloud/openstack/baremetalintrospection/httpbasic"
@@ -29,7 +31,7 @@ type TLSConfig struct {
 	SkipClientSANVerify   bool
 }
 
-func updateHTTPClient(client *gophercloud.ServiceClient, tlsConf TLSConfig) (*gophercloud.ServiceClient, error) {
+func newHTTPClient(tlsConf TLSConfig) (c http.Client, err error) {
 	tlsInfo := transport.TLSInfo{
 		TrustedCAFile:       tlsConf.TrustedCAFile,
 		CertFile:            tlsConf.ClientCertificateFile,
@@ -41,21 +43,21 @@ func updateHTTPClient(client *gophercloud.ServiceClient, tlsConf TLSConfig) (*go
 		if os.IsNotExist(err) {
 			tlsInfo.TrustedCAFile = ""
 		} else {
-			return client, err
+			return c, err
 		}
 	}
 	if _, err := os.Stat(tlsConf.ClientCertificateFile); err != nil {
 		if os.IsNotExist(err) {
 			tlsInfo.CertFile = ""
 		} else {
-			return client, err
+			return c, err
 		}
 	}
 	if _, err := os.Stat(tlsConf.ClientPrivateKeyFile); err != nil {
 		if os.IsNotExist(err) {
 			tlsInfo.KeyFile = ""
 		} else {
-			return client, err
+			return c, err
 		}
 	}
 	if tlsInfo.CertFile != "" && tlsInfo.KeyFile != "" {
@@ -64,15 +66,100 @@ func updateHTTPClient(client *gophercloud.ServiceClient, tlsConf TLSConfig) (*go
 
 	tlsTransport, err := transport.NewTransport(tlsInfo, tlsConnectionTimeout)
 	if err != nil {
-		return client, err
+		return c, err
 	}
-	c := http.Client{
-		Transport: tlsTransport,
+	c.Transport = tlsTransport
+	return c, nil
+}
+
+func updateHTTPClient(client *gophercloud.ServiceClient, tlsConf TLSConfig) (*gophercloud.ServiceClient, error) {
+	c, err := newHTTPClient(tlsConf)
+	if err != nil {
+		return client, err
 	}
 	client.HTTPClient = c
 	return client, nil
 }
 
+// keystoneClient authenticates with Keystone and creates a client for
+// the given service type. The token is renewed automatically when it
+// expires. The endpoint is looked up in the service catalog unless one
+// is given.
+func keystoneClient(endpoint, serviceType string, auth AuthConfig, tlsConf TLSConfig) (*gophercloud.ServiceClient, error) {
+	provider, err := openstack.NewClient(auth.AuthURL)
+	if err != nil {
+		return nil, err
+	}
+	provider.HTTPClient, err = newHTTPClient(tlsConf)
+	if err != nil {
+		return nil, err
+	}
+
+	userDomain := auth.UserDomainName
+	if userDomain == "" {
+		userDomain = "Default"
+	}
+	opts := gophercloud.AuthOptions{
+		IdentityEndpoint: auth.AuthURL,
+		AllowReauth:      true,
+	}
+	switch auth.Type {
+	case KeystonePasswordAuth:
+		projectDomain := auth.ProjectDomainName
+		if projectDomain == "" {
+			projectDomain = userDomain
+		}
+		opts.Username = auth.Username
+		opts.Password = auth.Password
+		opts.DomainName = userDomain
+		opts.Scope = &gophercloud.AuthScope{
+			ProjectName: auth.ProjectName,
+			DomainName:  projectDomain,
+		}
+	case KeystoneApplicationCredentialAuth:
+		opts.ApplicationCredentialID = auth.ApplicationCredentialID
+		opts.ApplicationCredentialName = auth.ApplicationCredentialName
+		opts.ApplicationCredentialSecret = auth.ApplicationCredentialSecret
+		if auth.ApplicationCredentialID == "" {
+			opts.Username = auth.Username
+			opts.DomainName = userDomain
+		}
+	}
+
+	if err = openstack.Authenticate(provider, opts); err != nil {
+		return nil, fmt.Errorf("failed to authenticate with Keystone: %w", err)
+	}
+
+	if endpoint != "" {
+		// The endpoint is set explicitly, the catalog may not list it
+		return &gophercloud.ServiceClient{
+			ProviderClient: provider,
+			Endpoint:       gophercloud.NormalizeURL(endpoint),
+			Type:           serviceType,
+		}, nil
+	}
+
+	eo := gophercloud.EndpointOpts{
+		Region:       auth.RegionName,
+		Availability: gophercloud.AvailabilityPublic,
+	}
+	var client *gophercloud.ServiceClient
+	if serviceType == "baremetal" {
+		client, err = openstack.NewBareMetalV1(provider, eo)
+	} else {
+		client, err = openstack.NewBareMetalIntrospectionV1(provider, eo)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to find the %s endpoint in the Keystone catalog: %w", serviceType, err)
+	}
+	// The catalog usually lists the unversioned root of the API
+	client.Endpoint = gophercloud.NormalizeURL(client.Endpoint)
+	if !strings.HasSuffix(client.Endpoint, "/v1/") {
+		client.Endpoint += "v1/"
+	}
+	return client, nil
+}
+
 // IronicClient creates a client for Ironic
 func IronicClient(ironicEndpoint string, auth AuthConfig, tls TLSConfig) (client *gophercloud.ServiceClient, err error) {
 	switch auth.Type {
@@ -86,6 +173,8 @@ func IronicClient(ironicEndpoint string, auth AuthConfig, tls TLSConfig) (client
 			IronicUser:         auth.Username,
 			IronicUserPassword: auth.Password,
 		})
+	case KeystonePasswordAuth, KeystoneApplicationCredentialAuth:
+		client, err = keystoneClient(ironicEndpoint, "baremetal", auth, tls)
 	default:
 		err = fmt.Errorf("Unknown auth type %s", auth.Type)
 	}
@@ -98,6 +187,10 @@ func IronicClient(ironicEndpoint string, auth AuthConfig, tls TLSConfig) (client
 	// Version 1.69 introduces deploySteps argument to provisioning.
 	client.Microversion = "1.69"
 
+	if auth.IsKeystone() {
+		// TLS was already configured to authenticate
+		return client, nil
+	}
 	return updateHTTPClient(client, tls)
 }
 
@@ -115,6 +208,8 @@ func InspectorClient(inspectorEndpoint string, auth AuthConfig, tls TLSConfig) (
 			IronicInspectorUser:         auth.Username,
 			IronicInspectorUserPassword: auth.Password,
 		})
+	case KeystonePasswordAuth, KeystoneApplicationCredentialAuth:
+		return keystoneClient(inspectorEndpoint, "baremetal-inspector", auth, tls)
 	default:
 		err = fmt.Errorf("Unknown auth type %s", auth.Type)
 	}
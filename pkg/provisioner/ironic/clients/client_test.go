@@ -0,0 +1,129 @@
+package clients
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeKeystone issues a new token for each authentication request and
+// lists an Ironic service in the catalog.
+type fakeKeystone struct {
+	server    *httptest.Server
+	ironicURL string
+	tokens    int
+}
+
+func newFakeKeystone(t *testing.T, ironicURL string) *fakeKeystone {
+	k := &fakeKeystone{ironicURL: ironicURL}
+	k.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/v3/auth/tokens" {
+			t.Errorf("unexpected Keystone request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		k.tokens++
+		w.Header().Set("X-Subject-Token", k.token())
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"token": map[string]interface{}{
+				"expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+				"catalog": []map[string]interface{}{
+					{
+						"type": "baremetal",
+						"name": "ironic",
+						"endpoints": []map[string]interface{}{
+							{
+								"interface": "public",
+								"region":    "RegionOne",
+								"region_id": "RegionOne",
+								"url":       k.ironicURL,
+							},
+						},
+					},
+				},
+			},
+		})
+	}))
+	t.Cleanup(k.server.Close)
+	return k
+}
+
+func (k *fakeKeystone) token() string {
+	return fmt.Sprintf("token-%d", k.tokens)
+}
+
+func TestIronicClientKeystone(t *testing.T) {
+	var keystone *fakeKeystone
+	requests := 0
+	ironic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.URL.Path != "/v1/nodes" {
+			t.Errorf("unexpected Ironic request %s", r.URL.Path)
+		}
+		// Reject the first token to force a new authentication
+		if r.Header.Get("X-Auth-Token") != keystone.token() || keystone.tokens < 2 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"nodes": []}`))
+	}))
+	defer ironic.Close()
+	keystone = newFakeKeystone(t, ironic.URL)
+
+	auth := AuthConfig{
+		Type:                        KeystoneApplicationCredentialAuth,
+		AuthURL:                     keystone.server.URL + "/v3",
+		ApplicationCredentialID:     "abc",
+		ApplicationCredentialSecret: "s3cr3t",
+		RegionName:                  "RegionOne",
+	}
+	client, err := IronicClient("", auth, TLSConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.Endpoint != ironic.URL+"/v1/" {
+		t.Errorf("unexpected endpoint %s", client.Endpoint)
+	}
+	if client.Microversion != "1.69" {
+		t.Errorf("unexpected microversion %s", client.Microversion)
+	}
+
+	if _, err := client.Get(client.ServiceURL("nodes"), nil, nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if keystone.tokens != 2 {
+		t.Errorf("expected 2 authentications, got %d", keystone.tokens)
+	}
+	if requests != 2 {
+		t.Errorf("expected 2 Ironic requests, got %d", requests)
+	}
+}
+
+func TestIronicClientKeystoneEndpoint(t *testing.T) {
+	keystone := newFakeKeystone(t, "http://catalog.test:6385")
+
+	auth := AuthConfig{
+		Type:        KeystonePasswordAuth,
+		AuthURL:     keystone.server.URL + "/v3",
+		Username:    "user",
+		Password:    "pass",
+		ProjectName: "baremetal",
+	}
+	client, err := IronicClient("http://ironic.test:6385/v1", auth, TLSConfig{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if client.Endpoint != "http://ironic.test:6385/v1/" {
+		t.Errorf("unexpected endpoint %s", client.Endpoint)
+	}
+
+	if _, err := InspectorClient("", auth, TLSConfig{}); err == nil {
+		t.Error("expected an error without an inspector in the catalog")
+	}
+}
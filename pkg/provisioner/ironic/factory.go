@@ -11,6 +11,8 @@ import (
 	"github.com/gophercloud/gophercloud"
 	logz "sigs.k8s.io/controller-runtime/pkg/log/zap"
 
+	"github.com/metal3-io/baremetal-operator/pkg/deployimages"
+	"github.com/metal3-io/baremetal-operator/pkg/ociimage"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
 )
@@ -22,49 +24,82 @@ type ironicProvisionerFactory struct {
 	// Keep pointers to ironic and inspector clients configured with
 	// the global auth settings to reuse the connection between
 	// reconcilers.
-	clientIronic    *gophercloud.ServiceClient
-	clientInspector *gophercloud.ServiceClient
+	clients *ironicClients
+
+	// The named backends configured in addition to the default one
+	backends []*ironicBackend
+	// Whether only the named backends are configured
+	noDefaultBackend bool
+
+	// Resolves the images referenced in OCI registries
+	imageResolver imageResolver
 }
 
-func NewProvisionerFactory(havePreprovImgBuilder bool) provisioner.Factory {
-	factory := ironicProvisionerFactory{}
+// NewProvisionerFactory returns a factory for Ironic provisioners
+// booting the given deploy images. The clients are rebuilt when their
+// auth and TLS files change while the factory is started, and failures
+// to build them are reported by Ready.
+func NewProvisionerFactory(havePreprovImgBuilder bool, deployImages deployimages.Config) provisioner.ReloadingFactory {
+	factory := ironicProvisionerFactory{
+		imageResolver: ociimage.NewResolver(),
+	}
 
 	factory.log = logz.New().WithName("provisioner").WithName("ironic")
 
-	err := factory.init(havePreprovImgBuilder)
+	err := factory.init(havePreprovImgBuilder, deployImages)
 	if err != nil {
 		factory.log.Error(err, "Cannot start ironic provisioner")
 		os.Exit(1)
 	}
+	if err := factory.Ready(); err != nil {
+		factory.log.Error(err, "ironic clients are not ready")
+	}
 	return factory
 }
 
-func (f *ironicProvisionerFactory) init(havePreprovImgBuilder bool) error {
-	ironicAuth, inspectorAuth, err := clients.LoadAuth()
+func (f *ironicProvisionerFactory) init(havePreprovImgBuilder bool, deployImages deployimages.Config) error {
+	var err error
+	f.config, err = loadConfigFromEnv(havePreprovImgBuilder, deployImages)
 	if err != nil {
 		return err
 	}
 
-	f.config, err = loadConfigFromEnv(havePreprovImgBuilder)
-	if err != nil {
-		return err
+	backendsFile := os.Getenv("IRONIC_BACKENDS_FILE")
+	if backendsFile != "" {
+		settings, err := loadBackendSettings(backendsFile)
+		if err != nil {
+			return err
+		}
+		for _, s := range settings {
+			backend, err := newBackend(s, f.config)
+			if err != nil {
+				return err
+			}
+			f.log.Info("ironic backend", "name", backend.name,
+				"endpoint", s.IronicEndpoint, "inspectorEndpoint", s.InspectorEndpoint)
+			f.backends = append(f.backends, backend)
+		}
 	}
 
-	ironicEndpoint, inspectorEndpoint, err := loadEndpointsFromEnv()
-	if err != nil {
-		return err
+	ironicEndpoint, inspectorEndpoint, endpointsErr := loadEndpointsFromEnv()
+	if len(f.backends) > 0 && ironicEndpoint == "" && inspectorEndpoint == "" {
+		// The default backend is optional when named backends are
+		// configured.
+		f.log.Info("no default ironic backend")
+		f.noDefaultBackend = true
+		return nil
 	}
 
 	tlsConf := loadTLSConfigFromEnv()
 
 	f.log.Info("ironic settings",
 		"endpoint", ironicEndpoint,
-		"ironicAuthType", ironicAuth.Type,
 		"inspectorEndpoint", inspectorEndpoint,
-		"inspectorAuthType", inspectorAuth.Type,
 		"deployKernelURL", f.config.deployKernelURL,
 		"deployRamdiskURL", f.config.deployRamdiskURL,
 		"deployISOURL", f.config.deployISOURL,
+		"deployImagesByArch", f.config.deployImagesByArch,
+		"bmcCACertsDir", f.config.bmcCACertsDir,
 		"CACertFile", tlsConf.TrustedCAFile,
 		"ClientCertFile", tlsConf.ClientCertificateFile,
 		"ClientPrivKeyFile", tlsConf.ClientPrivateKeyFile,
@@ -72,37 +107,75 @@ func (f *ironicProvisionerFactory) init(havePreprovImgBuilder bool) error {
 		"SkipClientSANVerify", tlsConf.SkipClientSANVerify,
 	)
 
-	f.clientIronic, err = clients.IronicClient(
+	build := func() (clientIronic, clientInspector *gophercloud.ServiceClient, err error) {
+		ironicAuth, inspectorAuth, err := clients.LoadAuth()
+		if err != nil {
+			return nil, nil, err
+		}
+		// The missing endpoints are looked up in the Keystone catalog
+		if endpointsErr != nil &&
+			((ironicEndpoint == "" && !ironicAuth.IsKeystone()) ||
+				(inspectorEndpoint == "" && !inspectorAuth.IsKeystone())) {
+			return nil, nil, endpointsErr
+		}
+		f.log.Info("ironic auth settings",
+			"ironicAuthType", ironicAuth.Type,
+			"inspectorAuthType", inspectorAuth.Type,
+		)
+		return newClients(ironicEndpoint, ironicAuth, inspectorEndpoint, inspectorAuth, tlsConf)
+	}
+	f.clients = newIronicClients(build, append(tlsFiles(tlsConf), authDirs(clients.AuthRoot())...))
+	return nil
+}
+
+// newClients creates the Ironic and Inspector clients of a backend.
+func newClients(ironicEndpoint string, ironicAuth clients.AuthConfig, inspectorEndpoint string, inspectorAuth clients.AuthConfig, tlsConf clients.TLSConfig) (clientIronic, clientInspector *gophercloud.ServiceClient, err error) {
+	clientIronic, err = clients.IronicClient(
 		ironicEndpoint, ironicAuth, tlsConf)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	f.clientInspector, err = clients.InspectorClient(
+	clientInspector, err = clients.InspectorClient(
 		inspectorEndpoint, inspectorAuth, tlsConf)
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	return nil
+	return clientIronic, clientInspector, nil
 }
 
 func (f ironicProvisionerFactory) ironicProvisioner(hostData provisioner.HostData, publisher provisioner.EventPublisher) (*ironicProvisioner, error) {
 	provisionerLogger := f.log.WithValues("host", ironicNodeName(hostData.ObjectMeta))
 
+	backend, err := f.backendForHost(hostData)
+	if err != nil {
+		return nil, err
+	}
+	if backend.name != "" {
+		provisionerLogger = provisionerLogger.WithValues("backend", backend.name)
+	}
+
+	clientIronic, clientInspector, err := backend.clients.get()
+	if err != nil {
+		return nil, fmt.Errorf("Ironic clients are not available: %w", err)
+	}
+
 	p := &ironicProvisioner{
-		config:                  f.config,
+		config:                  backend.config,
+		backend:                 backend.name,
 		objectMeta:              hostData.ObjectMeta,
 		nodeID:                  hostData.ProvisionerID,
 		bmcCreds:                hostData.BMCCredentials,
 		bmcAddress:              hostData.BMCAddress,
 		disableCertVerification: hostData.DisableCertificateVerification,
 		bootMACAddress:          hostData.BootMACAddress,
-		client:                  f.clientIronic,
-		inspector:               f.clientInspector,
+		client:                  clientIronic,
+		inspector:               clientInspector,
 		log:                     provisionerLogger,
 		debugLog:                provisionerLogger.V(1),
 		publisher:               publisher,
+		imageResolver:           f.imageResolver,
 	}
 
 	return p, nil
@@ -114,38 +187,87 @@ func (f ironicProvisionerFactory) NewProvisioner(hostData provisioner.HostData,
 	return f.ironicProvisioner(hostData, publisher)
 }
 
-func loadConfigFromEnv(havePreprovImgBuilder bool) (ironicConfig, error) {
+func loadConfigFromEnv(havePreprovImgBuilder bool, deployImages deployimages.Config) (ironicConfig, error) {
 	c := ironicConfig{
 		havePreprovImgBuilder: havePreprovImgBuilder,
 	}
 
-	c.deployKernelURL = os.Getenv("DEPLOY_KERNEL_URL")
-	c.deployRamdiskURL = os.Getenv("DEPLOY_RAMDISK_URL")
-	c.deployISOURL = os.Getenv("DEPLOY_ISO_URL")
-	if !havePreprovImgBuilder {
-		if c.deployISOURL == "" &&
-			(c.deployKernelURL == "" || c.deployRamdiskURL == "") {
-			return c, errors.New("Either DEPLOY_KERNEL_URL and DEPLOY_RAMDISK_URL or DEPLOY_ISO_URL must be set")
-		}
-		if (c.deployKernelURL == "" && c.deployRamdiskURL != "") ||
-			(c.deployKernelURL != "" && c.deployRamdiskURL == "") {
-			return c, errors.New("DEPLOY_KERNEL_URL and DEPLOY_RAMDISK_URL can only be set together")
+	if err := deployImages.Validate(havePreprovImgBuilder); err != nil {
+		return c, err
+	}
+	c.deployKernelURL = deployImages.Default.KernelURL
+	c.deployRamdiskURL = deployImages.Default.RamdiskURL
+	c.deployISOURL = deployImages.Default.ISOURL
+	c.deployImagesByArch = deployImages.ByArch
+
+	capacity, err := loadCapacityPolicyFromEnv()
+	if err != nil {
+		return c, err
+	}
+	c.capacity = capacity
+
+	c.bmcCACertsDir = os.Getenv("BMC_CACERTS_DIR")
+
+	return c, nil
+}
+
+func loadLimitFromEnv(name string) (value int, found bool, err error) {
+	valueStr := os.Getenv(name)
+	if valueStr == "" {
+		return 0, false, nil
+	}
+	value, err = strconv.Atoi(valueStr)
+	if err != nil || value < 0 {
+		return 0, false, fmt.Errorf("Invalid value set for variable %s=%s", name, valueStr)
+	}
+	return value, true, nil
+}
+
+func loadCapacityPolicyFromEnv() (capacityPolicy, error) {
+	policy := capacityPolicy{
+		maxBusyHosts:             20,
+		maxBusyHostsPerOperation: make(map[hostOperation]int),
+		priorityLabel:            os.Getenv("PROVISIONING_PRIORITY_LABEL"),
+	}
+
+	if value, found, err := loadLimitFromEnv("PROVISIONING_LIMIT"); err != nil {
+		return policy, err
+	} else if found {
+		policy.maxBusyHosts = value
+	}
+
+	for operation, name := range map[hostOperation]string{
+		operationInspection:     "PROVISIONING_LIMIT_INSPECTION",
+		operationProvisioning:   "PROVISIONING_LIMIT_PROVISIONING",
+		operationDeprovisioning: "PROVISIONING_LIMIT_DEPROVISIONING",
+	} {
+		if value, found, err := loadLimitFromEnv(name); err != nil {
+			return policy, err
+		} else if found {
+			policy.maxBusyHostsPerOperation[operation] = value
 		}
 	}
-	if c.deployKernelURL == "" && c.deployRamdiskURL != "" {
-		return c, errors.New("DEPLOY_RAMDISK_URL requires DEPLOY_KERNEL_URL to be set also")
+
+	perNamespace, _, err := loadLimitFromEnv("PROVISIONING_LIMIT_PER_NAMESPACE")
+	if err != nil {
+		return policy, err
 	}
+	policy.maxBusyHostsPerNamespace = perNamespace
 
-	c.maxBusyHosts = 20
-	if maxHostsStr := os.Getenv("PROVISIONING_LIMIT"); maxHostsStr != "" {
-		value, err := strconv.Atoi(maxHostsStr)
-		if err != nil {
-			return c, fmt.Errorf("Invalid value set for variable PROVISIONING_LIMIT=%s", maxHostsStr)
+	if value, found, err := loadLimitFromEnv("PROVISIONING_PRIORITY_RESERVED"); err != nil {
+		return policy, err
+	} else if found {
+		if policy.priorityLabel == "" {
+			return policy, errors.New("PROVISIONING_PRIORITY_RESERVED requires PROVISIONING_PRIORITY_LABEL to be set also")
+		}
+		if value >= policy.maxBusyHosts {
+			return policy, fmt.Errorf("PROVISIONING_PRIORITY_RESERVED=%d must be lower than PROVISIONING_LIMIT=%d",
+				value, policy.maxBusyHosts)
 		}
-		c.maxBusyHosts = value
+		policy.priorityReservedHosts = value
 	}
 
-	return c, nil
+	return policy, nil
 }
 
 func loadEndpointsFromEnv() (ironicEndpoint, inspectorEndpoint string, err error) {
@@ -1,10 +1,14 @@
 package ironic
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/metal3-io/baremetal-operator/pkg/deployimages"
 )
 
 type EnvFixture struct {
@@ -13,6 +17,8 @@ type EnvFixture struct {
 	kernelURL         string
 	ramdiskURL        string
 	isoURL            string
+	imagesFile        string
+	bmcCACertsDir     string
 
 	origEnv map[string]string
 }
@@ -43,12 +49,16 @@ func (f *EnvFixture) SetUp() {
 	f.replace("DEPLOY_KERNEL_URL", f.kernelURL)
 	f.replace("DEPLOY_RAMDISK_URL", f.ramdiskURL)
 	f.replace("DEPLOY_ISO_URL", f.isoURL)
+	f.replace("DEPLOY_IMAGES_FILE", f.imagesFile)
+	f.replace("BMC_CACERTS_DIR", f.bmcCACertsDir)
 }
 
 func (f EnvFixture) VerifyConfig(t *testing.T, c ironicConfig) {
 	assert.Equal(t, f.kernelURL, c.deployKernelURL)
 	assert.Equal(t, f.ramdiskURL, c.deployRamdiskURL)
 	assert.Equal(t, f.isoURL, c.deployISOURL)
+	assert.Equal(t, f.imagesFile != "", len(c.deployImagesByArch) > 0)
+	assert.Equal(t, f.bmcCACertsDir, c.bmcCACertsDir)
 }
 
 func (f EnvFixture) VerifyEndpoints(t *testing.T, ironic, inspector string) {
@@ -57,6 +67,31 @@ func (f EnvFixture) VerifyEndpoints(t *testing.T, ironic, inspector string) {
 }
 
 func TestLoadConfigFromEnv(t *testing.T) {
+	imagesDir := t.TempDir()
+	writeImages := func(name, content string) string {
+		path := filepath.Join(imagesDir, name)
+		if err := ioutil.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	imagesFile := writeImages("images.yaml", `
+x86_64:
+  kernelURL: http://kernel-x86_64
+  ramdiskURL: http://ramdisk-x86_64
+aarch64:
+  isoURL: http://iso-aarch64
+`)
+	kernelOnlyImagesFile := writeImages("kernel-only.yaml", `
+aarch64:
+  kernelURL: http://kernel-aarch64
+  isoURL: http://iso-aarch64
+`)
+	invalidImagesFile := writeImages("invalid.yaml", `
+aarch64:
+  ramdiskURL: http://ramdisk-aarch64
+`)
+
 	cases := []struct {
 		name                  string
 		env                   EnvFixture
@@ -84,6 +119,13 @@ func TestLoadConfigFromEnv(t *testing.T) {
 				ramdiskURL: "http://ramdisk",
 			},
 		},
+		{
+			name: "BMC CA certificates directory",
+			env: EnvFixture{
+				isoURL:        "http://iso",
+				bmcCACertsDir: "/certs/bmc",
+			},
+		},
 		{
 			name:          "no deploy info",
 			env:           EnvFixture{},
@@ -94,14 +136,14 @@ func TestLoadConfigFromEnv(t *testing.T) {
 			env: EnvFixture{
 				kernelURL: "http://kernel",
 			},
-			expectedError: "Either DEPLOY_KERNEL_URL and DEPLOY_RAMDISK_URL or DEPLOY_ISO_URL must be set",
+			expectedError: "DEPLOY_KERNEL_URL and DEPLOY_RAMDISK_URL can only be set together",
 		},
 		{
 			name: "only ramdisk",
 			env: EnvFixture{
 				ramdiskURL: "http://ramdisk",
 			},
-			expectedError:         "Either DEPLOY_KERNEL_URL and DEPLOY_RAMDISK_URL or DEPLOY_ISO_URL must be set",
+			expectedError:         "DEPLOY_RAMDISK_URL requires DEPLOY_KERNEL_URL to be set also",
 			expectedImgBuildError: "DEPLOY_RAMDISK_URL requires DEPLOY_KERNEL_URL to be set also",
 		},
 		{
@@ -118,9 +160,37 @@ func TestLoadConfigFromEnv(t *testing.T) {
 				ramdiskURL: "http://ramdisk",
 				isoURL:     "http://iso",
 			},
-			expectedError:         "DEPLOY_KERNEL_URL and DEPLOY_RAMDISK_URL can only be set together",
+			expectedError:         "DEPLOY_RAMDISK_URL requires DEPLOY_KERNEL_URL to be set also",
 			expectedImgBuildError: "DEPLOY_RAMDISK_URL requires DEPLOY_KERNEL_URL to be set also",
 		},
+		{
+			name: "images by architecture only",
+			env: EnvFixture{
+				imagesFile: imagesFile,
+			},
+		},
+		{
+			name: "images by architecture and defaults",
+			env: EnvFixture{
+				isoURL:     "http://iso",
+				imagesFile: imagesFile,
+			},
+		},
+		{
+			name: "images by architecture with kernel only",
+			env: EnvFixture{
+				imagesFile: kernelOnlyImagesFile,
+			},
+			expectedError: "deploy images for the aarch64 architecture: kernelURL and ramdiskURL can only be set together",
+		},
+		{
+			name: "invalid images by architecture",
+			env: EnvFixture{
+				imagesFile: invalidImagesFile,
+			},
+			expectedError:         "ramdiskURL requires kernelURL to be set also",
+			expectedImgBuildError: "ramdiskURL requires kernelURL to be set also",
+		},
 	}
 
 	for _, tt := range []string{"", " (with img builder)"} {
@@ -129,7 +199,11 @@ func TestLoadConfigFromEnv(t *testing.T) {
 				defer tc.env.TearDown()
 				tc.env.SetUp()
 				imgBuild := tt == ""
-				config, err := loadConfigFromEnv(imgBuild)
+				deployImages, err := deployimages.FromEnv()
+				if err != nil {
+					t.Fatal(err)
+				}
+				config, err := loadConfigFromEnv(imgBuild, deployImages)
 				expectedError := tc.expectedError
 				if imgBuild {
 					expectedError = tc.expectedImgBuildError
@@ -190,3 +264,94 @@ func TestLoadEndpointsFromEnv(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadCapacityPolicyFromEnv(t *testing.T) {
+	cases := []struct {
+		name           string
+		env            map[string]string
+		expectedPolicy capacityPolicy
+		expectedError  string
+	}{
+		{
+			name: "defaults",
+			expectedPolicy: capacityPolicy{
+				maxBusyHosts:             20,
+				maxBusyHostsPerOperation: map[hostOperation]int{},
+			},
+		},
+		{
+			name: "all limits",
+			env: map[string]string{
+				"PROVISIONING_LIMIT":                "30",
+				"PROVISIONING_LIMIT_INSPECTION":     "10",
+				"PROVISIONING_LIMIT_PROVISIONING":   "15",
+				"PROVISIONING_LIMIT_DEPROVISIONING": "5",
+				"PROVISIONING_LIMIT_PER_NAMESPACE":  "8",
+				"PROVISIONING_PRIORITY_LABEL":       "example.com/urgent",
+				"PROVISIONING_PRIORITY_RESERVED":    "4",
+			},
+			expectedPolicy: capacityPolicy{
+				maxBusyHosts: 30,
+				maxBusyHostsPerOperation: map[hostOperation]int{
+					operationInspection:     10,
+					operationProvisioning:   15,
+					operationDeprovisioning: 5,
+				},
+				maxBusyHostsPerNamespace: 8,
+				priorityLabel:            "example.com/urgent",
+				priorityReservedHosts:    4,
+			},
+		},
+		{
+			name:          "invalid limit",
+			env:           map[string]string{"PROVISIONING_LIMIT_INSPECTION": "many"},
+			expectedError: "Invalid value set for variable PROVISIONING_LIMIT_INSPECTION=many",
+		},
+		{
+			name:          "negative limit",
+			env:           map[string]string{"PROVISIONING_LIMIT_PER_NAMESPACE": "-1"},
+			expectedError: "Invalid value set for variable PROVISIONING_LIMIT_PER_NAMESPACE=-1",
+		},
+		{
+			name:          "reserved without label",
+			env:           map[string]string{"PROVISIONING_PRIORITY_RESERVED": "2"},
+			expectedError: "PROVISIONING_PRIORITY_RESERVED requires PROVISIONING_PRIORITY_LABEL to be set also",
+		},
+		{
+			name: "reserved all slots",
+			env: map[string]string{
+				"PROVISIONING_PRIORITY_LABEL":    "example.com/urgent",
+				"PROVISIONING_PRIORITY_RESERVED": "20",
+			},
+			expectedError: "PROVISIONING_PRIORITY_RESERVED=20 must be lower than PROVISIONING_LIMIT=20",
+		},
+	}
+
+	names := []string{
+		"PROVISIONING_LIMIT",
+		"PROVISIONING_LIMIT_INSPECTION",
+		"PROVISIONING_LIMIT_PROVISIONING",
+		"PROVISIONING_LIMIT_DEPROVISIONING",
+		"PROVISIONING_LIMIT_PER_NAMESPACE",
+		"PROVISIONING_PRIORITY_LABEL",
+		"PROVISIONING_PRIORITY_RESERVED",
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			env := EnvFixture{origEnv: map[string]string{}}
+			defer env.TearDown()
+			for _, name := range names {
+				env.replace(name, tc.env[name])
+			}
+
+			policy, err := loadCapacityPolicyFromEnv()
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tc.expectedPolicy, policy)
+			}
+		})
+	}
+}
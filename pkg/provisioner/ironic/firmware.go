@@ -0,0 +1,103 @@
+package ironic
+
+import (
+	"net/http"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/pkg/errors"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+)
+
+// The clean step of the management interface that flashes firmware
+// images.
+const firmwareUpdateStep = "update_firmware"
+
+// The version of the API that introduced the firmware components of a
+// node.
+const firmwareComponentsMicroversion = "1.86"
+
+// firmwareComponent is a firmware component of a node, as returned by
+// /v1/nodes/{node}/firmware.
+type firmwareComponent struct {
+	Component          string `json:"component"`
+	InitialVersion     string `json:"initial_version"`
+	CurrentVersion     string `json:"current_version"`
+	LastVersionFlashed string `json:"last_version_flashed"`
+}
+
+type firmwareComponentsResult struct {
+	Firmware []firmwareComponent `json:"firmware"`
+}
+
+// nodeServiceStep holds the service step of a node, which is not known
+// to gophercloud.
+type nodeServiceStep struct {
+	ServiceStep map[string]interface{} `json:"service_step"`
+}
+
+// isFirmwareUpdateStep returns true if a clean or service step is the
+// step of the management interface flashing firmware images.
+func isFirmwareUpdateStep(step map[string]interface{}) bool {
+	return step["interface"] == "management" && step["step"] == firmwareUpdateStep
+}
+
+// isFirmwareUpdateFailure returns true if cleaning or servicing of the
+// node failed while flashing firmware, according to the clean or
+// service step reported by Ironic in the failed state.
+func (p *ironicProvisioner) isFirmwareUpdateFailure(ironicNode *nodes.Node) (bool, error) {
+	switch nodes.ProvisionState(ironicNode.ProvisionState) {
+	case nodes.CleanFail:
+		return isFirmwareUpdateStep(ironicNode.CleanStep), nil
+	case nodeServiceFail:
+		client := *p.client
+		client.Microversion = servicingMicroversion
+		var node nodeServiceStep
+		if err := nodes.Get(&client, ironicNode.UUID).ExtractInto(&node); err != nil {
+			return false, errors.Wrap(err, "failed to get the service step")
+		}
+		return isFirmwareUpdateStep(node.ServiceStep), nil
+	}
+	return false, nil
+}
+
+// GetFirmwareComponents returns the firmware versions of the
+// components of the host. If Ironic is too old to report them, nil is
+// returned.
+func (p *ironicProvisioner) GetFirmwareComponents() (components []metal3v1alpha1.FirmwareComponentStatus, err error) {
+	if p.nodeID == "" {
+		return nil, provisioner.ErrNeedsRegistration
+	}
+
+	client := *p.client
+	client.Microversion = firmwareComponentsMicroversion
+
+	var result firmwareComponentsResult
+	_, err = client.Get(client.ServiceURL("nodes", p.nodeID, "firmware"), &result, nil)
+	if err != nil {
+		switch respErr := err.(type) {
+		case gophercloud.ErrDefault404:
+			p.log.Info("firmware components are not supported by ironic")
+			return nil, nil
+		case gophercloud.ErrUnexpectedResponseCode:
+			if respErr.Actual == http.StatusNotAcceptable {
+				p.log.Info("firmware components are not supported by ironic")
+				return nil, nil
+			}
+		}
+		return nil, errors.Wrap(err, "failed to get firmware components")
+	}
+
+	components = []metal3v1alpha1.FirmwareComponentStatus{}
+	for _, fw := range result.Firmware {
+		components = append(components, metal3v1alpha1.FirmwareComponentStatus{
+			Component:          fw.Component,
+			InitialVersion:     fw.InitialVersion,
+			CurrentVersion:     fw.CurrentVersion,
+			LastVersionFlashed: fw.LastVersionFlashed,
+		})
+	}
+	return components, nil
+}
@@ -0,0 +1,222 @@
+package ironic
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/stretchr/testify/assert"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/testserver"
+)
+
+func TestGetFirmwareComponents(t *testing.T) {
+	nodeUUID := "33ce8659-7400-4c68-9535-d10766f07a58"
+	firmwarePath := "/v1/nodes/" + nodeUUID + "/firmware"
+
+	cases := []struct {
+		name     string
+		ironic   *testserver.IronicMock
+		expected []metal3v1alpha1.FirmwareComponentStatus
+	}{
+		{
+			name: "components",
+			ironic: testserver.NewIronic(t).WithDefaultResponses().
+				FirmwareComponents(nodeUUID, []map[string]interface{}{
+					{"component": "bios", "initial_version": "1.0", "current_version": "1.2",
+						"last_version_flashed": "1.2"},
+					{"component": "bmc", "initial_version": "5.0", "current_version": "5.0"},
+				}),
+			expected: []metal3v1alpha1.FirmwareComponentStatus{
+				{Component: "bios", InitialVersion: "1.0", CurrentVersion: "1.2", LastVersionFlashed: "1.2"},
+				{Component: "bmc", InitialVersion: "5.0", CurrentVersion: "5.0"},
+			},
+		},
+		{
+			name: "no components",
+			ironic: testserver.NewIronic(t).WithDefaultResponses().
+				FirmwareComponents(nodeUUID, []map[string]interface{}{}),
+			expected: []metal3v1alpha1.FirmwareComponentStatus{},
+		},
+		{
+			name: "microversion not supported",
+			ironic: func() *testserver.IronicMock {
+				m := testserver.NewIronic(t).WithDefaultResponses()
+				m.ErrorResponse(firmwarePath, http.StatusNotAcceptable)
+				return m
+			}(),
+		},
+		{
+			name: "endpoint not found",
+			ironic: func() *testserver.IronicMock {
+				m := testserver.NewIronic(t).WithDefaultResponses()
+				m.ErrorResponse(firmwarePath, http.StatusNotFound)
+				return m
+			}(),
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.ironic.Start()
+			defer tc.ironic.Stop()
+
+			host := makeHost()
+			host.Status.Provisioning.ID = nodeUUID
+
+			publisher := func(reason, message string) {}
+			auth := clients.AuthConfig{Type: clients.NoAuth}
+			prov, err := newProvisionerWithSettings(host, bmc.Credentials{}, publisher,
+				tc.ironic.Endpoint(), auth, "https://inspector.test/", auth,
+			)
+			if err != nil {
+				t.Fatalf("could not create provisioner: %s", err)
+			}
+
+			components, err := prov.GetFirmwareComponents()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, components)
+		})
+	}
+}
+
+func TestBuildManualCleaningStepsFirmwareUpdates(t *testing.T) {
+	bmcAccess, err := bmc.NewAccessDetails("redfish://192.168.122.1", false)
+	if err != nil {
+		t.Fatalf("could not create access details: %s", err)
+	}
+
+	prov := &ironicProvisioner{}
+	steps, err := prov.buildManualCleaningSteps(bmcAccess, provisioner.PrepareData{
+		FirmwareConfig: &metal3v1alpha1.FirmwareConfig{
+			Settings: map[string]string{"BootMode": "Uefi"},
+		},
+		FirmwareUpdates: []metal3v1alpha1.FirmwareUpdate{
+			{
+				Component: metal3v1alpha1.FirmwareComponentBIOS,
+				URL:       "http://example.com/bios.bin",
+				Checksum:  "abcdef",
+			},
+		},
+	})
+
+	assert.NoError(t, err)
+	if assert.Len(t, steps, 2) {
+		assert.Equal(t, nodes.InterfaceManagement, steps[0].Interface)
+		assert.Equal(t, "update_firmware", steps[0].Step)
+		assert.Equal(t, nodes.InterfaceBIOS, steps[1].Interface)
+		assert.Equal(t, "apply_configuration", steps[1].Step)
+	}
+}
+
+func TestPrepareFirmwareUpdateFailed(t *testing.T) {
+	nodeUUID := "33ce8659-7400-4c68-9535-d10766f07a58"
+
+	cases := []struct {
+		name              string
+		cleanStep         map[string]interface{}
+		expectedErrorType metal3v1alpha1.ErrorType
+	}{
+		{
+			name:              "firmware update failed",
+			cleanStep:         map[string]interface{}{"interface": "management", "step": "update_firmware"},
+			expectedErrorType: metal3v1alpha1.FirmwareUpdateError,
+		},
+		{
+			name:      "BIOS settings failed",
+			cleanStep: map[string]interface{}{"interface": "bios", "step": "apply_configuration"},
+		},
+		{
+			name: "no step reported",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			// The message mentions the firmware update step, which
+			// must not be taken as the failed step
+			lastError := "Node " + nodeUUID + " failed: update_firmware was not reached"
+			ironic := testserver.NewIronic(t).WithDefaultResponses().Node(nodes.Node{
+				ProvisionState: string(nodes.CleanFail),
+				UUID:           nodeUUID,
+				LastError:      lastError,
+				CleanStep:      tc.cleanStep,
+			})
+			ironic.Start()
+			defer ironic.Stop()
+
+			host := makeHost()
+			host.Status.Provisioning.ID = nodeUUID
+
+			publisher := func(reason, message string) {}
+			auth := clients.AuthConfig{Type: clients.NoAuth}
+			prov, err := newProvisionerWithSettings(host, bmc.Credentials{}, publisher,
+				ironic.Endpoint(), auth, "https://inspector.test/", auth,
+			)
+			if err != nil {
+				t.Fatalf("could not create provisioner: %s", err)
+			}
+
+			result, started, err := prov.Prepare(provisioner.PrepareData{}, false)
+			assert.NoError(t, err)
+			assert.False(t, started)
+			assert.Equal(t, lastError, result.ErrorMessage)
+			assert.Equal(t, tc.expectedErrorType, result.ErrorType)
+		})
+	}
+}
+
+func TestServiceFirmwareUpdateFailed(t *testing.T) {
+	nodeUUID := "33ce8659-7400-4c68-9535-d10766f07a58"
+
+	cases := []struct {
+		name              string
+		serviceStep       map[string]interface{}
+		expectedErrorType metal3v1alpha1.ErrorType
+	}{
+		{
+			name:              "firmware update failed",
+			serviceStep:       map[string]interface{}{"interface": "management", "step": "update_firmware"},
+			expectedErrorType: metal3v1alpha1.FirmwareUpdateError,
+		},
+		{
+			name:              "BIOS settings failed",
+			serviceStep:       map[string]interface{}{"interface": "bios", "step": "apply_configuration"},
+			expectedErrorType: metal3v1alpha1.ServicingError,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ironic := testserver.NewIronic(t).WithDefaultResponses().NodeWithServiceStep(nodes.Node{
+				ProvisionState: string(nodeServiceFail),
+				UUID:           nodeUUID,
+				LastError:      "servicing failed",
+			}, tc.serviceStep)
+			ironic.Start()
+			defer ironic.Stop()
+
+			host := makeHost()
+			host.Status.Provisioning.ID = nodeUUID
+
+			publisher := func(reason, message string) {}
+			auth := clients.AuthConfig{Type: clients.NoAuth}
+			prov, err := newProvisionerWithSettings(host, bmc.Credentials{}, publisher,
+				ironic.Endpoint(), auth, "https://inspector.test/", auth,
+			)
+			if err != nil {
+				t.Fatalf("could not create provisioner: %s", err)
+			}
+
+			result, started, err := prov.Service(provisioner.ServicingData{}, false)
+			assert.NoError(t, err)
+			assert.False(t, started)
+			assert.Equal(t, "servicing failed", result.ErrorMessage)
+			assert.Equal(t, tc.expectedErrorType, result.ErrorType)
+		})
+	}
+}
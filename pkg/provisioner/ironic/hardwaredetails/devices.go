@@ -0,0 +1,195 @@
+package hardwaredetails
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/openstack/baremetalintrospection/v1/introspection"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+// pciVendors maps the PCI vendor IDs of the common GPU vendors, and of
+// the display controllers embedded in BMCs, to their names.
+var pciVendors = map[string]string{
+	"10de": "NVIDIA",
+	"1002": "AMD",
+	"8086": "Intel",
+	"1da3": "Habana Labs",
+	"1ed5": "Moore Threads",
+	"102b": "Matrox",
+	"1a03": "ASPEED",
+}
+
+var memoryTypeRegexp = regexp.MustCompile(`\b(LP)?DDR[0-9]*\b`)
+
+// extraInt returns an integer value of the extra hardware data, which
+// may be stored as a number or a string.
+func extraInt(data introspection.ExtraHardwareData, key string) (int64, bool) {
+	switch value := data[key].(type) {
+	case float64:
+		return int64(value), true
+	case int:
+		return int64(value), true
+	case string:
+		i, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+func extraString(data introspection.ExtraHardwareData, key string) string {
+	switch value := data[key].(type) {
+	case string:
+		return value
+	case float64:
+		return strconv.FormatInt(int64(value), 10)
+	case int:
+		return strconv.Itoa(value)
+	}
+	return ""
+}
+
+// getCPUTopology returns the number of processor sockets and of cores
+// of each processor from the extra hardware data.
+func getCPUTopology(cpudata introspection.ExtraHardwareDataSection) (sockets, coresPerSocket int) {
+	if number, ok := extraInt(cpudata["physical"], "number"); ok {
+		sockets = int(number)
+	} else {
+		for name := range cpudata {
+			if strings.HasPrefix(name, "physical_") {
+				sockets++
+			}
+		}
+	}
+	if cores, ok := extraInt(cpudata["physical_0"], "cores"); ok {
+		coresPerSocket = int(cores)
+	}
+	return
+}
+
+// getMemoryDetails returns the populated memory banks of the extra
+// hardware data, ordered by bank.
+func getMemoryDetails(memorydata introspection.ExtraHardwareDataSection) []metal3v1alpha1.MemoryModule {
+	type bank struct {
+		index  int
+		module metal3v1alpha1.MemoryModule
+	}
+	var banks []bank
+	for name, data := range memorydata {
+		if !strings.HasPrefix(name, "bank") {
+			continue
+		}
+		index, err := strconv.Atoi(strings.TrimLeft(strings.TrimPrefix(name, "bank"), ":_"))
+		if err != nil {
+			continue
+		}
+		size, ok := extraInt(data, "size")
+		if !ok || size == 0 {
+			// An empty slot
+			continue
+		}
+		module := metal3v1alpha1.MemoryModule{
+			Slot:         extraString(data, "slot"),
+			SizeBytes:    metal3v1alpha1.Capacity(size),
+			Type:         memoryTypeRegexp.FindString(extraString(data, "description")),
+			Vendor:       extraString(data, "vendor"),
+			Model:        extraString(data, "product"),
+			SerialNumber: extraString(data, "serial"),
+		}
+		if clock, ok := extraInt(data, "clock"); ok {
+			module.SpeedMegahertz = metal3v1alpha1.ClockSpeed(clock/1000000) * metal3v1alpha1.MegaHertz
+		}
+		banks = append(banks, bank{index: index, module: module})
+	}
+	sort.Slice(banks, func(i, j int) bool { return banks[i].index < banks[j].index })
+
+	var modules []metal3v1alpha1.MemoryModule
+	for _, b := range banks {
+		modules = append(modules, b.module)
+	}
+	return modules
+}
+
+// getNUMANodes returns the CPUs, memory and NICs of each NUMA node.
+func getNUMANodes(topology introspection.NUMATopology) []metal3v1alpha1.NUMANode {
+	nodes := map[int]*metal3v1alpha1.NUMANode{}
+	node := func(id int) *metal3v1alpha1.NUMANode {
+		if nodes[id] == nil {
+			nodes[id] = &metal3v1alpha1.NUMANode{ID: id}
+		}
+		return nodes[id]
+	}
+	for _, cpu := range topology.CPUs {
+		// Each entry is a physical core with its thread siblings
+		count := len(cpu.ThreadSiblings)
+		if count == 0 {
+			count = 1
+		}
+		node(cpu.NUMANode).CPUCount += count
+	}
+	for _, ram := range topology.RAM {
+		node(ram.NUMANode).RAMMebibytes += ram.SizeKB / 1024
+	}
+	for _, nic := range topology.NICs {
+		n := node(nic.NUMANode)
+		n.NICs = append(n.NICs, nic.Name)
+	}
+
+	var result []metal3v1alpha1.NUMANode
+	for _, n := range nodes {
+		sort.Strings(n.NICs)
+		result = append(result, *n)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
+
+func normalizePCIID(id string) string {
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(id), "0x"))
+}
+
+// getPCIDevices returns the PCI devices of the inventory, ordered by
+// address.
+func getPCIDevices(pcidata []PCIDeviceData) []metal3v1alpha1.PCIDevice {
+	var devices []metal3v1alpha1.PCIDevice
+	for _, device := range pcidata {
+		devices = append(devices, metal3v1alpha1.PCIDevice{
+			Address:  device.Bus,
+			VendorID: normalizePCIID(device.VendorID),
+			DeviceID: normalizePCIID(device.ProductID),
+			Class:    normalizePCIID(device.Class),
+			Revision: normalizePCIID(device.Revision),
+		})
+	}
+	sort.SliceStable(devices, func(i, j int) bool { return devices[i].Address < devices[j].Address })
+	return devices
+}
+
+// isGPU returns true for the display controllers and the processing
+// accelerators, by PCI class.
+func isGPU(class string) bool {
+	class = normalizePCIID(class)
+	return strings.HasPrefix(class, "03") || strings.HasPrefix(class, "12")
+}
+
+// getGPUs returns the PCI devices that are display controllers or
+// processing accelerators. The vendor name is only set for the known
+// vendors.
+func getGPUs(pcidata []PCIDeviceData) []metal3v1alpha1.GPU {
+	var gpus []metal3v1alpha1.GPU
+	for _, device := range getPCIDevices(pcidata) {
+		if !isGPU(device.Class) {
+			continue
+		}
+		gpus = append(gpus, metal3v1alpha1.GPU{
+			Address:  device.Address,
+			Vendor:   pciVendors[device.VendorID],
+			VendorID: device.VendorID,
+			DeviceID: device.DeviceID,
+		})
+	}
+	return gpus
+}
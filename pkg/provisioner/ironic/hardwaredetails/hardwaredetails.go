@@ -11,8 +11,46 @@ import (
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 )
 
+// Data is Ironic introspection data, with the parts that are not
+// parsed by gophercloud.
+type Data struct {
+	introspection.Data
+
+	PCIDevices []PCIDeviceData
+}
+
+// PCIDeviceData is a PCI device as reported by the pci-devices
+// collector of the agent.
+type PCIDeviceData struct {
+	VendorID  string `json:"vendor_id"`
+	ProductID string `json:"product_id"`
+	Class     string `json:"class"`
+	Revision  string `json:"revision"`
+	Bus       string `json:"bus"`
+}
+
+// ExtractData extracts the introspection data from the response of the
+// Inspector.
+func ExtractData(r introspection.DataResult) (*Data, error) {
+	data, err := r.Extract()
+	if err != nil {
+		return nil, err
+	}
+	// Collectors store their data next to the inventory
+	var collected struct {
+		PCIDevices []PCIDeviceData `json:"pci_devices"`
+	}
+	if err := r.ExtractInto(&collected); err != nil {
+		return nil, err
+	}
+	return &Data{
+		Data:       *data,
+		PCIDevices: collected.PCIDevices,
+	}, nil
+}
+
 // GetHardwareDetails converts Ironic introspection data into BareMetalHost HardwareDetails.
-func GetHardwareDetails(data *introspection.Data) *metal3v1alpha1.HardwareDetails {
+func GetHardwareDetails(data *Data) *metal3v1alpha1.HardwareDetails {
 	details := new(metal3v1alpha1.HardwareDetails)
 	details.Firmware = getFirmwareDetails(data.Extra.Firmware)
 	details.SystemVendor = getSystemVendorDetails(data.Inventory.SystemVendor)
@@ -20,33 +58,75 @@ func GetHardwareDetails(data *introspection.Data) *metal3v1alpha1.HardwareDetail
 	details.NIC = getNICDetails(data.Inventory.Interfaces, data.AllInterfaces, data.Extra.Network)
 	details.Storage = getStorageDetails(data.Inventory.Disks)
 	details.CPU = getCPUDetails(&data.Inventory.CPU)
+	details.CPU.Sockets, details.CPU.CoresPerSocket = getCPUTopology(data.Extra.CPU)
 	details.Hostname = data.Inventory.Hostname
+	details.Memory = getMemoryDetails(data.Extra.Memory)
+	details.NUMANodes = getNUMANodes(data.NUMATopology)
+	details.PCIDevices = getPCIDevices(data.PCIDevices)
+	details.GPUs = getGPUs(data.PCIDevices)
 	return details
 }
 
+// lldpVLANID returns a VLAN ID of the processed LLDP data. Numbers are
+// decoded as float64 from the JSON data.
+func lldpVLANID(value interface{}) (metal3v1alpha1.VLANID, bool) {
+	switch vid := value.(type) {
+	case int:
+		return metal3v1alpha1.VLANID(vid), true
+	case float64:
+		return metal3v1alpha1.VLANID(vid), true
+	}
+	return 0, false
+}
+
 func getVLANs(intf introspection.BaseInterfaceType) (vlans []metal3v1alpha1.VLAN, vlanid metal3v1alpha1.VLANID) {
 	if intf.LLDPProcessed == nil {
 		return
 	}
 	if spvs, ok := intf.LLDPProcessed["switch_port_vlans"]; ok {
-		if data, ok := spvs.([]map[string]interface{}); ok {
+		data, ok := spvs.([]map[string]interface{})
+		if list, isList := spvs.([]interface{}); isList {
+			data, ok = make([]map[string]interface{}, 0, len(list)), true
+			for _, item := range list {
+				if vlan, isMap := item.(map[string]interface{}); isMap {
+					data = append(data, vlan)
+				}
+			}
+		}
+		if ok {
 			vlans = make([]metal3v1alpha1.VLAN, len(data))
 			for i, vlan := range data {
-				vid, _ := vlan["id"].(int)
+				vid, _ := lldpVLANID(vlan["id"])
 				name, _ := vlan["name"].(string)
 				vlans[i] = metal3v1alpha1.VLAN{
-					ID:   metal3v1alpha1.VLANID(vid),
+					ID:   vid,
 					Name: name,
 				}
 			}
 		}
 	}
-	if vid, ok := intf.LLDPProcessed["switch_port_untagged_vlan_id"].(int); ok {
-		vlanid = metal3v1alpha1.VLANID(vid)
-	}
+	vlanid, _ = lldpVLANID(intf.LLDPProcessed["switch_port_untagged_vlan_id"])
 	return
 }
 
+// getLLDP returns the switch port a NIC is connected to from the
+// processed LLDP data, or nil if no LLDP data was received.
+func getLLDP(intf introspection.BaseInterfaceType) *metal3v1alpha1.LLDP {
+	if intf.LLDPProcessed == nil {
+		return nil
+	}
+	lldp := metal3v1alpha1.LLDP{}
+	lldp.SwitchID, _ = intf.LLDPProcessed["switch_chassis_id"].(string)
+	lldp.PortID, _ = intf.LLDPProcessed["switch_port_id"].(string)
+	lldp.SwitchSystemName, _ = intf.LLDPProcessed["switch_system_name"].(string)
+	lldp.PortDescription, _ = intf.LLDPProcessed["switch_port_description"].(string)
+	lldp.PortVLANID, _ = lldpVLANID(intf.LLDPProcessed["switch_port_untagged_vlan_id"])
+	if lldp == (metal3v1alpha1.LLDP{}) {
+		return nil
+	}
+	return &lldp
+}
+
 func getNICSpeedGbps(intfExtradata introspection.ExtraHardwareData) (speedGbps int) {
 	if speed, ok := intfExtradata["speed"].(string); ok {
 		if strings.HasSuffix(speed, "Gbps") {
@@ -63,6 +143,7 @@ func getNICDetails(ifdata []introspection.InterfaceType,
 	for _, intf := range ifdata {
 		baseIntf := basedata[intf.Name]
 		vlans, vlanid := getVLANs(baseIntf)
+		lldp := getLLDP(baseIntf)
 		// We still store one nic even if both ips are unset
 		// if both are set, we store two nics with each ip
 		if intf.IPV4Address != "" || intf.IPV6Address == "" {
@@ -76,6 +157,7 @@ func getNICDetails(ifdata []introspection.InterfaceType,
 				VLANID:    vlanid,
 				SpeedGbps: getNICSpeedGbps(extradata[intf.Name]),
 				PXE:       baseIntf.PXE,
+				LLDP:      lldp,
 			})
 		}
 		if intf.IPV6Address != "" {
@@ -89,6 +171,7 @@ func getNICDetails(ifdata []introspection.InterfaceType,
 				VLANID:    vlanid,
 				SpeedGbps: getNICSpeedGbps(extradata[intf.Name]),
 				PXE:       baseIntf.PXE,
+				LLDP:      lldp,
 			})
 		}
 	}
@@ -1,10 +1,17 @@
 package hardwaredetails
 
 import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 
+	"github.com/gophercloud/gophercloud"
 	"github.com/gophercloud/gophercloud/openstack/baremetalintrospection/v1/introspection"
+	"sigs.k8s.io/yaml"
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 )
@@ -200,6 +207,7 @@ func TestGetNICDetails(t *testing.T) {
 			{ID: 1},
 		},
 		VLANID: 1,
+		LLDP:   &metal3v1alpha1.LLDP{PortVLANID: 1},
 	})) {
 		t.Errorf("Unexpected NIC data")
 	}
@@ -233,6 +241,56 @@ func TestGetNICDetails(t *testing.T) {
 	}
 }
 
+func TestGetLLDP(t *testing.T) {
+	lldp := getLLDP(introspection.BaseInterfaceType{
+		LLDPProcessed: map[string]interface{}{
+			"switch_chassis_id":            "64:64:9b:31:12:00",
+			"switch_port_id":               "ge-0/0/7",
+			"switch_system_name":           "leaf-1.example.com",
+			"switch_port_description":      "worker-0 eno1",
+			"switch_port_untagged_vlan_id": float64(100),
+			"switch_port_mtu":              float64(9000),
+		},
+	})
+	expected := &metal3v1alpha1.LLDP{
+		SwitchID:         "64:64:9b:31:12:00",
+		PortID:           "ge-0/0/7",
+		SwitchSystemName: "leaf-1.example.com",
+		PortDescription:  "worker-0 eno1",
+		PortVLANID:       100,
+	}
+	if !reflect.DeepEqual(lldp, expected) {
+		t.Errorf("Unexpected LLDP data %+v", lldp)
+	}
+
+	if lldp := getLLDP(introspection.BaseInterfaceType{}); lldp != nil {
+		t.Errorf("Unexpected LLDP data without LLDP %+v", lldp)
+	}
+	if lldp := getLLDP(introspection.BaseInterfaceType{
+		LLDPProcessed: map[string]interface{}{"switch_port_mtu": float64(1500)},
+	}); lldp != nil {
+		t.Errorf("Unexpected LLDP data without a switch port %+v", lldp)
+	}
+}
+
+func TestGetVLANsJSON(t *testing.T) {
+	var intf introspection.BaseInterfaceType
+	err := json.Unmarshal([]byte(`{"lldp_processed": {
+		"switch_port_vlans": [{"id": 100, "name": "prov"}, {"id": 200}],
+		"switch_port_untagged_vlan_id": 100
+	}}`), &intf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vlans, vid := getVLANs(intf)
+	if vid != 100 {
+		t.Errorf("Unexpected untagged VLAN ID %d", vid)
+	}
+	if !reflect.DeepEqual(vlans, []metal3v1alpha1.VLAN{{ID: 100, Name: "prov"}, {ID: 200}}) {
+		t.Errorf("Unexpected VLANs %v", vlans)
+	}
+}
+
 func TestGetNICSpeedGbps(t *testing.T) {
 	s1 := getNICSpeedGbps(introspection.ExtraHardwareData{
 		"speed": "25Gbps",
@@ -299,8 +357,63 @@ func TestGetFirmwareDetails(t *testing.T) {
 	// Finally, ensure we can handle completely empty firmware data
 	firmware = getFirmwareDetails(introspection.ExtraHardwareDataSection{})
 
-	if (firmware != metal3v1alpha1.Firmware{}) {
+	if !reflect.DeepEqual(firmware, metal3v1alpha1.Firmware{}) {
 		t.Errorf("Expected firmware data to be empty but got: %s", firmware)
 	}
 
 }
+
+var updateGolden = flag.Bool("update", false, "update the golden files of the hardware details")
+
+// TestGetHardwareDetailsGolden converts the introspection data dumps of
+// testdata/*.json and compares the result with the hardware details of
+// testdata/*.golden.yaml. Run the tests with -update after changing the
+// conversion to regenerate the golden files.
+func TestGetHardwareDetailsGolden(t *testing.T) {
+	dumps, err := filepath.Glob(filepath.Join("testdata", "*.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dumps) == 0 {
+		t.Fatal("no introspection data found")
+	}
+
+	for _, dump := range dumps {
+		name := strings.TrimSuffix(filepath.Base(dump), ".json")
+		t.Run(name, func(t *testing.T) {
+			content, err := ioutil.ReadFile(dump)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var body interface{}
+			if err := json.Unmarshal(content, &body); err != nil {
+				t.Fatal(err)
+			}
+			data, err := ExtractData(introspection.DataResult{
+				Result: gophercloud.Result{Body: body},
+			})
+			if err != nil {
+				t.Fatalf("failed to extract the introspection data: %v", err)
+			}
+
+			actual, err := yaml.Marshal(GetHardwareDetails(data))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			golden := filepath.Join("testdata", name+".golden.yaml")
+			if *updateGolden {
+				if err := ioutil.WriteFile(golden, actual, 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			expected, err := ioutil.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(actual) != string(expected) {
+				t.Errorf("unexpected hardware details for %s:\n%s\nexpected:\n%s", name, actual, expected)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package ironic
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
@@ -17,9 +18,12 @@ import (
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/deployimages"
+	"github.com/metal3-io/baremetal-operator/pkg/ociimage"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/devicehints"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/hardwaredetails"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/redfish"
 )
 
 var (
@@ -65,7 +69,48 @@ type ironicConfig struct {
 	deployKernelURL       string
 	deployRamdiskURL      string
 	deployISOURL          string
-	maxBusyHosts          int
+	// the deploy images for each CPU architecture, overriding the
+	// images above when set
+	deployImagesByArch deployimages.ByArch
+	capacity           capacityPolicy
+	bmcCACertsDir      string
+}
+
+// deployImages returns the deploy images for a CPU architecture. The
+// images set by the DEPLOY_* variables are used when no images are
+// configured per architecture, or while the architecture of the host is
+// not known.
+func (c ironicConfig) deployImages(arch string) (deployimages.Images, error) {
+	if len(c.deployImagesByArch) == 0 || arch == "" {
+		return deployimages.Images{
+			KernelURL:  c.deployKernelURL,
+			RamdiskURL: c.deployRamdiskURL,
+			ISOURL:     c.deployISOURL,
+		}, nil
+	}
+	images, ok := c.deployImagesByArch[arch]
+	if !ok {
+		return images, fmt.Errorf("no deploy image configured for the %s architecture", arch)
+	}
+	return images, nil
+}
+
+// allDeployImages returns the default deploy images, when configured,
+// and those of each CPU architecture.
+func (c ironicConfig) allDeployImages() []deployimages.Images {
+	var all []deployimages.Images
+	defaultImages := deployimages.Images{
+		KernelURL:  c.deployKernelURL,
+		RamdiskURL: c.deployRamdiskURL,
+		ISOURL:     c.deployISOURL,
+	}
+	if defaultImages != (deployimages.Images{}) {
+		all = append(all, defaultImages)
+	}
+	for _, images := range c.deployImagesByArch {
+		all = append(all, images)
+	}
+	return all
 }
 
 // Provisioner implements the provisioning.Provisioner interface
@@ -77,6 +122,8 @@ type ironicProvisioner struct {
 	objectMeta metav1.ObjectMeta
 	// the UUID of the node in Ironic
 	nodeID string
+	// the name of the Ironic backend, empty for the default one
+	backend string
 	// the address of the BMC
 	bmcAddress string
 	// whether to disable SSL certificate verification
@@ -95,6 +142,20 @@ type ironicProvisioner struct {
 	debugLog logr.Logger
 	// an event publisher for recording significant events
 	publisher provisioner.EventPublisher
+	// resolves the images referenced in OCI registries
+	imageResolver imageResolver
+}
+
+// imageResolver resolves an OCI reference to an image that Ironic can
+// download, see ociimage.Resolver.
+type imageResolver interface {
+	Resolve(ctx context.Context, imageURL, arch, diskFormat, dockerConfig string) (*ociimage.Image, error)
+}
+
+// Backend returns the name of the Ironic backend the host is routed
+// to, or an empty string for the default backend.
+func (p *ironicProvisioner) Backend() string {
+	return p.backend
 }
 
 func (p *ironicProvisioner) bmcAccess() (bmc.AccessDetails, error) {
@@ -342,8 +403,35 @@ func (p *ironicProvisioner) ValidateManagementAccess(data provisioner.Management
 		return
 	}
 
+	if len(p.bmcCreds.CACertificate) > 0 && !p.disableCertVerification {
+		if !bmcAccess.SupportsCACertificate() {
+			msg := fmt.Sprintf("BMC driver %s does not support a CA certificate", bmcAccess.Type())
+			p.log.Info(msg)
+			result, err = operationFailed(msg)
+			return
+		}
+		if p.config.bmcCACertsDir == "" {
+			msg := "BMC CA certificates are not supported, BMC_CACERTS_DIR is not set"
+			if p.backend != "" {
+				msg = fmt.Sprintf("BMC CA certificates are not supported by Ironic backend %s, bmcCACertsDir is not set", p.backend)
+			}
+			result, err = operationFailed(msg)
+			return
+		}
+		p.bmcCreds.CACertificatePath, err = p.storeBMCCACertificate()
+		if err != nil {
+			result, err = transientError(err)
+			return
+		}
+	}
+
 	driverInfo := bmcAccess.DriverInfo(p.bmcCreds)
-	haveDeployImage := setDeployImage(driverInfo, p.config, bmcAccess, data.PreprovisioningImage)
+	haveDeployImage, err := setDeployImage(driverInfo, p.config, bmcAccess, data.PreprovisioningImage, data.CPUArchitecture)
+	if err != nil {
+		p.log.Info(err.Error())
+		result, err = operationFailed(err.Error())
+		return
+	}
 
 	// If we have not found a node yet, we need to create one
 	if ironicNode == nil {
@@ -532,11 +620,23 @@ func (p *ironicProvisioner) PreprovisioningImageFormats() ([]metal3v1alpha1.Imag
 		return nil, err
 	}
 
+	// The images of all architectures are considered, as the one of the
+	// host may not be known yet
+	haveKernel, haveAllRamdisks := false, true
+	for _, images := range p.config.allDeployImages() {
+		if images.KernelURL != "" {
+			haveKernel = true
+		}
+		if images.KernelURL == "" || images.RamdiskURL == "" {
+			haveAllRamdisks = false
+		}
+	}
+
 	var formats []metal3v1alpha1.ImageFormat
 	if accessDetails.SupportsISOPreprovisioningImage() {
 		formats = append(formats, metal3v1alpha1.ImageFormatISO)
 	} else {
-		if p.config.deployKernelURL != "" && p.config.deployRamdiskURL != "" {
+		if haveAllRamdisks {
 			// This is a PXE driver (no ISO support) so it shouldn't require any
 			// network customisation to boot the image, and we have sufficient
 			// data available to configure. Therefore, do not request an image
@@ -544,46 +644,54 @@ func (p *ironicProvisioner) PreprovisioningImageFormats() ([]metal3v1alpha1.Imag
 			return nil, nil
 		}
 	}
-	if p.config.deployKernelURL != "" {
+	if haveKernel {
 		formats = append(formats, metal3v1alpha1.ImageFormatInitRD)
 	}
 
 	return formats, nil
 }
 
-func setDeployImage(driverInfo map[string]interface{}, config ironicConfig, accessDetails bmc.AccessDetails, hostImage *provisioner.PreprovisioningImage) bool {
+// setDeployImage sets the deploy image matching the CPU architecture of
+// the host in the driver info. It returns whether an image was set, and
+// an error if no image is configured for the architecture.
+func setDeployImage(driverInfo map[string]interface{}, config ironicConfig, accessDetails bmc.AccessDetails, hostImage *provisioner.PreprovisioningImage, arch string) (bool, error) {
+	images, err := config.deployImages(arch)
+	if err != nil {
+		return false, err
+	}
+
 	allowISO := accessDetails.SupportsISOPreprovisioningImage()
-	allowInitRD := config.deployKernelURL != ""
+	allowInitRD := images.KernelURL != ""
 
 	if hostImage != nil {
 		switch hostImage.Format {
 		case metal3v1alpha1.ImageFormatISO:
 			if allowISO {
 				driverInfo["deploy_iso"] = hostImage.ImageURL
-				return true
+				return true, nil
 			}
 		case metal3v1alpha1.ImageFormatInitRD:
 			if allowInitRD {
-				driverInfo["deploy_kernel"] = config.deployKernelURL
+				driverInfo["deploy_kernel"] = images.KernelURL
 				driverInfo["deploy_ramdisk"] = hostImage.ImageURL
-				return true
+				return true, nil
 			}
 		}
 	}
 
 	if !config.havePreprovImgBuilder {
-		if allowISO && config.deployISOURL != "" {
-			driverInfo["deploy_iso"] = config.deployISOURL
-			return true
+		if allowISO && images.ISOURL != "" {
+			driverInfo["deploy_iso"] = images.ISOURL
+			return true, nil
 		}
 	}
-	if allowInitRD && config.deployRamdiskURL != "" {
-		driverInfo["deploy_kernel"] = config.deployKernelURL
-		driverInfo["deploy_ramdisk"] = config.deployRamdiskURL
-		return true
+	if allowInitRD && images.RamdiskURL != "" {
+		driverInfo["deploy_kernel"] = images.KernelURL
+		driverInfo["deploy_ramdisk"] = images.RamdiskURL
+		return true, nil
 	}
 
-	return false
+	return false, nil
 }
 
 func (p *ironicProvisioner) tryUpdateNode(ironicNode *nodes.Node, updater *nodeUpdater) (success bool, result provisioner.Result, err error) {
@@ -607,13 +715,20 @@ func (p *ironicProvisioner) tryUpdateNode(ironicNode *nodes.Node, updater *nodeU
 }
 
 func (p *ironicProvisioner) tryChangeNodeProvisionState(ironicNode *nodes.Node, opts nodes.ProvisionStateOpts) (success bool, result provisioner.Result, err error) {
+	return p.tryChangeNodeProvisionStateWith(p.client, ironicNode, opts.Target, opts)
+}
+
+// tryChangeNodeProvisionStateWith requests a change to the provision
+// state of the node using the given client, for requests that need a
+// particular API version or are not supported by gophercloud.
+func (p *ironicProvisioner) tryChangeNodeProvisionStateWith(client *gophercloud.ServiceClient, ironicNode *nodes.Node, target nodes.TargetProvisionState, opts nodes.ProvisionStateOptsBuilder) (success bool, result provisioner.Result, err error) {
 	p.log.Info("changing provisioning state",
 		"current", ironicNode.ProvisionState,
 		"existing target", ironicNode.TargetProvisionState,
-		"new target", opts.Target,
+		"new target", target,
 	)
 
-	changeResult := nodes.ChangeProvisionState(p.client, ironicNode.UUID, opts)
+	changeResult := nodes.ChangeProvisionState(client, ironicNode.UUID, opts)
 	switch changeResult.Err.(type) {
 	case nil:
 		success = true
@@ -623,7 +738,7 @@ func (p *ironicProvisioner) tryChangeNodeProvisionState(ironicNode *nodes.Node,
 		return
 	default:
 		result, err = transientError(errors.Wrap(changeResult.Err,
-			fmt.Sprintf("failed to change provisioning state to %q", opts.Target)))
+			fmt.Sprintf("failed to change provisioning state to %q", target)))
 		return
 	}
 
@@ -714,7 +829,7 @@ func (p *ironicProvisioner) InspectHardware(data provisioner.InspectData, force,
 	// Introspection is done
 	p.log.Info("getting hardware details from inspection")
 	response := introspection.GetIntrospectionData(p.inspector, ironicNode.UUID)
-	introData, err := response.Extract()
+	introData, err := hardwaredetails.ExtractData(response)
 	if err != nil {
 		result, err = transientError(errors.Wrap(err, "failed to retrieve hardware introspection data"))
 		return
@@ -751,6 +866,14 @@ func (p *ironicProvisioner) UpdateHardwareState() (hwState provisioner.HardwareS
 	return
 }
 
+// GetHardwareHealth collects the health of the hardware. Ironic
+// publishes the sensor data of the nodes only as notifications, so for
+// the Redfish BMCs the sensors are read from the BMC, as Ironic does.
+// The health of hosts with other BMC types is not collected.
+func (p *ironicProvisioner) GetHardwareHealth() (health *provisioner.HardwareHealth, err error) {
+	return redfish.CollectHealth(p.bmcAddress, p.bmcCreds, p.disableCertVerification)
+}
+
 func (p *ironicProvisioner) setLiveIsoUpdateOptsForNode(ironicNode *nodes.Node, imageData *metal3v1alpha1.Image, updater *nodeUpdater) {
 	optValues := optionsData{
 		"boot_iso": imageData.URL,
@@ -780,7 +903,7 @@ func (p *ironicProvisioner) setDirectDeployUpdateOptsForNode(ironicNode *nodes.N
 	// only want to do that for MD5, however, because those versions
 	// of ironic only support MD5 checksums.
 	var legacyChecksum *string
-	if checksumType == string(metal3v1alpha1.MD5) {
+	if checksumType == string(metal3v1alpha1.MD5) || checksumType == "" {
 		legacyChecksum = &checksum
 	}
 
@@ -794,6 +917,12 @@ func (p *ironicProvisioner) setDirectDeployUpdateOptsForNode(ironicNode *nodes.N
 		"image_checksum":      legacyChecksum,
 		"image_disk_format":   imageData.DiskFormat,
 	}
+	if checksumType == "" {
+		// Ironic detects the algorithm of the checksum read from the
+		// URL given as the legacy checksum
+		optValues["image_os_hash_algo"] = nil
+		optValues["image_os_hash_value"] = nil
+	}
 	updater.
 		SetInstanceInfoOpts(optValues, ironicNode).
 		SetTopLevelOpt("deploy_interface", "direct", ironicNode.DeployInterface)
@@ -804,7 +933,16 @@ func (p *ironicProvisioner) setCustomDeployUpdateOptsForNode(ironicNode *nodes.N
 	if imageData != nil && imageData.URL != "" {
 		checksum, checksumType, ok := imageData.GetChecksum()
 		// NOTE(dtantsur): all fields are optional for custom deploy
-		if ok {
+		if ok && checksumType == "" {
+			optValues = optionsData{
+				"boot_iso":            nil,
+				"image_checksum":      checksum,
+				"image_source":        imageData.URL,
+				"image_os_hash_algo":  nil,
+				"image_os_hash_value": nil,
+				"image_disk_format":   imageData.DiskFormat,
+			}
+		} else if ok {
 			optValues = optionsData{
 				"boot_iso":            nil,
 				"image_checksum":      nil,
@@ -858,6 +996,12 @@ func (p *ironicProvisioner) getImageUpdateOptsForNode(ironicNode *nodes.Node, im
 
 	updater.SetInstanceInfoOpts(optionsData{"capabilities": capabilitiesII}, ironicNode)
 
+	if imageData != nil && ociimage.IsReference(imageData.URL) {
+		// OCI references are resolved with the pull secret of the host
+		// when provisioning starts, and the resolved settings are kept.
+		return
+	}
+
 	if hasCustomDeploy {
 		// Custom deploy process
 		p.setCustomDeployUpdateOptsForNode(ironicNode, imageData, updater)
@@ -923,12 +1067,68 @@ func buildCapabilitiesValue(ironicNode *nodes.Node, bootMode metal3v1alpha1.Boot
 	return strings.Join(filteredCapabilities, ",")
 }
 
+// resolveImage resolves an image referenced in an OCI registry to the
+// URL and checksum of its layer for the host, and to the digest of
+// its manifest.
+func (p *ironicProvisioner) resolveImage(data provisioner.ProvisionData) (resolved *ociimage.Image, errorMessage string, err error) {
+	dockerConfig, err := data.HostConfig.ImagePullSecret()
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to read the image pull secret")
+	}
+	var diskFormat string
+	if data.Image.DiskFormat != nil {
+		diskFormat = *data.Image.DiskFormat
+	}
+
+	resolved, err = p.imageResolver.Resolve(context.TODO(), data.Image.URL, data.CPUArchitecture, diskFormat, dockerConfig)
+	if err != nil {
+		return nil, fmt.Sprintf("Failed to resolve image %s: %s", data.Image.URL, err), nil
+	}
+	p.log.Info("resolved image", "image", data.Image.URL, "digest", resolved.ManifestDigest,
+		"checksum", resolved.Checksum, "signed", resolved.Signed)
+	return resolved, "", nil
+}
+
 func (p *ironicProvisioner) setUpForProvisioning(ironicNode *nodes.Node, data provisioner.ProvisionData) (result provisioner.Result, err error) {
 
 	p.log.Info("starting provisioning", "node properties", ironicNode.Properties)
 
-	success, result, err := p.tryUpdateNode(ironicNode,
-		p.getUpdateOptsForNode(ironicNode, data))
+	deployData := data
+	var imageReference, downloadSource *string
+	if ociimage.IsReference(data.Image.URL) {
+		resolved, errorMessage, err := p.resolveImage(data)
+		if err != nil {
+			return transientError(err)
+		}
+		if errorMessage != "" {
+			return operationFailed(errorMessage)
+		}
+		deployData.Image = metal3v1alpha1.Image{
+			URL:          resolved.URL,
+			Checksum:     resolved.Checksum,
+			ChecksumType: metal3v1alpha1.ChecksumType(resolved.ChecksumType),
+			DiskFormat:   &resolved.DiskFormat,
+		}
+		reference := ociimage.PinReference(data.Image.URL, resolved.ManifestDigest)
+		imageReference = &reference
+		if resolved.Signed {
+			// The agent only downloads the image once booted, after
+			// signed URLs usually expired. The conductor downloads it
+			// as soon as the deployment starts and serves it instead.
+			local := "local"
+			downloadSource = &local
+		}
+	}
+
+	// The OCI reference is recorded, pinned to the digest of the
+	// manifest, to find whether Ironic has the image, since the URL it
+	// downloads is resolved from it and the tag may move
+	updater := p.getUpdateOptsForNode(ironicNode, deployData)
+	updater.SetInstanceInfoOpts(optionsData{
+		"image_reference":       imageReference,
+		"image_download_source": downloadSource,
+	}, ironicNode)
+	success, result, err := p.tryUpdateNode(ironicNode, updater)
 	if !success {
 		return
 	}
@@ -1023,7 +1223,13 @@ func (p *ironicProvisioner) Adopt(data provisioner.AdoptData, force bool) (resul
 func (p *ironicProvisioner) ironicHasSameImage(ironicNode *nodes.Node, image metal3v1alpha1.Image) (sameImage bool) {
 	// To make it easier to test if ironic is configured with
 	// the same image we are trying to provision to the host.
-	if image.DiskFormat != nil && *image.DiskFormat == "live-iso" {
+	if ociimage.IsReference(image.URL) {
+		sameImage = (ironicNode.InstanceInfo["image_reference"] == image.URL)
+		p.log.Info("checking image settings",
+			"reference", ironicNode.InstanceInfo["image_reference"],
+			"same", sameImage,
+			"provisionState", ironicNode.ProvisionState)
+	} else if image.DiskFormat != nil && *image.DiskFormat == "live-iso" {
 		sameImage = (ironicNode.InstanceInfo["boot_iso"] == image.URL)
 		p.log.Info("checking image settings",
 			"boot_iso", ironicNode.InstanceInfo["boot_iso"],
@@ -1031,9 +1237,14 @@ func (p *ironicProvisioner) ironicHasSameImage(ironicNode *nodes.Node, image met
 			"provisionState", ironicNode.ProvisionState)
 	} else {
 		checksum, checksumType, _ := image.GetChecksum()
-		sameImage = (ironicNode.InstanceInfo["image_source"] == image.URL &&
-			ironicNode.InstanceInfo["image_os_hash_algo"] == checksumType &&
-			ironicNode.InstanceInfo["image_os_hash_value"] == checksum)
+		if checksumType == "" {
+			sameImage = (ironicNode.InstanceInfo["image_source"] == image.URL &&
+				ironicNode.InstanceInfo["image_checksum"] == checksum)
+		} else {
+			sameImage = (ironicNode.InstanceInfo["image_source"] == image.URL &&
+				ironicNode.InstanceInfo["image_os_hash_algo"] == checksumType &&
+				ironicNode.InstanceInfo["image_os_hash_value"] == checksum)
+		}
 		p.log.Info("checking image settings",
 			"source", ironicNode.InstanceInfo["image_source"],
 			"image_os_hash_algo", checksumType,
@@ -1044,22 +1255,36 @@ func (p *ironicProvisioner) ironicHasSameImage(ironicNode *nodes.Node, image met
 	return sameImage
 }
 
-func (p *ironicProvisioner) buildManualCleaningSteps(bmcAccess bmc.AccessDetails, data provisioner.PrepareData) (cleanSteps []nodes.CleanStep, err error) {
-	// Build raid clean steps
-	if bmcAccess.RAIDInterface() != "no-raid" {
-		cleanSteps = append(cleanSteps, BuildRAIDCleanSteps(data.RAIDConfig)...)
-	} else if data.RAIDConfig != nil {
-		return nil, fmt.Errorf("RAID settings are defined, but the node's driver %s does not support RAID", bmcAccess.Driver())
+// buildFirmwareUpdateSteps returns the step flashing the requested
+// firmware updates, if any.
+func buildFirmwareUpdateSteps(bmcAccess bmc.AccessDetails, updates []metal3v1alpha1.FirmwareUpdate) (steps []nodes.CleanStep, err error) {
+	updateArgs, err := bmcAccess.BuildFirmwareUpdateArgs(updates)
+	if err != nil {
+		return nil, err
 	}
+	if len(updateArgs) != 0 {
+		steps = append(
+			steps,
+			nodes.CleanStep{
+				Interface: "management",
+				Step:      firmwareUpdateStep,
+				Args:      updateArgs,
+			},
+		)
+	}
+	return
+}
 
-	// Build bios clean steps
-	settings, err := bmcAccess.BuildBIOSSettings(data.FirmwareConfig)
+// buildBIOSSteps returns the step applying the requested BIOS
+// settings, if any.
+func buildBIOSSteps(bmcAccess bmc.AccessDetails, firmwareConfig *metal3v1alpha1.FirmwareConfig) (steps []nodes.CleanStep, err error) {
+	settings, err := bmcAccess.BuildBIOSSettings(firmwareConfig)
 	if err != nil {
 		return nil, err
 	}
 	if len(settings) != 0 {
-		cleanSteps = append(
-			cleanSteps,
+		steps = append(
+			steps,
 			nodes.CleanStep{
 				Interface: "bios",
 				Step:      "apply_configuration",
@@ -1069,6 +1294,30 @@ func (p *ironicProvisioner) buildManualCleaningSteps(bmcAccess bmc.AccessDetails
 			},
 		)
 	}
+	return
+}
+
+func (p *ironicProvisioner) buildManualCleaningSteps(bmcAccess bmc.AccessDetails, data provisioner.PrepareData) (cleanSteps []nodes.CleanStep, err error) {
+	// Build firmware update clean steps first, as flashing new
+	// firmware may reset the BIOS settings
+	cleanSteps, err = buildFirmwareUpdateSteps(bmcAccess, data.FirmwareUpdates)
+	if err != nil {
+		return nil, err
+	}
+
+	// Build raid clean steps
+	if bmcAccess.RAIDInterface() != "no-raid" {
+		cleanSteps = append(cleanSteps, BuildRAIDCleanSteps(data.RAIDConfig)...)
+	} else if data.RAIDConfig != nil {
+		return nil, fmt.Errorf("RAID settings are defined, but the node's driver %s does not support RAID", bmcAccess.Driver())
+	}
+
+	// Build bios clean steps
+	biosSteps, err := buildBIOSSteps(bmcAccess, data.FirmwareConfig)
+	if err != nil {
+		return nil, err
+	}
+	cleanSteps = append(cleanSteps, biosSteps...)
 
 	// TODO: Add manual cleaning steps for host configuration
 
@@ -1076,6 +1325,11 @@ func (p *ironicProvisioner) buildManualCleaningSteps(bmcAccess bmc.AccessDetails
 }
 
 func (p *ironicProvisioner) startManualCleaning(bmcAccess bmc.AccessDetails, ironicNode *nodes.Node, data provisioner.PrepareData) (success bool, result provisioner.Result, err error) {
+	result, err = p.checkBIOSSettings(data.FirmwareConfig)
+	if err != nil || result.ErrorMessage != "" {
+		return
+	}
+
 	if bmcAccess.RAIDInterface() != "no-raid" {
 		// Set raid configuration
 		err = setTargetRAIDCfg(p, ironicNode, data)
@@ -1164,6 +1418,16 @@ func (p *ironicProvisioner) Prepare(data provisioner.PrepareData, unprepared boo
 		// If unprepared is false, means the settings aren't cleared.
 		// So we can't set the node's state to manageable, until the settings are cleared.
 		if !unprepared {
+			var firmwareFailed bool
+			firmwareFailed, err = p.isFirmwareUpdateFailure(ironicNode)
+			if err != nil {
+				result, err = transientError(err)
+				return
+			}
+			if firmwareFailed {
+				result, err = firmwareUpdateFailed(ironicNode.LastError)
+				return
+			}
 			result, err = operationFailed(ironicNode.LastError)
 			return
 		}
@@ -1266,18 +1530,30 @@ func (p *ironicProvisioner) Provision(data provisioner.ProvisionData) (result pr
 
 	p.log.Info("provisioning image to host", "state", ironicNode.ProvisionState)
 
-	ironicHasSameImage := p.ironicHasSameImage(ironicNode, data.Image)
-
 	// Ironic has the settings it needs, see if it finds any issues
 	// with them.
 	switch nodes.ProvisionState(ironicNode.ProvisionState) {
 
 	case nodes.DeployFail:
+		image := data.Image
+		if ociimage.IsReference(image.URL) {
+			// Compare with the manifest the tag resolves to now, so
+			// that the image is deployed again when the tag moved
+			resolved, errorMessage, err := p.resolveImage(data)
+			if err != nil {
+				return transientError(err)
+			}
+			if errorMessage != "" {
+				return operationFailed(errorMessage)
+			}
+			image.URL = ociimage.PinReference(image.URL, resolved.ManifestDigest)
+		}
+
 		// Since we were here ironic has recorded an error for this host,
 		// with the image and checksum we have been trying to use, so we
 		// should stop. (If the image values do not match, we want to try
 		// again.)
-		if ironicHasSameImage {
+		if p.ironicHasSameImage(ironicNode, image) {
 			// Save me from "eventually consistent" systems built on
 			// top of relational databases...
 			if ironicNode.LastError == "" {
@@ -1349,7 +1625,12 @@ func (p *ironicProvisioner) Provision(data provisioner.ProvisionData) (result pr
 		p.publisher("ProvisioningComplete",
 			fmt.Sprintf("Image provisioning completed for %s", data.Image.URL))
 		p.log.Info("finished provisioning")
-		return operationComplete()
+		result, err = operationComplete()
+		if reference, ok := ironicNode.InstanceInfo["image_reference"].(string); ok &&
+			ociimage.IsPinnedFrom(reference, data.Image.URL) {
+			result.ImageReference = reference
+		}
+		return result, err
 
 	default:
 		// wait states like cleaning and clean wait
@@ -1456,6 +1737,23 @@ func (p *ironicProvisioner) Deprovision(force bool) (result provisioner.Result,
 		// Deploying cannot be stopped, wait for DeployWait or Active
 		return operationContinuing(deprovisionRequeueDelay)
 
+	case nodeServicing, nodeServiceWait:
+		p.log.Info("servicing")
+		// Servicing cannot be interrupted, wait for Active
+		return operationContinuing(deprovisionRequeueDelay)
+
+	case nodeServiceFail:
+		p.log.Info("servicing failed")
+		if ironicNode.Maintenance {
+			p.log.Info("clearing maintenance flag")
+			return p.setMaintenanceFlag(ironicNode, false)
+		}
+		// Return to Active so that the image can be removed
+		return p.changeNodeProvisionState(
+			ironicNode,
+			nodes.ProvisionStateOpts{Target: nodes.TargetAbort},
+		)
+
 	case nodes.Active, nodes.DeployFail, nodes.DeployWait:
 		p.log.Info("starting deprovisioning")
 		p.publisher("DeprovisioningStarted", "Image deprovisioning started")
@@ -1478,6 +1776,9 @@ func (p *ironicProvisioner) Delete() (result provisioner.Result, err error) {
 	if err != nil {
 		if errors.Is(err, provisioner.ErrNeedsRegistration) {
 			p.log.Info("no node found, already deleted")
+			if err = p.removeBMCCACertificate(); err != nil {
+				return transientError(err)
+			}
 			return operationComplete()
 		}
 		return transientError(err)
@@ -1529,6 +1830,10 @@ func (p *ironicProvisioner) Delete() (result provisioner.Result, err error) {
 		return transientError(errors.Wrap(err, "failed to remove host"))
 	}
 
+	if err = p.removeBMCCACertificate(); err != nil {
+		return transientError(err)
+	}
+
 	return operationContinuing(0)
 }
 
@@ -1710,50 +2015,3 @@ func (p *ironicProvisioner) IsReady() (result bool, err error) {
 	checker := newIronicDependenciesChecker(p.client, p.inspector, p.log)
 	return checker.IsReady()
 }
-
-func (p *ironicProvisioner) HasCapacity() (result bool, err error) {
-
-	hosts, err := p.loadBusyHosts()
-	if err != nil {
-		p.log.Error(err, "Unable to get hosts for determining current provisioner capacity")
-		return false, err
-	}
-
-	// If the current host is already under processing then let's skip the test
-	if _, ok := hosts[ironicNodeName(p.objectMeta)]; ok {
-		return true, nil
-	}
-
-	return len(hosts) < p.config.maxBusyHosts, nil
-}
-
-func (p *ironicProvisioner) loadBusyHosts() (hosts map[string]struct{}, err error) {
-
-	hosts = make(map[string]struct{})
-	pager := nodes.List(p.client, nodes.ListOpts{
-		Fields: []string{"uuid,name,provision_state,driver_internal_info,target_provision_state"},
-	})
-
-	page, err := pager.AllPages()
-	if err != nil {
-		return nil, err
-	}
-
-	allNodes, err := nodes.ExtractNodes(page)
-	if err != nil {
-		return nil, err
-	}
-
-	for _, node := range allNodes {
-
-		switch nodes.ProvisionState(node.ProvisionState) {
-		case nodes.Cleaning, nodes.CleanWait,
-			nodes.Inspecting, nodes.InspectWait,
-			nodes.Deploying, nodes.DeployWait,
-			nodes.Deleting:
-			hosts[node.Name] = struct{}{}
-		}
-	}
-
-	return hosts, nil
-}
@@ -10,6 +10,7 @@ import (
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/deployimages"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
 
@@ -30,8 +31,9 @@ func newTestProvisionerFactory() ironicProvisionerFactory {
 			deployKernelURL:  "http://deploy.test/ipa.kernel",
 			deployRamdiskURL: "http://deploy.test/ipa.initramfs",
 			deployISOURL:     "http://deploy.test/ipa.iso",
-			maxBusyHosts:     20,
+			capacity:         capacityPolicy{maxBusyHosts: 20},
 		},
+		clients: &ironicClients{},
 	}
 }
 
@@ -52,8 +54,7 @@ func newProvisionerWithSettings(host metal3v1alpha1.BareMetalHost, bmcCreds bmc.
 	}
 
 	factory := newTestProvisionerFactory()
-	factory.clientIronic = clientIronic
-	factory.clientInspector = clientInspector
+	factory.clients = &ironicClients{ironic: clientIronic, inspector: clientInspector}
 	return factory.ironicProvisioner(hostData, publisher)
 }
 
@@ -143,3 +144,77 @@ func TestNewNoBMCDetails(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.NotEqual(t, nil, prov)
 }
+
+func TestPreprovisioningImageFormats(t *testing.T) {
+	perArchRamdisks := deployimages.ByArch{
+		"x86_64": {
+			KernelURL:  "http://deploy.test/ipa-x86_64.kernel",
+			RamdiskURL: "http://deploy.test/ipa-x86_64.initramfs",
+		},
+		"aarch64": {
+			KernelURL:  "http://deploy.test/ipa-aarch64.kernel",
+			RamdiskURL: "http://deploy.test/ipa-aarch64.initramfs",
+		},
+	}
+	testCases := []struct {
+		Scenario      string
+		Address       string
+		DefaultImages bool
+		ByArch        deployimages.ByArch
+		Expected      []metal3v1alpha1.ImageFormat
+	}{
+		{
+			Scenario:      "PXE with default ramdisk",
+			Address:       "ipmi://192.168.122.1:6233",
+			DefaultImages: true,
+		},
+		{
+			Scenario: "PXE with per-architecture ramdisks only",
+			Address:  "ipmi://192.168.122.1:6233",
+			ByArch:   perArchRamdisks,
+		},
+		{
+			Scenario: "PXE with a missing ramdisk",
+			Address:  "ipmi://192.168.122.1:6233",
+			ByArch: deployimages.ByArch{
+				"x86_64":  perArchRamdisks["x86_64"],
+				"aarch64": {KernelURL: "http://deploy.test/ipa-aarch64.kernel", ISOURL: "http://deploy.test/ipa-aarch64.iso"},
+			},
+			Expected: []metal3v1alpha1.ImageFormat{metal3v1alpha1.ImageFormatInitRD},
+		},
+		{
+			Scenario: "virtual media with per-architecture ramdisks only",
+			Address:  "redfish-virtualmedia://192.168.122.1/redfish/v1/Systems/1",
+			ByArch:   perArchRamdisks,
+			Expected: []metal3v1alpha1.ImageFormat{metal3v1alpha1.ImageFormatISO, metal3v1alpha1.ImageFormatInitRD},
+		},
+		{
+			Scenario:      "virtual media with default images",
+			Address:       "redfish-virtualmedia://192.168.122.1/redfish/v1/Systems/1",
+			DefaultImages: true,
+			Expected:      []metal3v1alpha1.ImageFormat{metal3v1alpha1.ImageFormatISO, metal3v1alpha1.ImageFormatInitRD},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			host := makeHost()
+			host.Spec.BMC.Address = tc.Address
+			factory := newTestProvisionerFactory()
+			factory.config.havePreprovImgBuilder = true
+			if !tc.DefaultImages {
+				factory.config.deployKernelURL = ""
+				factory.config.deployRamdiskURL = ""
+				factory.config.deployISOURL = ""
+			}
+			factory.config.deployImagesByArch = tc.ByArch
+			prov, err := factory.ironicProvisioner(provisioner.BuildHostData(host, bmc.Credentials{}), nullEventPublisher)
+			if err != nil {
+				t.Fatalf("could not create provisioner: %s", err)
+			}
+
+			formats, err := prov.PreprovisioningImageFormats()
+			assert.NoError(t, err)
+			assert.Equal(t, tc.Expected, formats)
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package ironic
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// powerWatchInterval is how often the nodes are listed to find the
+// ones whose power state changed.
+var powerWatchInterval = 10 * time.Second
+
+// nodePowerStates holds the last power state seen of each node of a
+// backend, by node UUID.
+type nodePowerStates map[string]string
+
+// hostForNode returns the host a node was registered for, from the
+// name of the node.
+func hostForNode(nodeName string) (name types.NamespacedName, ok bool) {
+	parts := strings.SplitN(nodeName, nameSeparator, 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return name, false
+	}
+	return types.NamespacedName{Namespace: parts[0], Name: parts[1]}, true
+}
+
+// pollPowerStates lists the nodes of a backend and calls changed with
+// the hosts of the nodes whose power state changed since the last
+// listing. Nodes seen for the first time are only recorded.
+func pollPowerStates(client *gophercloud.ServiceClient, states nodePowerStates, changed func(types.NamespacedName)) error {
+	page, err := nodes.List(client, nodes.ListOpts{
+		Fields: []string{"uuid,name,power_state"},
+	}).AllPages()
+	if err != nil {
+		return err
+	}
+	allNodes, err := nodes.ExtractNodes(page)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(allNodes))
+	for _, node := range allNodes {
+		seen[node.UUID] = true
+		previous, known := states[node.UUID]
+		states[node.UUID] = node.PowerState
+		if !known || previous == node.PowerState {
+			continue
+		}
+		if host, ok := hostForNode(node.Name); ok {
+			changed(host)
+		}
+	}
+	for uuid := range states {
+		if !seen[uuid] {
+			delete(states, uuid)
+		}
+	}
+	return nil
+}
+
+// WatchPowerState lists the nodes of all backends regularly, and
+// reports the hosts whose power state changed until the context is
+// done. A single listing per backend replaces looking up each node.
+func (f ironicProvisionerFactory) WatchPowerState(ctx context.Context, changed func(types.NamespacedName)) error {
+	type watchedBackend struct {
+		name    string
+		clients *ironicClients
+		states  nodePowerStates
+	}
+	var watched []watchedBackend
+	if f.clients != nil && !f.noDefaultBackend {
+		watched = append(watched, watchedBackend{clients: f.clients, states: nodePowerStates{}})
+	}
+	for _, backend := range f.backends {
+		watched = append(watched, watchedBackend{name: backend.name, clients: backend.clients, states: nodePowerStates{}})
+	}
+
+	f.log.Info("watching power states", "interval", powerWatchInterval)
+	ticker := time.NewTicker(powerWatchInterval)
+	defer ticker.Stop()
+	for {
+		for _, w := range watched {
+			log := f.log
+			if w.name != "" {
+				log = log.WithValues("backend", w.name)
+			}
+			clientIronic, _, err := w.clients.get()
+			if err == nil {
+				err = pollPowerStates(clientIronic, w.states, changed)
+			}
+			if err != nil {
+				log.Error(err, "failed to list the power states of the nodes")
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
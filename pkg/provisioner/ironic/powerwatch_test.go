@@ -0,0 +1,108 @@
+package ironic
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/stretchr/testify/assert"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/testserver"
+)
+
+func TestHostForNode(t *testing.T) {
+	cases := []struct {
+		nodeName     string
+		expectedHost types.NamespacedName
+		expectedOK   bool
+	}{
+		{
+			nodeName:     "myns" + nameSeparator + "myhost",
+			expectedHost: types.NamespacedName{Namespace: "myns", Name: "myhost"},
+			expectedOK:   true,
+		},
+		{
+			nodeName: "myhost",
+		},
+		{
+			nodeName: nameSeparator + "myhost",
+		},
+		{
+			nodeName: "",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.nodeName, func(t *testing.T) {
+			host, ok := hostForNode(tc.nodeName)
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedHost, host)
+		})
+	}
+}
+
+func TestPollPowerStates(t *testing.T) {
+	ironic := testserver.NewIronic(t).Nodes([]nodes.Node{
+		{UUID: "uuid-0", Name: "myns" + nameSeparator + "host-0", PowerState: "power on"},
+		{UUID: "uuid-1", Name: "myns" + nameSeparator + "host-1", PowerState: "power off"},
+		{UUID: "uuid-2", Name: "myns" + nameSeparator + "host-2", PowerState: "power on"},
+		{UUID: "uuid-3", Name: "unknown", PowerState: "power on"},
+	}).Start()
+	defer ironic.Stop()
+
+	client, err := clients.IronicClient(ironic.Endpoint(), clients.AuthConfig{Type: clients.NoAuth}, clients.TLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	states := nodePowerStates{
+		"uuid-0": "power on",
+		"uuid-1": "power on",
+		"uuid-3": "power off",
+		"uuid-9": "power on",
+	}
+	var changed []types.NamespacedName
+	err = pollPowerStates(client, states, func(host types.NamespacedName) {
+		changed = append(changed, host)
+	})
+	assert.NoError(t, err)
+
+	// New nodes are only recorded, and nodes that are not named after
+	// a host are ignored
+	assert.Equal(t, []types.NamespacedName{{Namespace: "myns", Name: "host-1"}}, changed)
+	assert.Equal(t, nodePowerStates{
+		"uuid-0": "power on",
+		"uuid-1": "power off",
+		"uuid-2": "power on",
+		"uuid-3": "power on",
+	}, states)
+}
+
+func TestWatchPowerStateStops(t *testing.T) {
+	ironic := testserver.NewIronic(t).Nodes([]nodes.Node{}).Start()
+	defer ironic.Stop()
+
+	client, err := clients.IronicClient(ironic.Endpoint(), clients.AuthConfig{Type: clients.NoAuth}, clients.TLSConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	factory := newTestProvisionerFactory()
+	factory.clients = &ironicClients{ironic: client, inspector: client}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	done := make(chan error)
+	go func() {
+		done <- factory.WatchPowerState(ctx, func(types.NamespacedName) {})
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the watcher did not stop")
+	}
+	assert.NotEmpty(t, ironic.Requests)
+}
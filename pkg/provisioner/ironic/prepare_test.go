@@ -30,9 +30,13 @@ func (r *RAIDTestBMC) PowerInterface() string                                { r
 func (r *RAIDTestBMC) RAIDInterface() string                                 { return "" }
 func (r *RAIDTestBMC) VendorInterface() string                               { return "" }
 func (r *RAIDTestBMC) SupportsSecureBoot() bool                              { return false }
+func (r *RAIDTestBMC) SupportsCACertificate() bool                           { return false }
 func (r *RAIDTestBMC) BuildBIOSSettings(fwConf *metal3v1alpha1.FirmwareConfig) ([]map[string]string, error) {
 	return nil, nil
 }
+func (r *RAIDTestBMC) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (map[string]interface{}, error) {
+	return nil, nil
+}
 
 func TestPrepare(t *testing.T) {
 	bmc.RegisterFactory("raid-test", func(u *url.URL, dcv bool) (bmc.AccessDetails, error) {
@@ -1,6 +1,8 @@
 package ironic
 
 import (
+	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -10,6 +12,7 @@ import (
 
 	"github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/ociimage"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/fixture"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
@@ -392,3 +395,132 @@ func TestIronicHasSameImage(t *testing.T) {
 		})
 	}
 }
+
+type fakeImageResolver struct {
+	image *ociimage.Image
+	err   error
+	// the arguments of the last call
+	imageURL, arch, diskFormat, dockerConfig string
+}
+
+func (r *fakeImageResolver) Resolve(ctx context.Context, imageURL, arch, diskFormat, dockerConfig string) (*ociimage.Image, error) {
+	r.imageURL, r.arch, r.diskFormat, r.dockerConfig = imageURL, arch, diskFormat, dockerConfig
+	return r.image, r.err
+}
+
+func TestProvisionOCIImage(t *testing.T) {
+	nodeUUID := "33ce8659-7400-4c68-9535-d10766f07a58"
+	reference := "oci://quay.io/example/os:1.0"
+	resolved := &ociimage.Image{
+		URL:            "https://storage.test/signed/blob",
+		Signed:         true,
+		ManifestDigest: "sha256:60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752",
+		Checksum:       "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+		ChecksumType:   "sha256",
+		DiskFormat:     "qcow2",
+	}
+	pinned := reference + "@" + resolved.ManifestDigest
+
+	cases := []struct {
+		name                   string
+		provisionState         nodes.ProvisionState
+		instanceInfo           map[string]interface{}
+		resolveErr             error
+		expectedResolved       bool
+		expectedErrorMessage   string
+		expectedImageReference string
+	}{
+		{
+			name:             "available state",
+			provisionState:   nodes.Available,
+			expectedResolved: true,
+		},
+		{
+			name:                 "resolution failure",
+			provisionState:       nodes.Available,
+			resolveErr:           fmt.Errorf("no image for the aarch64 architecture"),
+			expectedErrorMessage: "Failed to resolve image oci://quay.io/example/os:1.0: no image for the aarch64 architecture",
+		},
+		{
+			name:                 "deployFail state with the same image",
+			provisionState:       nodes.DeployFail,
+			instanceInfo:         map[string]interface{}{"image_reference": pinned},
+			expectedErrorMessage: "Image provisioning failed: boom",
+		},
+		{
+			name:             "deployFail state with another image",
+			provisionState:   nodes.DeployFail,
+			instanceInfo:     map[string]interface{}{"image_reference": "oci://quay.io/example/os:0.9"},
+			expectedResolved: true,
+		},
+		{
+			name:           "deployFail state with a moved tag",
+			provisionState: nodes.DeployFail,
+			instanceInfo: map[string]interface{}{
+				"image_reference": reference + "@sha256:9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+			},
+			expectedResolved: true,
+		},
+		{
+			name:                   "active state",
+			provisionState:         nodes.Active,
+			instanceInfo:           map[string]interface{}{"image_reference": pinned},
+			expectedImageReference: pinned,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ironic := testserver.NewIronic(t).WithDefaultResponses().Node(nodes.Node{
+				ProvisionState: string(tc.provisionState),
+				UUID:           nodeUUID,
+				InstanceInfo:   tc.instanceInfo,
+				LastError:      "boom",
+			})
+			ironic.Start()
+			defer ironic.Stop()
+
+			host := makeHost()
+			host.Status.Provisioning.ID = nodeUUID
+			auth := clients.AuthConfig{Type: clients.NoAuth}
+			prov, err := newProvisionerWithSettings(host, bmc.Credentials{}, nullEventPublisher,
+				ironic.Endpoint(), auth, testserver.NewInspector(t).Endpoint(), auth,
+			)
+			if err != nil {
+				t.Fatalf("could not create provisioner: %s", err)
+			}
+			resolver := &fakeImageResolver{image: resolved, err: tc.resolveErr}
+			prov.imageResolver = resolver
+
+			result, err := prov.Provision(provisioner.ProvisionData{
+				Image:           v1alpha1.Image{URL: reference},
+				HostConfig:      fixture.NewHostConfigData("", "", ""),
+				BootMode:        v1alpha1.DefaultBootMode,
+				CPUArchitecture: "aarch64",
+			})
+			assert.NoError(t, err)
+			if tc.expectedErrorMessage != "" {
+				assert.Equal(t, tc.expectedErrorMessage, result.ErrorMessage)
+			}
+			assert.Equal(t, tc.expectedImageReference, result.ImageReference)
+			if !tc.expectedResolved {
+				return
+			}
+
+			assert.Equal(t, reference, resolver.imageURL)
+			assert.Equal(t, "aarch64", resolver.arch)
+			values := map[string]interface{}{}
+			for _, update := range ironic.GetLastNodeUpdateRequestFor(nodeUUID) {
+				values[update.Path] = update.Value
+			}
+			assert.Equal(t, resolved.URL, values["/instance_info/image_source"])
+			assert.Equal(t, resolved.Checksum, values["/instance_info/image_os_hash_value"])
+			assert.Equal(t, "sha256", values["/instance_info/image_os_hash_algo"])
+			assert.Equal(t, "qcow2", values["/instance_info/image_disk_format"])
+			assert.Equal(t, pinned, values["/instance_info/image_reference"])
+			// The conductor downloads the image before the signed URL
+			// expires
+			assert.Equal(t, "local", values["/instance_info/image_download_source"])
+		})
+	}
+}
@@ -0,0 +1,181 @@
+package ironic
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+// hostOperation is a class of operation that occupies a provisioning
+// slot while it is running.
+type hostOperation string
+
+const (
+	operationInspection     hostOperation = "inspection"
+	operationProvisioning   hostOperation = "provisioning"
+	operationDeprovisioning hostOperation = "deprovisioning"
+)
+
+// operationForState returns the operation a host starts when it
+// enters the given state, or an empty string for states that are only
+// subject to the overall limits.
+func operationForState(state metal3v1alpha1.ProvisioningState) hostOperation {
+	switch state {
+	case metal3v1alpha1.StateInspecting:
+		return operationInspection
+	case metal3v1alpha1.StateProvisioning:
+		return operationProvisioning
+	case metal3v1alpha1.StateDeprovisioning, metal3v1alpha1.StateDeleting:
+		return operationDeprovisioning
+	}
+	return ""
+}
+
+// operationForNode returns the operation an Ironic node is busy with.
+// The second return value is false if the node is not busy.
+func operationForNode(node nodes.Node) (hostOperation, bool) {
+	switch nodes.ProvisionState(node.ProvisionState) {
+	case nodes.Inspecting, nodes.InspectWait:
+		return operationInspection, true
+	case nodes.Deploying, nodes.DeployWait:
+		return operationProvisioning, true
+	case nodes.Cleaning, nodes.CleanWait:
+		// Manual cleaning is used to prepare the host for
+		// provisioning, automated cleaning to deprovision it.
+		if node.TargetProvisionState == string(nodes.Manageable) {
+			return operationProvisioning, true
+		}
+		return operationDeprovisioning, true
+	case nodes.Deleting:
+		return operationDeprovisioning, true
+	}
+	return "", false
+}
+
+// capacityPolicy holds the limits on the number of hosts the
+// provisioner works on simultaneously.
+type capacityPolicy struct {
+	// the maximum number of busy hosts overall
+	maxBusyHosts int
+	// the maximum number of hosts busy with each operation, where
+	// limited
+	maxBusyHostsPerOperation map[hostOperation]int
+	// the maximum number of busy hosts in a single namespace, or 0
+	// for no limit
+	maxBusyHostsPerNamespace int
+	// the label marking hosts that take priority
+	priorityLabel string
+	// the number of slots only usable by hosts with the priority
+	// label
+	priorityReservedHosts int
+}
+
+// busyHosts maps the names of the busy nodes to their operation
+type busyHosts map[string]hostOperation
+
+func (b busyHosts) countOperation(operation hostOperation) (count int) {
+	for _, op := range b {
+		if op == operation {
+			count++
+		}
+	}
+	return
+}
+
+func (b busyHosts) countNamespace(namespace string) (count int) {
+	prefix := namespace + nameSeparator
+	for name := range b {
+		if strings.HasPrefix(name, prefix) {
+			count++
+		}
+	}
+	return
+}
+
+func (p *ironicProvisioner) hasPriority() bool {
+	if p.config.capacity.priorityLabel == "" {
+		return false
+	}
+	_, ok := p.objectMeta.Labels[p.config.capacity.priorityLabel]
+	return ok
+}
+
+// checkCapacity applies the capacity policy to the current host,
+// returning the reason if it has to wait.
+func (p *ironicProvisioner) checkCapacity(hosts busyHosts, operation hostOperation) (reason string) {
+	policy := p.config.capacity
+	priority := p.hasPriority()
+
+	limit := policy.maxBusyHosts
+	if !priority {
+		limit -= policy.priorityReservedHosts
+	}
+	if len(hosts) >= limit {
+		if limit < policy.maxBusyHosts {
+			return fmt.Sprintf("%d hosts are busy and the remaining %d slots are reserved for hosts labelled %s",
+				len(hosts), policy.priorityReservedHosts, policy.priorityLabel)
+		}
+		return fmt.Sprintf("%d hosts are busy, the limit is %d", len(hosts), limit)
+	}
+
+	if opLimit, ok := policy.maxBusyHostsPerOperation[operation]; ok {
+		if count := hosts.countOperation(operation); count >= opLimit {
+			return fmt.Sprintf("%d hosts are busy with %s, the limit is %d", count, operation, opLimit)
+		}
+	}
+
+	if !priority && policy.maxBusyHostsPerNamespace > 0 {
+		namespace := p.objectMeta.Namespace
+		if count := hosts.countNamespace(namespace); count >= policy.maxBusyHostsPerNamespace {
+			return fmt.Sprintf("%d hosts in namespace %s are busy, the limit is %d",
+				count, namespace, policy.maxBusyHostsPerNamespace)
+		}
+	}
+
+	return ""
+}
+
+func (p *ironicProvisioner) HasCapacity(state metal3v1alpha1.ProvisioningState) (result bool, reason string, err error) {
+	hosts, err := p.loadBusyHosts()
+	if err != nil {
+		p.log.Error(err, "Unable to get hosts for determining current provisioner capacity")
+		return false, "", err
+	}
+
+	// If the current host is already under processing then let's skip the test
+	if _, ok := hosts[ironicNodeName(p.objectMeta)]; ok {
+		return true, "", nil
+	}
+
+	reason = p.checkCapacity(hosts, operationForState(state))
+	return reason == "", reason, nil
+}
+
+func (p *ironicProvisioner) loadBusyHosts() (hosts busyHosts, err error) {
+
+	hosts = make(busyHosts)
+	pager := nodes.List(p.client, nodes.ListOpts{
+		Fields: []string{"uuid,name,provision_state,driver_internal_info,target_provision_state"},
+	})
+
+	page, err := pager.AllPages()
+	if err != nil {
+		return nil, err
+	}
+
+	allNodes, err := nodes.ExtractNodes(page)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, node := range allNodes {
+		if operation, busy := operationForNode(node); busy {
+			hosts[node.Name] = operation
+		}
+	}
+
+	return hosts, nil
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
 	"github.com/stretchr/testify/assert"
 
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/metal3-io/baremetal-operator/pkg/bmc"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/testserver"
@@ -22,10 +23,15 @@ func TestHasCapacity(t *testing.T) {
 	cases := []struct {
 		name              string
 		provisioningLimit int
+		policy            capacityPolicy
 		nodeStates        []nodes.ProvisionState
+		otherNamespace    bool
 		hostName          string
+		hostLabels        map[string]string
+		hostState         metal3v1alpha1.ProvisioningState
 
 		expectedHasCapacity bool
+		expectedReason      string
 		expectedError       string
 	}{
 		{
@@ -34,6 +40,7 @@ func TestHasCapacity(t *testing.T) {
 			nodeStates:        states,
 
 			expectedHasCapacity: false,
+			expectedReason:      "7 hosts are busy, the limit is 7",
 		},
 		{
 			name:              "enough-capacity",
@@ -55,6 +62,118 @@ func TestHasCapacity(t *testing.T) {
 			provisioningLimit: 1,
 			nodeStates:        []nodes.ProvisionState{nodes.Active, nodes.AdoptFail, nodes.Adopting, nodes.Available, nodes.CleanFail},
 
+			expectedHasCapacity: true,
+		},
+		{
+			name:              "overall-limit-for-other-states",
+			provisioningLimit: len(states),
+			policy: capacityPolicy{
+				maxBusyHostsPerOperation: map[hostOperation]int{operationProvisioning: 1},
+			},
+			nodeStates: states,
+			hostState:  metal3v1alpha1.StateReady,
+
+			expectedHasCapacity: false,
+			expectedReason:      "7 hosts are busy, the limit is 7",
+		},
+		{
+			name:              "operation-limit-not-for-other-states",
+			provisioningLimit: 20,
+			policy: capacityPolicy{
+				maxBusyHostsPerOperation: map[hostOperation]int{operationProvisioning: 1},
+			},
+			nodeStates: states,
+			hostState:  metal3v1alpha1.StateReady,
+
+			expectedHasCapacity: true,
+		},
+		{
+			name:              "operation-limit-reached",
+			provisioningLimit: 20,
+			policy: capacityPolicy{
+				maxBusyHostsPerOperation: map[hostOperation]int{operationInspection: 2},
+			},
+			nodeStates: states,
+			hostState:  metal3v1alpha1.StateInspecting,
+
+			expectedHasCapacity: false,
+			expectedReason:      "2 hosts are busy with inspection, the limit is 2",
+		},
+		{
+			name:              "other-operation-limited",
+			provisioningLimit: 20,
+			policy: capacityPolicy{
+				maxBusyHostsPerOperation: map[hostOperation]int{operationInspection: 2},
+			},
+			nodeStates: states,
+			hostState:  metal3v1alpha1.StateDeprovisioning,
+
+			expectedHasCapacity: true,
+		},
+		{
+			name:              "deprovisioning-limit-reached",
+			provisioningLimit: 20,
+			policy: capacityPolicy{
+				maxBusyHostsPerOperation: map[hostOperation]int{operationDeprovisioning: 3},
+			},
+			nodeStates: states,
+			hostState:  metal3v1alpha1.StateDeleting,
+
+			expectedHasCapacity: false,
+			expectedReason:      "3 hosts are busy with deprovisioning, the limit is 3",
+		},
+		{
+			name:              "namespace-limit-reached",
+			provisioningLimit: 20,
+			policy:            capacityPolicy{maxBusyHostsPerNamespace: len(states)},
+			nodeStates:        states,
+
+			expectedHasCapacity: false,
+			expectedReason:      "7 hosts in namespace myns are busy, the limit is 7",
+		},
+		{
+			name:              "namespace-limit-other-namespace",
+			provisioningLimit: 20,
+			policy:            capacityPolicy{maxBusyHostsPerNamespace: len(states)},
+			nodeStates:        states,
+			otherNamespace:    true,
+
+			expectedHasCapacity: true,
+		},
+		{
+			name:              "namespace-limit-priority",
+			provisioningLimit: 20,
+			policy: capacityPolicy{
+				maxBusyHostsPerNamespace: len(states),
+				priorityLabel:            "example.com/urgent",
+			},
+			nodeStates: states,
+			hostLabels: map[string]string{"example.com/urgent": ""},
+
+			expectedHasCapacity: true,
+		},
+		{
+			name:              "reserved-for-priority",
+			provisioningLimit: len(states) + 1,
+			policy: capacityPolicy{
+				priorityLabel:         "example.com/urgent",
+				priorityReservedHosts: 1,
+			},
+			nodeStates: states,
+
+			expectedHasCapacity: false,
+			expectedReason:      "7 hosts are busy and the remaining 1 slots are reserved for hosts labelled example.com/urgent",
+		},
+		{
+			name:              "reserved-priority-host",
+			provisioningLimit: len(states) + 1,
+			policy: capacityPolicy{
+				priorityLabel:         "example.com/urgent",
+				priorityReservedHosts: 1,
+			},
+			nodeStates: states,
+			hostLabels: map[string]string{"example.com/urgent": "true"},
+
 			expectedHasCapacity: true,
 		},
 	}
@@ -62,10 +181,14 @@ func TestHasCapacity(t *testing.T) {
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
 
+			namespace := "myns"
+			if tc.otherNamespace {
+				namespace = "otherns"
+			}
 			allNodes := []nodes.Node{}
 			for n, state := range tc.nodeStates {
 				allNodes = append(allNodes, nodes.Node{
-					Name:           fmt.Sprintf("myns%snode-%d", nameSeparator, n),
+					Name:           fmt.Sprintf("%s%snode-%d", namespace, nameSeparator, n),
 					ProvisionState: string(state),
 				})
 			}
@@ -78,6 +201,7 @@ func TestHasCapacity(t *testing.T) {
 
 			host := makeHost()
 			host.Name = tc.hostName
+			host.Labels = tc.hostLabels
 
 			auth := clients.AuthConfig{Type: clients.NoAuth}
 
@@ -87,11 +211,17 @@ func TestHasCapacity(t *testing.T) {
 			if err != nil {
 				t.Fatalf("could not create provisioner: %s", err)
 			}
-			prov.config.maxBusyHosts = tc.provisioningLimit
+			prov.config.capacity = tc.policy
+			prov.config.capacity.maxBusyHosts = tc.provisioningLimit
 
-			result, err := prov.HasCapacity()
+			hostState := tc.hostState
+			if hostState == "" {
+				hostState = metal3v1alpha1.StateProvisioning
+			}
+			result, reason, err := prov.HasCapacity(hostState)
 
 			assert.Equal(t, tc.expectedHasCapacity, result)
+			assert.Equal(t, tc.expectedReason, reason)
 
 			if tc.expectedError == "" {
 				assert.NoError(t, err)
@@ -102,3 +232,34 @@ func TestHasCapacity(t *testing.T) {
 		})
 	}
 }
+
+func TestOperationForNode(t *testing.T) {
+	cases := []struct {
+		state             nodes.ProvisionState
+		target            nodes.ProvisionState
+		expectedOperation hostOperation
+		expectedBusy      bool
+	}{
+		{state: nodes.Inspecting, expectedOperation: operationInspection, expectedBusy: true},
+		{state: nodes.InspectWait, expectedOperation: operationInspection, expectedBusy: true},
+		{state: nodes.Deploying, expectedOperation: operationProvisioning, expectedBusy: true},
+		{state: nodes.DeployWait, expectedOperation: operationProvisioning, expectedBusy: true},
+		{state: nodes.Cleaning, target: nodes.Manageable, expectedOperation: operationProvisioning, expectedBusy: true},
+		{state: nodes.CleanWait, target: nodes.Manageable, expectedOperation: operationProvisioning, expectedBusy: true},
+		{state: nodes.Cleaning, target: nodes.Available, expectedOperation: operationDeprovisioning, expectedBusy: true},
+		{state: nodes.Deleting, target: nodes.Available, expectedOperation: operationDeprovisioning, expectedBusy: true},
+		{state: nodes.Active},
+		{state: nodes.CleanFail},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.state)+"-"+string(tc.target), func(t *testing.T) {
+			operation, busy := operationForNode(nodes.Node{
+				ProvisionState:       string(tc.state),
+				TargetProvisionState: string(tc.target),
+			})
+			assert.Equal(t, tc.expectedOperation, operation)
+			assert.Equal(t, tc.expectedBusy, busy)
+		})
+	}
+}
@@ -0,0 +1,280 @@
+package ironic
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
+)
+
+// clientReloadInterval is how often the auth and TLS files are checked
+// for changes.
+var clientReloadInterval = 30 * time.Second
+
+// clientRetryMaxDelay is the longest delay between attempts to build
+// clients that failed, while their files are unchanged.
+var clientRetryMaxDelay = 10 * time.Minute
+
+var clientReloadFailed = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "metal3_provisioner_client_reload_failed",
+	Help: "Whether the Ironic clients of a backend could not be rebuilt from their current files",
+}, []string{"backend"})
+
+func init() {
+	metrics.Registry.MustRegister(clientReloadFailed)
+}
+
+// clientBuilder creates the Ironic and Inspector clients from the
+// current content of the auth and TLS files.
+type clientBuilder func() (ironic, inspector *gophercloud.ServiceClient, err error)
+
+// ironicClients holds the clients of a backend. They are rebuilt when
+// the files they were created from change, so that rotated certificates
+// and passwords are used without restarting the operator.
+type ironicClients struct {
+	lock      sync.RWMutex
+	ironic    *gophercloud.ServiceClient
+	inspector *gophercloud.ServiceClient
+	// The last failure to build the clients
+	err error
+	// When to retry building the clients after a failure, and the
+	// delay before the retry after that
+	retryAt    time.Time
+	retryDelay time.Duration
+
+	build clientBuilder
+	// The files and directories the clients are built from
+	watched  []string
+	checksum string
+}
+
+// newIronicClients builds the clients. A failure is recorded rather
+// than returned, and is reported until the files are fixed.
+func newIronicClients(build clientBuilder, watched []string) *ironicClients {
+	c := &ironicClients{
+		build:   build,
+		watched: watched,
+	}
+	c.checksum = watchedChecksum(watched)
+	c.ironic, c.inspector, c.err = build()
+	c.scheduleRetry()
+	return c
+}
+
+// scheduleRetry sets when building the clients is retried after a
+// failure, doubling the delay after each one. Failures may be
+// transient, such as the Keystone catalog being unavailable, so they
+// are retried even if the files do not change.
+func (c *ironicClients) scheduleRetry() {
+	if c.err == nil {
+		c.retryAt, c.retryDelay = time.Time{}, 0
+		return
+	}
+	if c.retryDelay == 0 {
+		c.retryDelay = clientReloadInterval
+	}
+	c.retryAt = time.Now().Add(c.retryDelay)
+	c.retryDelay *= 2
+	if c.retryDelay > clientRetryMaxDelay {
+		c.retryDelay = clientRetryMaxDelay
+	}
+}
+
+// get returns the current clients. The clients built last are kept
+// when rebuilding them fails, so an error is only returned if they
+// were never built.
+func (c *ironicClients) get() (ironic, inspector *gophercloud.ServiceClient, err error) {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	if c.ironic == nil || c.inspector == nil {
+		return nil, nil, c.err
+	}
+	return c.ironic, c.inspector, nil
+}
+
+// lastError returns the last failure to build the clients.
+func (c *ironicClients) lastError() error {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+	return c.err
+}
+
+// reload rebuilds the clients if the watched files changed, or if
+// building them failed last time and the retry is due.
+func (c *ironicClients) reload() (changed bool, err error) {
+	if c.build == nil {
+		return false, nil
+	}
+	checksum := watchedChecksum(c.watched)
+	if checksum == c.checksum {
+		if c.lastError() == nil || time.Now().Before(c.retryAt) {
+			return false, nil
+		}
+	} else {
+		// Try new files straight away
+		c.retryDelay = 0
+	}
+	c.checksum = checksum
+
+	ironic, inspector, err := c.build()
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.err = err
+	if err == nil {
+		c.ironic, c.inspector = ironic, inspector
+	}
+	c.scheduleRetry()
+	return true, err
+}
+
+// tlsFiles returns the files a TLS configuration is loaded from.
+func tlsFiles(tlsConf clients.TLSConfig) []string {
+	return []string{
+		tlsConf.TrustedCAFile,
+		tlsConf.ClientCertificateFile,
+		tlsConf.ClientPrivateKeyFile,
+	}
+}
+
+// authDirs returns the directories the auth configuration is loaded
+// from.
+func authDirs(root string) []string {
+	return []string{
+		filepath.Join(root, "ironic"),
+		filepath.Join(root, "ironic-inspector"),
+	}
+}
+
+// watchedChecksum returns a checksum of the content of files and
+// directories. Hidden entries are skipped in directories, as secrets
+// mounted in a pod are symlinks to hidden directories swapped on
+// update.
+func watchedChecksum(paths []string) string {
+	hash := sha256.New()
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		fmt.Fprintf(hash, "%s\x00", path)
+
+		info, err := os.Stat(path)
+		if err != nil {
+			fmt.Fprintf(hash, "%s\x00", err)
+			continue
+		}
+		if !info.IsDir() {
+			hashFile(hash, path)
+			continue
+		}
+
+		entries, err := ioutil.ReadDir(path)
+		if err != nil {
+			fmt.Fprintf(hash, "%s\x00", err)
+			continue
+		}
+		names := []string{}
+		for _, entry := range entries {
+			if !strings.HasPrefix(entry.Name(), ".") {
+				names = append(names, entry.Name())
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Fprintf(hash, "%s\x00", name)
+			hashFile(hash, filepath.Join(path, name))
+		}
+	}
+	return fmt.Sprintf("%x", hash.Sum(nil))
+}
+
+func hashFile(hash io.Writer, path string) {
+	content, err := ioutil.ReadFile(filepath.Clean(path))
+	if err != nil {
+		fmt.Fprintf(hash, "%s\x00", err)
+		return
+	}
+	hash.Write(content)
+	hash.Write([]byte{0})
+}
+
+// reloadClients rebuilds the clients of all backends whose files
+// changed. Failures are logged and reported through a metric, while
+// the previous clients are kept in use.
+func (f ironicProvisionerFactory) reloadClients() {
+	reload := func(name string, c *ironicClients) {
+		log := f.log
+		if name != "" {
+			log = log.WithValues("backend", name)
+		}
+		changed, err := c.reload()
+		switch {
+		case err != nil:
+			log.Error(err, "failed to reload ironic clients")
+		case changed:
+			log.Info("reloaded ironic clients")
+		}
+		failed := 0.0
+		if c.lastError() != nil {
+			failed = 1
+		}
+		clientReloadFailed.WithLabelValues(name).Set(failed)
+	}
+
+	if f.clients != nil {
+		reload("", f.clients)
+	}
+	for _, backend := range f.backends {
+		reload(backend.name, backend.clients)
+	}
+}
+
+// Start watches the auth and TLS files of the backends until the
+// context is done.
+func (f ironicProvisionerFactory) Start(ctx context.Context) error {
+	ticker := time.NewTicker(clientReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			f.reloadClients()
+		}
+	}
+}
+
+// NeedLeaderElection returns false, as all replicas use the clients.
+func (f ironicProvisionerFactory) NeedLeaderElection() bool {
+	return false
+}
+
+// Ready returns an error if the clients of a backend were never built.
+// A failure to rebuild them is not reported here, as the previous
+// clients are still used.
+func (f ironicProvisionerFactory) Ready() error {
+	if f.clients != nil {
+		if _, _, err := f.clients.get(); err != nil {
+			return fmt.Errorf("failed to load the Ironic clients: %w", err)
+		}
+	}
+	for _, backend := range f.backends {
+		if _, _, err := backend.clients.get(); err != nil {
+			return fmt.Errorf("failed to load the clients of Ironic backend %s: %w", backend.name, err)
+		}
+	}
+	return nil
+}
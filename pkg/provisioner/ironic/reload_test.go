@@ -0,0 +1,164 @@
+package ironic
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
+)
+
+func TestReloadClients(t *testing.T) {
+	root := t.TempDir()
+	authDir := filepath.Join(root, "ironic")
+	if err := os.Mkdir(authDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	writeAuthFile := func(name, content string) {
+		if err := ioutil.WriteFile(filepath.Join(authDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeAuthFile("username", "user")
+	writeAuthFile("password", "pass-1")
+
+	build := func() (*gophercloud.ServiceClient, *gophercloud.ServiceClient, error) {
+		ironicAuth, inspectorAuth, err := clients.LoadAuthFromDir(root)
+		if err != nil {
+			return nil, nil, err
+		}
+		return newClients("https://ironic.test/v1/", ironicAuth,
+			"https://inspector.test/v1/", inspectorAuth, clients.TLSConfig{})
+	}
+
+	factory := newTestProvisionerFactory()
+	factory.clients = newIronicClients(build, authDirs(root))
+	assert.NoError(t, factory.Ready())
+	first, _, err := factory.clients.get()
+	assert.NoError(t, err)
+
+	changed, err := factory.clients.reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	writeAuthFile("password", "pass-2")
+	factory.reloadClients()
+	assert.NoError(t, factory.Ready())
+	second, _, err := factory.clients.get()
+	assert.NoError(t, err)
+	assert.NotSame(t, first, second)
+	assert.NotEqual(t, first.MoreHeaders["Authorization"], second.MoreHeaders["Authorization"])
+
+	// Secrets mounted in pods are updated through hidden directories
+	if err := os.Mkdir(filepath.Join(authDir, "..data"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	changed, err = factory.clients.reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	// The last clients are kept when the files are invalid
+	writeAuthFile("password", "")
+	factory.reloadClients()
+	assert.NoError(t, factory.Ready())
+	assert.EqualError(t, factory.clients.lastError(), "Empty HTTP Basic Auth password")
+	assert.Equal(t, 1.0, testutil.ToFloat64(clientReloadFailed.WithLabelValues("")))
+	current, _, err := factory.clients.get()
+	assert.NoError(t, err)
+	assert.Same(t, second, current)
+
+	writeAuthFile("password", "pass-3")
+	factory.reloadClients()
+	assert.NoError(t, factory.clients.lastError())
+	assert.Equal(t, 0.0, testutil.ToFloat64(clientReloadFailed.WithLabelValues("")))
+}
+
+func TestReloadClientsNeverBuilt(t *testing.T) {
+	root := t.TempDir()
+	build := func() (*gophercloud.ServiceClient, *gophercloud.ServiceClient, error) {
+		ironicAuth, inspectorAuth, err := clients.LoadAuthFromDir(root)
+		if err != nil {
+			return nil, nil, err
+		}
+		return newClients("https://ironic.test/v1/", ironicAuth,
+			"https://inspector.test/v1/", inspectorAuth, clients.TLSConfig{})
+	}
+	authDir := filepath.Join(root, "ironic-inspector")
+	if err := os.Mkdir(authDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	factory := newTestProvisionerFactory()
+	factory.backends = []*ironicBackend{
+		{name: "edge-1", config: factory.config, clients: newIronicClients(build, authDirs(root))},
+	}
+	err := factory.Ready()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to load the clients of Ironic backend edge-1")
+
+	host := makeHost()
+	host.Status.Provisioning.Backend = "edge-1"
+	_, err = factory.NewProvisioner(provisioner.BuildHostData(host, bmc.Credentials{}), nullEventPublisher)
+	assert.Error(t, err)
+
+	if err := ioutil.WriteFile(filepath.Join(authDir, "username"), []byte("user"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(authDir, "password"), []byte("pass"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	factory.reloadClients()
+	assert.NoError(t, factory.Ready())
+	_, err = factory.NewProvisioner(provisioner.BuildHostData(host, bmc.Credentials{}), nullEventPublisher)
+	assert.NoError(t, err)
+}
+
+func TestReloadClientsRetry(t *testing.T) {
+	failures := 2
+	builds := 0
+	build := func() (*gophercloud.ServiceClient, *gophercloud.ServiceClient, error) {
+		builds++
+		if builds <= failures {
+			return nil, nil, errors.New("catalog unavailable")
+		}
+		return newClients("https://ironic.test/v1/", clients.AuthConfig{Type: clients.NoAuth},
+			"https://inspector.test/v1/", clients.AuthConfig{Type: clients.NoAuth}, clients.TLSConfig{})
+	}
+
+	c := newIronicClients(build, []string{t.TempDir()})
+	assert.Error(t, c.lastError())
+	assert.Equal(t, 2*clientReloadInterval, c.retryDelay)
+
+	// Not retried before the delay expires
+	changed, err := c.reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, 1, builds)
+
+	c.retryAt = time.Now()
+	changed, err = c.reload()
+	assert.Error(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, 4*clientReloadInterval, c.retryDelay, "delay not doubled")
+
+	c.retryAt = time.Now()
+	changed, err = c.reload()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.NoError(t, c.lastError())
+	assert.Zero(t, c.retryDelay)
+
+	changed, err = c.reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, 3, builds)
+}
@@ -3,6 +3,7 @@ package ironic
 import (
 	"time"
 
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
 )
 
@@ -31,6 +32,20 @@ func operationFailed(message string) (provisioner.Result, error) {
 	return provisioner.Result{ErrorMessage: message}, nil
 }
 
+func firmwareUpdateFailed(message string) (provisioner.Result, error) {
+	return provisioner.Result{
+		ErrorMessage: message,
+		ErrorType:    metal3v1alpha1.FirmwareUpdateError,
+	}, nil
+}
+
+func servicingFailed(message string) (provisioner.Result, error) {
+	return provisioner.Result{
+		ErrorMessage: message,
+		ErrorType:    metal3v1alpha1.ServicingError,
+	}, nil
+}
+
 func transientError(err error) (provisioner.Result, error) {
 	return provisioner.Result{}, err
 }
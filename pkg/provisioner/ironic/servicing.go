@@ -0,0 +1,172 @@
+package ironic
+
+import (
+	"fmt"
+
+	"github.com/gophercloud/gophercloud"
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+)
+
+// The version of the API that introduced servicing of active nodes.
+const servicingMicroversion = "1.87"
+
+// The provision states and target of servicing, which are not known to
+// gophercloud.
+const (
+	nodeServicing   nodes.ProvisionState = "servicing"
+	nodeServiceWait nodes.ProvisionState = "service wait"
+	nodeServiceFail nodes.ProvisionState = "service failed"
+
+	targetService nodes.TargetProvisionState = "service"
+)
+
+// serviceOpts is the request to start servicing a node with the
+// given steps.
+type serviceOpts struct {
+	Target       nodes.TargetProvisionState `json:"target" required:"true"`
+	ServiceSteps []nodes.CleanStep          `json:"service_steps" required:"true"`
+}
+
+// ToProvisionStateMap assembles the request body for servicing.
+func (opts serviceOpts) ToProvisionStateMap() (map[string]interface{}, error) {
+	return gophercloud.BuildRequestBody(opts, "")
+}
+
+// buildServiceSteps returns the service steps applying the requested
+// changes. Servicing always boots the host into the agent, so when a
+// reboot into the agent is all that was requested the host is just
+// rebooted once there.
+func buildServiceSteps(bmcAccess bmc.AccessDetails, data provisioner.ServicingData) (serviceSteps []nodes.CleanStep, err error) {
+	// Flash new firmware first, as it may reset the BIOS settings
+	serviceSteps, err = buildFirmwareUpdateSteps(bmcAccess, data.FirmwareUpdates)
+	if err != nil {
+		return nil, err
+	}
+
+	biosSteps, err := buildBIOSSteps(bmcAccess, data.FirmwareConfig)
+	if err != nil {
+		return nil, err
+	}
+	serviceSteps = append(serviceSteps, biosSteps...)
+
+	if len(serviceSteps) == 0 && data.RebootToAgent {
+		serviceSteps = append(
+			serviceSteps,
+			nodes.CleanStep{
+				Interface: "power",
+				Step:      "reboot",
+			},
+		)
+	}
+	return
+}
+
+func (p *ironicProvisioner) startServicing(bmcAccess bmc.AccessDetails, ironicNode *nodes.Node, data provisioner.ServicingData) (success bool, result provisioner.Result, err error) {
+	result, err = p.checkBIOSSettings(data.FirmwareConfig)
+	if err != nil {
+		return
+	}
+	if result.ErrorMessage != "" {
+		result, err = servicingFailed(result.ErrorMessage)
+		return
+	}
+
+	serviceSteps, err := buildServiceSteps(bmcAccess, data)
+	if err != nil {
+		result, err = servicingFailed(err.Error())
+		return
+	}
+	if len(serviceSteps) == 0 {
+		result, err = operationComplete()
+		return
+	}
+
+	p.log.Info("starting servicing", "steps", serviceSteps)
+	client := *p.client
+	client.Microversion = servicingMicroversion
+	return p.tryChangeNodeProvisionStateWith(
+		&client,
+		ironicNode,
+		targetService,
+		serviceOpts{
+			Target:       targetService,
+			ServiceSteps: serviceSteps,
+		},
+	)
+}
+
+// Service applies changes to the firmware of a provisioned host
+// without removing its image. It may be called multiple times, and
+// should return true for its dirty flag until servicing is completed.
+func (p *ironicProvisioner) Service(data provisioner.ServicingData, restart bool) (result provisioner.Result, started bool, err error) {
+	bmcAccess, err := p.bmcAccess()
+	if err != nil {
+		result, err = transientError(err)
+		return
+	}
+
+	ironicNode, err := p.getNode()
+	if err != nil {
+		result, err = transientError(err)
+		return
+	}
+
+	switch nodes.ProvisionState(ironicNode.ProvisionState) {
+	case nodes.Active:
+		if restart {
+			started, result, err = p.startServicing(bmcAccess, ironicNode, data)
+			if started || result.Dirty || result.ErrorMessage != "" || err != nil {
+				return
+			}
+			// nothing to do
+			started = true
+		}
+		// Servicing finished
+		result, err = operationComplete()
+
+	case nodeServiceFail:
+		// Report the failure unless the controller asks to retry
+		if !restart {
+			var firmwareFailed bool
+			firmwareFailed, err = p.isFirmwareUpdateFailure(ironicNode)
+			if err != nil {
+				result, err = transientError(err)
+				return
+			}
+			if firmwareFailed {
+				result, err = firmwareUpdateFailed(ironicNode.LastError)
+				return
+			}
+			result, err = servicingFailed(ironicNode.LastError)
+			return
+		}
+		if ironicNode.Maintenance {
+			p.log.Info("clearing maintenance flag")
+			result, err = p.setMaintenanceFlag(ironicNode, false)
+			return
+		}
+		started, result, err = p.startServicing(bmcAccess, ironicNode, data)
+		if started || result.Dirty || result.ErrorMessage != "" || err != nil {
+			return
+		}
+		// There is nothing left to apply, so return the node to
+		// active without retrying
+		started = true
+		result, err = p.changeNodeProvisionState(
+			ironicNode,
+			nodes.ProvisionStateOpts{Target: nodes.TargetAbort},
+		)
+
+	case nodeServicing, nodeServiceWait:
+		p.log.Info("waiting for host to become active",
+			"state", ironicNode.ProvisionState)
+		result, err = operationContinuing(provisionRequeueDelay)
+
+	default:
+		result, err = transientError(fmt.Errorf("Have unexpected ironic node state %s", ironicNode.ProvisionState))
+	}
+	return
+}
@@ -0,0 +1,186 @@
+package ironic
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
+	"github.com/stretchr/testify/assert"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/testserver"
+)
+
+func TestBuildServiceSteps(t *testing.T) {
+	bmcAccess, err := bmc.NewAccessDetails("redfish://192.168.122.1", false)
+	if err != nil {
+		t.Fatalf("could not create access details: %s", err)
+	}
+
+	cases := []struct {
+		name          string
+		data          provisioner.ServicingData
+		expectedSteps []string
+	}{
+		{
+			name: "nothing to do",
+		},
+		{
+			name: "firmware changes",
+			data: provisioner.ServicingData{
+				FirmwareConfig: &metal3v1alpha1.FirmwareConfig{
+					Settings: map[string]string{"BootMode": "Uefi"},
+				},
+				FirmwareUpdates: []metal3v1alpha1.FirmwareUpdate{
+					{
+						Component: metal3v1alpha1.FirmwareComponentBIOS,
+						URL:       "http://example.com/bios.bin",
+					},
+				},
+				RebootToAgent: true,
+			},
+			expectedSteps: []string{"management.update_firmware", "bios.apply_configuration"},
+		},
+		{
+			name: "reboot into agent",
+			data: provisioner.ServicingData{
+				RebootToAgent: true,
+			},
+			expectedSteps: []string{"power.reboot"},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			steps, err := buildServiceSteps(bmcAccess, tc.data)
+			assert.NoError(t, err)
+
+			var names []string
+			for _, step := range steps {
+				names = append(names, string(step.Interface)+"."+step.Step)
+			}
+			assert.Equal(t, tc.expectedSteps, names)
+		})
+	}
+}
+
+func TestService(t *testing.T) {
+	nodeUUID := "33ce8659-7400-4c68-9535-d10766f07a58"
+
+	cases := []struct {
+		name                 string
+		provisionState       nodes.ProvisionState
+		data                 provisioner.ServicingData
+		restart              bool
+		expectedStarted      bool
+		expectedDirty        bool
+		expectedRequestAfter int
+		expectedErrorMessage bool
+		expectedTarget       string
+	}{
+		{
+			name:            "active state(no service steps)",
+			provisionState:  nodes.Active,
+			restart:         true,
+			expectedStarted: true,
+		},
+		{
+			name:                 "active state(have service steps)",
+			provisionState:       nodes.Active,
+			data:                 provisioner.ServicingData{RebootToAgent: true},
+			restart:              true,
+			expectedStarted:      true,
+			expectedDirty:        true,
+			expectedRequestAfter: 10,
+			expectedTarget:       "service",
+		},
+		{
+			name:           "active state(servicing finished)",
+			provisionState: nodes.Active,
+		},
+		{
+			name:                 "servicing state",
+			provisionState:       nodeServicing,
+			expectedDirty:        true,
+			expectedRequestAfter: 10,
+		},
+		{
+			name:                 "serviceWait state",
+			provisionState:       nodeServiceWait,
+			expectedDirty:        true,
+			expectedRequestAfter: 10,
+		},
+		{
+			name:                 "serviceFail state",
+			provisionState:       nodeServiceFail,
+			expectedErrorMessage: true,
+		},
+		{
+			name:                 "serviceFail state(retry)",
+			provisionState:       nodeServiceFail,
+			data:                 provisioner.ServicingData{RebootToAgent: true},
+			restart:              true,
+			expectedStarted:      true,
+			expectedDirty:        true,
+			expectedRequestAfter: 10,
+			expectedTarget:       "service",
+		},
+		{
+			name:                 "serviceFail state(nothing left to do)",
+			provisionState:       nodeServiceFail,
+			restart:              true,
+			expectedStarted:      true,
+			expectedDirty:        true,
+			expectedRequestAfter: 10,
+			expectedTarget:       "abort",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			ironic := testserver.NewIronic(t).WithDefaultResponses().Node(nodes.Node{
+				ProvisionState: string(tc.provisionState),
+				UUID:           nodeUUID,
+				LastError:      "servicing failed",
+			})
+			ironic.Start()
+			defer ironic.Stop()
+
+			host := makeHost()
+			host.Status.Provisioning.ID = nodeUUID
+
+			publisher := func(reason, message string) {}
+			auth := clients.AuthConfig{Type: clients.NoAuth}
+			prov, err := newProvisionerWithSettings(host, bmc.Credentials{}, publisher,
+				ironic.Endpoint(), auth, "https://inspector.test/", auth,
+			)
+			if err != nil {
+				t.Fatalf("could not create provisioner: %s", err)
+			}
+
+			result, started, err := prov.Service(tc.data, tc.restart)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedStarted, started)
+			assert.Equal(t, tc.expectedDirty, result.Dirty)
+			assert.Equal(t, time.Second*time.Duration(tc.expectedRequestAfter), result.RequeueAfter)
+			if tc.expectedErrorMessage {
+				assert.Equal(t, "servicing failed", result.ErrorMessage)
+				assert.Equal(t, metal3v1alpha1.ServicingError, result.ErrorType)
+			} else {
+				assert.Empty(t, result.ErrorMessage)
+			}
+
+			body, found := ironic.GetLastRequestFor("/v1/nodes/"+nodeUUID+"/states/provision", http.MethodPut)
+			if tc.expectedTarget == "" {
+				assert.False(t, found)
+			} else if assert.True(t, found) {
+				assert.Contains(t, body, `"target":"`+tc.expectedTarget+`"`)
+			}
+		})
+	}
+}
@@ -59,6 +59,8 @@ func (a *testAccessDetails) DriverInfo(bmcCreds bmc.Credentials) map[string]inte
 
 	if a.disableCertificateVerification {
 		result["test_verify_ca"] = false
+	} else if bmcCreds.CACertificatePath != "" {
+		result["test_verify_ca"] = bmcCreds.CACertificatePath
 	}
 	return result
 }
@@ -87,6 +89,10 @@ func (a *testAccessDetails) SupportsSecureBoot() bool {
 	return false
 }
 
+func (a *testAccessDetails) SupportsCACertificate() bool {
+	return true
+}
+
 func (a *testAccessDetails) SupportsISOPreprovisioningImage() bool {
 	return false
 }
@@ -94,3 +100,7 @@ func (a *testAccessDetails) SupportsISOPreprovisioningImage() bool {
 func (a *testAccessDetails) BuildBIOSSettings(firmwareConfig *metal3v1alpha1.FirmwareConfig) (settings []map[string]string, err error) {
 	return nil, nil
 }
+
+func (a *testAccessDetails) BuildFirmwareUpdateArgs(updates []metal3v1alpha1.FirmwareUpdate) (args map[string]interface{}, err error) {
+	return nil, nil
+}
@@ -114,6 +114,23 @@ func (m *IronicMock) Node(node nodes.Node) *IronicMock {
 	return m
 }
 
+// NodeWithServiceStep configures the server with a valid response for
+// [GET] /v1/nodes/{uuid} reporting the service step of the node, which
+// is not known to gophercloud.
+func (m *IronicMock) NodeWithServiceStep(node nodes.Node, step map[string]interface{}) *IronicMock {
+	content, err := json.Marshal(node)
+	if err != nil {
+		m.t.Error(err)
+	}
+	payload := map[string]interface{}{}
+	if err = json.Unmarshal(content, &payload); err != nil {
+		m.t.Error(err)
+	}
+	payload["service_step"] = step
+	m.ResponseJSON(m.buildURL("/v1/nodes/"+node.UUID, http.MethodGet), payload)
+	return m
+}
+
 // NodeUpdateError configures configures the server with an error response for [PATCH] /v1/nodes/{id}
 func (m *IronicMock) NodeUpdateError(id string, errorCode int) *IronicMock {
 	m.ResponseWithCode(m.buildURL("/v1/nodes/"+id, http.MethodPatch), "", errorCode)
@@ -270,3 +287,23 @@ func (m *IronicMock) Nodes(allNodes []nodes.Node) *IronicMock {
 	m.ResponseJSON(m.buildURL("/v1/nodes", http.MethodGet), resp)
 	return m
 }
+
+// BIOSSettings configures the server with a valid response for [GET] /v1/nodes/<node>/bios
+func (m *IronicMock) BIOSSettings(nodeUUID string, settings []map[string]interface{}) *IronicMock {
+	resp := map[string][]map[string]interface{}{
+		"bios": settings,
+	}
+
+	m.ResponseJSON(m.buildURL("/v1/nodes/"+nodeUUID+"/bios", http.MethodGet), resp)
+	return m
+}
+
+// FirmwareComponents configures the server with a valid response for [GET] /v1/nodes/<node>/firmware
+func (m *IronicMock) FirmwareComponents(nodeUUID string, components []map[string]interface{}) *IronicMock {
+	resp := map[string][]map[string]interface{}{
+		"firmware": components,
+	}
+
+	m.ResponseJSON(m.buildURL("/v1/nodes/"+nodeUUID+"/firmware", http.MethodGet), resp)
+	return m
+}
@@ -7,9 +7,11 @@ import (
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
 	"github.com/stretchr/testify/assert"
 
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/metal3-io/baremetal-operator/pkg/bmc"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/testserver"
+	redfishserver "github.com/metal3-io/baremetal-operator/pkg/provisioner/redfish/testserver"
 )
 
 func TestUpdateHardwareState(t *testing.T) {
@@ -152,3 +154,59 @@ func TestUpdateHardwareState(t *testing.T) {
 		})
 	}
 }
+
+func TestGetHardwareHealth(t *testing.T) {
+	bmcServer := redfishserver.NewRedfish(t).Start()
+	defer bmcServer.Stop()
+	ironic := testserver.NewIronic(t).Ready().Start()
+	defer ironic.Stop()
+	inspector := testserver.NewInspector(t).Ready().Start()
+	defer inspector.Stop()
+
+	creds := bmc.Credentials{Username: redfishserver.Username, Password: redfishserver.Password}
+	auth := clients.AuthConfig{Type: clients.NoAuth}
+
+	cases := []struct {
+		name         string
+		address      string
+		expectHealth bool
+	}{
+		{
+			name:         "redfish",
+			address:      bmcServer.Address("redfish"),
+			expectHealth: true,
+		},
+		{
+			name:         "idrac-virtualmedia",
+			address:      bmcServer.Address("idrac-virtualmedia"),
+			expectHealth: true,
+		},
+		{
+			name:    "ipmi",
+			address: "ipmi://192.168.122.1:6233",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			host := makeHost()
+			host.Spec.BMC.Address = tc.address
+			prov, err := newProvisionerWithSettings(host, creds, nullEventPublisher,
+				ironic.Endpoint(), auth, inspector.Endpoint(), auth,
+			)
+			if err != nil {
+				t.Fatalf("could not create provisioner: %s", err)
+			}
+
+			health, err := prov.GetHardwareHealth()
+			assert.NoError(t, err)
+			if tc.expectHealth {
+				if assert.NotNil(t, health) {
+					assert.Equal(t, metal3v1alpha1.HealthOK, health.Status)
+				}
+			} else {
+				assert.Nil(t, health)
+			}
+		})
+	}
+}
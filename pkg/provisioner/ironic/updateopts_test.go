@@ -726,6 +726,52 @@ func TestGetUpdateOptsForNodeDell(t *testing.T) {
 	}
 }
 
+func TestGetUpdateOptsForNodeChecksumURL(t *testing.T) {
+	host := makeHost()
+	host.Spec.Image = &metal3v1alpha1.Image{
+		URL:      "http://images.test/image.qcow2",
+		Checksum: "http://images.test/SHA256SUMS",
+	}
+
+	eventPublisher := func(reason, message string) {}
+	auth := clients.AuthConfig{Type: clients.NoAuth}
+
+	prov, err := newProvisionerWithSettings(host, bmc.Credentials{}, eventPublisher,
+		"https://ironic.test", auth, "https://ironic.test", auth,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ironicNode := &nodes.Node{
+		InstanceInfo: map[string]interface{}{
+			"image_os_hash_algo":  "sha256",
+			"image_os_hash_value": "oldchecksum",
+		},
+	}
+
+	provData := provisioner.ProvisionData{
+		Image:    *host.Spec.Image,
+		BootMode: metal3v1alpha1.DefaultBootMode,
+	}
+	patches := prov.getUpdateOptsForNode(ironicNode, provData).Updates
+
+	// The algorithm is detected by Ironic once the checksum is read
+	updates := map[string]nodes.UpdateOperation{}
+	for _, patch := range patches {
+		update := patch.(nodes.UpdateOperation)
+		updates[update.Path] = update
+	}
+	assert.Equal(t, "http://images.test/SHA256SUMS", updates["/instance_info/image_checksum"].Value)
+	assert.Equal(t, nodes.RemoveOp, updates["/instance_info/image_os_hash_algo"].Op)
+	assert.Equal(t, nodes.RemoveOp, updates["/instance_info/image_os_hash_value"].Op)
+
+	ironicNode.InstanceInfo = map[string]interface{}{
+		"image_source":   "http://images.test/image.qcow2",
+		"image_checksum": "http://images.test/SHA256SUMS",
+	}
+	assert.True(t, prov.ironicHasSameImage(ironicNode, *host.Spec.Image))
+}
+
 func TestGetUpdateOptsForNodeLiveIso(t *testing.T) {
 	eventPublisher := func(reason, message string) {}
 	auth := clients.AuthConfig{Type: clients.NoAuth}
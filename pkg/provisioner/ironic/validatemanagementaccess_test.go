@@ -1,7 +1,10 @@
 package ironic
 
 import (
+	"io/ioutil"
 	"net/http"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/gophercloud/gophercloud/openstack/baremetal/v1/nodes"
@@ -10,6 +13,7 @@ import (
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/deployimages"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/clients"
 	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic/testserver"
@@ -782,3 +786,155 @@ func TestValidateManagementAccessMalformedBMCAddress(t *testing.T) {
 	}
 	assert.Equal(t, "failed to parse BMC address information: failed to parse BMC address information: parse \"<ipmi://192.168.122.1:6233>\": first path segment in URL cannot contain colon", result.ErrorMessage)
 }
+
+func TestValidateManagementAccessCACertificate(t *testing.T) {
+	host := makeHost()
+	host.Status.Provisioning.ID = "" // so we don't lookup by uuid
+
+	var createdNode *nodes.Node
+
+	createCallback := func(node nodes.Node) {
+		createdNode = &node
+	}
+
+	ironic := testserver.NewIronic(t).Ready().CreateNodes(createCallback).NoNode(host.Namespace + nameSeparator + host.Name).NoNode(host.Name)
+	ironic.AddDefaultResponse("/v1/nodes/node-0", "PATCH", http.StatusOK, "{}")
+	ironic.Start()
+	defer ironic.Stop()
+
+	auth := clients.AuthConfig{Type: clients.NoAuth}
+	creds := bmc.Credentials{CACertificate: []byte("my CA")}
+	prov, err := newProvisionerWithSettings(host, creds, nullEventPublisher,
+		ironic.Endpoint(), auth, testserver.NewInspector(t).Endpoint(), auth,
+	)
+	if err != nil {
+		t.Fatalf("could not create provisioner: %s", err)
+	}
+	prov.config.bmcCACertsDir = t.TempDir()
+
+	result, _, err := prov.ValidateManagementAccess(provisioner.ManagementAccessData{}, false, false)
+	if err != nil {
+		t.Fatalf("error from ValidateManagementAccess: %s", err)
+	}
+	assert.Equal(t, "", result.ErrorMessage)
+
+	path := filepath.Join(prov.config.bmcCACertsDir, "myns~myhost.crt")
+	assert.Equal(t, path, createdNode.DriverInfo["test_verify_ca"])
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "my CA", string(data))
+
+	assert.NoError(t, prov.removeBMCCACertificate())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestValidateManagementAccessCACertificateNoDir(t *testing.T) {
+	host := makeHost()
+
+	ironic := testserver.NewIronic(t).Ready().
+		Node(nodes.Node{
+			Name: host.Namespace + nameSeparator + host.Name,
+			UUID: host.Status.Provisioning.ID,
+		})
+	ironic.Start()
+	defer ironic.Stop()
+
+	auth := clients.AuthConfig{Type: clients.NoAuth}
+	creds := bmc.Credentials{CACertificate: []byte("my CA")}
+	prov, err := newProvisionerWithSettings(host, creds, nullEventPublisher,
+		ironic.Endpoint(), auth, testserver.NewInspector(t).Endpoint(), auth,
+	)
+	if err != nil {
+		t.Fatalf("could not create provisioner: %s", err)
+	}
+
+	result, _, err := prov.ValidateManagementAccess(provisioner.ManagementAccessData{}, false, false)
+	if err != nil {
+		t.Fatalf("error from ValidateManagementAccess: %s", err)
+	}
+	assert.Contains(t, result.ErrorMessage, "BMC_CACERTS_DIR is not set")
+
+	prov.backend = "edge-1"
+	result, _, err = prov.ValidateManagementAccess(provisioner.ManagementAccessData{}, false, false)
+	if err != nil {
+		t.Fatalf("error from ValidateManagementAccess: %s", err)
+	}
+	assert.Contains(t, result.ErrorMessage, "not supported by Ironic backend edge-1")
+}
+
+func TestValidateManagementAccessDeployImagesByArch(t *testing.T) {
+	testCases := []struct {
+		Scenario        string
+		Arch            string
+		ExpectedKernel  string
+		ExpectedError   string
+		ExpectedCreated bool
+	}{
+		{
+			Scenario:        "matching architecture",
+			Arch:            "aarch64",
+			ExpectedKernel:  "http://deploy.test/ipa-aarch64.kernel",
+			ExpectedCreated: true,
+		},
+		{
+			Scenario:        "unknown architecture",
+			ExpectedKernel:  "http://deploy.test/ipa.kernel",
+			ExpectedCreated: true,
+		},
+		{
+			Scenario:      "no image for the architecture",
+			Arch:          "ppc64le",
+			ExpectedError: "no deploy image configured for the ppc64le architecture",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			host := makeHost()
+			host.Status.Provisioning.ID = "" // so we don't lookup by uuid
+
+			var createdNode *nodes.Node
+
+			createCallback := func(node nodes.Node) {
+				createdNode = &node
+			}
+
+			ironic := testserver.NewIronic(t).Ready().CreateNodes(createCallback).NoNode(host.Namespace + nameSeparator + host.Name).NoNode(host.Name)
+			ironic.AddDefaultResponse("/v1/nodes/node-0", "PATCH", http.StatusOK, "{}")
+			ironic.Start()
+			defer ironic.Stop()
+
+			auth := clients.AuthConfig{Type: clients.NoAuth}
+			prov, err := newProvisionerWithSettings(host, bmc.Credentials{}, nullEventPublisher,
+				ironic.Endpoint(), auth, testserver.NewInspector(t).Endpoint(), auth,
+			)
+			if err != nil {
+				t.Fatalf("could not create provisioner: %s", err)
+			}
+			prov.config.deployImagesByArch = deployimages.ByArch{
+				"x86_64": {
+					KernelURL:  "http://deploy.test/ipa-x86_64.kernel",
+					RamdiskURL: "http://deploy.test/ipa-x86_64.initramfs",
+				},
+				"aarch64": {
+					KernelURL:  "http://deploy.test/ipa-aarch64.kernel",
+					RamdiskURL: "http://deploy.test/ipa-aarch64.initramfs",
+				},
+			}
+
+			result, _, err := prov.ValidateManagementAccess(provisioner.ManagementAccessData{CPUArchitecture: tc.Arch}, false, false)
+			if err != nil {
+				t.Fatalf("error from ValidateManagementAccess: %s", err)
+			}
+			assert.Equal(t, tc.ExpectedError, result.ErrorMessage)
+			if tc.ExpectedCreated {
+				if assert.NotNil(t, createdNode) {
+					assert.Equal(t, tc.ExpectedKernel, createdNode.DriverInfo["deploy_kernel"])
+				}
+			} else {
+				assert.Nil(t, createdNode)
+			}
+		})
+	}
+}
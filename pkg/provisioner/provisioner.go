@@ -1,10 +1,12 @@
 package provisioner
 
 import (
+	"context"
 	"errors"
 	"time"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 
 	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
 	"github.com/metal3-io/baremetal-operator/pkg/bmc"
@@ -26,6 +28,7 @@ type HostData struct {
 	DisableCertificateVerification bool
 	BootMACAddress                 string
 	ProvisionerID                  string
+	ProvisionerBackend             string
 }
 
 func BuildHostData(host metal3v1alpha1.BareMetalHost, bmcCreds bmc.Credentials) HostData {
@@ -36,6 +39,7 @@ func BuildHostData(host metal3v1alpha1.BareMetalHost, bmcCreds bmc.Credentials)
 		DisableCertificateVerification: host.Spec.BMC.DisableCertificateVerification,
 		BootMACAddress:                 host.Spec.BootMACAddress,
 		ProvisionerID:                  host.Status.Provisioning.ID,
+		ProvisionerBackend:             host.Status.Provisioning.Backend,
 	}
 }
 
@@ -44,6 +48,33 @@ type Factory interface {
 	NewProvisioner(hostData HostData, publish EventPublisher) (Provisioner, error)
 }
 
+// ReloadingFactory is a Factory that reloads its configuration while it
+// is started.
+type ReloadingFactory interface {
+	Factory
+
+	// Start watches the configuration until the context is done.
+	Start(ctx context.Context) error
+
+	// NeedLeaderElection returns whether the configuration is only
+	// watched by the leader.
+	NeedLeaderElection() bool
+
+	// Ready returns an error if the configuration was never loaded.
+	// Failures to reload it are not reported, as the last loaded
+	// configuration is still used.
+	Ready() error
+}
+
+// PowerStateWatcher is implemented by the factories able to report the
+// hosts whose power state changed, so that the hosts do not have to be
+// polled as often.
+type PowerStateWatcher interface {
+	// WatchPowerState calls changed with the hosts whose power state
+	// changed until the context is done.
+	WatchPowerState(ctx context.Context, changed func(types.NamespacedName)) error
+}
+
 // HostConfigData retrieves host configuration data
 type HostConfigData interface {
 	// UserData is the interface for a function to retrieve user
@@ -57,6 +88,11 @@ type HostConfigData interface {
 	// MetaData is the interface for a function to retrieve metadata
 	// configuration for a host.
 	MetaData() (string, error)
+
+	// ImagePullSecret is the interface for a function to retrieve the
+	// registry credentials, in the format of ~/.docker/config.json, for
+	// an image referenced in an OCI registry.
+	ImagePullSecret() (string, error)
 }
 
 type PreprovisioningImage struct {
@@ -73,6 +109,8 @@ type ManagementAccessData struct {
 	CurrentImage          *metal3v1alpha1.Image
 	PreprovisioningImage  *PreprovisioningImage
 	HasCustomDeploy       bool
+	// CPUArchitecture is the architecture of the host, if known
+	CPUArchitecture string
 }
 
 type AdoptData struct {
@@ -87,6 +125,15 @@ type PrepareData struct {
 	RAIDConfig      *metal3v1alpha1.RAIDConfig
 	RootDeviceHints *metal3v1alpha1.RootDeviceHints
 	FirmwareConfig  *metal3v1alpha1.FirmwareConfig
+	FirmwareUpdates []metal3v1alpha1.FirmwareUpdate
+}
+
+type ServicingData struct {
+	FirmwareConfig  *metal3v1alpha1.FirmwareConfig
+	FirmwareUpdates []metal3v1alpha1.FirmwareUpdate
+	// RebootToAgent requests that the host be booted into the agent
+	// even if there are no changes to apply.
+	RebootToAgent bool
 }
 
 type ProvisionData struct {
@@ -96,6 +143,8 @@ type ProvisionData struct {
 	HardwareProfile hardware.Profile
 	RootDeviceHints *metal3v1alpha1.RootDeviceHints
 	CustomDeploy    *metal3v1alpha1.CustomDeploy
+	// CPUArchitecture is the architecture of the host, if known
+	CPUArchitecture string
 }
 
 // Provisioner holds the state information for talking to the
@@ -126,6 +175,12 @@ type Provisioner interface {
 	// possible, such as reading from a cache.
 	UpdateHardwareState() (hwState HardwareState, err error)
 
+	// GetHardwareHealth returns the health of the hardware and the
+	// readings of its sensors, or nil if the provisioner cannot
+	// collect them for the host. It may query the BMC, so it is
+	// called less often than UpdateHardwareState.
+	GetHardwareHealth() (health *HardwareHealth, err error)
+
 	// Adopt brings an externally-provisioned host under management by
 	// the provisioner.
 	Adopt(data AdoptData, force bool) (result Result, err error)
@@ -133,6 +188,22 @@ type Provisioner interface {
 	// Prepare remove existing configuration and set new configuration
 	Prepare(data PrepareData, unprepared bool) (result Result, started bool, err error)
 
+	// GetFirmwareSettings returns the current BIOS settings of the
+	// host, keyed by setting name, and optionally the schema of the
+	// settings where the BMC reports it.
+	GetFirmwareSettings(includeSchema bool) (settings map[string]string, schema map[string]metal3v1alpha1.SettingSchema, err error)
+
+	// GetFirmwareComponents returns the firmware versions of the
+	// components of the host, or nil if they cannot be determined.
+	GetFirmwareComponents() (components []metal3v1alpha1.FirmwareComponentStatus, err error)
+
+	// Service applies changes to the firmware of a provisioned host
+	// while keeping the image on its disk(s). It may be called
+	// multiple times, and should return true for its dirty flag until
+	// the servicing operation is completed. If restart is true, the
+	// changes have not been applied yet and servicing is (re)started.
+	Service(data ServicingData, restart bool) (result Result, started bool, err error)
+
 	// Provision writes the image from the host spec to the host. It
 	// may be called multiple times, and should return true for its
 	// dirty flag until the provisioning operation is completed.
@@ -168,8 +239,15 @@ type Provisioner interface {
 	// all the incoming requests.
 	IsReady() (result bool, err error)
 
-	// HasCapacity checks if the backend has a free (de)provisioning slot for the current host
-	HasCapacity() (result bool, err error)
+	// HasCapacity checks if the backend has a free slot for the current
+	// host to enter the given state. If not, the reason explains which
+	// limit has been reached.
+	HasCapacity(state metal3v1alpha1.ProvisioningState) (result bool, reason string, err error)
+
+	// Backend returns the name of the provisioning backend the host
+	// is routed to, or an empty string when there is only a default
+	// backend.
+	Backend() string
 }
 
 // Result holds the response from a call in the Provsioner API.
@@ -182,6 +260,13 @@ type Result struct {
 	RequeueAfter time.Duration
 	// Any error message produced by the provisioner.
 	ErrorMessage string
+	// The type of the error in ErrorMessage, when it is more specific
+	// than the one implied by the operation that failed.
+	ErrorType metal3v1alpha1.ErrorType
+	// ImageReference is set by Provision when it completes, to the
+	// reference of an image from an OCI registry pinned to the digest
+	// of the manifest that was deployed.
+	ImageReference string
 }
 
 // HardwareState holds the response from an UpdateHardwareState call
@@ -191,6 +276,45 @@ type HardwareState struct {
 	PoweredOn *bool
 }
 
+// HardwareHealth holds the response from a GetHardwareHealth call
+type HardwareHealth struct {
+	// Status is the overall health of the host.
+	Status metal3v1alpha1.HealthStatus
+
+	// Components holds the health of the monitored components and
+	// the readings of their sensors.
+	Components []ComponentHealth
+}
+
+// ComponentHealth holds the health of a hardware component
+type ComponentHealth struct {
+	Name   string
+	Type   metal3v1alpha1.ComponentType
+	Status metal3v1alpha1.HealthStatus
+
+	// Reading is the current value reported by the sensor of the
+	// component, in Units, or nil if it has no sensor.
+	Reading *float64
+	Units   SensorUnits
+}
+
+// SensorUnits is the unit of the reading of a sensor
+type SensorUnits string
+
+const (
+	// UnitsRPM is for fan speeds in revolutions per minute
+	UnitsRPM SensorUnits = "rpm"
+	// UnitsPercent is for fan speeds in percent of the maximum
+	UnitsPercent SensorUnits = "percent"
+	// UnitsCelsius is for temperatures in degrees Celsius
+	UnitsCelsius SensorUnits = "celsius"
+	// UnitsWatts is for power outputs in Watts
+	UnitsWatts SensorUnits = "watts"
+)
+
+// AllSensorUnits lists the units of the sensor readings
+var AllSensorUnits = []SensorUnits{UnitsRPM, UnitsPercent, UnitsCelsius, UnitsWatts}
+
 // ErrNeedsRegistration is returned if the host is not registered
 var ErrNeedsRegistration = errors.New("Host not registered")
 
@@ -0,0 +1,477 @@
+package redfish
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+)
+
+const (
+	serviceRoot       = "/redfish/v1"
+	systemsCollection = serviceRoot + "/Systems"
+)
+
+var requestTimeout = 30 * time.Second
+
+// idleConnTimeout is how long connections to a BMC are kept open
+// between requests.
+var idleConnTimeout = 90 * time.Second
+
+// transportKey identifies the BMCs that can share a transport.
+type transportKey struct {
+	endpoint      string
+	insecure      bool
+	caCertificate string
+}
+
+// transports holds the transports shared by the clients of each BMC, as
+// a client is created for every reconcile of a host.
+var (
+	transportsLock sync.Mutex
+	transports     = map[transportKey]*http.Transport{}
+)
+
+// link is a reference to another Redfish resource.
+type link struct {
+	ID string `json:"@odata.id"`
+}
+
+type action struct {
+	Target string `json:"target"`
+}
+
+type collection struct {
+	Members []link `json:"Members"`
+}
+
+// status is the state and health of a resource.
+type status struct {
+	State        string `json:"State"`
+	Health       string `json:"Health"`
+	HealthRollup string `json:"HealthRollup"`
+}
+
+type computerSystem struct {
+	ID           string `json:"Id"`
+	Name         string `json:"Name"`
+	Manufacturer string `json:"Manufacturer"`
+	Model        string `json:"Model"`
+	SerialNumber string `json:"SerialNumber"`
+	BiosVersion  string `json:"BiosVersion"`
+	HostName     string `json:"HostName"`
+	PowerState   string `json:"PowerState"`
+	Status       status `json:"Status"`
+
+	Boot struct {
+		BootSourceOverrideTarget  string `json:"BootSourceOverrideTarget"`
+		BootSourceOverrideEnabled string `json:"BootSourceOverrideEnabled"`
+	} `json:"Boot"`
+
+	ProcessorSummary struct {
+		Count  int    `json:"Count"`
+		Model  string `json:"Model"`
+		Status status `json:"Status"`
+	} `json:"ProcessorSummary"`
+
+	MemorySummary struct {
+		TotalSystemMemoryGiB float64 `json:"TotalSystemMemoryGiB"`
+		Status               status  `json:"Status"`
+	} `json:"MemorySummary"`
+
+	Processors         *link `json:"Processors"`
+	EthernetInterfaces *link `json:"EthernetInterfaces"`
+	SimpleStorage      *link `json:"SimpleStorage"`
+	Storage            *link `json:"Storage"`
+	Bios               *link `json:"Bios"`
+
+	Links struct {
+		ManagedBy []link `json:"ManagedBy"`
+		Chassis   []link `json:"Chassis"`
+	} `json:"Links"`
+
+	Actions struct {
+		Reset action `json:"#ComputerSystem.Reset"`
+	} `json:"Actions"`
+}
+
+type processor struct {
+	ProcessorType  string  `json:"ProcessorType"`
+	Model          string  `json:"Model"`
+	InstructionSet string  `json:"InstructionSet"`
+	MaxSpeedMHz    float64 `json:"MaxSpeedMHz"`
+	TotalCores     int     `json:"TotalCores"`
+}
+
+type ipAddress struct {
+	Address string `json:"Address"`
+}
+
+type ethernetInterface struct {
+	ID            string      `json:"Id"`
+	Name          string      `json:"Name"`
+	MACAddress    string      `json:"MACAddress"`
+	SpeedMbps     int         `json:"SpeedMbps"`
+	IPv4Addresses []ipAddress `json:"IPv4Addresses"`
+	IPv6Addresses []ipAddress `json:"IPv6Addresses"`
+}
+
+type simpleStorage struct {
+	Devices []struct {
+		Name          string `json:"Name"`
+		Manufacturer  string `json:"Manufacturer"`
+		Model         string `json:"Model"`
+		CapacityBytes int64  `json:"CapacityBytes"`
+	} `json:"Devices"`
+}
+
+type storage struct {
+	Drives []link `json:"Drives"`
+}
+
+type drive struct {
+	Name          string `json:"Name"`
+	Manufacturer  string `json:"Manufacturer"`
+	Model         string `json:"Model"`
+	SerialNumber  string `json:"SerialNumber"`
+	MediaType     string `json:"MediaType"`
+	Protocol      string `json:"Protocol"`
+	CapacityBytes int64  `json:"CapacityBytes"`
+	Status        status `json:"Status"`
+}
+
+type chassis struct {
+	Thermal *link `json:"Thermal"`
+	Power   *link `json:"Power"`
+}
+
+type thermal struct {
+	Fans []struct {
+		Name         string   `json:"Name"`
+		Reading      *float64 `json:"Reading"`
+		ReadingUnits string   `json:"ReadingUnits"`
+		Status       status   `json:"Status"`
+	} `json:"Fans"`
+	Temperatures []struct {
+		Name           string   `json:"Name"`
+		ReadingCelsius *float64 `json:"ReadingCelsius"`
+		Status         status   `json:"Status"`
+	} `json:"Temperatures"`
+}
+
+type power struct {
+	PowerSupplies []struct {
+		Name             string   `json:"Name"`
+		PowerOutputWatts *float64 `json:"PowerOutputWatts"`
+		Status           status   `json:"Status"`
+	} `json:"PowerSupplies"`
+}
+
+type manager struct {
+	VirtualMedia *link `json:"VirtualMedia"`
+}
+
+type virtualMedia struct {
+	ID         string   `json:"Id"`
+	MediaTypes []string `json:"MediaTypes"`
+	Image      string   `json:"Image"`
+	Inserted   bool     `json:"Inserted"`
+
+	Actions struct {
+		InsertMedia action `json:"#VirtualMedia.InsertMedia"`
+		EjectMedia  action `json:"#VirtualMedia.EjectMedia"`
+	} `json:"Actions"`
+}
+
+type bios struct {
+	Attributes map[string]interface{} `json:"Attributes"`
+}
+
+// statusError is returned when the BMC responds with an error status.
+type statusError struct {
+	method     string
+	path       string
+	statusCode int
+	message    string
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("%s %s failed with status %d: %s", e.method, e.path, e.statusCode, e.message)
+}
+
+// client talks to the Redfish service of a BMC.
+type client struct {
+	endpoint   string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+// bmcTypes are the types of BMC addresses that use Redfish.
+var bmcTypes = map[string]bool{
+	"redfish":              true,
+	"redfish-virtualmedia": true,
+	"idrac-redfish":        true,
+	"idrac-virtualmedia":   true,
+	"ilo5-redfish":         true,
+	"ilo5-virtualmedia":    true,
+}
+
+// bmcAddress is a parsed Redfish BMC address.
+type bmcAddress struct {
+	// The URL of the Redfish service, without the path
+	endpoint string
+	// The path of the system, empty if the service has a single system
+	systemPath string
+}
+
+// parseAddress parses Redfish BMC addresses such as
+// redfish+http://192.168.0.1:8000/redfish/v1/Systems/1 or
+// redfish-virtualmedia://bmc.example.com/redfish/v1/Systems/1.
+func parseAddress(address string) (parsed bmcAddress, err error) {
+	if address == "" {
+		return parsed, fmt.Errorf("missing BMC address")
+	}
+	u, err := url.Parse(address)
+	if err != nil {
+		return parsed, fmt.Errorf("failed to parse BMC address: %w", err)
+	}
+
+	schemes := strings.SplitN(u.Scheme, "+", 2)
+	if !bmcTypes[schemes[0]] {
+		return parsed, fmt.Errorf("the Redfish provisioner does not support BMC type %s", schemes[0])
+	}
+	transport := "https"
+	if len(schemes) > 1 {
+		transport = schemes[1]
+	}
+	if transport != "http" && transport != "https" {
+		return parsed, fmt.Errorf("unsupported transport %s in BMC address", transport)
+	}
+	if u.Host == "" {
+		return parsed, fmt.Errorf("missing host in BMC address")
+	}
+
+	parsed.endpoint = fmt.Sprintf("%s://%s", transport, u.Host)
+	parsed.systemPath = strings.TrimSuffix(u.Path, "/")
+	return parsed, nil
+}
+
+// getTransport returns the transport for a BMC, creating it the first
+// time. Idle connections are closed after idleConnTimeout, so that
+// transports of BMCs that are no longer used hold no connections.
+func getTransport(endpoint string, caCertificate []byte, disableCertificateVerification bool) (*http.Transport, error) {
+	key := transportKey{endpoint: endpoint, insecure: disableCertificateVerification}
+	if !disableCertificateVerification {
+		key.caCertificate = string(caCertificate)
+	}
+
+	transportsLock.Lock()
+	defer transportsLock.Unlock()
+	if transport, ok := transports[key]; ok {
+		return transport, nil
+	}
+
+	tlsConfig := &tls.Config{
+		// #nosec G402 Verification is disabled on request only
+		InsecureSkipVerify: disableCertificateVerification,
+	}
+	if key.caCertificate != "" {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCertificate) {
+			return nil, fmt.Errorf("failed to parse the BMC CA certificate")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+		IdleConnTimeout: idleConnTimeout,
+	}
+	transports[key] = transport
+	return transport, nil
+}
+
+func newClient(endpoint string, creds bmc.Credentials, disableCertificateVerification bool) (*client, error) {
+	transport, err := getTransport(endpoint, creds.CACertificate, disableCertificateVerification)
+	if err != nil {
+		return nil, err
+	}
+
+	return &client{
+		endpoint: endpoint,
+		username: creds.Username,
+		password: creds.Password,
+		httpClient: &http.Client{
+			Timeout:   requestTimeout,
+			Transport: transport,
+		},
+	}, nil
+}
+
+func (c *client) do(method, path string, body interface{}, out interface{}) error {
+	var reader *bytes.Reader
+	if body != nil {
+		content, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(content)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, c.endpoint+path, reader)
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(c.username, c.password)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	content, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return statusError{
+			method:     method,
+			path:       path,
+			statusCode: resp.StatusCode,
+			message:    strings.TrimSpace(string(content)),
+		}
+	}
+	if out != nil && len(content) > 0 {
+		if err := json.Unmarshal(content, out); err != nil {
+			return fmt.Errorf("failed to parse the response to %s %s: %w", method, path, err)
+		}
+	}
+	return nil
+}
+
+func (c *client) get(path string, out interface{}) error {
+	return c.do(http.MethodGet, path, nil, out)
+}
+
+func (c *client) patch(path string, body interface{}) error {
+	return c.do(http.MethodPatch, path, body, nil)
+}
+
+func (c *client) post(path string, body interface{}) error {
+	return c.do(http.MethodPost, path, body, nil)
+}
+
+// findSystem returns the path of the only system of the service.
+func (c *client) findSystem() (string, error) {
+	var systems collection
+	if err := c.get(systemsCollection, &systems); err != nil {
+		return "", err
+	}
+	if len(systems.Members) != 1 {
+		return "", fmt.Errorf("the BMC manages %d systems, the BMC address must include the path of one of them",
+			len(systems.Members))
+	}
+	return systems.Members[0].ID, nil
+}
+
+func (c *client) system(path string) (system computerSystem, err error) {
+	err = c.get(path, &system)
+	return
+}
+
+// reset runs a ComputerSystem.Reset action.
+func (c *client) reset(system computerSystem, resetType string) error {
+	target := system.Actions.Reset.Target
+	if target == "" {
+		return fmt.Errorf("the system does not support the Reset action")
+	}
+	return c.post(target, map[string]string{"ResetType": resetType})
+}
+
+// setBootOverride sets the device the system boots from.
+func (c *client) setBootOverride(systemPath, target, enabled string) error {
+	return c.patch(systemPath, map[string]interface{}{
+		"Boot": map[string]string{
+			"BootSourceOverrideTarget":  target,
+			"BootSourceOverrideEnabled": enabled,
+		},
+	})
+}
+
+// virtualCD returns the path and state of the virtual media device
+// accepting CD images in the manager of the system.
+func (c *client) virtualCD(system computerSystem) (path string, media virtualMedia, err error) {
+	if len(system.Links.ManagedBy) == 0 {
+		return "", media, fmt.Errorf("the system has no manager")
+	}
+	var mgr manager
+	if err = c.get(system.Links.ManagedBy[0].ID, &mgr); err != nil {
+		return
+	}
+	if mgr.VirtualMedia == nil {
+		return "", media, fmt.Errorf("the manager of the system does not support virtual media")
+	}
+
+	var devices collection
+	if err = c.get(mgr.VirtualMedia.ID, &devices); err != nil {
+		return
+	}
+	for _, member := range devices.Members {
+		var device virtualMedia
+		if err = c.get(member.ID, &device); err != nil {
+			return
+		}
+		for _, mediaType := range device.MediaTypes {
+			if mediaType == "CD" || mediaType == "DVD" {
+				return member.ID, device, nil
+			}
+		}
+	}
+	return "", media, fmt.Errorf("the manager of the system has no virtual CD drive")
+}
+
+// insertMedia inserts an image in a virtual media device, using the
+// InsertMedia action when available.
+func (c *client) insertMedia(path string, media virtualMedia, image string) error {
+	if target := media.Actions.InsertMedia.Target; target != "" {
+		return c.post(target, map[string]interface{}{
+			"Image":          image,
+			"Inserted":       true,
+			"WriteProtected": true,
+		})
+	}
+	return c.patch(path, map[string]interface{}{
+		"Image":    image,
+		"Inserted": true,
+	})
+}
+
+// ejectMedia ejects the image from a virtual media device, using the
+// EjectMedia action when available.
+func (c *client) ejectMedia(path string, media virtualMedia) error {
+	if target := media.Actions.EjectMedia.Target; target != "" {
+		return c.post(target, map[string]interface{}{})
+	}
+	return c.patch(path, map[string]interface{}{
+		"Image":    nil,
+		"Inserted": false,
+	})
+}
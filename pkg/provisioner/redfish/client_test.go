@@ -0,0 +1,108 @@
+package redfish
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+)
+
+func TestParseAddress(t *testing.T) {
+	cases := []struct {
+		address            string
+		expectedEndpoint   string
+		expectedSystemPath string
+		expectedError      string
+	}{
+		{
+			address:            "redfish://bmc.test/redfish/v1/Systems/1",
+			expectedEndpoint:   "https://bmc.test",
+			expectedSystemPath: "/redfish/v1/Systems/1",
+		},
+		{
+			address:            "redfish+http://192.168.111.1:8000/redfish/v1/Systems/abc/",
+			expectedEndpoint:   "http://192.168.111.1:8000",
+			expectedSystemPath: "/redfish/v1/Systems/abc",
+		},
+		{
+			address:          "redfish-virtualmedia+https://[fd00::1]:8443",
+			expectedEndpoint: "https://[fd00::1]:8443",
+		},
+		{
+			address:            "idrac-virtualmedia://bmc.test/redfish/v1/Systems/System.Embedded.1",
+			expectedEndpoint:   "https://bmc.test",
+			expectedSystemPath: "/redfish/v1/Systems/System.Embedded.1",
+		},
+		{
+			address:          "ilo5-redfish://bmc.test",
+			expectedEndpoint: "https://bmc.test",
+		},
+		{
+			address:          "ilo5-virtualmedia://bmc.test",
+			expectedEndpoint: "https://bmc.test",
+		},
+		{
+			address:          "idrac-redfish+http://bmc.test",
+			expectedEndpoint: "http://bmc.test",
+		},
+		{
+			address:       "idrac://bmc.test",
+			expectedError: "the Redfish provisioner does not support BMC type idrac",
+		},
+		{
+			address:       "notredfish://bmc.test",
+			expectedError: "the Redfish provisioner does not support BMC type notredfish",
+		},
+		{
+			address:       "fake-virtualmedia://bmc.test",
+			expectedError: "the Redfish provisioner does not support BMC type fake-virtualmedia",
+		},
+		{
+			address:       "ipmi://bmc.test:623",
+			expectedError: "the Redfish provisioner does not support BMC type ipmi",
+		},
+		{
+			address:       "redfish+ftp://bmc.test",
+			expectedError: "unsupported transport ftp in BMC address",
+		},
+		{
+			address:       "",
+			expectedError: "missing BMC address",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.address, func(t *testing.T) {
+			parsed, err := parseAddress(tc.address)
+			if tc.expectedError != "" {
+				assert.EqualError(t, err, tc.expectedError)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expectedEndpoint, parsed.endpoint)
+			assert.Equal(t, tc.expectedSystemPath, parsed.systemPath)
+		})
+	}
+}
+
+func TestNewClientSharesTransport(t *testing.T) {
+	first, err := newClient("https://bmc-1.test", bmc.Credentials{Username: "admin"}, false)
+	assert.NoError(t, err)
+	second, err := newClient("https://bmc-1.test", bmc.Credentials{Username: "other"}, false)
+	assert.NoError(t, err)
+	assert.Same(t, first.httpClient.Transport, second.httpClient.Transport)
+	assert.Equal(t, idleConnTimeout, first.httpClient.Transport.(*http.Transport).IdleConnTimeout)
+
+	insecure, err := newClient("https://bmc-1.test", bmc.Credentials{}, true)
+	assert.NoError(t, err)
+	assert.NotSame(t, first.httpClient.Transport, insecure.httpClient.Transport)
+
+	other, err := newClient("https://bmc-2.test", bmc.Credentials{}, false)
+	assert.NoError(t, err)
+	assert.NotSame(t, first.httpClient.Transport, other.httpClient.Transport)
+
+	_, err = newClient("https://bmc-3.test", bmc.Credentials{CACertificate: []byte("not a certificate")}, false)
+	assert.Error(t, err)
+}
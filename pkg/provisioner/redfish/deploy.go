@@ -0,0 +1,53 @@
+package redfish
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+type deployState int
+
+const (
+	// deployUnknown is the state of a deployment not started by this
+	// process, e.g. before the operator restarted
+	deployUnknown deployState = iota
+	// deployStarted is the state of a deployment once the host was
+	// reset to boot the deploy ISO
+	deployStarted
+	// deployBooted is the state of a deployment once the host was seen
+	// running the deploy ISO
+	deployBooted
+)
+
+// deployTracker records the progress of the custom deployments. The
+// BMC may report the host as powered off for a while after the reset,
+// so the end of a deployment cannot be told from the power state alone.
+// The progress is only kept in memory, as it is of no interest outside
+// the provisioner.
+type deployTracker struct {
+	lock   sync.Mutex
+	states map[types.NamespacedName]deployState
+}
+
+func newDeployTracker() *deployTracker {
+	return &deployTracker{states: map[types.NamespacedName]deployState{}}
+}
+
+func (t *deployTracker) get(name types.NamespacedName) deployState {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	return t.states[name]
+}
+
+func (t *deployTracker) set(name types.NamespacedName, state deployState) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.states[name] = state
+}
+
+func (t *deployTracker) forget(name types.NamespacedName) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	delete(t.states, name)
+}
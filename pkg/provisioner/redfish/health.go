@@ -0,0 +1,162 @@
+package redfish
+
+import (
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+)
+
+// CollectHealth collects the health of a host from its Redfish BMC,
+// for the provisioners that manage the host through a service that
+// does not report the sensor data, such as Ironic. It returns nil when
+// the BMC is not a Redfish one.
+func CollectHealth(bmcAddress string, creds bmc.Credentials, disableCertificateVerification bool) (*provisioner.HardwareHealth, error) {
+	if _, err := parseAddress(bmcAddress); err != nil {
+		return nil, nil
+	}
+	p := redfishProvisioner{
+		bmcAddress:              bmcAddress,
+		bmcCreds:                creds,
+		disableCertVerification: disableCertificateVerification,
+	}
+	return p.GetHardwareHealth()
+}
+
+// healthSeverity orders the health statuses from the best to the
+// worst.
+var healthSeverity = map[metal3v1alpha1.HealthStatus]int{
+	metal3v1alpha1.HealthOK:       0,
+	metal3v1alpha1.HealthWarning:  1,
+	metal3v1alpha1.HealthCritical: 2,
+}
+
+// healthStatus converts the health of a Redfish resource. It returns
+// an empty status when the health is not reported or the component is
+// not present.
+func healthStatus(s status, rollup bool) metal3v1alpha1.HealthStatus {
+	if s.State == "Absent" {
+		return ""
+	}
+	health := s.Health
+	if rollup && s.HealthRollup != "" {
+		health = s.HealthRollup
+	}
+	switch health {
+	case "OK", "Warning", "Critical":
+		return metal3v1alpha1.HealthStatus(health)
+	}
+	return ""
+}
+
+// healthCollector accumulates the health of the components of a host.
+type healthCollector struct {
+	health provisioner.HardwareHealth
+}
+
+func (h *healthCollector) add(name string, componentType metal3v1alpha1.ComponentType, s status, reading *float64, units provisioner.SensorUnits) {
+	health := healthStatus(s, false)
+	if health == "" {
+		return
+	}
+	if reading == nil {
+		units = ""
+	}
+	h.health.Components = append(h.health.Components, provisioner.ComponentHealth{
+		Name:    name,
+		Type:    componentType,
+		Status:  health,
+		Reading: reading,
+		Units:   units,
+	})
+	h.worsen(health)
+}
+
+func (h *healthCollector) worsen(health metal3v1alpha1.HealthStatus) {
+	if health != "" && healthSeverity[health] > healthSeverity[h.health.Status] {
+		h.health.Status = health
+	}
+}
+
+// hardwareHealth collects the health of a system and the readings of
+// the sensors of its chassis. The overall status is the worst of the
+// health rollup reported by the BMC and of the health of the
+// components.
+func (c *client) hardwareHealth(system computerSystem) (*provisioner.HardwareHealth, error) {
+	h := healthCollector{
+		health: provisioner.HardwareHealth{Status: metal3v1alpha1.HealthOK},
+	}
+	h.worsen(healthStatus(system.Status, true))
+
+	h.add("Processors", metal3v1alpha1.ComponentProcessor, system.ProcessorSummary.Status, nil, "")
+	h.add("Memory", metal3v1alpha1.ComponentMemory, system.MemorySummary.Status, nil, "")
+
+	if err := c.driveHealth(system, &h); err != nil {
+		return nil, err
+	}
+
+	for _, chassisLink := range system.Links.Chassis {
+		if err := c.chassisHealth(chassisLink.ID, &h); err != nil {
+			return nil, err
+		}
+	}
+	return &h.health, nil
+}
+
+func (c *client) driveHealth(system computerSystem, h *healthCollector) error {
+	if system.Storage == nil {
+		return nil
+	}
+	var controllers collection
+	if err := c.get(system.Storage.ID, &controllers); err != nil {
+		return err
+	}
+	for _, member := range controllers.Members {
+		var ctrl storage
+		if err := c.get(member.ID, &ctrl); err != nil {
+			return err
+		}
+		for _, driveLink := range ctrl.Drives {
+			var d drive
+			if err := c.get(driveLink.ID, &d); err != nil {
+				return err
+			}
+			h.add(d.Name, metal3v1alpha1.ComponentDisk, d.Status, nil, "")
+		}
+	}
+	return nil
+}
+
+func (c *client) chassisHealth(path string, h *healthCollector) error {
+	var ch chassis
+	if err := c.get(path, &ch); err != nil {
+		return err
+	}
+
+	if ch.Thermal != nil {
+		var t thermal
+		if err := c.get(ch.Thermal.ID, &t); err != nil {
+			return err
+		}
+		for _, fan := range t.Fans {
+			units := provisioner.UnitsRPM
+			if fan.ReadingUnits == "Percent" {
+				units = provisioner.UnitsPercent
+			}
+			h.add(fan.Name, metal3v1alpha1.ComponentFan, fan.Status, fan.Reading, units)
+		}
+		for _, temp := range t.Temperatures {
+			h.add(temp.Name, metal3v1alpha1.ComponentTemperature, temp.Status, temp.ReadingCelsius, provisioner.UnitsCelsius)
+		}
+	}
+
+	if ch.Power != nil {
+		var p power
+		if err := c.get(ch.Power.ID, &p); err != nil {
+			return err
+		}
+		for _, psu := range p.PowerSupplies {
+			h.add(psu.Name, metal3v1alpha1.ComponentPowerSupply, psu.Status, psu.PowerOutputWatts, provisioner.UnitsWatts)
+		}
+	}
+	return nil
+}
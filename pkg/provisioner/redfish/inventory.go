@@ -0,0 +1,189 @@
+package redfish
+
+import (
+	"strings"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+// architectures maps the Redfish instruction sets to the architecture
+// names used in the hardware details.
+var architectures = map[string]string{
+	"x86-64":   "x86_64",
+	"x86":      "i686",
+	"ARM-A64":  "aarch64",
+	"ARM-A32":  "armv7l",
+	"PowerISA": "ppc64le",
+}
+
+// hardwareDetails collects the hardware details of a system from its
+// Redfish inventory.
+func (c *client) hardwareDetails(system computerSystem) (*metal3v1alpha1.HardwareDetails, error) {
+	details := &metal3v1alpha1.HardwareDetails{
+		SystemVendor: metal3v1alpha1.HardwareSystemVendor{
+			Manufacturer: system.Manufacturer,
+			ProductName:  system.Model,
+			SerialNumber: system.SerialNumber,
+		},
+		Firmware: metal3v1alpha1.Firmware{
+			BIOS: metal3v1alpha1.BIOS{
+				Version: system.BiosVersion,
+			},
+		},
+		RAMMebibytes: int(system.MemorySummary.TotalSystemMemoryGiB * 1024),
+		Hostname:     system.HostName,
+		CPU: metal3v1alpha1.CPU{
+			Model: system.ProcessorSummary.Model,
+			Count: system.ProcessorSummary.Count,
+		},
+	}
+
+	if err := c.processorDetails(system, &details.CPU); err != nil {
+		return nil, err
+	}
+
+	nics, err := c.nicDetails(system)
+	if err != nil {
+		return nil, err
+	}
+	details.NIC = nics
+
+	disks, err := c.storageDetails(system)
+	if err != nil {
+		return nil, err
+	}
+	details.Storage = disks
+
+	return details, nil
+}
+
+func (c *client) processorDetails(system computerSystem, cpu *metal3v1alpha1.CPU) error {
+	if system.Processors == nil {
+		return nil
+	}
+	var processors collection
+	if err := c.get(system.Processors.ID, &processors); err != nil {
+		return err
+	}
+	for _, member := range processors.Members {
+		var proc processor
+		if err := c.get(member.ID, &proc); err != nil {
+			return err
+		}
+		if proc.ProcessorType != "" && proc.ProcessorType != "CPU" {
+			continue
+		}
+		cpu.Sockets++
+		if cpu.Sockets > 1 {
+			continue
+		}
+		if cpu.Model == "" {
+			cpu.Model = proc.Model
+		}
+		cpu.Arch = architectures[proc.InstructionSet]
+		cpu.ClockMegahertz = metal3v1alpha1.ClockSpeed(proc.MaxSpeedMHz) * metal3v1alpha1.MegaHertz
+		cpu.CoresPerSocket = proc.TotalCores
+	}
+	return nil
+}
+
+func (c *client) nicDetails(system computerSystem) (nics []metal3v1alpha1.NIC, err error) {
+	if system.EthernetInterfaces == nil {
+		return nil, nil
+	}
+	var interfaces collection
+	if err := c.get(system.EthernetInterfaces.ID, &interfaces); err != nil {
+		return nil, err
+	}
+	for _, member := range interfaces.Members {
+		var iface ethernetInterface
+		if err := c.get(member.ID, &iface); err != nil {
+			return nil, err
+		}
+		nic := metal3v1alpha1.NIC{
+			Name:      iface.ID,
+			MAC:       strings.ToLower(iface.MACAddress),
+			SpeedGbps: iface.SpeedMbps / 1000,
+		}
+		for _, addresses := range [][]ipAddress{iface.IPv4Addresses, iface.IPv6Addresses} {
+			if nic.IP == "" && len(addresses) > 0 {
+				nic.IP = addresses[0].Address
+			}
+		}
+		nics = append(nics, nic)
+	}
+	return nics, nil
+}
+
+func diskType(mediaType, protocol string) metal3v1alpha1.DiskType {
+	switch {
+	case protocol == "NVMe":
+		return metal3v1alpha1.NVME
+	case mediaType == "HDD":
+		return metal3v1alpha1.HDD
+	case mediaType == "SSD":
+		return metal3v1alpha1.SSD
+	}
+	return ""
+}
+
+// storageDetails returns the drives of the Storage resources, or the
+// devices of the SimpleStorage resources on BMCs that do not implement
+// Storage.
+func (c *client) storageDetails(system computerSystem) (disks []metal3v1alpha1.Storage, err error) {
+	if system.Storage != nil {
+		var controllers collection
+		if err := c.get(system.Storage.ID, &controllers); err != nil {
+			return nil, err
+		}
+		for _, member := range controllers.Members {
+			var ctrl storage
+			if err := c.get(member.ID, &ctrl); err != nil {
+				return nil, err
+			}
+			for _, driveLink := range ctrl.Drives {
+				var d drive
+				if err := c.get(driveLink.ID, &d); err != nil {
+					return nil, err
+				}
+				disks = append(disks, metal3v1alpha1.Storage{
+					Name:         d.Name,
+					Type:         diskType(d.MediaType, d.Protocol),
+					Rotational:   d.MediaType == "HDD",
+					SizeBytes:    metal3v1alpha1.Capacity(d.CapacityBytes),
+					Vendor:       d.Manufacturer,
+					Model:        d.Model,
+					SerialNumber: d.SerialNumber,
+				})
+			}
+		}
+		return disks, nil
+	}
+
+	if system.SimpleStorage == nil {
+		return nil, nil
+	}
+	var controllers collection
+	if err := c.get(system.SimpleStorage.ID, &controllers); err != nil {
+		return nil, err
+	}
+	for _, member := range controllers.Members {
+		var ctrl simpleStorage
+		if err := c.get(member.ID, &ctrl); err != nil {
+			return nil, err
+		}
+		for _, device := range ctrl.Devices {
+			if device.CapacityBytes == 0 {
+				// Not a disk, or not present
+				continue
+			}
+			disks = append(disks, metal3v1alpha1.Storage{
+				Name:      device.Name,
+				SizeBytes: metal3v1alpha1.Capacity(device.CapacityBytes),
+				Vendor:    device.Manufacturer,
+				Model:     device.Model,
+			})
+		}
+	}
+	return disks, nil
+}
@@ -0,0 +1,535 @@
+package redfish
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	logz "sigs.k8s.io/controller-runtime/pkg/log/zap"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+)
+
+const (
+	powerStateOn  = "On"
+	powerStateOff = "Off"
+
+	bootTargetCD   = "Cd"
+	bootTargetNone = "None"
+
+	bootOnce       = "Once"
+	bootContinuous = "Continuous"
+	bootDisabled   = "Disabled"
+
+	liveISOFormat = "live-iso"
+)
+
+var provisionRequeueDelay = time.Second * 10
+var powerRequeueDelay = time.Second * 10
+
+type redfishConfig struct {
+	// The ISO booted to run custom deployments
+	deployISOURL string
+}
+
+type redfishProvisionerFactory struct {
+	log     logr.Logger
+	config  redfishConfig
+	deploys *deployTracker
+}
+
+// NewProvisionerFactory returns a factory for provisioners that manage
+// hosts through their Redfish BMC, without Ironic.
+func NewProvisionerFactory() provisioner.Factory {
+	return redfishProvisionerFactory{
+		log: logz.New().WithName("provisioner").WithName("redfish"),
+		config: redfishConfig{
+			deployISOURL: os.Getenv("DEPLOY_ISO_URL"),
+		},
+		deploys: newDeployTracker(),
+	}
+}
+
+// NewProvisioner returns a new Redfish provisioner for a host.
+func (f redfishProvisionerFactory) NewProvisioner(hostData provisioner.HostData, publisher provisioner.EventPublisher) (provisioner.Provisioner, error) {
+	return f.redfishProvisioner(hostData, publisher), nil
+}
+
+func (f redfishProvisionerFactory) redfishProvisioner(hostData provisioner.HostData, publisher provisioner.EventPublisher) *redfishProvisioner {
+	return &redfishProvisioner{
+		config:                  f.config,
+		deploys:                 f.deploys,
+		objectMeta:              hostData.ObjectMeta,
+		provID:                  hostData.ProvisionerID,
+		bmcAddress:              hostData.BMCAddress,
+		bmcCreds:                hostData.BMCCredentials,
+		disableCertVerification: hostData.DisableCertificateVerification,
+		log:                     f.log.WithValues("host", hostData.ObjectMeta.Namespace+"~"+hostData.ObjectMeta.Name),
+		publisher:               publisher,
+	}
+}
+
+// redfishProvisioner implements the provisioner.Provisioner interface
+// by talking to the Redfish service of the BMC of the host.
+type redfishProvisioner struct {
+	config redfishConfig
+	// the progress of the custom deployments of all hosts
+	deploys *deployTracker
+	// the object metadata of the BareMetalHost resource
+	objectMeta metav1.ObjectMeta
+	// the path of the system on the BMC, once registered
+	provID string
+	// the address of the BMC
+	bmcAddress string
+	// the bmc credentials
+	bmcCreds bmc.Credentials
+	// whether to skip verifying the BMC certificate
+	disableCertVerification bool
+	// a client for the BMC, once connected
+	client *client
+	// a logger configured for this host
+	log logr.Logger
+	// an event publisher for recording significant events
+	publisher provisioner.EventPublisher
+}
+
+// connect creates the client for the BMC and returns the path of the
+// system. The path in the BMC address is used when set, so the host
+// follows changes of address.
+func (p *redfishProvisioner) connect() (systemPath string, err error) {
+	address, err := parseAddress(p.bmcAddress)
+	if err != nil {
+		return "", err
+	}
+	p.client, err = newClient(address.endpoint, p.bmcCreds, p.disableCertVerification)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case address.systemPath != "":
+		return address.systemPath, nil
+	case p.provID != "":
+		return p.provID, nil
+	default:
+		return p.client.findSystem()
+	}
+}
+
+// getSystem returns the path and the current state of the system.
+func (p *redfishProvisioner) getSystem() (path string, system computerSystem, err error) {
+	path, err = p.connect()
+	if err != nil {
+		return
+	}
+	system, err = p.client.system(path)
+	if err != nil {
+		err = fmt.Errorf("failed to get the system from the BMC: %w", err)
+	}
+	return
+}
+
+func (p *redfishProvisioner) HasCapacity(state metal3v1alpha1.ProvisioningState) (result bool, reason string, err error) {
+	// Each host is managed through its own BMC
+	return true, "", nil
+}
+
+func (p *redfishProvisioner) Backend() string {
+	return ""
+}
+
+// ValidateManagementAccess tests the connection information for the
+// host to verify that the location and credentials work.
+func (p *redfishProvisioner) ValidateManagementAccess(data provisioner.ManagementAccessData, credentialsChanged, force bool) (result provisioner.Result, provID string, err error) {
+	p.log.Info("testing management access")
+
+	path, _, err := p.getSystem()
+	if err != nil {
+		result.ErrorMessage = err.Error()
+		return result, "", nil
+	}
+
+	if p.provID != path {
+		p.log.Info("registered system", "path", path)
+		p.publisher("Registered", "Registered new host")
+	}
+	return result, path, nil
+}
+
+func (p *redfishProvisioner) PreprovisioningImageFormats() ([]metal3v1alpha1.ImageFormat, error) {
+	return nil, nil
+}
+
+// InspectHardware collects the hardware details from the Redfish
+// inventory of the system. The host does not need to be booted.
+func (p *redfishProvisioner) InspectHardware(data provisioner.InspectData, force, refresh bool) (result provisioner.Result, started bool, details *metal3v1alpha1.HardwareDetails, err error) {
+	started = true
+	if refresh {
+		// The inventory is read again on the next call, once the
+		// request is acknowledged
+		result.Dirty = true
+		return
+	}
+
+	_, system, err := p.getSystem()
+	if err != nil {
+		return
+	}
+	details, err = p.client.hardwareDetails(system)
+	if err != nil {
+		err = fmt.Errorf("failed to read the hardware inventory: %w", err)
+		return
+	}
+
+	p.publisher("InspectionComplete", "Hardware inspection completed")
+	return
+}
+
+// UpdateHardwareState fetches the power state of the system.
+func (p *redfishProvisioner) UpdateHardwareState() (hwState provisioner.HardwareState, err error) {
+	_, system, err := p.getSystem()
+	if err != nil {
+		return
+	}
+
+	var poweredOn bool
+	switch system.PowerState {
+	case powerStateOn:
+		poweredOn = true
+		hwState.PoweredOn = &poweredOn
+	case powerStateOff:
+		hwState.PoweredOn = &poweredOn
+	default:
+		p.log.Info("unknown power state", "state", system.PowerState)
+	}
+	return
+}
+
+// GetHardwareHealth collects the health of the system and the
+// readings of the sensors of its chassis.
+func (p *redfishProvisioner) GetHardwareHealth() (health *provisioner.HardwareHealth, err error) {
+	_, system, err := p.getSystem()
+	if err != nil {
+		return
+	}
+	health, err = p.client.hardwareHealth(system)
+	if err != nil {
+		err = fmt.Errorf("failed to collect the hardware health: %w", err)
+	}
+	return
+}
+
+// Adopt does nothing, as the provisioner keeps no state outside the
+// BMC.
+func (p *redfishProvisioner) Adopt(data provisioner.AdoptData, force bool) (result provisioner.Result, err error) {
+	return
+}
+
+// Prepare reports an error when changes of the configuration are
+// requested, as they need an agent running on the host.
+func (p *redfishProvisioner) Prepare(data provisioner.PrepareData, unprepared bool) (result provisioner.Result, started bool, err error) {
+	if !unprepared {
+		return
+	}
+	if data.RAIDConfig != nil &&
+		(len(data.RAIDConfig.HardwareRAIDVolumes) > 0 || len(data.RAIDConfig.SoftwareRAIDVolumes) > 0) {
+		result.ErrorMessage = "RAID configuration is not supported by the Redfish provisioner"
+		return
+	}
+	if data.FirmwareConfig != nil || len(data.FirmwareUpdates) > 0 {
+		result.ErrorMessage = "firmware changes are not supported by the Redfish provisioner"
+	}
+	return
+}
+
+// Service reports an error when firmware changes are requested.
+func (p *redfishProvisioner) Service(data provisioner.ServicingData, restart bool) (result provisioner.Result, started bool, err error) {
+	if restart && (data.FirmwareConfig != nil || len(data.FirmwareUpdates) > 0) {
+		result.ErrorMessage = "firmware changes are not supported by the Redfish provisioner"
+	}
+	return
+}
+
+// GetFirmwareSettings returns the current BIOS attributes of the
+// system.
+func (p *redfishProvisioner) GetFirmwareSettings(includeSchema bool) (settings map[string]string, schema map[string]metal3v1alpha1.SettingSchema, err error) {
+	_, system, err := p.getSystem()
+	if err != nil {
+		return
+	}
+	if system.Bios == nil {
+		return nil, nil, nil
+	}
+
+	var attributes bios
+	if err = p.client.get(system.Bios.ID, &attributes); err != nil {
+		return nil, nil, fmt.Errorf("failed to get the BIOS settings: %w", err)
+	}
+	settings = make(map[string]string, len(attributes.Attributes))
+	for name, value := range attributes.Attributes {
+		settings[name] = fmt.Sprint(value)
+	}
+	return settings, nil, nil
+}
+
+// GetFirmwareComponents returns the BIOS version of the system.
+func (p *redfishProvisioner) GetFirmwareComponents() (components []metal3v1alpha1.FirmwareComponentStatus, err error) {
+	if p.provID == "" {
+		return nil, provisioner.ErrNeedsRegistration
+	}
+	_, system, err := p.getSystem()
+	if err != nil {
+		return nil, err
+	}
+	if system.BiosVersion == "" {
+		return nil, nil
+	}
+	return []metal3v1alpha1.FirmwareComponentStatus{
+		{
+			Component:      "bios",
+			CurrentVersion: system.BiosVersion,
+		},
+	}, nil
+}
+
+// bootFromCD inserts an ISO in the virtual CD drive and boots the system
+// from it.
+func (p *redfishProvisioner) bootFromCD(path string, system computerSystem, mediaPath string, media virtualMedia, image, enabled string) error {
+	if media.Inserted {
+		if err := p.client.ejectMedia(mediaPath, media); err != nil {
+			return fmt.Errorf("failed to eject %s: %w", media.Image, err)
+		}
+	}
+	if err := p.client.insertMedia(mediaPath, media, image); err != nil {
+		return fmt.Errorf("failed to insert %s: %w", image, err)
+	}
+	if err := p.client.setBootOverride(path, bootTargetCD, enabled); err != nil {
+		return fmt.Errorf("failed to boot from virtual media: %w", err)
+	}
+
+	resetType := "On"
+	if system.PowerState == powerStateOn {
+		resetType = "ForceRestart"
+	}
+	if err := p.client.reset(system, resetType); err != nil {
+		return fmt.Errorf("failed to reboot the system: %w", err)
+	}
+	return nil
+}
+
+// Provision boots a live ISO image, or runs a custom deployment from
+// the deploy ISO. The deploy ISO is expected to power the host off when
+// the deployment is done, after which the host is booted from its disk.
+// A deployment whose progress is unknown, because the operator
+// restarted, is started again unless the host is running it.
+// Other images cannot be written to disk without an agent on the host.
+func (p *redfishProvisioner) Provision(data provisioner.ProvisionData) (result provisioner.Result, err error) {
+	var image string
+	var bootMode string
+	switch {
+	case data.CustomDeploy != nil:
+		if p.config.deployISOURL == "" {
+			result.ErrorMessage = "DEPLOY_ISO_URL must be set for custom deployments with the Redfish provisioner"
+			return
+		}
+		image = p.config.deployISOURL
+		bootMode = bootOnce
+	case data.Image.DiskFormat != nil && *data.Image.DiskFormat == liveISOFormat:
+		image = data.Image.URL
+		bootMode = bootContinuous
+	default:
+		result.ErrorMessage = "the Redfish provisioner only supports live-iso images and custom deployments"
+		return
+	}
+
+	path, system, err := p.getSystem()
+	if err != nil {
+		return
+	}
+	mediaPath, media, err := p.client.virtualCD(system)
+	if err != nil {
+		return
+	}
+
+	name := types.NamespacedName{Namespace: p.objectMeta.Namespace, Name: p.objectMeta.Name}
+	deploy := p.deploys.get(name)
+	restart := bootMode == bootOnce && deploy == deployUnknown && system.PowerState != powerStateOn
+
+	if !media.Inserted || media.Image != image || restart {
+		p.log.Info("booting from virtual media", "image", image)
+		if err = p.bootFromCD(path, system, mediaPath, media, image, bootMode); err != nil {
+			return
+		}
+		if bootMode == bootOnce {
+			p.deploys.set(name, deployStarted)
+		}
+		p.publisher("ProvisioningStarted", fmt.Sprintf("Image provisioning started for %s", image))
+		result.Dirty = true
+		result.RequeueAfter = provisionRequeueDelay
+		return
+	}
+
+	if bootMode == bootContinuous {
+		if system.PowerState != powerStateOn {
+			p.log.Info("powering on the live ISO")
+			if err = p.client.reset(system, "On"); err != nil {
+				return
+			}
+			result.Dirty = true
+			result.RequeueAfter = provisionRequeueDelay
+			return
+		}
+		p.publisher("ProvisioningComplete", fmt.Sprintf("Image provisioning completed for %s", image))
+		return
+	}
+
+	// The BMC may report the host as powered off for a while after
+	// the reset, so the deployment is only done once the host has been
+	// seen powered on and then off again.
+	if system.PowerState == powerStateOn {
+		if deploy != deployBooted {
+			p.log.Info("deploy ISO is running")
+			p.deploys.set(name, deployBooted)
+		}
+		p.log.Info("waiting for the deploy ISO to power off the host")
+		result.Dirty = true
+		result.RequeueAfter = provisionRequeueDelay
+		return
+	}
+	if deploy != deployBooted {
+		p.log.Info("waiting for the host to boot the deploy ISO", "powerState", system.PowerState)
+		result.Dirty = true
+		result.RequeueAfter = provisionRequeueDelay
+		return
+	}
+
+	p.log.Info("deployment done, booting from disk")
+	p.deploys.forget(name)
+	if err = p.client.ejectMedia(mediaPath, media); err != nil {
+		return
+	}
+	if err = p.client.setBootOverride(path, bootTargetNone, bootDisabled); err != nil {
+		return
+	}
+	if err = p.client.reset(system, "On"); err != nil {
+		return
+	}
+	p.publisher("ProvisioningComplete", "Custom deploy provisioning completed")
+	return
+}
+
+// Deprovision ejects the virtual media and powers the host off. The
+// content of the disks is not erased.
+func (p *redfishProvisioner) Deprovision(force bool) (result provisioner.Result, err error) {
+	p.log.Info("ensuring host is deprovisioned")
+	p.deploys.forget(types.NamespacedName{Namespace: p.objectMeta.Namespace, Name: p.objectMeta.Name})
+
+	path, system, err := p.getSystem()
+	if err != nil {
+		return
+	}
+
+	mediaPath, media, err := p.client.virtualCD(system)
+	if err != nil {
+		return
+	}
+	if media.Inserted {
+		p.publisher("DeprovisioningStarted", "Image deprovisioning started")
+		if err = p.client.ejectMedia(mediaPath, media); err != nil {
+			return
+		}
+		result.Dirty = true
+	}
+	if system.Boot.BootSourceOverrideEnabled != "" && system.Boot.BootSourceOverrideEnabled != bootDisabled {
+		if err = p.client.setBootOverride(path, bootTargetNone, bootDisabled); err != nil {
+			return
+		}
+		result.Dirty = true
+	}
+	if system.PowerState == powerStateOn {
+		if err = p.client.reset(system, "ForceOff"); err != nil {
+			return
+		}
+		result.Dirty = true
+	}
+
+	if result.Dirty {
+		result.RequeueAfter = provisionRequeueDelay
+		return
+	}
+	p.publisher("DeprovisioningComplete", "Image deprovisioning completed")
+	return
+}
+
+// Delete does nothing, as the provisioner keeps no state outside the
+// BMC.
+func (p *redfishProvisioner) Delete() (result provisioner.Result, err error) {
+	return
+}
+
+// Detach does nothing, as the provisioner keeps no state outside the
+// BMC.
+func (p *redfishProvisioner) Detach() (result provisioner.Result, err error) {
+	return
+}
+
+// PowerOn ensures the server is powered on independently of any image
+// provisioning operation.
+func (p *redfishProvisioner) PowerOn() (result provisioner.Result, err error) {
+	p.log.Info("ensuring host is powered on")
+
+	_, system, err := p.getSystem()
+	if err != nil {
+		return
+	}
+	if system.PowerState == powerStateOn {
+		return
+	}
+
+	if err = p.client.reset(system, "On"); err != nil {
+		return result, fmt.Errorf("failed to power on: %w", err)
+	}
+	p.publisher("PowerOn", "Host powered on")
+	result.Dirty = true
+	result.RequeueAfter = powerRequeueDelay
+	return
+}
+
+// PowerOff ensures the server is powered off independently of any image
+// provisioning operation.
+func (p *redfishProvisioner) PowerOff(rebootMode metal3v1alpha1.RebootMode) (result provisioner.Result, err error) {
+	p.log.Info("ensuring host is powered off", "mode", rebootMode)
+
+	_, system, err := p.getSystem()
+	if err != nil {
+		return
+	}
+	if system.PowerState == powerStateOff {
+		return
+	}
+
+	resetType := "ForceOff"
+	message := "Host powered off"
+	if rebootMode == metal3v1alpha1.RebootModeSoft {
+		resetType = "GracefulShutdown"
+		message = "Host soft powered off"
+	}
+	if err = p.client.reset(system, resetType); err != nil {
+		return result, fmt.Errorf("failed to power off: %w", err)
+	}
+	p.publisher("PowerOff", message)
+	result.Dirty = true
+	result.RequeueAfter = powerRequeueDelay
+	return
+}
+
+// IsReady always returns true, as there is no provisioning service to
+// wait for.
+func (p *redfishProvisioner) IsReady() (result bool, err error) {
+	return true, nil
+}
@@ -0,0 +1,408 @@
+package redfish
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc/emulator"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/redfish/testserver"
+)
+
+// Implements provisioner.EventPublisher to swallow events for tests.
+func nullEventPublisher(reason, message string) {}
+
+func newTestProvisioner(bmcAddress, provID string) *redfishProvisioner {
+	factory := redfishProvisionerFactory{
+		log: logf.Log,
+		config: redfishConfig{
+			deployISOURL: "http://deploy.test/deploy.iso",
+		},
+		deploys: newDeployTracker(),
+	}
+	return factory.redfishProvisioner(provisioner.HostData{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myhost",
+			Namespace: "myns",
+		},
+		BMCAddress: bmcAddress,
+		BMCCredentials: bmc.Credentials{
+			Username: testserver.Username,
+			Password: testserver.Password,
+		},
+		ProvisionerID: provID,
+	}, nullEventPublisher)
+}
+
+func TestValidateManagementAccess(t *testing.T) {
+	bmcServer := testserver.NewRedfish(t).Start()
+	defer bmcServer.Stop()
+
+	prov := newTestProvisioner(bmcServer.Address("redfish"), "")
+	result, provID, err := prov.ValidateManagementAccess(provisioner.ManagementAccessData{}, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "", result.ErrorMessage)
+	assert.Equal(t, bmcServer.SystemPath(), provID)
+
+	// The system is looked up when the address has no path
+	address := bmcServer.Address("redfish-virtualmedia")
+	prov = newTestProvisioner(address[:len(address)-len(bmcServer.SystemPath())], "")
+	_, provID, err = prov.ValidateManagementAccess(provisioner.ManagementAccessData{}, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, bmcServer.SystemPath(), provID)
+
+	prov = newTestProvisioner(bmcServer.Address("redfish"), "")
+	prov.bmcCreds.Password = "wrong"
+	result, provID, err = prov.ValidateManagementAccess(provisioner.ManagementAccessData{}, false, false)
+	assert.NoError(t, err)
+	assert.Contains(t, result.ErrorMessage, "failed with status 401")
+	assert.Equal(t, "", provID)
+
+	prov = newTestProvisioner("ipmi://192.168.122.1:6233", "")
+	result, _, err = prov.ValidateManagementAccess(provisioner.ManagementAccessData{}, false, false)
+	assert.NoError(t, err)
+	assert.Equal(t, "the Redfish provisioner does not support BMC type ipmi", result.ErrorMessage)
+}
+
+func TestInspectHardware(t *testing.T) {
+	bmcServer := testserver.NewRedfish(t).Start()
+	defer bmcServer.Stop()
+
+	prov := newTestProvisioner(bmcServer.Address("redfish"), bmcServer.SystemPath())
+	_, started, details, err := prov.InspectHardware(provisioner.InspectData{}, false, false)
+	assert.NoError(t, err)
+	assert.True(t, started)
+	assert.Equal(t, &metal3v1alpha1.HardwareDetails{
+		SystemVendor: metal3v1alpha1.HardwareSystemVendor{
+			Manufacturer: "Metal3",
+			ProductName:  "Emulated System",
+			SerialNumber: "SN-1",
+		},
+		Firmware: metal3v1alpha1.Firmware{
+			BIOS: metal3v1alpha1.BIOS{Version: "1.2.3"},
+		},
+		RAMMebibytes: 16384,
+		NIC: []metal3v1alpha1.NIC{
+			{Name: "NIC1", MAC: "52:54:00:aa:bb:01", IP: "192.168.111.20", SpeedGbps: 10},
+			{Name: "NIC2", MAC: "52:54:00:aa:bb:02", SpeedGbps: 1},
+		},
+		Storage: []metal3v1alpha1.Storage{
+			{
+				Name:         "Disk1",
+				Type:         metal3v1alpha1.SSD,
+				SizeBytes:    480103981056,
+				Model:        "Emulated SSD",
+				SerialNumber: "S1",
+			},
+			{
+				Name:         "Disk2",
+				Type:         metal3v1alpha1.HDD,
+				Rotational:   true,
+				SizeBytes:    2000398934016,
+				Model:        "Emulated HDD",
+				SerialNumber: "S2",
+			},
+		},
+		CPU: metal3v1alpha1.CPU{
+			Arch:           "x86_64",
+			Model:          "Emulated CPU",
+			ClockMegahertz: 2400,
+			Count:          2,
+			Sockets:        2,
+			CoresPerSocket: 8,
+		},
+	}, details)
+
+	result, started, details, err := prov.InspectHardware(provisioner.InspectData{}, false, true)
+	assert.NoError(t, err)
+	assert.True(t, started)
+	assert.True(t, result.Dirty)
+	assert.Nil(t, details)
+}
+
+func TestPower(t *testing.T) {
+	bmcServer := testserver.NewRedfish(t).Start()
+	defer bmcServer.Stop()
+
+	prov := newTestProvisioner(bmcServer.Address("redfish"), bmcServer.SystemPath())
+
+	hwState, err := prov.UpdateHardwareState()
+	assert.NoError(t, err)
+	assert.False(t, *hwState.PoweredOn)
+
+	result, err := prov.PowerOn()
+	assert.NoError(t, err)
+	assert.True(t, result.Dirty)
+	assert.Equal(t, "On", bmcServer.PowerState())
+
+	result, err = prov.PowerOn()
+	assert.NoError(t, err)
+	assert.False(t, result.Dirty)
+
+	hwState, err = prov.UpdateHardwareState()
+	assert.NoError(t, err)
+	assert.True(t, *hwState.PoweredOn)
+
+	result, err = prov.PowerOff(metal3v1alpha1.RebootModeSoft)
+	assert.NoError(t, err)
+	assert.True(t, result.Dirty)
+	assert.Equal(t, "Off", bmcServer.PowerState())
+
+	result, err = prov.PowerOff(metal3v1alpha1.RebootModeHard)
+	assert.NoError(t, err)
+	assert.False(t, result.Dirty)
+
+	assert.Equal(t, []string{"On", "GracefulShutdown"}, bmcServer.Resets())
+}
+
+func TestProvisionLiveISO(t *testing.T) {
+	bmcServer := testserver.NewRedfish(t).Start()
+	defer bmcServer.Stop()
+
+	prov := newTestProvisioner(bmcServer.Address("redfish-virtualmedia"), bmcServer.SystemPath())
+	liveISO := "live-iso"
+	data := provisioner.ProvisionData{
+		Image: metal3v1alpha1.Image{
+			URL:        "http://images.test/live.iso",
+			DiskFormat: &liveISO,
+		},
+	}
+
+	result, err := prov.Provision(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "", result.ErrorMessage)
+	assert.True(t, result.Dirty)
+	assert.Equal(t, "http://images.test/live.iso", bmcServer.Media())
+	target, enabled := bmcServer.BootOverride()
+	assert.Equal(t, "Cd", target)
+	assert.Equal(t, "Continuous", enabled)
+	assert.Equal(t, "On", bmcServer.PowerState())
+
+	result, err = prov.Provision(data)
+	assert.NoError(t, err)
+	assert.False(t, result.Dirty)
+
+	// The live ISO is booted again when the host is powered off
+	bmcServer.SetPowerState("Off")
+	result, err = prov.Provision(data)
+	assert.NoError(t, err)
+	assert.True(t, result.Dirty)
+	assert.Equal(t, "On", bmcServer.PowerState())
+
+	result, err = prov.Deprovision(false)
+	assert.NoError(t, err)
+	assert.True(t, result.Dirty)
+	assert.Equal(t, "", bmcServer.Media())
+	assert.Equal(t, "Off", bmcServer.PowerState())
+	target, enabled = bmcServer.BootOverride()
+	assert.Equal(t, "None", target)
+	assert.Equal(t, "Disabled", enabled)
+
+	result, err = prov.Deprovision(false)
+	assert.NoError(t, err)
+	assert.False(t, result.Dirty)
+}
+
+func TestProvisionCustomDeploy(t *testing.T) {
+	bmcServer := testserver.NewRedfish(t).Start()
+	defer bmcServer.Stop()
+
+	prov := newTestProvisioner(bmcServer.Address("redfish"), bmcServer.SystemPath())
+	data := provisioner.ProvisionData{
+		CustomDeploy: &metal3v1alpha1.CustomDeploy{Method: "install_everything"},
+	}
+
+	result, err := prov.Provision(data)
+	assert.NoError(t, err)
+	assert.True(t, result.Dirty)
+	assert.Equal(t, "http://deploy.test/deploy.iso", bmcServer.Media())
+	assert.Equal(t, "On", bmcServer.PowerState())
+
+	// The BMC still reports the host off after the reset
+	bmcServer.SetPowerState("Off")
+	result, err = prov.Provision(data)
+	assert.NoError(t, err)
+	assert.True(t, result.Dirty)
+	assert.Equal(t, "http://deploy.test/deploy.iso", bmcServer.Media())
+	assert.Equal(t, "Off", bmcServer.PowerState())
+
+	bmcServer.SetPowerState("On")
+	result, err = prov.Provision(data)
+	assert.NoError(t, err)
+	assert.True(t, result.Dirty)
+
+	// The deploy ISO powers the host off when done
+	bmcServer.SetPowerState("Off")
+	result, err = prov.Provision(data)
+	assert.NoError(t, err)
+	assert.False(t, result.Dirty)
+	assert.Equal(t, "", bmcServer.Media())
+	assert.Equal(t, "On", bmcServer.PowerState())
+
+	// A deployment whose progress was lost is started again
+	prov = newTestProvisioner(bmcServer.Address("redfish"), bmcServer.SystemPath())
+	bmcServer.SetPowerState("Off")
+	result, err = prov.Provision(data)
+	assert.NoError(t, err)
+	assert.True(t, result.Dirty)
+	assert.Equal(t, "http://deploy.test/deploy.iso", bmcServer.Media())
+	assert.Equal(t, "On", bmcServer.PowerState())
+
+	// but not while the host is running it
+	prov = newTestProvisioner(bmcServer.Address("redfish"), bmcServer.SystemPath())
+	result, err = prov.Provision(data)
+	assert.NoError(t, err)
+	assert.True(t, result.Dirty)
+	assert.Equal(t, "On", bmcServer.PowerState())
+	bmcServer.SetPowerState("Off")
+	result, err = prov.Provision(data)
+	assert.NoError(t, err)
+	assert.False(t, result.Dirty)
+	assert.Equal(t, "", bmcServer.Media())
+
+	prov.config.deployISOURL = ""
+	result, err = prov.Provision(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "DEPLOY_ISO_URL must be set for custom deployments with the Redfish provisioner", result.ErrorMessage)
+}
+
+func TestProvisionUnsupportedImage(t *testing.T) {
+	prov := newTestProvisioner("redfish://bmc.test/redfish/v1/Systems/1", "/redfish/v1/Systems/1")
+	result, err := prov.Provision(provisioner.ProvisionData{
+		Image: metal3v1alpha1.Image{URL: "http://images.test/image.qcow2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "the Redfish provisioner only supports live-iso images and custom deployments", result.ErrorMessage)
+}
+
+func TestFirmware(t *testing.T) {
+	bmcServer := testserver.NewRedfish(t).Start()
+	defer bmcServer.Stop()
+
+	prov := newTestProvisioner(bmcServer.Address("redfish"), bmcServer.SystemPath())
+
+	settings, schema, err := prov.GetFirmwareSettings(true)
+	assert.NoError(t, err)
+	assert.Nil(t, schema)
+	assert.Equal(t, map[string]string{
+		"BootMode":             "Uefi",
+		"ProcVirtualization":   "Enabled",
+		"NumCoresPerProcessor": "8",
+	}, settings)
+
+	components, err := prov.GetFirmwareComponents()
+	assert.NoError(t, err)
+	assert.Equal(t, []metal3v1alpha1.FirmwareComponentStatus{
+		{Component: "bios", CurrentVersion: "1.2.3"},
+	}, components)
+
+	result, _, err := prov.Prepare(provisioner.PrepareData{
+		FirmwareConfig: &metal3v1alpha1.FirmwareConfig{},
+	}, true)
+	assert.NoError(t, err)
+	assert.Equal(t, "firmware changes are not supported by the Redfish provisioner", result.ErrorMessage)
+}
+
+func TestPowerFailure(t *testing.T) {
+	bmcServer := testserver.NewRedfish(t).Start()
+	defer bmcServer.Stop()
+	bmcServer.Emulator().InjectFailure(emulator.Failure{
+		Method:     http.MethodPost,
+		Path:       bmcServer.SystemPath() + "/Actions/*",
+		StatusCode: http.StatusInternalServerError,
+		Message:    "BMC is busy",
+		Count:      1,
+	})
+
+	prov := newTestProvisioner(bmcServer.Address("redfish"), bmcServer.SystemPath())
+
+	result, err := prov.PowerOn()
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to power on")
+	assert.False(t, result.Dirty)
+	assert.Equal(t, "Off", bmcServer.PowerState())
+
+	result, err = prov.PowerOn()
+	assert.NoError(t, err)
+	assert.True(t, result.Dirty)
+	assert.Equal(t, "On", bmcServer.PowerState())
+}
+
+func TestHardwareHealth(t *testing.T) {
+	bmcServer := testserver.NewRedfish(t).Start()
+	defer bmcServer.Stop()
+
+	prov := newTestProvisioner(bmcServer.Address("redfish"), bmcServer.SystemPath())
+
+	health, err := prov.GetHardwareHealth()
+	assert.NoError(t, err)
+	if assert.NotNil(t, health) {
+		assert.Equal(t, metal3v1alpha1.HealthOK, health.Status)
+		assert.Len(t, health.Components, 10)
+		fan := health.Components[4]
+		assert.Equal(t, "Fan1", fan.Name)
+		assert.Equal(t, metal3v1alpha1.ComponentFan, fan.Type)
+		assert.Equal(t, 5400.0, *fan.Reading)
+		assert.Equal(t, provisioner.UnitsRPM, fan.Units)
+	}
+
+	if err := bmcServer.Emulator().UpdateSystem("1", func(s *emulator.System) {
+		s.Drives[1].Health = "Critical"
+		s.MemoryHealth = "Warning"
+		s.PowerSupplies[0].Health = "Critical"
+		s.PowerSupplies[0].Reading = 0
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	health, err = prov.GetHardwareHealth()
+	assert.NoError(t, err)
+	if assert.NotNil(t, health) {
+		assert.Equal(t, metal3v1alpha1.HealthCritical, health.Status)
+		statuses := map[string]metal3v1alpha1.HealthStatus{}
+		for _, c := range health.Components {
+			statuses[c.Name] = c.Status
+		}
+		assert.Equal(t, metal3v1alpha1.HealthWarning, statuses["Memory"])
+		assert.Equal(t, metal3v1alpha1.HealthCritical, statuses["Disk2"])
+		assert.Equal(t, metal3v1alpha1.HealthCritical, statuses["PS1"])
+		assert.Equal(t, metal3v1alpha1.HealthOK, statuses["PS2"])
+	}
+
+	bmcServer.Emulator().InjectFailure(emulator.Failure{
+		Path:       "/redfish/v1/Chassis/*",
+		StatusCode: http.StatusInternalServerError,
+		Message:    "sensors unavailable",
+	})
+	health, err = prov.GetHardwareHealth()
+	assert.Error(t, err)
+	assert.Nil(t, health)
+
+	// The power state does not depend on the sensors
+	hwState, err := prov.UpdateHardwareState()
+	assert.NoError(t, err)
+	assert.False(t, *hwState.PoweredOn)
+}
+
+func TestCollectHealth(t *testing.T) {
+	bmcServer := testserver.NewRedfish(t).Start()
+	defer bmcServer.Stop()
+
+	creds := bmc.Credentials{Username: testserver.Username, Password: testserver.Password}
+	health, err := CollectHealth(bmcServer.Address("idrac-virtualmedia"), creds, false)
+	assert.NoError(t, err)
+	if assert.NotNil(t, health) {
+		assert.Equal(t, metal3v1alpha1.HealthOK, health.Status)
+		assert.Len(t, health.Components, 10)
+	}
+
+	health, err = CollectHealth("ipmi://192.168.122.1:6233", creds, false)
+	assert.NoError(t, err)
+	assert.Nil(t, health)
+}
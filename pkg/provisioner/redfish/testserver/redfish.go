@@ -0,0 +1,103 @@
+package testserver
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/metal3-io/baremetal-operator/pkg/bmc/emulator"
+)
+
+const (
+	systemID = "1"
+
+	// Username is the BMC username accepted by the server
+	Username = "admin"
+	// Password is the BMC password accepted by the server
+	Password = "password"
+)
+
+// Redfish is a Redfish test server emulating a BMC that manages a
+// single system.
+type Redfish struct {
+	t        *testing.T
+	server   *httptest.Server
+	emulator *emulator.Emulator
+}
+
+// NewRedfish returns a Redfish test server with the system powered off.
+func NewRedfish(t *testing.T) *Redfish {
+	return &Redfish{
+		t:        t,
+		emulator: emulator.New(Username, Password).AddSystem(emulator.DefaultSystem(systemID)),
+	}
+}
+
+// Emulator returns the emulator behind the server, to inject failures or
+// change the state of the system.
+func (r *Redfish) Emulator() *emulator.Emulator {
+	return r.emulator
+}
+
+// Start runs the server
+func (r *Redfish) Start() *Redfish {
+	r.server = httptest.NewServer(r.emulator)
+	return r
+}
+
+// Stop closes the server down
+func (r *Redfish) Stop() {
+	r.server.Close()
+}
+
+// Address returns a BMC address pointing to the system, with the given
+// BMC type such as "redfish" or "redfish-virtualmedia".
+func (r *Redfish) Address(bmcType string) string {
+	return strings.Replace(r.server.URL, "http://", bmcType+"+http://", 1) + r.SystemPath()
+}
+
+// SystemPath returns the path of the system
+func (r *Redfish) SystemPath() string {
+	return emulator.SystemPath(systemID)
+}
+
+func (r *Redfish) system() emulator.System {
+	s, ok := r.emulator.System(systemID)
+	if !ok {
+		r.t.Fatalf("no system %s in the emulator", systemID)
+	}
+	return s
+}
+
+// SetPowerState changes the power state of the system, as if it were
+// changed out of band.
+func (r *Redfish) SetPowerState(state string) *Redfish {
+	if err := r.emulator.UpdateSystem(systemID, func(s *emulator.System) {
+		s.PowerState = state
+	}); err != nil {
+		r.t.Fatal(err)
+	}
+	return r
+}
+
+// PowerState returns the power state of the system
+func (r *Redfish) PowerState() string {
+	return r.system().PowerState
+}
+
+// BootOverride returns the boot source override target and mode
+func (r *Redfish) BootOverride() (target, enabled string) {
+	s := r.system()
+	return s.BootSourceOverrideTarget, s.BootSourceOverrideEnabled
+}
+
+// Media returns the image inserted in the virtual CD drive, or an empty
+// string.
+func (r *Redfish) Media() string {
+	return r.system().VirtualCD.Image
+}
+
+// Resets returns the reset types requested so far
+func (r *Redfish) Resets() []string {
+	return r.system().Resets
+}
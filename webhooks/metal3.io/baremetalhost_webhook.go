@@ -0,0 +1,207 @@
+/*
+
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	"github.com/go-logr/logr"
+	admissionv1 "k8s.io/api/admission/v1"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+	"github.com/metal3-io/baremetal-operator/pkg/bmc"
+	"github.com/metal3-io/baremetal-operator/pkg/provisioner/ironic"
+)
+
+const (
+	validateBareMetalHostPath = "/validate-metal3-io-v1alpha1-baremetalhost"
+	mutateBareMetalHostPath   = "/mutate-metal3-io-v1alpha1-baremetalhost"
+)
+
+// +kubebuilder:webhook:verbs=create;update,path=/validate-metal3-io-v1alpha1-baremetalhost,mutating=false,failurePolicy=fail,sideEffects=None,admissionReviewVersions=v1;v1beta1,groups=metal3.io,resources=baremetalhosts,versions=v1alpha1,name=baremetalhost.metal3.io
+// +kubebuilder:webhook:verbs=create;update,path=/mutate-metal3-io-v1alpha1-baremetalhost,mutating=true,failurePolicy=fail,sideEffects=None,admissionReviewVersions=v1;v1beta1,groups=metal3.io,resources=baremetalhosts,versions=v1alpha1,name=mbaremetalhost.metal3.io
+
+// BareMetalHostValidator rejects BareMetalHost resources that would
+// only fail later during registration or preparation.
+type BareMetalHostValidator struct {
+	Log     logr.Logger
+	decoder *admission.Decoder
+}
+
+// BareMetalHostDefaulter fills in default values for BareMetalHost
+// fields that are left empty.
+type BareMetalHostDefaulter struct {
+	Log     logr.Logger
+	decoder *admission.Decoder
+}
+
+// SetupWebhookWithManager registers the BareMetalHost webhooks with
+// the webhook server of the manager.
+func SetupWebhookWithManager(mgr ctrl.Manager) {
+	log := ctrl.Log.WithName("webhooks").WithName("BareMetalHost")
+	server := mgr.GetWebhookServer()
+	server.Register(validateBareMetalHostPath,
+		&webhook.Admission{Handler: &BareMetalHostValidator{Log: log}})
+	server.Register(mutateBareMetalHostPath,
+		&webhook.Admission{Handler: &BareMetalHostDefaulter{Log: log}})
+}
+
+// InjectDecoder is called by the webhook server to provide a decoder
+// for the admission requests.
+func (v *BareMetalHostValidator) InjectDecoder(d *admission.Decoder) error {
+	v.decoder = d
+	return nil
+}
+
+// Handle validates a BareMetalHost on create and update. Hosts that
+// are being deleted are not validated, so that the finalizer can
+// always be removed.
+func (v *BareMetalHostValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	host := &metal3v1alpha1.BareMetalHost{}
+	if err := v.decoder.Decode(req, host); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if !host.DeletionTimestamp.IsZero() {
+		return admission.Allowed("")
+	}
+
+	var errs field.ErrorList
+	if req.Operation == admissionv1.Update {
+		oldHost := &metal3v1alpha1.BareMetalHost{}
+		if err := v.decoder.DecodeRaw(req.OldObject, oldHost); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+		errs = append(validateHost(oldHost, host), validateHostUpdate(oldHost, host)...)
+	} else {
+		errs = validateHost(nil, host)
+	}
+
+	if len(errs) != 0 {
+		v.Log.Info("rejecting host", "host", req.Namespace+"/"+req.Name,
+			"errors", errs.ToAggregate().Error())
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+	return admission.Allowed("")
+}
+
+// InjectDecoder is called by the webhook server to provide a decoder
+// for the admission requests.
+func (d *BareMetalHostDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
+
+// Handle sets default values on a BareMetalHost.
+func (d *BareMetalHostDefaulter) Handle(ctx context.Context, req admission.Request) admission.Response {
+	host := &metal3v1alpha1.BareMetalHost{}
+	if err := d.decoder.Decode(req, host); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	defaultHost(host)
+
+	marshaled, err := json.Marshal(host)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// defaultHost sets the default values of any empty fields in the
+// spec.
+func defaultHost(host *metal3v1alpha1.BareMetalHost) {
+	if host.Spec.AutomatedCleaningMode == "" {
+		host.Spec.AutomatedCleaningMode = metal3v1alpha1.CleaningModeMetadata
+	}
+}
+
+// validateHost checks the spec of a host for settings that the
+// provisioner would refuse. On update, oldHost is the current version
+// of the host and only the fields that changed are checked, so that
+// hosts created before a check was added can still be updated.
+func validateHost(oldHost, host *metal3v1alpha1.BareMetalHost) field.ErrorList {
+	var errs field.ErrorList
+	specPath := field.NewPath("spec")
+
+	bmcChanged, bootModeChanged, firmwareUpdatesChanged, raidChanged := true, true, true, true
+	if oldHost != nil {
+		bmcChanged = oldHost.Spec.BMC.Address != host.Spec.BMC.Address ||
+			oldHost.Spec.BMC.DisableCertificateVerification != host.Spec.BMC.DisableCertificateVerification
+		bootModeChanged = oldHost.Spec.BootMode != host.Spec.BootMode
+		firmwareUpdatesChanged = !reflect.DeepEqual(oldHost.Spec.FirmwareUpdates, host.Spec.FirmwareUpdates)
+		raidChanged = !reflect.DeepEqual(oldHost.Spec.RAID, host.Spec.RAID)
+	}
+
+	if host.Spec.BMC.Address != "" {
+		accessDetails, err := bmc.NewAccessDetails(host.Spec.BMC.Address,
+			host.Spec.BMC.DisableCertificateVerification)
+		if err != nil {
+			if bmcChanged {
+				errs = append(errs, field.Invalid(specPath.Child("bmc", "address"),
+					host.Spec.BMC.Address, err.Error()))
+			}
+		} else {
+			if (bmcChanged || bootModeChanged) &&
+				host.Spec.BootMode == metal3v1alpha1.UEFISecureBoot && !accessDetails.SupportsSecureBoot() {
+				errs = append(errs, field.Invalid(specPath.Child("bootMode"),
+					host.Spec.BootMode,
+					fmt.Sprintf("BMC driver %s does not support secure boot", accessDetails.Type())))
+			}
+			if bmcChanged || firmwareUpdatesChanged {
+				if _, err := accessDetails.BuildFirmwareUpdateArgs(host.Spec.FirmwareUpdates); err != nil {
+					errs = append(errs, field.Invalid(specPath.Child("firmwareUpdates"),
+						host.Spec.FirmwareUpdates, err.Error()))
+				}
+			}
+		}
+	}
+
+	if raidChanged && host.Spec.RAID != nil {
+		if _, err := ironic.BuildTargetRAIDCfg(host.Spec.RAID); err != nil {
+			errs = append(errs, field.Invalid(specPath.Child("raid"),
+				host.Spec.RAID, err.Error()))
+		}
+	}
+
+	return errs
+}
+
+// validateHostUpdate checks that fields which cannot change once the
+// host has been registered are left alone.
+func validateHostUpdate(oldHost, newHost *metal3v1alpha1.BareMetalHost) field.ErrorList {
+	var errs field.ErrorList
+
+	if oldHost.Status.Provisioning.ID == "" {
+		return errs
+	}
+
+	if oldHost.Spec.BootMACAddress != "" && newHost.Spec.BootMACAddress != oldHost.Spec.BootMACAddress {
+		errs = append(errs, field.Forbidden(field.NewPath("spec", "bootMACAddress"),
+			"bootMACAddress can not be changed once the host is registered"))
+	}
+
+	return errs
+}
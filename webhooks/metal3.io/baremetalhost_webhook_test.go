@@ -0,0 +1,266 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/assert"
+	admissionv1 "k8s.io/api/admission/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	metal3v1alpha1 "github.com/metal3-io/baremetal-operator/apis/metal3.io/v1alpha1"
+)
+
+func newHost(spec metal3v1alpha1.BareMetalHostSpec) *metal3v1alpha1.BareMetalHost {
+	return &metal3v1alpha1.BareMetalHost{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "myhost",
+			Namespace: "myns",
+		},
+		Spec: spec,
+	}
+}
+
+func TestValidateHost(t *testing.T) {
+	testCases := []struct {
+		Scenario string
+		Spec     metal3v1alpha1.BareMetalHostSpec
+		Errors   []string
+	}{
+		{
+			Scenario: "empty",
+			Spec:     metal3v1alpha1.BareMetalHostSpec{},
+		},
+		{
+			Scenario: "valid address",
+			Spec: metal3v1alpha1.BareMetalHostSpec{
+				BMC: metal3v1alpha1.BMCDetails{Address: "ipmi://192.168.122.1:6233"},
+			},
+		},
+		{
+			Scenario: "unknown BMC type",
+			Spec: metal3v1alpha1.BareMetalHostSpec{
+				BMC: metal3v1alpha1.BMCDetails{Address: "foo://192.168.122.1"},
+			},
+			Errors: []string{"spec.bmc.address"},
+		},
+		{
+			Scenario: "secure boot supported",
+			Spec: metal3v1alpha1.BareMetalHostSpec{
+				BMC:      metal3v1alpha1.BMCDetails{Address: "redfish://192.168.122.1/redfish/v1/Systems/1"},
+				BootMode: metal3v1alpha1.UEFISecureBoot,
+			},
+		},
+		{
+			Scenario: "secure boot unsupported",
+			Spec: metal3v1alpha1.BareMetalHostSpec{
+				BMC:      metal3v1alpha1.BMCDetails{Address: "ipmi://192.168.122.1:6233"},
+				BootMode: metal3v1alpha1.UEFISecureBoot,
+			},
+			Errors: []string{"spec.bootMode"},
+		},
+		{
+			Scenario: "firmware updates supported",
+			Spec: metal3v1alpha1.BareMetalHostSpec{
+				BMC: metal3v1alpha1.BMCDetails{Address: "redfish://192.168.122.1/redfish/v1/Systems/1"},
+				FirmwareUpdates: []metal3v1alpha1.FirmwareUpdate{
+					{Component: metal3v1alpha1.FirmwareComponentBIOS, URL: "http://example.com/bios.bin", Checksum: "abcdef"},
+				},
+			},
+		},
+		{
+			Scenario: "firmware updates unsupported",
+			Spec: metal3v1alpha1.BareMetalHostSpec{
+				BMC: metal3v1alpha1.BMCDetails{Address: "ipmi://192.168.122.1:6233"},
+				FirmwareUpdates: []metal3v1alpha1.FirmwareUpdate{
+					{Component: metal3v1alpha1.FirmwareComponentBIOS, URL: "http://example.com/bios.bin", Checksum: "abcdef"},
+				},
+			},
+			Errors: []string{"spec.firmwareUpdates"},
+		},
+		{
+			Scenario: "valid RAID",
+			Spec: metal3v1alpha1.BareMetalHostSpec{
+				RAID: &metal3v1alpha1.RAIDConfig{
+					SoftwareRAIDVolumes: []metal3v1alpha1.SoftwareRAIDVolume{
+						{Level: "1"},
+					},
+				},
+			},
+		},
+		{
+			Scenario: "duplicate hardware RAID volume names",
+			Spec: metal3v1alpha1.BareMetalHostSpec{
+				RAID: &metal3v1alpha1.RAIDConfig{
+					HardwareRAIDVolumes: []metal3v1alpha1.HardwareRAIDVolume{
+						{Name: "root", Level: "1"},
+						{Name: "root", Level: "0"},
+					},
+				},
+			},
+			Errors: []string{"spec.raid"},
+		},
+		{
+			Scenario: "software RAID without RAID1",
+			Spec: metal3v1alpha1.BareMetalHostSpec{
+				RAID: &metal3v1alpha1.RAIDConfig{
+					SoftwareRAIDVolumes: []metal3v1alpha1.SoftwareRAIDVolume{
+						{Level: "0"},
+					},
+				},
+			},
+			Errors: []string{"spec.raid"},
+		},
+		{
+			Scenario: "multiple errors",
+			Spec: metal3v1alpha1.BareMetalHostSpec{
+				BMC: metal3v1alpha1.BMCDetails{Address: "foo://192.168.122.1"},
+				RAID: &metal3v1alpha1.RAIDConfig{
+					SoftwareRAIDVolumes: []metal3v1alpha1.SoftwareRAIDVolume{
+						{Level: "0"},
+					},
+				},
+			},
+			Errors: []string{"spec.bmc.address", "spec.raid"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			errs := validateHost(nil, newHost(tc.Spec))
+			fields := []string{}
+			for _, err := range errs {
+				fields = append(fields, err.Field)
+			}
+			if tc.Errors == nil {
+				tc.Errors = []string{}
+			}
+			assert.Equal(t, tc.Errors, fields)
+		})
+	}
+}
+
+func TestValidateHostUpdate(t *testing.T) {
+	testCases := []struct {
+		Scenario    string
+		ProvisionID string
+		OldMAC      string
+		NewMAC      string
+		ExpectError bool
+	}{
+		{
+			Scenario: "unregistered",
+			OldMAC:   "00:11:22:33:44:55",
+			NewMAC:   "00:11:22:33:44:66",
+		},
+		{
+			Scenario:    "registered unchanged",
+			ProvisionID: "node-uuid",
+			OldMAC:      "00:11:22:33:44:55",
+			NewMAC:      "00:11:22:33:44:55",
+		},
+		{
+			Scenario:    "registered set",
+			ProvisionID: "node-uuid",
+			NewMAC:      "00:11:22:33:44:55",
+		},
+		{
+			Scenario:    "registered changed",
+			ProvisionID: "node-uuid",
+			OldMAC:      "00:11:22:33:44:55",
+			NewMAC:      "00:11:22:33:44:66",
+			ExpectError: true,
+		},
+		{
+			Scenario:    "registered removed",
+			ProvisionID: "node-uuid",
+			OldMAC:      "00:11:22:33:44:55",
+			ExpectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.Scenario, func(t *testing.T) {
+			oldHost := newHost(metal3v1alpha1.BareMetalHostSpec{BootMACAddress: tc.OldMAC})
+			oldHost.Status.Provisioning.ID = tc.ProvisionID
+			newHost := newHost(metal3v1alpha1.BareMetalHostSpec{BootMACAddress: tc.NewMAC})
+			newHost.Status.Provisioning.ID = tc.ProvisionID
+
+			errs := validateHostUpdate(oldHost, newHost)
+			assert.Equal(t, tc.ExpectError, len(errs) != 0, errs)
+		})
+	}
+}
+
+func TestValidateHostChangedFields(t *testing.T) {
+	invalidRAID := &metal3v1alpha1.RAIDConfig{
+		SoftwareRAIDVolumes: []metal3v1alpha1.SoftwareRAIDVolume{{Level: "0"}},
+	}
+	oldHost := newHost(metal3v1alpha1.BareMetalHostSpec{
+		BMC:      metal3v1alpha1.BMCDetails{Address: "ipmi://192.168.122.1:6233"},
+		BootMode: metal3v1alpha1.UEFISecureBoot,
+		RAID:     invalidRAID,
+	})
+
+	host := oldHost.DeepCopy()
+	host.Spec.Online = true
+	assert.Empty(t, validateHost(oldHost, host))
+
+	host = oldHost.DeepCopy()
+	host.Spec.BMC.Address = "ipmi://192.168.122.2:6233"
+	errs := validateHost(oldHost, host)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.bootMode", errs[0].Field)
+	}
+
+	host = oldHost.DeepCopy()
+	host.Spec.RAID = invalidRAID.DeepCopy()
+	host.Spec.RAID.SoftwareRAIDVolumes = append(host.Spec.RAID.SoftwareRAIDVolumes, metal3v1alpha1.SoftwareRAIDVolume{Level: "1"})
+	errs = validateHost(oldHost, host)
+	if assert.Len(t, errs, 1) {
+		assert.Equal(t, "spec.raid", errs[0].Field)
+	}
+}
+
+func TestHandleDeletedHost(t *testing.T) {
+	scheme := runtime.NewScheme()
+	assert.NoError(t, metal3v1alpha1.AddToScheme(scheme))
+	decoder, err := admission.NewDecoder(scheme)
+	assert.NoError(t, err)
+	validator := &BareMetalHostValidator{Log: logr.Discard(), decoder: decoder}
+
+	host := newHost(metal3v1alpha1.BareMetalHostSpec{
+		BMC: metal3v1alpha1.BMCDetails{Address: "foo://192.168.122.1"},
+	})
+	host.TypeMeta = metav1.TypeMeta{APIVersion: metal3v1alpha1.GroupVersion.String(), Kind: "BareMetalHost"}
+	request := func() admission.Request {
+		raw, _ := json.Marshal(host)
+		return admission.Request{AdmissionRequest: admissionv1.AdmissionRequest{
+			Operation: admissionv1.Update,
+			Object:    runtime.RawExtension{Raw: raw},
+			OldObject: runtime.RawExtension{Raw: []byte(`{"apiVersion": "metal3.io/v1alpha1", "kind": "BareMetalHost"}`)},
+		}}
+	}
+
+	assert.False(t, validator.Handle(context.Background(), request()).Allowed)
+
+	now := metav1.Now()
+	host.DeletionTimestamp = &now
+	assert.True(t, validator.Handle(context.Background(), request()).Allowed)
+}
+
+func TestDefaultHost(t *testing.T) {
+	host := newHost(metal3v1alpha1.BareMetalHostSpec{})
+	defaultHost(host)
+	assert.Equal(t, metal3v1alpha1.CleaningModeMetadata, host.Spec.AutomatedCleaningMode)
+
+	host = newHost(metal3v1alpha1.BareMetalHostSpec{
+		AutomatedCleaningMode: metal3v1alpha1.CleaningModeDisabled,
+	})
+	defaultHost(host)
+	assert.Equal(t, metal3v1alpha1.CleaningModeDisabled, host.Spec.AutomatedCleaningMode)
+}